@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+func main() {
+	var (
+		field     = flag.String("field", "", "Derived field to backfill (see -list)")
+		batchSize = flag.Int("batch-size", 500, "Number of rows to update per batch")
+		afterKey  = flag.String("after", "", "Resume after this key (printed as last_key in progress logs)")
+		maxBatch  = flag.Int("max-batches", 0, "Stop after this many batches (0 = until done)")
+		list      = flag.Bool("list", false, "List the supported fields and exit")
+	)
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Parse()
+
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if *list {
+		printFields()
+		return
+	}
+
+	if *field == "" {
+		fmt.Fprintln(os.Stderr, "Usage: backfill -field NAME [-batch-size N] [-after KEY] [-max-batches N]")
+		printFields()
+		os.Exit(1)
+	}
+
+	def, ok := infraBQ.LookupBackfillField(*field)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown field: %s\n\n", *field)
+		printFields()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	log.Info().
+		Str("field", def.Name).
+		Int("batch_size", *batchSize).
+		Str("after", *afterKey).
+		Msg("Starting backfill")
+
+	start := time.Now()
+	lastKey := *afterKey
+	total := 0
+	batches := 0
+
+	for {
+		n, key, err := infraBQ.BackfillBatch(ctx, def, lastKey, *batchSize)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("field", def.Name).
+				Str("last_key", lastKey).
+				Msg("Backfill batch failed - rerun with -after to resume")
+		}
+		if n == 0 {
+			break
+		}
+
+		lastKey = key
+		total += n
+		batches++
+
+		log.Info().
+			Str("field", def.Name).
+			Int("batch", batches).
+			Int("rows", n).
+			Int("total_rows", total).
+			Str("last_key", lastKey).
+			Msg("Backfill batch completed")
+
+		if *maxBatch > 0 && batches >= *maxBatch {
+			log.Info().Str("last_key", lastKey).Msg("Reached -max-batches limit - rerun with -after to continue")
+			break
+		}
+	}
+
+	log.Info().
+		Str("field", def.Name).
+		Int("total_rows", total).
+		Int("batches", batches).
+		Dur("duration", time.Since(start)).
+		Msg("Backfill finished")
+}
+
+func printFields() {
+	fmt.Println("Supported fields:")
+	for _, f := range infraBQ.ListBackfillFields() {
+		fmt.Printf("  %-40s %s\n", f.Name, f.Description)
+	}
+}
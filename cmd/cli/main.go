@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
 	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/logger"
@@ -16,26 +20,48 @@ import (
 )
 
 func main() {
-	log := logger.New()
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Usage = printUsage
+	flag.Parse()
 
-	if len(os.Args) < 2 {
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if err := apptime.Configure(""); err != nil {
+		log.Fatal().Err(err).Msg("Invalid APP_TIMEZONE")
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "ingest":
-		runIngest(log)
+		runIngest(log, args[1:])
 	case "upload":
-		runUpload(log)
+		runUpload(log, args[1:])
 	case "reparse":
-		runReparse(log)
+		runReparse(log, args[1:])
+	case "reprocess":
+		runReprocess(log, args[1:])
+	case "revalidate-categories":
+		runRevalidateCategories(log, args[1:])
+	case "retag":
+		runRetag(log, args[1:])
 	case "inspect":
-		runInspect(log)
+		runInspect(log, args[1:])
+	case "model-output":
+		runModelOutput(log, args[1:])
+	case "merge-default-accounts":
+		runMergeDefaultAccounts(log, args[1:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
 		printUsage()
 		os.Exit(1)
 	}
@@ -44,44 +70,122 @@ func main() {
 func printUsage() {
 	fmt.Println("Finance Tracker CLI")
 	fmt.Println("\nUsage:")
-	fmt.Println("  cli <command> [options]")
+	fmt.Println("  cli [-log-format json|console] <command> [options]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  ingest    Parse and ingest a bank statement from GCS")
 	fmt.Println("  upload    Upload a PDF file to GCS")
 	fmt.Println("  reparse   Re-parse an existing document by ID")
+	fmt.Println("  reprocess Re-run post-processing on a stored model output (no AI call)")
+	fmt.Println("  revalidate-categories  Re-match a document's transactions to the current taxonomy (no AI call)")
+	fmt.Println("  retag     Apply the current tag rules to a document's transactions (no AI call)")
 	fmt.Println("  inspect   Inspect a document and its transactions")
+	fmt.Println("  model-output  Print the raw model output stored for a document or parsing run")
+	fmt.Println("  merge-default-accounts  Merge DOC-* fallback accounts into extracted accounts")
 	fmt.Println("  help      Show this help message")
 	fmt.Println("\nRun 'cli <command> -h' for more information on a command.")
+	fmt.Println("\nPipeline commands exit with 3 on parse errors, 4 on storage errors,")
+	fmt.Println("5 on validation errors and 6 on duplicate documents.")
+}
+
+// defaultPipelineTimeout bounds a single ingest, reparse or reprocess run unless -timeout is given.
+const defaultPipelineTimeout = 5 * time.Minute
+
+// pipelineContext returns a context for a pipeline command, cancelled after timeout.
+func pipelineContext(log zerolog.Logger, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		log.Fatal().Dur("timeout", timeout).Msg("Error: --timeout must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return logger.WithContext(ctx, log), cancel
+}
+
+// pipelineExitCodes maps pipeline error kinds to process exit codes.
+var pipelineExitCodes = map[string]int{
+	"parse":      3,
+	"storage":    4,
+	"validation": 5,
+	"duplicate":  6,
 }
 
-func runIngest(log zerolog.Logger) {
+// exitPipelineError logs a pipeline failure with its kind and failed step,
+// then exits with the code for that kind (1 if unclassified).
+func exitPipelineError(log zerolog.Logger, msg string, err error) {
+	kind := pipeline.ErrorKind(err)
+	event := log.Error().Err(err).Str("error_kind", kind)
+
+	var stepErr *pipeline.StepError
+	if errors.As(err, &stepErr) {
+		event = event.Str("step", stepErr.Step)
+	}
+	event.Msg(msg)
+
+	code, ok := pipelineExitCodes[kind]
+	if !ok {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func runIngest(log zerolog.Logger, args []string) {
 	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
 	gcsURI := fs.String("gcs-uri", "", "GCS URI of the statement PDF")
-	fs.Parse(os.Args[2:])
+	file := fs.String("file", "", "Path to a local statement PDF, ingested without GCS")
+	timeout := fs.Duration("timeout", defaultPipelineTimeout, "Maximum duration of the run, e.g. 10m")
+	printPrompt := fs.Bool("print-prompt", false, "Print the statement prompt instead of ingesting (no AI call)")
+	institution := fs.String("institution", "", "Institution ID of the statement, e.g. HSBC; detected from the statement if empty. With -print-prompt, the institution whose prompt is printed")
+	sourceSystem := fs.String("source-system", "", "source_system of the new document (default: the institution, or "+pipeline.DefaultSourceSystem+")")
+	fs.Parse(args)
+
+	if *printPrompt {
+		ctx, cancel := pipelineContext(log, *timeout)
+		defer cancel()
+		printStatementPrompt(ctx, log, *institution)
+		return
+	}
 
-	if *gcsURI == "" {
-		log.Fatal().Msg("Error: --gcs-uri is required")
+	if (*gcsURI == "") == (*file == "") {
+		log.Fatal().Msg("Error: exactly one of --gcs-uri and --file is required")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := pipelineContext(log, *timeout)
 	defer cancel()
-	ctx = logger.WithContext(ctx, log)
-
-	log.Info().Str("gcs_uri", *gcsURI).Msg("Starting ingestion")
 
-	if err := pipeline.IngestStatementFromGCS(ctx, *gcsURI); err != nil {
-		log.Fatal().Err(err).Msg("Ingestion failed")
+	opts := pipeline.IngestOptions{
+		SourceSystem:  *sourceSystem,
+		InstitutionID: *institution,
+		PDFPassword:   os.Getenv(pipeline.PDFPasswordEnv),
+	}
+	var err error
+	if *file != "" {
+		log.Info().Str("file", *file).Msg("Starting ingestion")
+		err = pipeline.IngestStatementFromFile(ctx, *file, opts)
+	} else {
+		log.Info().Str("gcs_uri", *gcsURI).Msg("Starting ingestion")
+		err = pipeline.IngestStatementFromGCSWithOptions(ctx, *gcsURI, opts)
+	}
+	if err != nil {
+		exitPipelineError(log, "Ingestion failed", err)
 	}
 
 	fmt.Println("Ingestion completed successfully.")
 }
 
-func runUpload(log zerolog.Logger) {
+// printStatementPrompt prints the statement prompt for institutionID as it would be sent
+// to the model.
+func printStatementPrompt(ctx context.Context, log zerolog.Logger, institutionID string) {
+	prompt, err := pipeline.BuildStatementPrompt(ctx, pipeline.DefaultUserID, institutionID)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to build statement prompt")
+	}
+	fmt.Print(prompt)
+}
+
+func runUpload(log zerolog.Logger, args []string) {
 	fs := flag.NewFlagSet("upload", flag.ExitOnError)
 	bucketName := fs.String("bucket", "", "GCS bucket name")
 	objectName := fs.String("object", "", "GCS object name (defaults to filename)")
 	filePath := fs.String("file", "", "Path to local PDF file")
-	fs.Parse(os.Args[2:])
+	fs.Parse(args)
 
 	if *bucketName == "" || *filePath == "" {
 		log.Fatal().Msg("Usage: cli upload -bucket NAME -file PATH")
@@ -100,25 +204,30 @@ func runUpload(log zerolog.Logger) {
 		Str("file", *filePath).
 		Msg("Uploading file to GCS")
 
-	if err := gcsuploader.UploadFile(ctx, *bucketName, *objectName, *filePath); err != nil {
+	storage, err := gcsuploader.NewStorageServiceFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid storage configuration")
+	}
+	if err := storage.UploadFile(ctx, *bucketName, *objectName, *filePath); err != nil {
 		log.Fatal().Err(err).Msg("Upload failed")
 	}
 
 	fmt.Printf("Uploaded %s to gs://%s/%s\n", *filePath, *bucketName, *objectName)
 }
 
-func runReparse(log zerolog.Logger) {
+func runReparse(log zerolog.Logger, args []string) {
 	fs := flag.NewFlagSet("reparse", flag.ExitOnError)
 	documentID := fs.String("document-id", "", "Document ID to re-parse")
-	fs.Parse(os.Args[2:])
+	timeout := fs.Duration("timeout", defaultPipelineTimeout, "Maximum duration of the run, e.g. 10m")
+	printPrompt := fs.Bool("print-prompt", false, "Print the statement prompt for the document instead of re-parsing (no AI call)")
+	fs.Parse(args)
 
 	if *documentID == "" {
 		log.Fatal().Msg("Error: --document-id is required")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := pipelineContext(log, *timeout)
 	defer cancel()
-	ctx = logger.WithContext(ctx, log)
 
 	log.Info().Str("document_id", *documentID).Msg("Starting re-parse")
 
@@ -140,23 +249,104 @@ func runReparse(log zerolog.Logger) {
 		log.Fatal().Msg("Document not found")
 	}
 
+	if *printPrompt {
+		printStatementPrompt(ctx, log, doc.InstitutionID)
+		return
+	}
+
 	if doc.GCSURI == "" {
 		log.Fatal().Msg("Document has no GCS URI")
 	}
 
 	log.Info().Str("gcs_uri", doc.GCSURI).Msg("Re-parsing document")
 
-	if err := pipeline.IngestStatementFromGCS(ctx, doc.GCSURI); err != nil {
-		log.Fatal().Err(err).Msg("Re-parse failed")
+	// Documents ingested with -file are read from the same local path
+	if path, ok := gcsuploader.LocalPathFromURI(doc.GCSURI); ok {
+		err = pipeline.IngestStatementFromFile(ctx, path, pipeline.IngestOptions{})
+	} else {
+		err = pipeline.IngestStatementFromGCS(ctx, doc.GCSURI)
+	}
+	if err != nil {
+		exitPipelineError(log, "Re-parse failed", err)
 	}
 
 	fmt.Println("Re-parse completed successfully.")
 }
 
-func runInspect(log zerolog.Logger) {
+func runReprocess(log zerolog.Logger, args []string) {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	parsingRunID := fs.String("parsing-run-id", "", "Parsing run whose stored model output should be reprocessed")
+	timeout := fs.Duration("timeout", defaultPipelineTimeout, "Maximum duration of the run, e.g. 10m")
+	fs.Parse(args)
+
+	if *parsingRunID == "" {
+		log.Fatal().Msg("Error: --parsing-run-id is required")
+	}
+
+	ctx, cancel := pipelineContext(log, *timeout)
+	defer cancel()
+
+	log.Info().Str("parsing_run_id", *parsingRunID).Msg("Starting reprocess from stored model output")
+
+	newRunID, err := pipeline.ReprocessFromModelOutput(ctx, *parsingRunID)
+	if err != nil {
+		exitPipelineError(log, "Reprocess failed", err)
+	}
+
+	fmt.Printf("Reprocess completed successfully. New parsing run: %s\n", newRunID)
+}
+
+func runRevalidateCategories(log zerolog.Logger, args []string) {
+	fs := flag.NewFlagSet("revalidate-categories", flag.ExitOnError)
+	documentID := fs.String("document-id", "", "Document whose transactions should be revalidated")
+	timeout := fs.Duration("timeout", defaultPipelineTimeout, "Maximum duration of the run, e.g. 10m")
+	fs.Parse(args)
+
+	if *documentID == "" {
+		log.Fatal().Msg("Error: --document-id is required")
+	}
+
+	ctx, cancel := pipelineContext(log, *timeout)
+	defer cancel()
+
+	result, err := pipeline.RevalidateCategories(ctx, *documentID)
+	if err != nil {
+		exitPipelineError(log, "Category revalidation failed", err)
+	}
+
+	for _, a := range result.Invalid {
+		fmt.Printf("INVALID %q / %q (%d transactions)\n", a.CategoryName, a.SubcategoryName, a.TransactionCount)
+	}
+	fmt.Printf("Revalidation completed. Updated %d transactions, %d category assignments no longer valid.\n",
+		result.Updated, len(result.Invalid))
+}
+
+func runRetag(log zerolog.Logger, args []string) {
+	fs := flag.NewFlagSet("retag", flag.ExitOnError)
+	documentID := fs.String("document-id", "", "Document whose transactions should be tagged")
+	timeout := fs.Duration("timeout", defaultPipelineTimeout, "Maximum duration of the run, e.g. 10m")
+	fs.Parse(args)
+
+	if *documentID == "" {
+		log.Fatal().Msg("Error: --document-id is required")
+	}
+
+	ctx, cancel := pipelineContext(log, *timeout)
+	defer cancel()
+
+	result, err := pipeline.ApplyTagRules(ctx, *documentID)
+	if err != nil {
+		exitPipelineError(log, "Retagging failed", err)
+	}
+
+	fmt.Printf("Retagging completed. %d transactions matched a rule, %d got new tags.\n",
+		result.Matched, result.Updated)
+}
+
+func runInspect(log zerolog.Logger, args []string) {
 	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
 	documentID := fs.String("document-id", "", "Document ID to inspect")
-	fs.Parse(os.Args[2:])
+	fs.Parse(args)
 
 	if *documentID == "" {
 		log.Fatal().Msg("Error: --document-id is required")
@@ -228,3 +418,105 @@ func runInspect(log zerolog.Logger) {
 	}
 	fmt.Println()
 }
+
+func runModelOutput(log zerolog.Logger, args []string) {
+	fs := flag.NewFlagSet("model-output", flag.ExitOnError)
+	documentID := fs.String("document-id", "", "Show the latest model output for this document")
+	parsingRunID := fs.String("parsing-run-id", "", "Show all model outputs for this parsing run")
+	fs.Parse(args)
+
+	if (*documentID == "") == (*parsingRunID == "") {
+		log.Fatal().Msg("Error: exactly one of --document-id or --parsing-run-id is required")
+	}
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	var outputs []*infraBQ.ModelOutputRow
+	if *documentID != "" {
+		output, err := infraBQ.GetLatestModelOutput(ctx, *documentID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load model output")
+		}
+		if output != nil {
+			outputs = append(outputs, output)
+		}
+	} else {
+		var err error
+		outputs, err = infraBQ.ListModelOutputsByParsingRun(ctx, *parsingRunID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load model outputs")
+		}
+	}
+
+	if len(outputs) == 0 {
+		log.Fatal().Msg("No model output found")
+	}
+
+	for _, o := range outputs {
+		fmt.Println("\n=== Model Output ===")
+		fmt.Printf("Output ID:   %s\n", o.OutputID)
+		fmt.Printf("Document ID: %s\n", o.DocumentID)
+		fmt.Printf("Parsing Run: %s\n", o.ParsingRunID)
+		fmt.Printf("Model:       %s\n", o.ModelName)
+		if o.CreatedTS.Valid {
+			fmt.Printf("Created:     %s\n", o.CreatedTS.Timestamp)
+		}
+
+		raw := o.RawJSON.JSONVal
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, []byte(raw), "", "  "); err == nil {
+			raw = pretty.String()
+		}
+		fmt.Printf("\n%s\n", raw)
+	}
+}
+
+func runMergeDefaultAccounts(log zerolog.Logger, args []string) {
+	fs := flag.NewFlagSet("merge-default-accounts", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Only print the merges that would be made")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	candidates, err := infraBQ.FindDefaultAccountMergeCandidates(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to find merge candidates")
+	}
+
+	// Group by default account; only merge when exactly one real account matches
+	targets := make(map[string][]*infraBQ.AccountMergeCandidate)
+	var order []string
+	for _, c := range candidates {
+		if _, ok := targets[c.SyntheticAccountID]; !ok {
+			order = append(order, c.SyntheticAccountID)
+		}
+		targets[c.SyntheticAccountID] = append(targets[c.SyntheticAccountID], c)
+	}
+
+	merged := 0
+	for _, id := range order {
+		matches := targets[id]
+		if len(matches) > 1 {
+			fmt.Printf("SKIP  %s: ambiguous, matches %d real accounts\n", matches[0].SyntheticAccountNumber, len(matches))
+			continue
+		}
+
+		c := matches[0]
+		fmt.Printf("MERGE %s -> %s (%d shared documents)\n", c.SyntheticAccountNumber, c.TargetAccountNumber, c.SharedDocuments)
+		if *dryRun {
+			continue
+		}
+
+		if err := infraBQ.MergeAccount(ctx, c.SyntheticAccountID, c.TargetAccountID); err != nil {
+			log.Fatal().Err(err).
+				Str("from_account_id", c.SyntheticAccountID).
+				Str("to_account_id", c.TargetAccountID).
+				Msg("Merge failed")
+		}
+		merged++
+	}
+
+	fmt.Printf("\nMerged %d of %d default accounts.\n", merged, len(order))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+func main() {
+	var (
+		outDir    = flag.String("out", "", "Directory to write the NDJSON files to (created if missing)")
+		tables    = flag.String("tables", strings.Join(infraBQ.ExportTableNames, ","), "Comma-separated tables to export")
+		startDate = flag.String("start-date", "", "Only export transactions on or after this date (YYYY-MM-DD)")
+		endDate   = flag.String("end-date", "", "Only export transactions on or before this date (YYYY-MM-DD)")
+		pageSize  = flag.Int("page-size", infraBQ.DefaultReadPageSize, "Rows fetched from BigQuery per request; lower it to reduce memory use")
+	)
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Parse()
+
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if *outDir == "" {
+		fmt.Fprintln(os.Stderr, "Usage: export -out DIR [-tables LIST] [-start-date YYYY-MM-DD] [-end-date YYYY-MM-DD]")
+		fmt.Fprintf(os.Stderr, "Tables: %s\n", strings.Join(infraBQ.ExportTableNames, ", "))
+		os.Exit(1)
+	}
+
+	if *pageSize <= 0 {
+		log.Fatal().Int("page_size", *pageSize).Msg("Error: -page-size must be positive")
+	}
+	opts := infraBQ.ExportOptions{PageSize: *pageSize}
+	if *startDate != "" {
+		if opts.StartDate, err = time.Parse("2006-01-02", *startDate); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -start-date")
+		}
+	}
+	if *endDate != "" {
+		if opts.EndDate, err = time.Parse("2006-01-02", *endDate); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -end-date")
+		}
+	}
+
+	selected, err := parseTables(*tables)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -tables")
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatal().Err(err).Str("out", *outDir).Msg("Failed to create output directory")
+	}
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	start := time.Now()
+	for _, table := range selected {
+		path := filepath.Join(*outDir, table+".ndjson")
+		n, err := exportTable(ctx, table, opts, path)
+		if err != nil {
+			log.Fatal().Err(err).Str("table", table).Msg("Export failed")
+		}
+		log.Info().Str("table", table).Int("rows", n).Str("file", path).Msg("Table exported")
+	}
+
+	log.Info().
+		Str("out", *outDir).
+		Int("tables", len(selected)).
+		Dur("duration", time.Since(start)).
+		Msg("Export finished")
+}
+
+// exportTable writes table to path as NDJSON and returns the number of rows written.
+// Rows go to a temporary file first so an interrupted export never leaves a truncated
+// file that looks complete.
+func exportTable(ctx context.Context, table string, opts infraBQ.ExportOptions, path string) (int, error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("creating %s: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	n := 0
+
+	err = infraBQ.ExportTable(ctx, table, opts, func(row interface{}) error {
+		line, err := infraBQ.MarshalExportRow(row)
+		if err != nil {
+			return fmt.Errorf("encoding %s row: %w", table, err)
+		}
+		n++
+		_, err = w.Write(append(line, '\n'))
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return n, fmt.Errorf("writing %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return n, fmt.Errorf("closing %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return n, fmt.Errorf("renaming %s: %w", tmp, err)
+	}
+	return n, nil
+}
+
+// parseTables splits a comma-separated table list and checks each name is exportable.
+func parseTables(list string) ([]string, error) {
+	known := make(map[string]bool, len(infraBQ.ExportTableNames))
+	for _, t := range infraBQ.ExportTableNames {
+		known[t] = true
+	}
+
+	var tables []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !known[t] {
+			return nil, fmt.Errorf("unknown table %q (supported: %s)", t, strings.Join(infraBQ.ExportTableNames, ", "))
+		}
+		tables = append(tables, t)
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables selected")
+	}
+	return tables, nil
+}
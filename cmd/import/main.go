@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// maxReportedErrors caps the validation problems logged before giving up.
+const maxReportedErrors = 20
+
+func main() {
+	var (
+		inDir        = flag.String("in", "", "Directory containing the NDJSON files written by cmd/export")
+		tables       = flag.String("tables", strings.Join(infraBQ.ExportTableNames, ","), "Comma-separated tables to import")
+		skipExisting = flag.Bool("skip-existing", false, "Skip rows whose ID (or document checksum) already exists instead of failing")
+		batchSize    = flag.Int("batch-size", 250, "Number of rows to insert per batch")
+	)
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Parse()
+
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if *inDir == "" {
+		fmt.Fprintln(os.Stderr, "Usage: import -in DIR [-tables LIST] [-skip-existing] [-batch-size N]")
+		fmt.Fprintf(os.Stderr, "Tables: %s\n", strings.Join(infraBQ.ExportTableNames, ", "))
+		os.Exit(1)
+	}
+	if *batchSize <= 0 {
+		log.Fatal().Int("batch_size", *batchSize).Msg("-batch-size must be positive")
+	}
+
+	selected, err := parseTables(*tables)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -tables")
+	}
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	// References are checked against every table, not just the selected ones
+	existing := map[string]*infraBQ.ImportKeys{}
+	for _, table := range infraBQ.ExportTableNames {
+		keys, err := infraBQ.ExistingImportKeys(ctx, table)
+		if err != nil {
+			log.Fatal().Err(err).Str("table", table).Msg("Failed to load existing keys")
+		}
+		existing[table] = keys
+	}
+
+	// Pass 1: validate everything before writing anything
+	var problems []string
+	plan := newPlanner(existing, *skipExisting)
+	for _, table := range selected {
+		err := readNDJSON(filepath.Join(*inDir, table+".ndjson"), table, func(line int, row interface{}) error {
+			if _, err := plan.decide(table, row); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.ndjson:%d: %v", table, line, err))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal().Err(err).Str("table", table).Msg("Failed to read backup")
+		}
+	}
+	if len(problems) > 0 {
+		for i, p := range problems {
+			if i == maxReportedErrors {
+				log.Error().Int("more", len(problems)-i).Msg("Further problems omitted")
+				break
+			}
+			log.Error().Msg(p)
+		}
+		log.Fatal().Int("problems", len(problems)).Msg("Backup failed validation - nothing was imported")
+	}
+
+	// Pass 2: insert, making the same decisions as pass 1
+	start := time.Now()
+	plan = newPlanner(existing, *skipExisting)
+	for _, table := range selected {
+		inserted, skipped := 0, 0
+		var batch []interface{}
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := infraBQ.ImportRows(ctx, table, batch); err != nil {
+				return err
+			}
+			inserted += len(batch)
+			batch = batch[:0]
+			return nil
+		}
+
+		err := readNDJSON(filepath.Join(*inDir, table+".ndjson"), table, func(line int, row interface{}) error {
+			insert, err := plan.decide(table, row)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if !insert {
+				skipped++
+				return nil
+			}
+			batch = append(batch, row)
+			if len(batch) >= *batchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			log.Fatal().Err(err).
+				Str("table", table).
+				Int("inserted", inserted).
+				Msg("Import failed - rerun with -skip-existing to resume")
+		}
+
+		log.Info().Str("table", table).Int("inserted", inserted).Int("skipped", skipped).Msg("Table imported")
+	}
+
+	log.Info().
+		Str("in", *inDir).
+		Int("tables", len(selected)).
+		Dur("duration", time.Since(start)).
+		Msg("Import finished")
+}
+
+// readNDJSON decodes each line of path into a fresh row of table's type and passes it
+// to fn with its 1-based line number. A missing file is treated as an empty table.
+func readNDJSON(path, table string, fn func(line int, row interface{}) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for line := 1; ; line++ {
+		row, err := infraBQ.NewImportRow(table)
+		if err != nil {
+			return err
+		}
+		if err := dec.Decode(row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: row %d: %w", path, line, err)
+		}
+		if err := fn(line, row); err != nil {
+			return err
+		}
+	}
+}
+
+// parseTables splits a comma-separated table list, checks each name is importable and
+// returns them in dependency order.
+func parseTables(list string) ([]string, error) {
+	requested := map[string]bool{}
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		requested[t] = true
+	}
+
+	var tables []string
+	for _, t := range infraBQ.ExportTableNames {
+		if requested[t] {
+			tables = append(tables, t)
+			delete(requested, t)
+		}
+	}
+	for t := range requested {
+		return nil, fmt.Errorf("unknown table %q (supported: %s)", t, strings.Join(infraBQ.ExportTableNames, ", "))
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables selected")
+	}
+	return tables, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+// planner decides row by row what an import inserts. It checks that rows do not collide
+// with existing data and that every reference points at a row that exists in BigQuery or
+// earlier in the import. Tables must be fed in infraBQ.ExportTableNames order.
+type planner struct {
+	existing     map[string]*infraBQ.ImportKeys
+	skipExisting bool
+
+	imported map[string]map[string]bool
+
+	// Documents skipped because an identical file is already stored under another ID, and
+	// the parsing runs belonging to them. Their children are skipped too.
+	skippedDocs map[string]bool
+	skippedRuns map[string]bool
+}
+
+func newPlanner(existing map[string]*infraBQ.ImportKeys, skipExisting bool) *planner {
+	p := &planner{
+		existing:     existing,
+		skipExisting: skipExisting,
+		imported:     map[string]map[string]bool{},
+		skippedDocs:  map[string]bool{},
+		skippedRuns:  map[string]bool{},
+	}
+	for _, table := range infraBQ.ExportTableNames {
+		p.imported[table] = map[string]bool{}
+		if p.existing[table] == nil {
+			p.existing[table] = &infraBQ.ImportKeys{IDs: map[string]bool{}, Checksums: map[string]bool{}}
+		}
+	}
+	return p
+}
+
+// decide reports whether row should be inserted into table. It returns an error if the
+// row conflicts with existing data (without -skip-existing) or has a dangling reference.
+func (p *planner) decide(table string, row interface{}) (bool, error) {
+	id, err := rowID(row)
+	if err != nil {
+		return false, err
+	}
+	if id == "" {
+		return false, fmt.Errorf("row has no ID")
+	}
+	if p.imported[table][id] {
+		return false, fmt.Errorf("%s appears more than once", id)
+	}
+	p.imported[table][id] = true
+
+	if p.existing[table].IDs[id] {
+		if !p.skipExisting {
+			return false, fmt.Errorf("%s already exists (use -skip-existing to skip it)", id)
+		}
+		return false, nil
+	}
+
+	switch r := row.(type) {
+	case *infraBQ.DocumentRow:
+		if r.ChecksumSHA256 != "" && p.existing[table].Checksums[r.ChecksumSHA256] {
+			if !p.skipExisting {
+				return false, fmt.Errorf("document %s: a document with checksum %s already exists (use -skip-existing to skip it)", id, r.ChecksumSHA256)
+			}
+			p.skippedDocs[id] = true
+			return false, nil
+		}
+		return true, p.requireRef(infraBQ.ExportAccounts, r.AccountID, "document", id)
+
+	case *infraBQ.ParsingRunRow:
+		if p.skippedDocs[r.DocumentID] {
+			p.skippedRuns[id] = true
+			return false, nil
+		}
+		return true, p.requireRef(infraBQ.ExportDocuments, r.DocumentID, "parsing run", id)
+
+	case *infraBQ.TransactionRow:
+		if p.skippedDocs[r.DocumentID] || p.skippedRuns[r.ParsingRunID] {
+			return false, nil
+		}
+		for _, ref := range []struct{ table, id string }{
+			{infraBQ.ExportDocuments, r.DocumentID},
+			{infraBQ.ExportParsingRuns, r.ParsingRunID},
+			{infraBQ.ExportAccounts, r.AccountID},
+		} {
+			if err := p.requireRef(ref.table, ref.id, "transaction", id); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	return true, nil
+}
+
+// requireRef checks that a non-empty reference from a row to refTable resolves.
+func (p *planner) requireRef(refTable, refID, kind, id string) error {
+	if refID == "" || p.existing[refTable].IDs[refID] || p.imported[refTable][refID] {
+		return nil
+	}
+	return fmt.Errorf("%s %s references missing %s %s", kind, id, refTable, refID)
+}
+
+// rowID returns the primary key of an exported row.
+func rowID(row interface{}) (string, error) {
+	switch r := row.(type) {
+	case *infraBQ.CategoryRow:
+		return r.CategoryID, nil
+	case *infraBQ.AccountRow:
+		return r.AccountID, nil
+	case *infraBQ.DocumentRow:
+		return r.DocumentID, nil
+	case *infraBQ.ParsingRunRow:
+		return r.ParsingRunID, nil
+	case *infraBQ.TransactionRow:
+		return r.TransactionID, nil
+	default:
+		return "", fmt.Errorf("unsupported row type %T", row)
+	}
+}
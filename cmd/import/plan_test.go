@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+func keys(ids ...string) *infraBQ.ImportKeys {
+	k := &infraBQ.ImportKeys{IDs: map[string]bool{}, Checksums: map[string]bool{}}
+	for _, id := range ids {
+		k.IDs[id] = true
+	}
+	return k
+}
+
+func TestPlannerReferences(t *testing.T) {
+	p := newPlanner(map[string]*infraBQ.ImportKeys{infraBQ.ExportAccounts: keys("acc-1")}, false)
+
+	steps := []struct {
+		table   string
+		row     interface{}
+		wantErr string
+	}{
+		{infraBQ.ExportDocuments, &infraBQ.DocumentRow{DocumentID: "doc-1", AccountID: "acc-1"}, ""},
+		{infraBQ.ExportParsingRuns, &infraBQ.ParsingRunRow{ParsingRunID: "run-1", DocumentID: "doc-1"}, ""},
+		{infraBQ.ExportParsingRuns, &infraBQ.ParsingRunRow{ParsingRunID: "run-2", DocumentID: "doc-9"}, "missing documents doc-9"},
+		{infraBQ.ExportTransactions, &infraBQ.TransactionRow{TransactionID: "tx-1", DocumentID: "doc-1", ParsingRunID: "run-1", AccountID: "acc-1"}, ""},
+		{infraBQ.ExportTransactions, &infraBQ.TransactionRow{TransactionID: "tx-2", DocumentID: "doc-1", ParsingRunID: "run-1", AccountID: "acc-2"}, "missing accounts acc-2"},
+		{infraBQ.ExportTransactions, &infraBQ.TransactionRow{TransactionID: "tx-1", DocumentID: "doc-1"}, "more than once"},
+		{infraBQ.ExportTransactions, &infraBQ.TransactionRow{DocumentID: "doc-1"}, "no ID"},
+	}
+
+	for _, s := range steps {
+		insert, err := p.decide(s.table, s.row)
+		if s.wantErr == "" {
+			if err != nil || !insert {
+				t.Errorf("decide(%s, %+v) = %v, %v; want insert", s.table, s.row, insert, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), s.wantErr) {
+			t.Errorf("decide(%s, %+v) error = %v, want %q", s.table, s.row, err, s.wantErr)
+		}
+	}
+}
+
+func TestPlannerSkipExisting(t *testing.T) {
+	docs := keys("doc-1")
+	docs.Checksums["abc"] = true
+	existing := map[string]*infraBQ.ImportKeys{infraBQ.ExportDocuments: docs}
+
+	strict := newPlanner(existing, false)
+	if _, err := strict.decide(infraBQ.ExportDocuments, &infraBQ.DocumentRow{DocumentID: "doc-1"}); err == nil {
+		t.Error("existing document ID should fail without -skip-existing")
+	}
+	if _, err := strict.decide(infraBQ.ExportDocuments, &infraBQ.DocumentRow{DocumentID: "doc-2", ChecksumSHA256: "abc"}); err == nil {
+		t.Error("existing checksum should fail without -skip-existing")
+	}
+
+	p := newPlanner(existing, true)
+	for _, s := range []struct {
+		table string
+		row   interface{}
+	}{
+		{infraBQ.ExportDocuments, &infraBQ.DocumentRow{DocumentID: "doc-1"}},
+		{infraBQ.ExportDocuments, &infraBQ.DocumentRow{DocumentID: "doc-2", ChecksumSHA256: "abc"}},
+		{infraBQ.ExportParsingRuns, &infraBQ.ParsingRunRow{ParsingRunID: "run-2", DocumentID: "doc-2"}},
+		{infraBQ.ExportTransactions, &infraBQ.TransactionRow{TransactionID: "tx-2", DocumentID: "doc-2", ParsingRunID: "run-2"}},
+	} {
+		insert, err := p.decide(s.table, s.row)
+		if err != nil || insert {
+			t.Errorf("decide(%s, %+v) = %v, %v; want skipped", s.table, s.row, insert, err)
+		}
+	}
+}
+
+func TestExportedTransactionRoundTrip(t *testing.T) {
+	in := &infraBQ.TransactionRow{
+		TransactionID:   "tx-1",
+		TransactionDate: civil.Date{Year: 2024, Month: 3, Day: 5},
+		Amount:          big.NewRat(-1050, 100),
+		Currency:        "GBP",
+		Tags:            []string{"work"},
+	}
+	data, err := infraBQ.MarshalExportRow(in)
+	if err != nil {
+		t.Fatalf("MarshalExportRow: %v", err)
+	}
+
+	row, err := infraBQ.NewImportRow(infraBQ.ExportTransactions)
+	if err != nil {
+		t.Fatalf("NewImportRow: %v", err)
+	}
+	if err := json.Unmarshal(data, row); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", data, err)
+	}
+
+	out := row.(*infraBQ.TransactionRow)
+	if out.TransactionDate != in.TransactionDate || out.Amount.Cmp(in.Amount) != 0 || out.Tags[0] != "work" {
+		t.Errorf("round trip changed the row: %s", data)
+	}
+}
+
+func TestExportImportRoundTrip_ExactNumerics(t *testing.T) {
+	// Three-decimal currency, and a balance above 2^53 minor units
+	amount, _ := new(big.Rat).SetString("-12.345")
+	balance, _ := new(big.Rat).SetString("9007199254740993.125")
+
+	rows := map[string]interface{}{
+		infraBQ.ExportTransactions: &infraBQ.TransactionRow{
+			TransactionID:   "tx-1",
+			TransactionDate: civil.Date{Year: 2024, Month: 3, Day: 5},
+			Amount:          amount,
+			Currency:        "BHD",
+			BalanceAfter:    balance,
+		},
+		infraBQ.ExportDocuments: &infraBQ.DocumentRow{
+			DocumentID:     "doc-1",
+			OpeningBalance: amount,
+			ClosingBalance: balance,
+		},
+	}
+
+	for table, in := range rows {
+		data, err := infraBQ.MarshalExportRow(in)
+		if err != nil {
+			t.Fatalf("MarshalExportRow(%s): %v", table, err)
+		}
+		out, err := infraBQ.NewImportRow(table)
+		if err != nil {
+			t.Fatalf("NewImportRow: %v", err)
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+
+		var got []*big.Rat
+		switch r := out.(type) {
+		case *infraBQ.TransactionRow:
+			got = []*big.Rat{r.Amount, r.BalanceAfter}
+		case *infraBQ.DocumentRow:
+			got = []*big.Rat{r.OpeningBalance, r.ClosingBalance}
+		}
+		for i, want := range []*big.Rat{amount, balance} {
+			if got[i] == nil || got[i].Cmp(want) != 0 {
+				t.Errorf("%s: value %d = %v after round trip, want %s (%s)", table, i, got[i], want.FloatString(3), data)
+				continue
+			}
+			// The value inserted on import is the exported one
+			if s, err := infraBQ.NumericString(got[i]); err != nil || s != want.FloatString(9) {
+				t.Errorf("%s: NumericString = %q, %v; want %s", table, s, err, want.FloatString(9))
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/dvloznov/finance-tracker/internal/logger"
@@ -11,27 +12,48 @@ import (
 )
 
 func main() {
-	// Initialize structured logger
-	log := logger.New()
-
 	// Parse CLI flags
 	gcsURI := flag.String("gcs-uri", "", "GCS URI of the statement PDF (e.g. gs://bucket/file.pdf)")
+	file := flag.String("file", "", "Path to a local statement PDF, ingested without GCS")
+	institution := flag.String("institution", "", "Institution ID of the statement, e.g. HSBC (detected from the statement if empty)")
+	sourceSystem := flag.String("source-system", "", "source_system of the new document (default: the institution, or "+pipeline.DefaultSourceSystem+")")
+	timeout := flag.Duration("timeout", 5*time.Minute, "Maximum duration of the ingestion, e.g. 10m")
+	logFormat := logger.FormatFlag(flag.CommandLine)
 	flag.Parse()
 
-	if *gcsURI == "" {
-		log.Fatal().Msg("Error: --gcs-uri is required")
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if (*gcsURI == "") == (*file == "") {
+		log.Fatal().Msg("Error: exactly one of --gcs-uri and --file is required")
+	}
+	if *timeout <= 0 {
+		log.Fatal().Dur("timeout", *timeout).Msg("Error: --timeout must be positive")
 	}
 
 	// Create context with timeout so CLI doesn't hang
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
 	// Add logger to context
 	ctx = logger.WithContext(ctx, log)
 
-	log.Info().Str("gcs_uri", *gcsURI).Msg("Starting ingestion")
-
-	if err := pipeline.IngestStatementFromGCS(ctx, *gcsURI); err != nil {
+	opts := pipeline.IngestOptions{
+		SourceSystem:  *sourceSystem,
+		InstitutionID: *institution,
+		PDFPassword:   os.Getenv(pipeline.PDFPasswordEnv),
+	}
+	if *file != "" {
+		log.Info().Str("file", *file).Msg("Starting ingestion")
+		err = pipeline.IngestStatementFromFile(ctx, *file, opts)
+	} else {
+		log.Info().Str("gcs_uri", *gcsURI).Msg("Starting ingestion")
+		err = pipeline.IngestStatementFromGCSWithOptions(ctx, *gcsURI, opts)
+	}
+	if err != nil {
 		log.Fatal().Err(err).Msg("Ingestion failed")
 	}
 
@@ -39,7 +39,7 @@ type AppliedMigration struct {
 var (
 	projectID       = flag.String("project", "", "GCP project ID (required)")
 	datasetID       = flag.String("dataset", "finance", "BigQuery dataset ID")
-	appliedBy       = flag.String("applied-by", "migrate-cli", "Name of the tool applying migrations")
+	appliedBy       = flag.String("applied-by", defaultAppliedBy(os.Getenv, os.Hostname), "Who or what applies the migrations, recorded in schema_migrations.applied_by")
 	migrationsDir   = flag.String("migrations", "migrations/bigquery", "Path to migrations directory")
 )
 
@@ -223,9 +223,39 @@ func readMigrations() ([]Migration, error) {
 		return migrations[i].Version < migrations[j].Version
 	})
 
+	gaps, err := checkMigrationVersions(migrations)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range gaps {
+		log.Printf("Warning: no migration with version %04d", v)
+	}
+
 	return migrations, nil
 }
 
+// checkMigrationVersions checks migrations sorted by version. Two files with the same
+// version, e.g. created on separate branches, are an error, as only one of them would
+// ever be applied. Missing versions are returned so they can be reported.
+func checkMigrationVersions(migrations []Migration) ([]int, error) {
+	var duplicates []string
+	var gaps []int
+	for i := 1; i < len(migrations); i++ {
+		prev, cur := migrations[i-1], migrations[i]
+		if cur.Version == prev.Version {
+			duplicates = append(duplicates, fmt.Sprintf("%s and %s", prev.Filename, cur.Filename))
+			continue
+		}
+		for v := prev.Version + 1; v < cur.Version; v++ {
+			gaps = append(gaps, v)
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("duplicate migration versions: %s", strings.Join(duplicates, "; "))
+	}
+	return gaps, nil
+}
+
 // getAppliedMigrations retrieves the list of already applied migrations
 func getAppliedMigrations(ctx context.Context, client *bigquery.Client) ([]AppliedMigration, error) {
 	sql := fmt.Sprintf(`
@@ -334,3 +364,35 @@ func recordMigration(ctx context.Context, client *bigquery.Client, migration Mig
 
 	return nil
 }
+
+// defaultAppliedBy identifies who or what runs the tool: the CI build when running in a
+// known CI system, otherwise $USER@hostname, falling back to "migrate-cli".
+func defaultAppliedBy(getenv func(string) string, hostname func() (string, error)) string {
+	switch {
+	case getenv("GITHUB_RUN_ID") != "":
+		return fmt.Sprintf("github-actions:%s#%s", getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID"))
+	case getenv("CI_JOB_ID") != "":
+		return fmt.Sprintf("gitlab-ci:%s#%s", getenv("CI_PROJECT_PATH"), getenv("CI_JOB_ID"))
+	case getenv("BUILD_ID") != "":
+		return "ci-build:" + getenv("BUILD_ID")
+	}
+
+	user := getenv("USER")
+	if user == "" {
+		user = getenv("USERNAME")
+	}
+	host, err := hostname()
+	if err != nil {
+		host = ""
+	}
+	switch {
+	case user != "" && host != "":
+		return user + "@" + host
+	case user != "":
+		return user
+	case host != "":
+		return "migrate-cli@" + host
+	default:
+		return "migrate-cli"
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -51,3 +54,72 @@ func TestMigrationChecksumConsistency(t *testing.T) {
 		t.Error("Different content should not be identical")
 	}
 }
+
+func TestDefaultAppliedBy(t *testing.T) {
+	host := func() (string, error) { return "build-box", nil }
+	noHost := func() (string, error) { return "", errors.New("no hostname") }
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		hostname func() (string, error)
+		want     string
+	}{
+		{"user and host", map[string]string{"USER": "denis"}, host, "denis@build-box"},
+		{"user only", map[string]string{"USER": "denis"}, noHost, "denis"},
+		{"host only", nil, host, "migrate-cli@build-box"},
+		{"nothing", nil, noHost, "migrate-cli"},
+		{"github actions", map[string]string{"USER": "runner", "GITHUB_REPOSITORY": "o/r", "GITHUB_RUN_ID": "42"}, host, "github-actions:o/r#42"},
+		{"gitlab", map[string]string{"CI_PROJECT_PATH": "g/p", "CI_JOB_ID": "7"}, host, "gitlab-ci:g/p#7"},
+		{"cloud build", map[string]string{"BUILD_ID": "abc"}, host, "ci-build:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := defaultAppliedBy(getenv, tt.hostname); got != tt.want {
+				t.Errorf("defaultAppliedBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMigrationVersions(t *testing.T) {
+	m := func(version int, filename string) Migration {
+		return Migration{Version: version, Filename: filename}
+	}
+
+	t.Run("contiguous", func(t *testing.T) {
+		gaps, err := checkMigrationVersions([]Migration{m(1, "0001_a.sql"), m(2, "0002_b.sql"), m(3, "0003_c.sql")})
+		if err != nil || len(gaps) != 0 {
+			t.Errorf("got gaps %v, err %v; want none", gaps, err)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := checkMigrationVersions([]Migration{m(6, "0006_a.sql"), m(7, "0007_b.sql"), m(7, "0007_c.sql")})
+		if err == nil || !strings.Contains(err.Error(), "0007_b.sql and 0007_c.sql") {
+			t.Errorf("err = %v, want the duplicate files named", err)
+		}
+	})
+
+	t.Run("gaps", func(t *testing.T) {
+		gaps, err := checkMigrationVersions([]Migration{m(1, "0001_a.sql"), m(4, "0004_b.sql"), m(5, "0005_c.sql")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(gaps, []int{2, 3}) {
+			t.Errorf("gaps = %v, want [2 3]", gaps)
+		}
+	})
+}
+
+func TestRepositoryMigrationVersions(t *testing.T) {
+	migrations, err := readMigrations()
+	if err != nil {
+		t.Fatalf("readMigrations: %v", err)
+	}
+	gaps, err := checkMigrationVersions(migrations)
+	if err != nil || len(gaps) != 0 {
+		t.Errorf("migrations/bigquery: gaps %v, err %v", gaps, err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// defaultRetention is how long model outputs of unsuccessful or superseded runs are kept.
+const defaultRetention = 90 * 24 * time.Hour
+
+func main() {
+	var (
+		retention = flag.Duration("retention", envDuration("MODEL_OUTPUT_RETENTION", defaultRetention),
+			"Prune outputs older than this, e.g. 720h (or set MODEL_OUTPUT_RETENTION env)")
+		archiveBucket = flag.String("archive-bucket", os.Getenv("MODEL_OUTPUT_ARCHIVE_BUCKET"),
+			"GCS bucket to archive outputs to as gzipped JSON (or set MODEL_OUTPUT_ARCHIVE_BUCKET env)")
+		discard   = flag.Bool("discard", false, "Discard outputs without archiving them when no -archive-bucket is set")
+		batchSize = flag.Int("batch-size", 100, "Number of outputs to prune per batch")
+		dryRun    = flag.Bool("dry-run", false, "List what would be pruned without changing anything")
+	)
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Parse()
+
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	if *archiveBucket == "" && !*discard && !*dryRun {
+		fmt.Fprintln(os.Stderr, "Usage: prune (-archive-bucket BUCKET | -discard) [-retention DURATION] [-batch-size N] [-dry-run]")
+		os.Exit(1)
+	}
+	if *retention <= 0 {
+		log.Fatal().Dur("retention", *retention).Msg("Error: -retention must be positive")
+	}
+	if *batchSize <= 0 {
+		log.Fatal().Int("batch_size", *batchSize).Msg("Error: -batch-size must be positive")
+	}
+
+	ctx := context.Background()
+	ctx = logger.WithContext(ctx, log)
+
+	cutoff := time.Now().Add(-*retention)
+	log.Info().
+		Time("cutoff", cutoff).
+		Str("archive_bucket", *archiveBucket).
+		Bool("dry_run", *dryRun).
+		Msg("Starting model output prune")
+
+	if *dryRun {
+		rows, err := infraBQ.ListPrunableModelOutputs(ctx, cutoff, *batchSize)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to list prunable model outputs")
+		}
+		for _, row := range rows {
+			fmt.Printf("%s\t%s\t%s\t%s\n", row.OutputID, row.ParsingRunID, row.DocumentID, row.CreatedTS.Timestamp.Format(time.RFC3339))
+		}
+		log.Info().Int("outputs", len(rows)).Msg("Dry run: first batch of prunable outputs listed")
+		return
+	}
+
+	var bucket *storage.BucketHandle
+	if *archiveBucket != "" {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create storage client")
+		}
+		defer client.Close()
+		bucket = client.Bucket(*archiveBucket)
+	}
+
+	start := time.Now()
+	var total int64
+	for {
+		rows, err := infraBQ.ListPrunableModelOutputs(ctx, cutoff, *batchSize)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to list prunable model outputs")
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		pruned := make([]infraBQ.PrunedModelOutput, 0, len(rows))
+		for _, row := range rows {
+			p := infraBQ.PrunedModelOutput{OutputID: row.OutputID}
+			if bucket != nil {
+				if p.ArchiveURI, err = archiveOutput(ctx, bucket, row); err != nil {
+					log.Fatal().Err(err).Str("output_id", row.OutputID).Msg("Failed to archive model output")
+				}
+			}
+			pruned = append(pruned, p)
+		}
+
+		n, err := infraBQ.MarkModelOutputsPruned(ctx, pruned)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to mark model outputs as pruned")
+		}
+		if n == 0 {
+			// Nothing changed, so listing again would return the same rows
+			log.Fatal().Int("outputs", len(pruned)).Msg("No model outputs were updated")
+		}
+		total += n
+		log.Info().Int64("pruned", n).Int64("total", total).Msg("Batch pruned")
+	}
+
+	log.Info().
+		Int64("pruned", total).
+		Dur("duration", time.Since(start)).
+		Msg("Model output prune finished")
+}
+
+// archivedOutput is the JSON document written to GCS for each pruned output.
+type archivedOutput struct {
+	OutputID      string          `json:"output_id"`
+	ParsingRunID  string          `json:"parsing_run_id"`
+	DocumentID    string          `json:"document_id"`
+	ModelName     string          `json:"model_name"`
+	ModelVersion  string          `json:"model_version,omitempty"`
+	CreatedTS     time.Time       `json:"created_ts"`
+	RawJSON       json.RawMessage `json:"raw_json,omitempty"`
+	ExtractedText string          `json:"extracted_text,omitempty"`
+}
+
+// archiveOutput writes row to bucket as gzipped JSON and returns its gs:// URI.
+func archiveOutput(ctx context.Context, bucket *storage.BucketHandle, row *infraBQ.ModelOutputRow) (string, error) {
+	doc := archivedOutput{
+		OutputID:      row.OutputID,
+		ParsingRunID:  row.ParsingRunID,
+		DocumentID:    row.DocumentID,
+		ModelName:     row.ModelName,
+		ModelVersion:  row.ModelVersion.StringVal,
+		CreatedTS:     row.CreatedTS.Timestamp,
+		ExtractedText: row.ExtractedText.StringVal,
+	}
+	if row.RawJSON.Valid {
+		doc.RawJSON = json.RawMessage(row.RawJSON.JSONVal)
+	}
+
+	object := archiveObjectName(row)
+	w := bucket.Object(object).NewWriter(ctx)
+	w.ContentType = "application/json"
+	w.ContentEncoding = "gzip"
+
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(doc); err != nil {
+		w.Close()
+		return "", fmt.Errorf("encoding %s: %w", object, err)
+	}
+	if err := gz.Close(); err != nil {
+		w.Close()
+		return "", fmt.Errorf("compressing %s: %w", object, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("writing %s: %w", object, err)
+	}
+
+	return fmt.Sprintf("gs://%s/%s", bucket.BucketName(), object), nil
+}
+
+// archiveObjectName returns the object name an output is archived under.
+func archiveObjectName(row *infraBQ.ModelOutputRow) string {
+	return fmt.Sprintf("model_outputs/%s/%s/%s.json.gz", row.DocumentID, row.ParsingRunID, row.OutputID)
+}
+
+// envDuration returns the duration in the environment variable key, or def if it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
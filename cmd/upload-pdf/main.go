@@ -11,9 +11,6 @@ import (
 )
 
 func main() {
-	// Initialize structured logger
-	log := logger.New()
-
 	var (
 		bucketName string
 		objectName string
@@ -23,8 +20,15 @@ func main() {
 	flag.StringVar(&bucketName, "bucket", "", "GCS bucket name (required)")
 	flag.StringVar(&objectName, "object", "", "GCS object name (optional; defaults to file name)")
 	flag.StringVar(&filePath, "file", "", "Path to local PDF file (required)")
+	logFormat := logger.FormatFlag(flag.CommandLine)
 	flag.Parse()
 
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
 	if bucketName == "" || filePath == "" {
 		log.Fatal().Msg("Usage: upload-pdf -bucket BUCKET_NAME -file /path/to/file.pdf [-object OBJECT_NAME]")
 	}
@@ -42,7 +46,11 @@ func main() {
 		Str("file", filePath).
 		Msg("Uploading file to GCS")
 
-	if err := gcsuploader.UploadFile(ctx, bucketName, objectName, filePath); err != nil {
+	storage, err := gcsuploader.NewStorageServiceFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid storage configuration")
+	}
+	if err := storage.UploadFile(ctx, bucketName, objectName, filePath); err != nil {
 		log.Fatal().Err(err).Msg("Upload failed")
 	}
 
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+	"github.com/rs/zerolog"
+)
+
+const (
+	knownChecksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	otherChecksum = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+)
+
+// checksumRepo is a DocumentRepository with one document per known checksum.
+type checksumRepo struct {
+	bigquery.DocumentRepository
+}
+
+func (r *checksumRepo) FindDocumentByChecksum(ctx context.Context, checksum string) (*bigquery.DocumentRow, error) {
+	switch checksum {
+	case knownChecksum:
+		return &bigquery.DocumentRow{DocumentID: "doc-1", UserID: "user-1", ChecksumSHA256: checksum}, nil
+	case otherChecksum:
+		return &bigquery.DocumentRow{DocumentID: "doc-2", UserID: "user-2", ChecksumSHA256: checksum}, nil
+	}
+	return nil, nil
+}
+
+func TestListDocumentsByChecksum(t *testing.T) {
+	tests := []struct {
+		name       string
+		checksum   string
+		extra      string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{name: "found", checksum: knownChecksum, wantStatus: http.StatusOK, wantIDs: []string{"doc-1"}},
+		{name: "upper case", checksum: strings.ToUpper(knownChecksum), wantStatus: http.StatusOK, wantIDs: []string{"doc-1"}},
+		{name: "not found", checksum: strings.Repeat("0", 64), wantStatus: http.StatusOK},
+		{name: "other user's document", checksum: otherChecksum, wantStatus: http.StatusOK},
+		{name: "not hex", checksum: strings.Repeat("z", 64), wantStatus: http.StatusBadRequest},
+		{name: "wrong length", checksum: "abcd", wantStatus: http.StatusBadRequest},
+		{name: "with include", checksum: knownChecksum, extra: "&include=stats", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewDocumentsHandler(&checksumRepo{}, nil, nil, DocumentsConfig{UserID: "user-1"}, zerolog.Nop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents?checksum="+tt.checksum+tt.extra, nil)
+			rec := httptest.NewRecorder()
+			h.ListDocuments(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Documents []struct {
+					DocumentID string `json:"document_id"`
+				} `json:"documents"`
+				Count int `json:"count"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Documents == nil {
+				t.Error("documents is null, want a list")
+			}
+			if body.Count != len(tt.wantIDs) || len(body.Documents) != len(tt.wantIDs) {
+				t.Fatalf("got %d documents (count %d), want %v", len(body.Documents), body.Count, tt.wantIDs)
+			}
+			for i, want := range tt.wantIDs {
+				if body.Documents[i].DocumentID != want {
+					t.Errorf("document %d = %s, want %s", i, body.Documents[i].DocumentID, want)
+				}
+			}
+		})
+	}
+}
+
+// uploadDedupRepo is a DocumentRepository holding documents by ID, whose conditional
+// insert only adds absent documents.
+type uploadDedupRepo struct {
+	bigquery.DocumentRepository
+	docs map[string]*bigquery.DocumentRow
+}
+
+func (r *uploadDedupRepo) InsertDocumentIfAbsent(ctx context.Context, row *bigquery.DocumentRow) (bool, error) {
+	if _, ok := r.docs[row.DocumentID]; ok {
+		return false, nil
+	}
+	r.docs[row.DocumentID] = row
+	return true, nil
+}
+
+func (r *uploadDedupRepo) GetDocument(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+	return r.docs[documentID], nil
+}
+
+func TestInsertChecksumDocument(t *testing.T) {
+	ctx := context.Background()
+	repo := &uploadDedupRepo{docs: map[string]*bigquery.DocumentRow{}}
+	h := NewDocumentsHandler(repo, nil, nil, DocumentsConfig{UserID: "user-1"}, zerolog.Nop())
+
+	first := &bigquery.DocumentRow{
+		DocumentID: pipeline.DocumentIDFromChecksum("user-1", knownChecksum),
+		UserID:     "user-1",
+		GCSURI:     "gs://bucket/uploads/a.pdf",
+	}
+	if existing, err := h.insertChecksumDocument(ctx, first); err != nil || existing != nil {
+		t.Fatalf("first upload: existing %v, err %v; want it inserted", existing, err)
+	}
+
+	again := &bigquery.DocumentRow{DocumentID: first.DocumentID, UserID: "user-1", GCSURI: "gs://bucket/uploads/b.pdf"}
+	existing, err := h.insertChecksumDocument(ctx, again)
+	if err != nil || existing != first {
+		t.Errorf("same content again: existing %v, err %v; want the first document", existing, err)
+	}
+
+	other := &bigquery.DocumentRow{DocumentID: pipeline.DocumentIDFromChecksum("user-2", knownChecksum), UserID: "user-2"}
+	if existing, err := h.insertChecksumDocument(ctx, other); err != nil || existing != nil {
+		t.Errorf("same content from another user: existing %v, err %v; want a new document", existing, err)
+	}
+
+	repo.docs["taken"] = &bigquery.DocumentRow{DocumentID: "taken", UserID: "user-2"}
+	if _, err := h.insertChecksumDocument(ctx, &bigquery.DocumentRow{DocumentID: "taken", UserID: "user-1"}); err == nil {
+		t.Error("insert over another user's document succeeded")
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// DefaultAllowedContentTypes are the upload content types accepted when none are configured.
+var DefaultAllowedContentTypes = []string{"application/pdf"}
+
+// ParseContentTypes splits a comma-separated list of MIME types such as
+// "application/pdf,text/csv" and normalizes them to lower case without parameters.
+func ParseContentTypes(list string) ([]string, error) {
+	var types []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(item)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			return nil, fmt.Errorf("invalid content type %q", item)
+		}
+		types = append(types, mediaType)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("at least one content type is required")
+	}
+	return types, nil
+}
+
+// contentTypeAllowed reports whether contentType, ignoring parameters such as charset,
+// is one of allowed.
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedContentTypeMessage is the error returned for uploads of other content types.
+func unsupportedContentTypeMessage(allowed []string) string {
+	return "Unsupported content type: must be one of " + strings.Join(allowed, ", ")
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseContentTypes(t *testing.T) {
+	got, err := ParseContentTypes(" application/PDF, text/csv ,application/x-ofx")
+	if err != nil {
+		t.Fatalf("ParseContentTypes: %v", err)
+	}
+	want := []string{"application/pdf", "text/csv", "application/x-ofx"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseContentTypes = %v, want %v", got, want)
+	}
+
+	for _, list := range []string{"", " , ", "pdf", "application/pdf,;"} {
+		if _, err := ParseContentTypes(list); err == nil {
+			t.Errorf("ParseContentTypes(%q) succeeded, want error", list)
+		}
+	}
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	allowed := []string{"application/pdf", "text/csv"}
+	tests := map[string]bool{
+		"application/pdf":          true,
+		"Application/PDF":          true,
+		"text/csv; charset=utf-8":  true,
+		"application/octet-stream": false,
+		"":                         false,
+	}
+	for contentType, want := range tests {
+		if got := contentTypeAllowed(contentType, allowed); got != want {
+			t.Errorf("contentTypeAllowed(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
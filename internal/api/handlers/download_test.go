@@ -0,0 +1,43 @@
+package handlers
+
+import "testing"
+
+func TestParseGCSURI(t *testing.T) {
+	bucket, object, err := parseGCSURI("gs://statements/2024/03/05/abc_statement.pdf")
+	if err != nil {
+		t.Fatalf("parseGCSURI: %v", err)
+	}
+	if bucket != "statements" || object != "2024/03/05/abc_statement.pdf" {
+		t.Errorf("got bucket=%q object=%q", bucket, object)
+	}
+
+	for _, uri := range []string{"", "statements/file.pdf", "gs://statements", "gs://statements/", "gs:///file.pdf"} {
+		if _, _, err := parseGCSURI(uri); err == nil {
+			t.Errorf("parseGCSURI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := map[string]string{
+		"statement.pdf":       `attachment; filename=statement.pdf`,
+		"March statement.pdf": `attachment; filename="March statement.pdf"`,
+		"relevé.pdf":          `attachment; filename*=utf-8''relev%C3%A9.pdf`,
+	}
+	for filename, want := range tests {
+		if got := contentDisposition(filename); got != want {
+			t.Errorf("contentDisposition(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
+
+func TestValidateDownloadMode(t *testing.T) {
+	for _, mode := range []string{"", DownloadModeProxy, DownloadModeSignedURL} {
+		if err := ValidateDownloadMode(mode); err != nil {
+			t.Errorf("ValidateDownloadMode(%q) = %v", mode, err)
+		}
+	}
+	if err := ValidateDownloadMode("inline"); err == nil {
+		t.Error("ValidateDownloadMode(\"inline\") succeeded, want error")
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/rs/zerolog"
+)
+
+// fullPublisher is a Publisher whose queue is always full.
+type fullPublisher struct {
+	jobs.Publisher
+}
+
+func (p *fullPublisher) PublishParseDocument(ctx context.Context, job *jobs.ParseDocumentJob) error {
+	return jobs.ErrQueueFull
+}
+
+func TestEnqueueParsingQueueFull(t *testing.T) {
+	h := NewDocumentsHandler(nil, &fullPublisher{}, nil, DocumentsConfig{}, zerolog.Nop())
+
+	body := `{"document_id": "doc-1", "gcs_uri": "gs://bucket/doc-1.pdf"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/parse", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.EnqueueParsing(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429; body: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Retry-After"); got != queueFullRetryAfter {
+		t.Errorf("Retry-After = %q, want %q", got, queueFullRetryAfter)
+	}
+}
+
+// recordingPublisher records the jobs it is given.
+type recordingPublisher struct {
+	jobs.Publisher
+	published []*jobs.ParseDocumentJob
+}
+
+func (p *recordingPublisher) PublishParseDocument(ctx context.Context, job *jobs.ParseDocumentJob) error {
+	p.published = append(p.published, job)
+	return nil
+}
+
+func TestEnqueueParsingPassesPDFPassword(t *testing.T) {
+	publisher := &recordingPublisher{}
+	h := NewDocumentsHandler(nil, publisher, nil, DocumentsConfig{}, zerolog.Nop())
+
+	body := `{"document_id": "doc-1", "gcs_uri": "gs://bucket/doc-1.pdf", "pdf_password": "s3cret"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/parse", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.EnqueueParsing(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; body: %s", rec.Code, rec.Body)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].PDFPassword != "s3cret" {
+		t.Fatalf("published %+v, want one job with the PDF password", publisher.published)
+	}
+
+	// The jobs API must not return it
+	encoded, err := json.Marshal(publisher.published[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), "s3cret") || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Errorf("PDF password serialized: job %s, response %s", encoded, rec.Body)
+	}
+}
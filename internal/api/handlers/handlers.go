@@ -2,54 +2,222 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	bigquerylib "cloud.google.com/go/bigquery"
 	"cloud.google.com/go/storage"
 	"github.com/dvloznov/finance-tracker/internal/api/middleware"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
 	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
+// DocumentsConfig holds settings for the documents handler.
+type DocumentsConfig struct {
+	// Bucket is the GCS bucket that uploads are written to.
+	Bucket string
+
+	// ObjectNameTemplate controls where uploads are placed in the bucket.
+	// See buildObjectName for the supported placeholders. Empty means DefaultObjectNameTemplate.
+	ObjectNameTemplate string
+
+	// UserID is substituted for the {user_id} placeholder.
+	UserID string
+
+	// SignedURLExpiry is how long upload URLs stay valid. Zero means DefaultSignedURLExpiry.
+	SignedURLExpiry time.Duration
+
+	// UploadURLKey signs upload URLs so UploadDocument can reject expired or altered
+	// ones. Empty means a random key, which invalidates outstanding URLs on restart and
+	// differs between API instances.
+	UploadURLKey []byte
+
+	// DownloadMode selects how original files are served: DownloadModeProxy (default)
+	// or DownloadModeSignedURL.
+	DownloadMode string
+
+	// AllowedContentTypes lists the MIME types uploads may have; others are rejected with
+	// 415. Empty means DefaultAllowedContentTypes.
+	AllowedContentTypes []string
+
+	// DocumentIDMode is pipeline.DocumentIDChecksum to give uploads the ID derived from
+	// their checksum instead of the one in the upload URL. Empty means
+	// pipeline.DocumentIDRandom.
+	DocumentIDMode string
+}
+
+// Ways DownloadDocument can serve a document's original file.
+const (
+	// DownloadModeProxy streams the file through the API server. Works with user credentials.
+	DownloadModeProxy = "proxy"
+
+	// DownloadModeSignedURL redirects to a short-lived signed GCS URL. Requires credentials
+	// that can sign, e.g. a service account.
+	DownloadModeSignedURL = "signed_url"
+)
+
+// DownloadURLExpiry is the lifetime of signed download URLs.
+const DownloadURLExpiry = 5 * time.Minute
+
+// ValidateDownloadMode checks that mode is empty or a supported download mode.
+func ValidateDownloadMode(mode string) error {
+	switch mode {
+	case "", DownloadModeProxy, DownloadModeSignedURL:
+		return nil
+	default:
+		return fmt.Errorf("unsupported download mode %q: must be %s or %s", mode, DownloadModeProxy, DownloadModeSignedURL)
+	}
+}
+
+const (
+	// DefaultSignedURLExpiry is the upload URL lifetime used when none is configured.
+	DefaultSignedURLExpiry = 15 * time.Minute
+
+	// MaxSignedURLExpiry is the longest lifetime GCS allows for V4 signed URLs.
+	MaxSignedURLExpiry = 7 * 24 * time.Hour
+)
+
+// ValidateSignedURLExpiry checks that d is positive and within the GCS V4 limit.
+func ValidateSignedURLExpiry(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("signed URL expiry must be positive, got %s", d)
+	}
+	if d > MaxSignedURLExpiry {
+		return fmt.Errorf("signed URL expiry %s exceeds the GCS maximum of %s", d, MaxSignedURLExpiry)
+	}
+	return nil
+}
+
+// signedURLExpiry returns the configured upload URL lifetime.
+func (c DocumentsConfig) signedURLExpiry() time.Duration {
+	if c.SignedURLExpiry <= 0 {
+		return DefaultSignedURLExpiry
+	}
+	return c.SignedURLExpiry
+}
+
+// allowedContentTypes returns the configured upload content types.
+func (c DocumentsConfig) allowedContentTypes() []string {
+	if len(c.AllowedContentTypes) == 0 {
+		return DefaultAllowedContentTypes
+	}
+	return c.AllowedContentTypes
+}
+
 // DocumentsHandler handles document-related endpoints.
 type DocumentsHandler struct {
 	repo      bigquery.DocumentRepository
 	publisher jobs.Publisher
-	bucket    string
+	store     gcsuploader.ObjectStore
+	cfg       DocumentsConfig
 	log       zerolog.Logger
 }
 
-// NewDocumentsHandler creates a new documents handler.
-func NewDocumentsHandler(repo bigquery.DocumentRepository, publisher jobs.Publisher, bucket string, log zerolog.Logger) *DocumentsHandler {
+// NewDocumentsHandler creates a new documents handler. Uploaded files are written to,
+// served from and deleted from store.
+func NewDocumentsHandler(repo bigquery.DocumentRepository, publisher jobs.Publisher, store gcsuploader.ObjectStore, cfg DocumentsConfig, log zerolog.Logger) *DocumentsHandler {
+	if len(cfg.UploadURLKey) == 0 {
+		cfg.UploadURLKey = newUploadURLKey()
+	}
 	return &DocumentsHandler{
 		repo:      repo,
 		publisher: publisher,
-		bucket:    bucket,
+		store:     store,
+		cfg:       cfg,
 		log:       log,
 	}
 }
 
 // ListDocuments handles GET /api/documents
+// With ?include=stats every document also carries transaction_count, total_in and
+// total_out for its active parsing run. With ?checksum= only the document with that
+// SHA-256 checksum is listed, if there is one.
 func (h *DocumentsHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	documents, err := h.repo.ListAllDocuments(ctx)
+	if checksum := r.URL.Query().Get("checksum"); checksum != "" {
+		h.findDocumentsByChecksum(w, r, checksum)
+		return
+	}
+
+	includeStats := false
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "stats":
+			includeStats = true
+		default:
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid include: must be stats")
+			return
+		}
+	}
+
+	var documents interface{}
+	var count int
+	if includeStats {
+		rows, err := h.repo.ListAllDocumentsWithStats(ctx)
+		if err != nil {
+			h.log.Error().Err(err).Msg("Failed to list documents with stats")
+			middleware.WriteError(w, http.StatusInternalServerError, "Failed to list documents")
+			return
+		}
+		documents, count = rows, len(rows)
+	} else {
+		rows, err := h.repo.ListAllDocuments(ctx)
+		if err != nil {
+			h.log.Error().Err(err).Msg("Failed to list documents")
+			middleware.WriteError(w, http.StatusInternalServerError, "Failed to list documents")
+			return
+		}
+		documents, count = rows, len(rows)
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"documents": documents,
+		"count":     count,
+	})
+}
+
+// findDocumentsByChecksum lists the document whose file has the given SHA-256 checksum,
+// so clients can skip uploading a file that was ingested before.
+func (h *DocumentsHandler) findDocumentsByChecksum(w http.ResponseWriter, r *http.Request, checksum string) {
+	checksum = strings.ToLower(checksum)
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != 2*sha256.Size {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid checksum: must be a hex SHA-256 digest")
+		return
+	}
+	if r.URL.Query().Get("include") != "" {
+		middleware.WriteError(w, http.StatusBadRequest, "checksum cannot be combined with include")
+		return
+	}
+
+	doc, err := h.repo.FindDocumentByChecksum(r.Context(), checksum)
 	if err != nil {
-		h.log.Error().Err(err).Msg("Failed to list documents")
+		h.log.Error().Err(err).Msg("Failed to find document by checksum")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to list documents")
 		return
 	}
 
+	documents := []*bigquery.DocumentRow{}
+	if doc != nil && (doc.UserID == "" || doc.UserID == h.cfg.UserID) {
+		documents = append(documents, doc)
+	}
 	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"documents": documents,
 		"count":     len(documents),
@@ -59,8 +227,11 @@ func (h *DocumentsHandler) ListDocuments(w http.ResponseWriter, r *http.Request)
 // CreateUploadURL handles POST /api/documents/upload-url
 func (h *DocumentsHandler) CreateUploadURL(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Filename    string `json:"filename"`
-		ContentType string `json:"content_type"`
+		Filename      string `json:"filename"`
+		ContentType   string `json:"content_type"`
+		Institution   string `json:"institution,omitempty"`
+		InstitutionID string `json:"institution_id,omitempty"`
+		SourceSystem  string `json:"source_system,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -73,66 +244,187 @@ func (h *DocumentsHandler) CreateUploadURL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if req.ContentType != "" && !contentTypeAllowed(req.ContentType, h.cfg.allowedContentTypes()) {
+		middleware.WriteError(w, http.StatusUnsupportedMediaType, unsupportedContentTypeMessage(h.cfg.allowedContentTypes()))
+		return
+	}
+
+	source, err := pipeline.IngestOptions{InstitutionID: req.InstitutionID, SourceSystem: req.SourceSystem}.Normalize()
+	if err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Unknown institution_id")
+		return
+	}
+	if req.Institution == "" {
+		req.Institution = source.InstitutionID
+	}
+
 	// Generate unique object name
-	objectName := fmt.Sprintf("uploads/%s/%s", time.Now().Format("2006/01/02"), uuid.New().String()+"-"+req.Filename)
-	gcsURI := fmt.Sprintf("gs://%s/%s", h.bucket, objectName)
-	documentID := uuid.New().String()
+	objectName, err := buildObjectName(h.cfg.ObjectNameTemplate, objectNameParams{
+		Date:        apptime.Now(),
+		UUID:        uuid.New().String(),
+		Filename:    req.Filename,
+		UserID:      h.cfg.UserID,
+		Institution: req.Institution,
+	})
+	if err != nil {
+		h.log.Warn().Err(err).Str("filename", req.Filename).Msg("Rejected upload object name")
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid filename")
+		return
+	}
+	h.writeUploadURL(w, uuid.New().String(), objectName, req.Filename, source)
+}
+
+// writeUploadURL responds with the URL to upload documentID to objectName. The URL is
+// signed with its expiry, which UploadDocument enforces.
+func (h *DocumentsHandler) writeUploadURL(w http.ResponseWriter, documentID, objectName, filename string, source pipeline.IngestOptions) {
+	gcsURI := fmt.Sprintf("gs://%s/%s", h.cfg.Bucket, objectName)
 
 	// For local development with user credentials, return direct upload URL
 	// In production with service accounts, this would use signed URLs
-	uploadURL := fmt.Sprintf("/api/documents/upload/%s?object_name=%s&filename=%s", documentID, url.QueryEscape(objectName), url.QueryEscape(req.Filename))
+	uploadQuery := url.Values{"object_name": {objectName}, "filename": {filename}}
+	if source.InstitutionID != "" {
+		uploadQuery.Set("institution_id", source.InstitutionID)
+	}
+	if source.SourceSystem != "" {
+		uploadQuery.Set("source_system", source.SourceSystem)
+	}
+	expiry := h.cfg.signedURLExpiry()
+	expiresAt := time.Now().Add(expiry)
+	signUploadURLQuery(h.cfg.UploadURLKey, documentID, uploadQuery, expiresAt)
+	uploadURL := fmt.Sprintf("/api/documents/upload/%s?%s", documentID, uploadQuery.Encode())
 
-	middleware.WriteJSON(w, http.StatusOK, map[string]string{
-		"upload_url":  uploadURL,
-		"gcs_uri":     gcsURI,
-		"object_name": objectName,
-		"document_id": documentID,
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"upload_url":         uploadURL,
+		"gcs_uri":            gcsURI,
+		"object_name":        objectName,
+		"document_id":        documentID,
+		"expires_at":         expiresAt.UTC().Format(time.RFC3339),
+		"expires_in_seconds": int64(expiry.Seconds()),
 	})
 }
 
+// RefreshUploadURL handles POST /api/documents/:documentId/refresh-upload-url
+// It issues a new upload URL for the same document and object as an expired one from
+// CreateUploadURL, as long as the document has not been uploaded yet. The body carries
+// the object_name returned by CreateUploadURL and the original filename, institution_id
+// and source_system.
+func (h *DocumentsHandler) RefreshUploadURL(w http.ResponseWriter, r *http.Request, documentID string) {
+	var req struct {
+		ObjectName    string `json:"object_name"`
+		Filename      string `json:"filename"`
+		InstitutionID string `json:"institution_id,omitempty"`
+		SourceSystem  string `json:"source_system,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := uuid.Parse(documentID); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid document ID")
+		return
+	}
+	if req.ObjectName == "" || req.Filename == "" {
+		middleware.WriteError(w, http.StatusBadRequest, "object_name and filename are required")
+		return
+	}
+	// The institution in the name may be the free-form one given to CreateUploadURL rather
+	// than institution_id, so only the user is checked
+	if err := validateUploadObjectName(h.cfg.ObjectNameTemplate, req.ObjectName, objectNameParams{UserID: h.cfg.UserID}); err != nil {
+		h.log.Warn().Err(err).Str("document_id", documentID).Msg("Rejected upload object name")
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid object_name")
+		return
+	}
+
+	source, err := pipeline.IngestOptions{InstitutionID: req.InstitutionID, SourceSystem: req.SourceSystem}.Normalize()
+	if err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Unknown institution_id")
+		return
+	}
+
+	// The document record is only created once the file is uploaded
+	doc, err := h.findDocument(r.Context(), documentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list documents")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
+		return
+	}
+	if doc != nil {
+		middleware.WriteError(w, http.StatusConflict, "Document has already been uploaded")
+		return
+	}
+
+	h.log.Info().Str("document_id", documentID).Msg("Refreshed upload URL")
+	h.writeUploadURL(w, documentID, req.ObjectName, req.Filename, source)
+}
+
 // UploadDocument handles POST /api/documents/upload/:documentId
-// Direct upload endpoint for local development with user credentials
+// Direct upload endpoint for local development with user credentials. Only unexpired
+// URLs from CreateUploadURL or RefreshUploadURL are accepted.
 func (h *DocumentsHandler) UploadDocument(w http.ResponseWriter, r *http.Request, documentID string) {
 	ctx := r.Context()
 
+	if err := verifyUploadURL(h.cfg.UploadURLKey, documentID, r.URL.Query(), time.Now()); err != nil {
+		h.log.Warn().Err(err).Str("document_id", documentID).Msg("Rejected upload URL")
+		if errors.Is(err, errUploadURLExpired) {
+			middleware.WriteError(w, http.StatusForbidden, "Upload URL has expired; request a new one")
+			return
+		}
+		middleware.WriteError(w, http.StatusForbidden, "Invalid upload URL")
+		return
+	}
+
 	// Get object name from query parameter (passed from CreateUploadURL)
 	objectName := r.URL.Query().Get("object_name")
 	if objectName == "" {
 		middleware.WriteError(w, http.StatusBadRequest, "object_name is required")
 		return
 	}
+	if err := validateUploadObjectName(h.cfg.ObjectNameTemplate, objectName, objectNameParams{UserID: h.cfg.UserID}); err != nil {
+		h.log.Warn().Err(err).Str("document_id", documentID).Msg("Rejected upload object name")
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid object_name")
+		return
+	}
+
+	source, err := pipeline.IngestOptions{
+		InstitutionID: r.URL.Query().Get("institution_id"),
+		SourceSystem:  r.URL.Query().Get("source_system"),
+	}.Normalize()
+	if err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Unknown institution_id")
+		return
+	}
 
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/pdf"
 	}
-
-	gcsURI := fmt.Sprintf("gs://%s/%s", h.bucket, objectName)
-
-	// Upload to GCS
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		h.log.Error().Err(err).Msg("Failed to create storage client")
-		middleware.WriteError(w, http.StatusInternalServerError, "Failed to upload file")
+	if !contentTypeAllowed(contentType, h.cfg.allowedContentTypes()) {
+		h.log.Warn().Str("document_id", documentID).Str("content_type", contentType).Msg("Rejected upload content type")
+		middleware.WriteError(w, http.StatusUnsupportedMediaType, unsupportedContentTypeMessage(h.cfg.allowedContentTypes()))
 		return
 	}
-	defer client.Close()
 
-	wc := client.Bucket(h.bucket).Object(objectName).NewWriter(ctx)
-	wc.ContentType = contentType
+	gcsURI := fmt.Sprintf("gs://%s/%s", h.cfg.Bucket, objectName)
 
-	// Copy request body directly to GCS
-	written, err := io.Copy(wc, r.Body)
+	// Copy request body directly to storage, hashing it on the way if the ID depends on it
+	body := io.Reader(r.Body)
+	hasher := sha256.New()
+	if h.cfg.DocumentIDMode == pipeline.DocumentIDChecksum {
+		body = io.TeeReader(r.Body, hasher)
+	}
+	written, err := h.store.WriteObject(ctx, h.cfg.Bucket, objectName, contentType, body)
 	if err != nil {
-		h.log.Error().Err(err).Msg("Failed to write to GCS")
+		h.log.Error().Err(err).Msg("Failed to write to storage")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to upload file")
 		return
 	}
 
-	if err := wc.Close(); err != nil {
-		h.log.Error().Err(err).Msg("Failed to close GCS writer")
-		middleware.WriteError(w, http.StatusInternalServerError, "Failed to upload file")
-		return
+	var checksum string
+	if h.cfg.DocumentIDMode == pipeline.DocumentIDChecksum {
+		checksum = hex.EncodeToString(hasher.Sum(nil))
+		documentID = pipeline.DocumentIDFromChecksum(h.cfg.UserID, checksum)
 	}
 
 	h.log.Info().
@@ -143,25 +435,52 @@ func (h *DocumentsHandler) UploadDocument(w http.ResponseWriter, r *http.Request
 
 	// Save document metadata to BigQuery
 	filename := r.URL.Query().Get("filename")
-	if filename == "" {
-		filename = "document.pdf"
-	}
 	// Clean filename - remove any path or query parameters
 	if idx := strings.Index(filename, "?"); idx > 0 {
 		filename = filename[:idx]
 	}
-	filename = filepath.Base(filename)
+	filename = sanitizeFilename(filename)
+	if filename == "" {
+		filename = "document.pdf"
+	}
 
 	doc := &bigquery.DocumentRow{
 		DocumentID:       documentID,
+		UserID:           h.cfg.UserID,
 		OriginalFilename: filename,
 		GCSURI:           gcsURI,
-		UploadTS:         time.Now(),
-		ParsingStatus:    "PENDING",
+		SourceSystem:     source.DocumentSourceSystem(),
+		InstitutionID:    source.InstitutionID,
+		UploadTS:         apptime.Now(),
+		ParsingStatus:    bigquery.DocumentStatusPending,
 		FileMimeType:     contentType,
+		ChecksumSHA256:   checksum,
 	}
 
-	if err := h.repo.InsertDocument(ctx, doc); err != nil {
+	if h.cfg.DocumentIDMode == pipeline.DocumentIDChecksum {
+		existing, err := h.insertChecksumDocument(ctx, doc)
+		if err != nil {
+			h.log.Error().Err(err).Msg("Failed to insert document metadata")
+			middleware.WriteError(w, http.StatusInternalServerError, "Failed to save document metadata")
+			return
+		}
+		if existing != nil {
+			// Same content as an existing document; drop the new copy unless the
+			// upload overwrote the existing document's own object
+			if gcsURI != existing.GCSURI {
+				if err := h.store.DeleteObject(ctx, gcsURI); err != nil {
+					h.log.Warn().Err(err).Str("gcs_uri", gcsURI).Msg("Failed to delete duplicate upload")
+				}
+			}
+			h.log.Info().Str("document_id", existing.DocumentID).Msg("Upload matches an existing document")
+			middleware.WriteJSON(w, http.StatusOK, map[string]string{
+				"document_id": existing.DocumentID,
+				"gcs_uri":     existing.GCSURI,
+				"status":      "duplicate",
+			})
+			return
+		}
+	} else if err := h.repo.InsertDocument(ctx, doc); err != nil {
 		h.log.Error().Err(err).Msg("Failed to insert document metadata")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to save document metadata")
 		return
@@ -174,11 +493,41 @@ func (h *DocumentsHandler) UploadDocument(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// insertChecksumDocument inserts doc, whose ID is derived from its owner and checksum,
+// unless a document with that ID already exists, in which case it returns that
+// document instead. Concurrent uploads of the same content insert a single row.
+func (h *DocumentsHandler) insertChecksumDocument(ctx context.Context, doc *bigquery.DocumentRow) (*bigquery.DocumentRow, error) {
+	inserted, err := h.repo.InsertDocumentIfAbsent(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	if inserted {
+		return nil, nil
+	}
+
+	existing, err := h.repo.GetDocument(ctx, doc.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("document %s was neither inserted nor found", doc.DocumentID)
+	}
+	if existing.UserID != doc.UserID {
+		return nil, fmt.Errorf("document %s belongs to another user", doc.DocumentID)
+	}
+	return existing, nil
+}
+
+// queueFullRetryAfter is the Retry-After, in seconds, sent when the job queue is full.
+const queueFullRetryAfter = "5"
+
 // EnqueueParsing handles POST /api/documents/parse
 func (h *DocumentsHandler) EnqueueParsing(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DocumentID string `json:"document_id"`
-		GCSURI     string `json:"gcs_uri"`
+		DocumentID    string `json:"document_id"`
+		GCSURI        string `json:"gcs_uri"`
+		InstitutionID string `json:"institution_id,omitempty"`
+		PDFPassword   string `json:"pdf_password,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -191,16 +540,30 @@ func (h *DocumentsHandler) EnqueueParsing(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	source, err := pipeline.IngestOptions{InstitutionID: req.InstitutionID}.Normalize()
+	if err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Unknown institution_id")
+		return
+	}
+
 	ctx := r.Context()
 
 	// Create parse job
 	job := &jobs.ParseDocumentJob{
-		DocumentID: req.DocumentID,
-		GCSURI:     req.GCSURI,
+		DocumentID:    req.DocumentID,
+		GCSURI:        req.GCSURI,
+		InstitutionID: source.InstitutionID,
+		PDFPassword:   req.PDFPassword,
 	}
 
 	// Publish job
 	if err := h.publisher.PublishParseDocument(ctx, job); err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			h.log.Warn().Str("document_id", req.DocumentID).Msg("Job queue full, rejecting parsing job")
+			w.Header().Set("Retry-After", queueFullRetryAfter)
+			middleware.WriteError(w, http.StatusTooManyRequests, "Too many pending parsing jobs, retry later")
+			return
+		}
 		h.log.Error().Err(err).Msg("Failed to enqueue parsing job")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to enqueue parsing job")
 		return
@@ -221,27 +584,18 @@ func (h *DocumentsHandler) DeleteDocument(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 
 	// Get document details to find GCS URI
-	docs, err := h.repo.ListAllDocuments(ctx)
+	doc, err := h.findDocument(ctx, documentID)
 	if err != nil {
 		h.log.Error().Err(err).Msg("Failed to list documents")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
 		return
 	}
 
-	var gcsURI string
-	var found bool
-	for _, doc := range docs {
-		if doc.DocumentID == documentID {
-			gcsURI = doc.GCSURI
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	if doc == nil {
 		middleware.WriteError(w, http.StatusNotFound, "Document not found")
 		return
 	}
+	gcsURI := doc.GCSURI
 
 	// Delete from BigQuery (cascades to all related data)
 	if err := infraBQ.DeleteDocument(ctx, documentID); err != nil {
@@ -250,10 +604,10 @@ func (h *DocumentsHandler) DeleteDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Delete from GCS
+	// Delete from storage
 	if gcsURI != "" {
-		if err := h.deleteFromGCS(ctx, gcsURI); err != nil {
-			h.log.Warn().Err(err).Str("gcs_uri", gcsURI).Msg("Failed to delete file from GCS (document already deleted from database)")
+		if err := h.store.DeleteObject(ctx, gcsURI); err != nil {
+			h.log.Warn().Err(err).Str("gcs_uri", gcsURI).Msg("Failed to delete file from storage (document already deleted from database)")
 			// Continue anyway - document is deleted from DB
 		}
 	}
@@ -269,112 +623,382 @@ func (h *DocumentsHandler) DeleteDocument(w http.ResponseWriter, r *http.Request
 	})
 }
 
-// deleteFromGCS deletes a file from GCS given its gs:// URI
-func (h *DocumentsHandler) deleteFromGCS(ctx context.Context, gcsURI string) error {
-	// Parse gs://bucket/path format
-	if !strings.HasPrefix(gcsURI, "gs://") {
-		return fmt.Errorf("invalid GCS URI format: %s", gcsURI)
-	}
+// UpdateDocument handles PATCH /api/documents/:documentId
+// Accepts {"account_id": "..."} and moves the document and all its transactions to that
+// account, correcting a wrong account detection without re-parsing.
+func (h *DocumentsHandler) UpdateDocument(w http.ResponseWriter, r *http.Request, documentID string) {
+	ctx := r.Context()
 
-	path := strings.TrimPrefix(gcsURI, "gs://")
-	parts := strings.SplitN(path, "/", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid GCS URI format: %s", gcsURI)
+	var req struct {
+		AccountID string `json:"account_id"`
 	}
-
-	bucket := parts[0]
-	objectName := parts[1]
-
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to create storage client: %w", err)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
 	}
-	defer client.Close()
-
-	obj := client.Bucket(bucket).Object(objectName)
-	if err := obj.Delete(ctx); err != nil {
-		return fmt.Errorf("failed to delete object: %w", err)
+	accountID := strings.TrimSpace(req.AccountID)
+	if accountID == "" {
+		middleware.WriteError(w, http.StatusBadRequest, "account_id is required")
+		return
 	}
 
-	return nil
-}
-
-// generateSignedURL generates a signed URL for uploading to GCS.
-func (h *DocumentsHandler) generateSignedURL(ctx context.Context, bucket, object, contentType string) (string, error) {
-	client, err := storage.NewClient(ctx)
+	doc, err := h.findDocument(ctx, documentID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create storage client: %w", err)
+		h.log.Error().Err(err).Msg("Failed to list documents")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
+		return
 	}
-	defer client.Close()
-
-	opts := &storage.SignedURLOptions{
-		Method:      "PUT",
-		Expires:     time.Now().Add(15 * time.Minute),
-		ContentType: contentType,
-		Scheme:      storage.SigningSchemeV4,
+	if doc == nil || (doc.UserID != "" && doc.UserID != h.cfg.UserID) {
+		middleware.WriteError(w, http.StatusNotFound, "Document not found")
+		return
 	}
 
-	url, err := client.Bucket(bucket).SignedURL(object, opts)
+	found, err := h.repo.ReassignDocumentAccount(ctx, documentID, accountID)
+	if errors.Is(err, bigquery.ErrAccountNotFound) {
+		middleware.WriteError(w, http.StatusBadRequest, "Account not found")
+		return
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to generate signed URL: %w", err)
+		h.log.Error().Err(err).Str("document_id", documentID).Msg("Failed to reassign document account")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to update document")
+		return
+	}
+	if !found {
+		middleware.WriteError(w, http.StatusNotFound, "Document not found")
+		return
 	}
 
-	return url, nil
-}
+	h.log.Info().
+		Str("document_id", documentID).
+		Str("from_account_id", doc.AccountID).
+		Str("account_id", accountID).
+		Msg("Document reassigned to account")
 
-// TransactionsHandler handles transaction-related endpoints.
-type TransactionsHandler struct {
-	repo bigquery.DocumentRepository
-	log  zerolog.Logger
+	middleware.WriteJSON(w, http.StatusOK, map[string]string{
+		"document_id": documentID,
+		"account_id":  accountID,
+	})
 }
 
-// NewTransactionsHandler creates a new transactions handler.
-func NewTransactionsHandler(repo bigquery.DocumentRepository, log zerolog.Logger) *TransactionsHandler {
-	return &TransactionsHandler{
-		repo: repo,
-		log:  log,
+// GetDocument handles GET /api/documents/:documentId
+// Returns the document, including the opening and closing balances of its statement.
+func (h *DocumentsHandler) GetDocument(w http.ResponseWriter, r *http.Request, documentID string) {
+	doc, err := h.findDocument(r.Context(), documentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list documents")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
+		return
 	}
+	if doc == nil || (doc.UserID != "" && doc.UserID != h.cfg.UserID) {
+		middleware.WriteError(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, doc)
 }
 
-// ListTransactions handles GET /api/transactions
-func (h *TransactionsHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+// DownloadDocument handles GET /api/documents/:documentId/download
+// Serves the original uploaded file, either proxied or via a signed URL redirect.
+func (h *DocumentsHandler) DownloadDocument(w http.ResponseWriter, r *http.Request, documentID string) {
 	ctx := r.Context()
 
-	// Parse query parameters
-	query := r.URL.Query()
-	startDateStr := query.Get("start_date")
-	endDateStr := query.Get("end_date")
+	doc, err := h.findDocument(ctx, documentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list documents")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
+		return
+	}
+	// Documents owned by someone else are reported as missing rather than forbidden
+	// so their IDs cannot be probed.
+	if doc == nil || (doc.UserID != "" && doc.UserID != h.cfg.UserID) {
+		middleware.WriteError(w, http.StatusNotFound, "Document not found")
+		return
+	}
 
-	var startDate, endDate time.Time
-	var err error
+	if _, _, err := parseGCSURI(doc.GCSURI); err != nil {
+		h.log.Error().Err(err).Str("document_id", documentID).Msg("Document has no downloadable file")
+		middleware.WriteError(w, http.StatusNotFound, "Document file not found")
+		return
+	}
 
-	if startDateStr != "" {
-		startDate, err = time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			middleware.WriteError(w, http.StatusBadRequest, "Invalid start_date format")
-			return
-		}
-	} else {
-		startDate = time.Now().AddDate(-1, 0, 0) // 1 year ago
+	filename := sanitizeFilename(doc.OriginalFilename)
+	if filename == "" {
+		filename = "document.pdf"
 	}
 
-	if endDateStr != "" {
-		endDate, err = time.Parse("2006-01-02", endDateStr)
+	if h.cfg.DownloadMode == DownloadModeSignedURL {
+		signedURL, err := h.store.SignedURL(ctx, doc.GCSURI, &storage.SignedURLOptions{
+			Method:  http.MethodGet,
+			Expires: time.Now().Add(DownloadURLExpiry),
+			Scheme:  storage.SigningSchemeV4,
+			QueryParameters: url.Values{
+				"response-content-disposition": {contentDisposition(filename)},
+			},
+		})
 		if err != nil {
-			middleware.WriteError(w, http.StatusBadRequest, "Invalid end_date format")
+			h.log.Error().Err(err).Str("document_id", documentID).Msg("Failed to sign download URL")
+			middleware.WriteError(w, http.StatusInternalServerError, "Failed to download file")
 			return
 		}
-	} else {
-		endDate = time.Now()
+		http.Redirect(w, r, signedURL, http.StatusFound)
+		return
 	}
 
-	transactions, err := h.repo.QueryTransactionsByDateRange(ctx, startDate, endDate)
+	reader, err := h.store.OpenObject(ctx, doc.GCSURI)
+	if errors.Is(err, gcsuploader.ErrObjectNotExist) {
+		middleware.WriteError(w, http.StatusNotFound, "Document file not found")
+		return
+	}
+	if err != nil {
+		h.log.Error().Err(err).Str("gcs_uri", doc.GCSURI).Msg("Failed to open file in storage")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to download file")
+		return
+	}
+	defer reader.Close()
+
+	contentType := doc.FileMimeType
+	if contentType == "" {
+		contentType = reader.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition(filename))
+	w.Header().Set("Content-Length", strconv.FormatInt(reader.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, reader); err != nil {
+		// Headers are already sent; all we can do is log
+		h.log.Warn().Err(err).Str("document_id", documentID).Msg("Download interrupted")
+	}
+}
+
+// DiffParsingRuns handles GET /api/documents/:documentId/diff?from=runA&to=runB
+// Lists the transactions added, removed and changed between two parsing runs of a document.
+func (h *DocumentsHandler) DiffParsingRuns(w http.ResponseWriter, r *http.Request, documentID string) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	fromRunID, toRunID := query.Get("from"), query.Get("to")
+	if fromRunID == "" || toRunID == "" {
+		middleware.WriteError(w, http.StatusBadRequest, "from and to parsing run IDs are required")
+		return
+	}
+
+	doc, err := h.findDocument(ctx, documentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list documents")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve document")
+		return
+	}
+	if doc == nil || (doc.UserID != "" && doc.UserID != h.cfg.UserID) {
+		middleware.WriteError(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	diff, err := pipeline.DiffParsingRuns(ctx, h.repo, documentID, fromRunID, toRunID)
+	if errors.Is(err, pipeline.ErrParsingRunNotFound) {
+		middleware.WriteError(w, http.StatusNotFound, "Parsing run not found for this document")
+		return
+	}
+	if err != nil {
+		h.log.Error().Err(err).Str("document_id", documentID).Msg("Failed to diff parsing runs")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to compare parsing runs")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, diff)
+}
+
+// findDocument returns the document with the given ID, or nil if there is none.
+func (h *DocumentsHandler) findDocument(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+	return h.repo.GetDocument(ctx, documentID)
+}
+
+// contentDisposition builds an attachment Content-Disposition header for filename.
+func contentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// parseGCSURI splits a gs://bucket/object URI into its bucket and object name.
+func parseGCSURI(gcsURI string) (bucket, objectName string, err error) {
+	if !strings.HasPrefix(gcsURI, "gs://") {
+		return "", "", fmt.Errorf("invalid GCS URI format: %s", gcsURI)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(gcsURI, "gs://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid GCS URI format: %s", gcsURI)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// generateSignedURL generates a signed URL for uploading to GCS.
+func (h *DocumentsHandler) generateSignedURL(ctx context.Context, bucket, object, contentType string) (string, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage client: %w", err)
+	}
+	defer client.Close()
+
+	opts := &storage.SignedURLOptions{
+		Method:      "PUT",
+		Expires:     time.Now().Add(h.cfg.signedURLExpiry()),
+		ContentType: contentType,
+		Scheme:      storage.SigningSchemeV4,
+	}
+
+	url, err := client.Bucket(bucket).SignedURL(object, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signed URL: %w", err)
+	}
+
+	return url, nil
+}
+
+// DefaultTransactionWindowDays is how far back ListTransactions looks when no start_date is given.
+const DefaultTransactionWindowDays = 365
+
+// TransactionsConfig holds settings for the transactions handler.
+type TransactionsConfig struct {
+	// DefaultWindowDays is the length of the date range used when the request has no
+	// start_date. Zero means DefaultTransactionWindowDays.
+	DefaultWindowDays int
+
+	// UserID is the user whose transactions are aggregated by ListMerchants.
+	UserID string
+}
+
+// defaultWindowDays returns the configured default date range length.
+func (c TransactionsConfig) defaultWindowDays() int {
+	if c.DefaultWindowDays <= 0 {
+		return DefaultTransactionWindowDays
+	}
+	return c.DefaultWindowDays
+}
+
+// Response headers reporting the date range ListTransactions actually used.
+const (
+	StartDateHeader = "X-Start-Date"
+	EndDateHeader   = "X-End-Date"
+)
+
+// TransactionsHandler handles transaction-related endpoints.
+type TransactionsHandler struct {
+	repo bigquery.DocumentRepository
+	cfg  TransactionsConfig
+	log  zerolog.Logger
+}
+
+// NewTransactionsHandler creates a new transactions handler.
+func NewTransactionsHandler(repo bigquery.DocumentRepository, cfg TransactionsConfig, log zerolog.Logger) *TransactionsHandler {
+	return &TransactionsHandler{
+		repo: repo,
+		cfg:  cfg,
+		log:  log,
+	}
+}
+
+// ListTransactions handles GET /api/transactions
+// Without start_date the range starts DefaultWindowDays ago; without end_date it ends today.
+// all=true drops the defaults so the full history is returned. The effective range is
+// echoed in the X-Start-Date and X-End-Date headers; a missing header means unbounded.
+func (h *TransactionsHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse query parameters
+	query := r.URL.Query()
+	startDateStr := query.Get("start_date")
+	endDateStr := query.Get("end_date")
+
+	all := false
+	if allStr := query.Get("all"); allStr != "" {
+		var err error
+		all, err = strconv.ParseBool(allStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid all: must be true or false")
+			return
+		}
+	}
+
+	var startDate, endDate time.Time
+	var err error
+
+	if startDateStr != "" {
+		startDate, err = time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid start_date format")
+			return
+		}
+	} else if !all {
+		startDate = apptime.Now().AddDate(0, 0, -h.cfg.defaultWindowDays())
+	}
+
+	if endDateStr != "" {
+		endDate, err = time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid end_date format")
+			return
+		}
+	} else if !all {
+		endDate = apptime.Now()
+	}
+
+	var filter bigquery.TransactionFilter
+
+	if direction := strings.ToUpper(query.Get("direction")); direction != "" {
+		if direction != "IN" && direction != "OUT" {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid direction: must be IN or OUT")
+			return
+		}
+		filter.Direction = direction
+	}
+
+	if pendingStr := query.Get("is_pending"); pendingStr != "" {
+		isPending, err := strconv.ParseBool(pendingStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid is_pending: must be true or false")
+			return
+		}
+		filter.IsPending = &isPending
+	}
+
+	if reviewedStr := query.Get("reviewed"); reviewedStr != "" {
+		isReviewed, err := strconv.ParseBool(reviewedStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid reviewed: must be true or false")
+			return
+		}
+		filter.IsReviewed = &isReviewed
+	}
+
+	if excludeZeroStr := query.Get("exclude_zero"); excludeZeroStr != "" {
+		filter.ExcludeZeroAmount, err = strconv.ParseBool(excludeZeroStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid exclude_zero: must be true or false")
+			return
+		}
+	}
+
+	transactions, err := h.repo.QueryTransactionsWithFilter(ctx, startDate, endDate, filter)
 	if err != nil {
 		h.log.Error().Err(err).Msg("Failed to query transactions")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to query transactions")
 		return
 	}
 
+	// Report the range in headers so the body can stay a plain array
+	if !startDate.IsZero() {
+		w.Header().Set(StartDateHeader, startDate.Format("2006-01-02"))
+	}
+	if !endDate.IsZero() {
+		w.Header().Set(EndDateHeader, endDate.Format("2006-01-02"))
+	}
+
 	// Return array directly for frontend compatibility
 	if transactions == nil {
 		transactions = []*bigquery.TransactionRow{}
@@ -382,16 +1006,258 @@ func (h *TransactionsHandler) ListTransactions(w http.ResponseWriter, r *http.Re
 	middleware.WriteJSON(w, http.StatusOK, transactions)
 }
 
+// ReviewTransaction handles POST /api/transactions/:id/review
+// Marks the transaction as reviewed. A body of {"reviewed": false} marks it unreviewed again.
+func (h *TransactionsHandler) ReviewTransaction(w http.ResponseWriter, r *http.Request, transactionID string) {
+	req := struct {
+		Reviewed *bool `json:"reviewed"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	reviewed := req.Reviewed == nil || *req.Reviewed
+
+	found, err := h.repo.SetTransactionReviewed(r.Context(), transactionID, reviewed)
+	if err != nil {
+		h.log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to update review status")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to update review status")
+		return
+	}
+	if !found {
+		middleware.WriteError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"transaction_id": transactionID,
+		"is_reviewed":    reviewed,
+	})
+}
+
+// Limits on the number of merchants ListMerchants returns.
+const (
+	DefaultMerchantsLimit = 20
+	MaxMerchantsLimit     = 100
+)
+
+// ListMerchants handles GET /api/merchants?prefix=&limit=
+// Returns distinct transaction descriptions starting with prefix and how many
+// transactions carry each, most frequent first, for autocomplete.
+func (h *TransactionsHandler) ListMerchants(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := DefaultMerchantsLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > MaxMerchantsLimit {
+			middleware.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: must be between 1 and %d", MaxMerchantsLimit))
+			return
+		}
+		limit = n
+	}
+
+	merchants, err := h.repo.ListDistinctDescriptions(r.Context(), h.cfg.UserID, query.Get("prefix"), limit)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list merchants")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to list merchants")
+		return
+	}
+
+	if merchants == nil {
+		merchants = []bigquery.DescriptionCount{}
+	}
+	middleware.WriteJSON(w, http.StatusOK, merchants)
+}
+
+// maxRecategorizeIDs caps the transaction_ids accepted by a single recategorize request.
+const maxRecategorizeIDs = 1000
+
+// RecategorizeTransactions handles POST /api/transactions/recategorize
+// Assigns a category to every transaction matching the filter in a single update.
+func (h *TransactionsHandler) RecategorizeTransactions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TransactionIDs      []string `json:"transaction_ids"`
+		DescriptionContains string   `json:"description_contains"`
+		Category            string   `json:"category"`
+		Subcategory         string   `json:"subcategory"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	filter := bigquery.RecategorizeFilter{
+		UserID:              h.cfg.UserID,
+		TransactionIDs:      req.TransactionIDs,
+		DescriptionContains: strings.TrimSpace(req.DescriptionContains),
+	}
+	if filter.IsEmpty() {
+		middleware.WriteError(w, http.StatusBadRequest, "transaction_ids or description_contains is required")
+		return
+	}
+	if len(filter.TransactionIDs) > maxRecategorizeIDs {
+		middleware.WriteError(w, http.StatusBadRequest, fmt.Sprintf("At most %d transaction_ids are allowed", maxRecategorizeIDs))
+		return
+	}
+	if strings.TrimSpace(req.Category) == "" {
+		middleware.WriteError(w, http.StatusBadRequest, "category is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	validator, err := pipeline.NewCategoryValidator(ctx, h.repo, h.cfg.UserID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to load categories")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to load categories")
+		return
+	}
+
+	category, ok := validator.ExactCategory(req.Category, req.Subcategory)
+	if !ok {
+		middleware.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Invalid category/subcategory combination: %q / %q", req.Category, req.Subcategory))
+		return
+	}
+
+	updated, err := h.repo.RecategorizeTransactions(ctx, filter, category)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to recategorize transactions")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to recategorize transactions")
+		return
+	}
+
+	h.log.Info().
+		Int64("updated", updated).
+		Str("category_id", category.CategoryID).
+		Msg("Transactions recategorized")
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"updated":     updated,
+		"category_id": category.CategoryID,
+	})
+}
+
+// AccountsHandler handles account-related endpoints.
+type AccountsHandler struct {
+	repo bigquery.AccountRepository
+	log  zerolog.Logger
+}
+
+// NewAccountsHandler creates a new accounts handler.
+func NewAccountsHandler(repo bigquery.AccountRepository, log zerolog.Logger) *AccountsHandler {
+	return &AccountsHandler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// ListAccounts handles GET /api/accounts
+// Lists accounts. Document-scoped fallback accounts (account number DOC-...) are left out
+// unless ?include_synthetic=true.
+func (h *AccountsHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	includeSynthetic := false
+	if includeStr := r.URL.Query().Get("include_synthetic"); includeStr != "" {
+		var err error
+		includeSynthetic, err = strconv.ParseBool(includeStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid include_synthetic: must be true or false")
+			return
+		}
+	}
+
+	accounts, err := h.repo.ListAllAccounts(r.Context())
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list accounts")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to retrieve accounts")
+		return
+	}
+
+	result := make([]*bigquery.AccountRow, 0, len(accounts))
+	for _, account := range accounts {
+		if includeSynthetic || !isSyntheticAccount(account) {
+			result = append(result, account)
+		}
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"accounts": result,
+		"count":    len(result),
+	})
+}
+
+// isSyntheticAccount reports whether account is a fallback created for a document
+// whose account identifiers could not be extracted.
+func isSyntheticAccount(account *bigquery.AccountRow) bool {
+	return strings.HasPrefix(account.AccountNumber, infraBQ.DefaultAccountPrefix)
+}
+
+// GetBalance handles GET /api/accounts/:id/balance
+func (h *AccountsHandler) GetBalance(w http.ResponseWriter, r *http.Request, accountID string) {
+	ctx := r.Context()
+
+	asOf := apptime.Now()
+	if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+		var err error
+		asOf, err = time.Parse("2006-01-02", asOfStr)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid as_of format")
+			return
+		}
+	}
+
+	balance, err := h.repo.GetAccountBalance(ctx, accountID, asOf)
+	if err != nil {
+		h.log.Error().Err(err).Str("account_id", accountID).Msg("Failed to get account balance")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to get account balance")
+		return
+	}
+
+	if balance == nil {
+		middleware.WriteError(w, http.StatusNotFound, "No transactions found for account")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, balance)
+}
+
+// GetCoverage handles GET /api/accounts/:id/coverage
+// Lists the statement periods ingested for the account with missing months and overlaps.
+func (h *AccountsHandler) GetCoverage(w http.ResponseWriter, r *http.Request, accountID string) {
+	coverage, err := h.repo.GetAccountCoverage(r.Context(), accountID)
+	if err != nil {
+		h.log.Error().Err(err).Str("account_id", accountID).Msg("Failed to get account coverage")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to get account coverage")
+		return
+	}
+
+	if coverage == nil {
+		middleware.WriteError(w, http.StatusNotFound, "No statements found for account")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, coverage)
+}
+
+// CategoriesConfig holds settings for the categories handler.
+type CategoriesConfig struct {
+	// UserID is the user whose custom categories are merged with the global ones.
+	UserID string
+}
+
 // CategoriesHandler handles category-related endpoints.
 type CategoriesHandler struct {
 	repo bigquery.DocumentRepository
+	cfg  CategoriesConfig
 	log  zerolog.Logger
 }
 
 // NewCategoriesHandler creates a new categories handler.
-func NewCategoriesHandler(repo bigquery.DocumentRepository, log zerolog.Logger) *CategoriesHandler {
+func NewCategoriesHandler(repo bigquery.DocumentRepository, cfg CategoriesConfig, log zerolog.Logger) *CategoriesHandler {
 	return &CategoriesHandler{
 		repo: repo,
+		cfg:  cfg,
 		log:  log,
 	}
 }
@@ -400,7 +1266,7 @@ func NewCategoriesHandler(repo bigquery.DocumentRepository, log zerolog.Logger)
 func (h *CategoriesHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	categories, err := h.repo.ListActiveCategories(ctx)
+	categories, err := h.repo.ListActiveCategories(ctx, h.cfg.UserID)
 	if err != nil {
 		h.log.Error().Err(err).Msg("Failed to list categories")
 		middleware.WriteError(w, http.StatusInternalServerError, "Failed to list categories")
@@ -413,20 +1279,235 @@ func (h *CategoriesHandler) ListCategories(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+// ValidateCategories handles GET /api/categories/validate. It reports whether the
+// taxonomy is usable for parsing, as a pre-flight check before enabling ingestion.
+func (h *CategoriesHandler) ValidateCategories(w http.ResponseWriter, r *http.Request) {
+	report, err := pipeline.CheckTaxonomy(r.Context(), h.repo, h.cfg.UserID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to validate categories")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to validate categories")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, report)
+}
+
+// TagRulesConfig holds settings for the tag rules handler.
+type TagRulesConfig struct {
+	// UserID is the user whose tag rules are managed.
+	UserID string
+}
+
+// TagRulesHandler handles tag rule endpoints.
+type TagRulesHandler struct {
+	repo bigquery.DocumentRepository
+	cfg  TagRulesConfig
+	log  zerolog.Logger
+}
+
+// NewTagRulesHandler creates a new tag rules handler.
+func NewTagRulesHandler(repo bigquery.DocumentRepository, cfg TagRulesConfig, log zerolog.Logger) *TagRulesHandler {
+	return &TagRulesHandler{
+		repo: repo,
+		cfg:  cfg,
+		log:  log,
+	}
+}
+
+// tagRuleRequest is the body of tag rule create and update requests.
+type tagRuleRequest struct {
+	DescriptionPattern string   `json:"description_pattern"`
+	Merchant           string   `json:"merchant"`
+	Tags               []string `json:"tags"`
+	Priority           int64    `json:"priority"`
+	StopProcessing     bool     `json:"stop_processing"`
+}
+
+// decodeTagRule reads a tag rule request into a validated row. It writes a 400 response
+// and returns nil if the request is invalid.
+func decodeTagRule(w http.ResponseWriter, r *http.Request) *bigquery.TagRuleRow {
+	var req tagRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return nil
+	}
+
+	row := &bigquery.TagRuleRow{
+		Tags:           req.Tags,
+		Priority:       req.Priority,
+		StopProcessing: req.StopProcessing,
+	}
+	if pattern := strings.TrimSpace(req.DescriptionPattern); pattern != "" {
+		row.DescriptionPattern = bigquerylib.NullString{StringVal: pattern, Valid: true}
+	}
+	if merchant := strings.TrimSpace(req.Merchant); merchant != "" {
+		row.Merchant = bigquerylib.NullString{StringVal: merchant, Valid: true}
+	}
+	if err := pipeline.ValidateTagRule(row); err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag rule: %v", err))
+		return nil
+	}
+	return row
+}
+
+// ListTagRules handles GET /api/tag-rules
+func (h *TagRulesHandler) ListTagRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.repo.ListTagRules(r.Context(), h.cfg.UserID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list tag rules")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to list tag rules")
+		return
+	}
+
+	if rules == nil {
+		rules = []bigquery.TagRuleRow{}
+	}
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// CreateTagRule handles POST /api/tag-rules
+func (h *TagRulesHandler) CreateTagRule(w http.ResponseWriter, r *http.Request) {
+	row := decodeTagRule(w, r)
+	if row == nil {
+		return
+	}
+	row.RuleID = uuid.New().String()
+	row.UserID = h.cfg.UserID
+	row.CreatedTS = apptime.Now()
+
+	if err := h.repo.InsertTagRule(r.Context(), row); err != nil {
+		h.log.Error().Err(err).Msg("Failed to create tag rule")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to create tag rule")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusCreated, row)
+}
+
+// UpdateTagRule handles PUT /api/tag-rules/:id
+// Replaces the rule's conditions, tags, priority and stop flag.
+func (h *TagRulesHandler) UpdateTagRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	row := decodeTagRule(w, r)
+	if row == nil {
+		return
+	}
+	row.RuleID = ruleID
+	row.UserID = h.cfg.UserID
+
+	found, err := h.repo.UpdateTagRule(r.Context(), row)
+	if err != nil {
+		h.log.Error().Err(err).Str("rule_id", ruleID).Msg("Failed to update tag rule")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to update tag rule")
+		return
+	}
+	if !found {
+		middleware.WriteError(w, http.StatusNotFound, "Tag rule not found")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rule_id": ruleID,
+		"status":  "updated",
+	})
+}
+
+// DeleteTagRule handles DELETE /api/tag-rules/:id
+func (h *TagRulesHandler) DeleteTagRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	found, err := h.repo.DeleteTagRule(r.Context(), h.cfg.UserID, ruleID)
+	if err != nil {
+		h.log.Error().Err(err).Str("rule_id", ruleID).Msg("Failed to delete tag rule")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to delete tag rule")
+		return
+	}
+	if !found {
+		middleware.WriteError(w, http.StatusNotFound, "Tag rule not found")
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rule_id": ruleID,
+		"status":  "deleted",
+	})
+}
+
 // JobsHandler handles job-related endpoints.
 type JobsHandler struct {
 	store jobs.JobStore
+	queue QueueStats
 	log   zerolog.Logger
 }
 
-// NewJobsHandler creates a new jobs handler.
-func NewJobsHandler(store jobs.JobStore, log zerolog.Logger) *JobsHandler {
+// QueueStats reports the state of the job queue.
+type QueueStats interface {
+	// Depth returns the number of jobs waiting in the queue.
+	Depth() int
+	// Capacity returns the number of jobs that can wait before the queue is full.
+	Capacity() int
+	// Workers returns the number of running workers.
+	Workers() int
+	// InFlight returns the number of jobs being processed, i.e. busy workers.
+	InFlight() int
+}
+
+// NewJobsHandler creates a new jobs handler. queue may be nil, in which case the
+// stats endpoint reports only job counts.
+func NewJobsHandler(store jobs.JobStore, queue QueueStats, log zerolog.Logger) *JobsHandler {
 	return &JobsHandler{
 		store: store,
+		queue: queue,
 		log:   log,
 	}
 }
 
+// GetStats handles GET /api/stats
+// It reports the queue depth and worker saturation, and the number of jobs in each
+// status, for alerting and for deciding when to scale workers.
+func (h *JobsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	jobsList, err := h.store.ListJobs(ctx, jobs.JobFilter{})
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to list jobs")
+		middleware.WriteError(w, http.StatusInternalServerError, "Failed to list jobs")
+		return
+	}
+
+	byStatus := map[jobs.JobStatus]int{
+		jobs.JobStatusPending:   0,
+		jobs.JobStatusRunning:   0,
+		jobs.JobStatusRetrying:  0,
+		jobs.JobStatusCompleted: 0,
+		jobs.JobStatusFailed:    0,
+	}
+	for _, job := range jobsList {
+		byStatus[job.Status]++
+	}
+
+	resp := map[string]interface{}{
+		"jobs_by_status": byStatus,
+	}
+	if h.queue != nil {
+		workers, active := h.queue.Workers(), h.queue.InFlight()
+		// Fraction of workers busy; 1 with jobs waiting means workers are the bottleneck
+		saturation := 0.0
+		if workers > 0 {
+			saturation = float64(active) / float64(workers)
+		}
+		resp["queue"] = map[string]interface{}{
+			"depth":             h.queue.Depth(),
+			"capacity":          h.queue.Capacity(),
+			"workers":           workers,
+			"active_workers":    active,
+			"worker_saturation": saturation,
+		}
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
+
 // GetJob handles GET /api/jobs/{id}
 func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request, jobID string) {
 	ctx := r.Context()
@@ -452,6 +1533,32 @@ func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
 		Status:     jobs.JobStatus(query.Get("status")),
 	}
 
+	order, err := jobs.ParseJobOrder(query.Get("order"))
+	if err != nil {
+		middleware.WriteError(w, http.StatusBadRequest, "order must be created_desc or created_asc")
+		return
+	}
+	filter.Order = order
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, p.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*p.dst = t
+	}
+
 	if limitStr := query.Get("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil {
 			filter.Limit = limit
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/rs/zerolog"
+)
+
+// accountsRepo is an AccountRepository with one real and one fallback account.
+type accountsRepo struct {
+	bigquery.AccountRepository
+}
+
+func (r *accountsRepo) ListAllAccounts(ctx context.Context) ([]*bigquery.AccountRow, error) {
+	return []*bigquery.AccountRow{
+		{AccountID: "acc-1", AccountNumber: "12345678"},
+		{AccountID: "acc-2", AccountNumber: "DOC-abcdef12"},
+	}, nil
+}
+
+func TestListAccounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{name: "hides synthetic accounts", wantStatus: http.StatusOK, wantIDs: []string{"acc-1"}},
+		{name: "includes synthetic accounts", query: "?include_synthetic=true", wantStatus: http.StatusOK, wantIDs: []string{"acc-1", "acc-2"}},
+		{name: "invalid include_synthetic", query: "?include_synthetic=maybe", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewAccountsHandler(&accountsRepo{}, zerolog.Nop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/accounts"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			h.ListAccounts(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Accounts []bigquery.AccountRow `json:"accounts"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			var ids []string
+			for _, a := range body.Accounts {
+				ids = append(ids, a.AccountID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("accounts = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("accounts = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
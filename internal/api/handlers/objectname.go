@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultObjectNameTemplate is the default layout for uploaded objects in the bucket.
+const DefaultObjectNameTemplate = "uploads/{date}/{uuid}-{filename}"
+
+// objectNameParams holds the values substituted into an object-name template.
+type objectNameParams struct {
+	Date        time.Time
+	UUID        string
+	Filename    string
+	UserID      string
+	Institution string
+}
+
+// buildObjectName expands an object-name template and validates the result.
+// Supported placeholders: {date} (YYYY/MM/DD), {uuid}, {filename}, {user_id}, {institution}.
+func buildObjectName(template string, p objectNameParams) (string, error) {
+	if template == "" {
+		template = DefaultObjectNameTemplate
+	}
+
+	institution := sanitizeObjectNameComponent(strings.ToUpper(p.Institution))
+	if institution == "" {
+		institution = "UNKNOWN"
+	}
+
+	r := strings.NewReplacer(
+		"{date}", p.Date.Format("2006/01/02"),
+		"{uuid}", p.UUID,
+		"{filename}", sanitizeFilename(p.Filename),
+		"{user_id}", sanitizeObjectNameComponent(p.UserID),
+		"{institution}", institution,
+	)
+	name := r.Replace(template)
+
+	if err := validateObjectName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ValidateObjectNameTemplate checks that a template expands to a safe object name
+// and includes {uuid}, so uploads of the same file never overwrite each other.
+func ValidateObjectNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{uuid}") {
+		return fmt.Errorf("object name template must contain {uuid}: %q", template)
+	}
+	if objectNamePrefix(template) == "" {
+		return fmt.Errorf("object name template must start with a fixed directory (e.g. uploads/): %q", template)
+	}
+	_, err := buildObjectName(template, objectNameParams{
+		Date:        time.Now(),
+		UUID:        "00000000-0000-0000-0000-000000000000",
+		Filename:    "statement.pdf",
+		UserID:      "user",
+		Institution: "bank",
+	})
+	return err
+}
+
+// objectNamePrefix returns the fixed leading directory of a template (up to and
+// including the last "/" before the first placeholder), e.g. "uploads/".
+func objectNamePrefix(template string) string {
+	if template == "" {
+		template = DefaultObjectNameTemplate
+	}
+	static := template
+	if i := strings.Index(static, "{"); i >= 0 {
+		static = static[:i]
+	}
+	i := strings.LastIndex(static, "/")
+	if i < 0 {
+		return ""
+	}
+	return static[:i+1]
+}
+
+// validateUploadObjectName checks a client-supplied object name before writing to it:
+// it must be a safe object name the template could have produced for p's user and, when
+// p.Institution is set, institution. Other placeholders may hold any value.
+func validateUploadObjectName(template, name string, p objectNameParams) error {
+	if err := validateObjectName(name); err != nil {
+		return err
+	}
+	if objectNamePrefix(template) == "" || !objectNamePattern(template, p).MatchString(name) {
+		return fmt.Errorf("object name does not match the template %q: %q", template, name)
+	}
+	return nil
+}
+
+// objectNamePlaceholder matches a placeholder in an object-name template.
+var objectNamePlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// objectNamePattern returns a regexp matching the names buildObjectName expands template
+// to for p's user and, if set, p.Institution.
+func objectNamePattern(template string, p objectNameParams) *regexp.Regexp {
+	if template == "" {
+		template = DefaultObjectNameTemplate
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range objectNamePlaceholder.FindAllStringIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		last = loc[1]
+
+		switch placeholder := template[loc[0]:loc[1]]; placeholder {
+		case "{date}":
+			pattern.WriteString(`[0-9]{4}/[0-9]{2}/[0-9]{2}`)
+		case "{uuid}", "{filename}":
+			pattern.WriteString(`[^/]+`)
+		case "{user_id}":
+			pattern.WriteString(regexp.QuoteMeta(sanitizeObjectNameComponent(p.UserID)))
+		case "{institution}":
+			if p.Institution == "" {
+				pattern.WriteString(`[^/]+`)
+				break
+			}
+			pattern.WriteString(regexp.QuoteMeta(sanitizeObjectNameComponent(strings.ToUpper(p.Institution))))
+		default:
+			// Not a placeholder buildObjectName replaces
+			pattern.WriteString(regexp.QuoteMeta(placeholder))
+		}
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// sanitizeFilename reduces a client-supplied filename to its final path element,
+// treating both "/" and "\\" as separators.
+func sanitizeFilename(name string) string {
+	return sanitizeObjectNameComponent(path.Base(strings.ReplaceAll(name, "\\", "/")))
+}
+
+// sanitizeObjectNameComponent strips characters that could change the object's
+// location in the bucket when a value is substituted into a single path segment.
+func sanitizeObjectNameComponent(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case r < 0x20 || r == 0x7f:
+			return -1
+		}
+		return r
+	}, s)
+	if s == "." || s == ".." {
+		return ""
+	}
+	return s
+}
+
+// validateObjectName rejects object names that are absolute, contain traversal
+// segments, backslashes, control characters, or empty path segments.
+func validateObjectName(name string) error {
+	if name == "" {
+		return fmt.Errorf("object name is empty")
+	}
+	if strings.HasPrefix(name, "/") {
+		return fmt.Errorf("object name must not be absolute: %q", name)
+	}
+	if strings.Contains(name, "\\") {
+		return fmt.Errorf("object name must not contain backslashes: %q", name)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("object name contains control characters: %q", name)
+		}
+	}
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "":
+			return fmt.Errorf("object name contains an empty path segment: %q", name)
+		case ".", "..":
+			return fmt.Errorf("object name contains a relative path segment: %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildObjectName(t *testing.T) {
+	date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		params   objectNameParams
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "default template",
+			params: objectNameParams{Date: date, UUID: "abc", Filename: "statement.pdf"},
+			want:   "uploads/2024/03/05/abc-statement.pdf",
+		},
+		{
+			name:     "user and institution",
+			template: "uploads/{user_id}/{institution}/{date}/{uuid}-{filename}",
+			params:   objectNameParams{Date: date, UUID: "abc", Filename: "s.pdf", UserID: "denis", Institution: "barclays"},
+			want:     "uploads/denis/BARCLAYS/2024/03/05/abc-s.pdf",
+		},
+		{
+			name:     "missing institution",
+			template: "uploads/{institution}/{uuid}",
+			params:   objectNameParams{Date: date, UUID: "abc"},
+			want:     "uploads/UNKNOWN/abc",
+		},
+		{
+			name:   "filename traversal is stripped",
+			params: objectNameParams{Date: date, UUID: "abc", Filename: "../../etc/passwd"},
+			want:   "uploads/2024/03/05/abc-passwd",
+		},
+		{
+			name:     "institution traversal is neutralised",
+			template: "uploads/{institution}/{uuid}",
+			params:   objectNameParams{Date: date, UUID: "abc", Institution: "../.."},
+			want:     "uploads/.._../abc",
+		},
+		{
+			name:     "user id of dot-dot",
+			template: "uploads/{user_id}/{uuid}",
+			params:   objectNameParams{Date: date, UUID: "abc", UserID: ".."},
+			wantErr:  true,
+		},
+		{
+			name:     "absolute template",
+			template: "/uploads/{uuid}",
+			params:   objectNameParams{Date: date, UUID: "abc"},
+			wantErr:  true,
+		},
+		{
+			name:     "traversal in template",
+			template: "uploads/../{uuid}",
+			params:   objectNameParams{Date: date, UUID: "abc"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildObjectName(tt.template, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("buildObjectName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateObjectNameTemplate(t *testing.T) {
+	if err := ValidateObjectNameTemplate(DefaultObjectNameTemplate); err != nil {
+		t.Errorf("default template rejected: %v", err)
+	}
+	if err := ValidateObjectNameTemplate("uploads/{date}/{filename}"); err == nil {
+		t.Error("expected template without {uuid} to be rejected")
+	}
+	if err := ValidateObjectNameTemplate("{uuid}-{filename}"); err == nil {
+		t.Error("expected template without a fixed directory to be rejected")
+	}
+	if err := ValidateObjectNameTemplate("uploads//{uuid}"); err == nil {
+		t.Error("expected template with empty segment to be rejected")
+	}
+}
+
+func TestValidateUploadObjectNameInstitution(t *testing.T) {
+	const template = "statements/{user_id}/{institution}/{uuid}"
+	p := objectNameParams{UserID: "denis", Institution: "hsbc"}
+	if err := validateUploadObjectName(template, "statements/denis/HSBC/abc", p); err != nil {
+		t.Errorf("object under the known institution rejected: %v", err)
+	}
+	if err := validateUploadObjectName(template, "statements/denis/MONZO/abc", p); err == nil {
+		t.Error("object under another institution accepted")
+	}
+}
+
+func TestValidateUploadObjectName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		object   string
+		wantErr  bool
+	}{
+		{name: "valid default", object: "uploads/2024/03/05/abc-statement.pdf"},
+		{name: "valid custom prefix", template: "statements/{user_id}/{uuid}", object: "statements/denis/abc"},
+		{name: "another user's path", template: "statements/{user_id}/{uuid}", object: "statements/mallory/abc", wantErr: true},
+		{name: "user after date", template: "uploads/{date}/{user_id}/{uuid}", object: "uploads/2024/03/05/denis/abc"},
+		{name: "another user's path after date", template: "uploads/{date}/{user_id}/{uuid}", object: "uploads/2024/03/05/mallory/abc", wantErr: true},
+		{name: "any institution when unknown", template: "statements/{institution}/{uuid}", object: "statements/HSBC/abc"},
+		{name: "not a date", object: "uploads/denis/abc-statement.pdf", wantErr: true},
+		{name: "traversal out of prefix", object: "uploads/../secrets/key.json", wantErr: true},
+		{name: "absolute path", object: "/uploads/abc.pdf", wantErr: true},
+		{name: "other prefix", object: "processed/abc.pdf", wantErr: true},
+		{name: "prefix lookalike", object: "uploads-evil/abc.pdf", wantErr: true},
+		{name: "bare prefix", object: "uploads/", wantErr: true},
+		{name: "backslash", object: "uploads\\..\\abc.pdf", wantErr: true},
+		{name: "wrong prefix for custom template", template: "statements/{uuid}", object: "uploads/abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUploadObjectName(tt.template, tt.object, objectNameParams{UserID: "denis"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateUploadObjectName(%q) error = %v, wantErr %v", tt.object, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/rs/zerolog"
+)
+
+const uploadedDocumentID = "0b1f6a0e-4c7d-4a55-9d6c-2f0e8f3b1a11"
+
+// uploadedRepo is a DocumentRepository holding one uploaded document.
+type uploadedRepo struct {
+	bigquery.DocumentRepository
+}
+
+func (r *uploadedRepo) GetDocument(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+	if documentID != uploadedDocumentID {
+		return nil, nil
+	}
+	return &bigquery.DocumentRow{DocumentID: uploadedDocumentID, UserID: "user-1"}, nil
+}
+
+func TestRefreshUploadURL(t *testing.T) {
+	const pendingID = "6d3c1a2b-8e9f-4a0b-b1c2-d3e4f5a6b7c8"
+	h := NewDocumentsHandler(&uploadedRepo{}, nil, nil, DocumentsConfig{
+		Bucket:             "bucket",
+		UserID:             "user-1",
+		ObjectNameTemplate: DefaultObjectNameTemplate,
+	}, zerolog.Nop())
+
+	tests := []struct {
+		name       string
+		documentID string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "pending document",
+			documentID: pendingID,
+			body:       `{"object_name": "uploads/2024/01/05/abc-statement.pdf", "filename": "statement.pdf", "institution_id": "barclays"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "already uploaded",
+			documentID: uploadedDocumentID,
+			body:       `{"object_name": "uploads/2024/01/05/abc-statement.pdf", "filename": "statement.pdf"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "object outside the upload prefix",
+			documentID: pendingID,
+			body:       `{"object_name": "other/statement.pdf", "filename": "statement.pdf"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing object name",
+			documentID: pendingID,
+			body:       `{"filename": "statement.pdf"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid document ID",
+			documentID: "not-a-uuid",
+			body:       `{"object_name": "uploads/2024/01/05/abc-statement.pdf", "filename": "statement.pdf"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/documents/"+tt.documentID+"/refresh-upload-url", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.RefreshUploadURL(rec, req, tt.documentID)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp["document_id"] != pendingID || resp["gcs_uri"] != "gs://bucket/uploads/2024/01/05/abc-statement.pdf" {
+				t.Errorf("response = %v, want the same document and object", resp)
+			}
+			uploadURL, _ := resp["upload_url"].(string)
+			if !strings.HasPrefix(uploadURL, "/api/documents/upload/"+pendingID+"?") || !strings.Contains(uploadURL, "institution_id=BARCLAYS") {
+				t.Errorf("upload_url = %q", uploadURL)
+			}
+			if u, err := url.Parse(uploadURL); err != nil {
+				t.Errorf("upload_url = %q: %v", uploadURL, err)
+			} else if err := verifyUploadURL(h.cfg.UploadURLKey, pendingID, u.Query(), time.Now()); err != nil {
+				t.Errorf("upload_url = %q is not accepted: %v", uploadURL, err)
+			}
+			if _, ok := resp["expires_at"]; !ok {
+				t.Error("response has no expires_at")
+			}
+		})
+	}
+}
+
+func TestRefreshUploadURLRejectsAnotherUsersObject(t *testing.T) {
+	const pendingID = "6d3c1a2b-8e9f-4a0b-b1c2-d3e4f5a6b7c8"
+	h := NewDocumentsHandler(&uploadedRepo{}, nil, nil, DocumentsConfig{
+		Bucket:             "bucket",
+		UserID:             "user-1",
+		ObjectNameTemplate: "statements/{user_id}/{uuid}-{filename}",
+	}, zerolog.Nop())
+
+	for object, want := range map[string]int{
+		"statements/user-1/abc-statement.pdf": http.StatusOK,
+		"statements/user-2/abc-statement.pdf": http.StatusBadRequest,
+	} {
+		body := `{"object_name": "` + object + `", "filename": "statement.pdf"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/documents/"+pendingID+"/refresh-upload-url", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.RefreshUploadURL(rec, req, pendingID)
+
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d; body: %s", object, rec.Code, want, rec.Body)
+		}
+	}
+}
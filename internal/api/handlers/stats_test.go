@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/dvloznov/finance-tracker/internal/jobs/inmemory"
+	"github.com/rs/zerolog"
+)
+
+// stubQueue reports fixed queue stats.
+type stubQueue struct {
+	depth, capacity, workers, inFlight int
+}
+
+func (q stubQueue) Depth() int    { return q.depth }
+func (q stubQueue) Capacity() int { return q.capacity }
+func (q stubQueue) Workers() int  { return q.workers }
+func (q stubQueue) InFlight() int { return q.inFlight }
+
+func TestGetStats(t *testing.T) {
+	store := inmemory.NewStore()
+	for id, status := range map[string]jobs.JobStatus{
+		"job-1": jobs.JobStatusPending,
+		"job-2": jobs.JobStatusPending,
+		"job-3": jobs.JobStatusRunning,
+		"job-4": jobs.JobStatusFailed,
+	} {
+		if err := store.SaveJob(context.Background(), &jobs.ParseDocumentJob{JobID: id, Status: status}); err != nil {
+			t.Fatalf("SaveJob: %v", err)
+		}
+	}
+	h := NewJobsHandler(store, stubQueue{depth: 2, capacity: 100, workers: 5, inFlight: 4}, zerolog.Nop())
+
+	rec := httptest.NewRecorder()
+	h.GetStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		JobsByStatus map[string]int `json:"jobs_by_status"`
+		Queue        struct {
+			Depth            int     `json:"depth"`
+			Capacity         int     `json:"capacity"`
+			Workers          int     `json:"workers"`
+			ActiveWorkers    int     `json:"active_workers"`
+			WorkerSaturation float64 `json:"worker_saturation"`
+		} `json:"queue"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	wantStatus := map[string]int{"pending": 2, "running": 1, "retrying": 0, "completed": 0, "failed": 1}
+	for status, want := range wantStatus {
+		if got, ok := resp.JobsByStatus[status]; !ok || got != want {
+			t.Errorf("jobs_by_status[%s] = %d (present %v), want %d", status, got, ok, want)
+		}
+	}
+	q := resp.Queue
+	if q.Depth != 2 || q.Capacity != 100 || q.Workers != 5 || q.ActiveWorkers != 4 || q.WorkerSaturation != 0.8 {
+		t.Errorf("queue = %+v, want depth 2, capacity 100, 5 workers, 4 active, saturation 0.8", q)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+	"github.com/rs/zerolog"
+)
+
+func TestUploadAndDownloadWithLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	key := []byte("test-key")
+	repo := &uploadDedupRepo{docs: map[string]*bigquery.DocumentRow{}}
+	h := NewDocumentsHandler(repo, nil, gcsuploader.NewLocalStorageService(root), DocumentsConfig{
+		Bucket:             "bucket",
+		UserID:             "user-1",
+		ObjectNameTemplate: DefaultObjectNameTemplate,
+		UploadURLKey:       key,
+		DocumentIDMode:     pipeline.DocumentIDChecksum,
+	}, zerolog.Nop())
+
+	upload := func(objectName string) map[string]string {
+		t.Helper()
+		q := url.Values{"object_name": {objectName}, "filename": {"statement.pdf"}}
+		signUploadURLQuery(key, uploadedDocumentID, q, time.Now().Add(time.Minute))
+		req := httptest.NewRequest(http.MethodPost, "/api/documents/upload/"+uploadedDocumentID+"?"+q.Encode(), strings.NewReader("%PDF-1.4"))
+		rec := httptest.NewRecorder()
+		h.UploadDocument(rec, req, uploadedDocumentID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("upload to %s: status %d, body %s", objectName, rec.Code, rec.Body)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	exists := func(objectName string) bool {
+		_, err := os.Stat(filepath.Join(root, "bucket", filepath.FromSlash(objectName)))
+		return err == nil
+	}
+
+	const first, second = "uploads/2024/01/05/abc-statement.pdf", "uploads/2024/01/06/def-statement.pdf"
+	created := upload(first)
+	if created["status"] != "uploaded" || !exists(first) {
+		t.Fatalf("first upload = %v, stored %v", created, exists(first))
+	}
+
+	// Uploading over the existing document's own object must not delete it
+	if again := upload(first); again["status"] != "duplicate" || again["document_id"] != created["document_id"] || !exists(first) {
+		t.Errorf("upload to the same object = %v, stored %v", again, exists(first))
+	}
+	if again := upload(second); again["status"] != "duplicate" || exists(second) || !exists(first) {
+		t.Errorf("upload to another object = %v, new copy kept %v, original kept %v", again, exists(second), exists(first))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/documents/"+created["document_id"]+"/download", nil)
+	rec := httptest.NewRecorder()
+	h.DownloadDocument(rec, req, created["document_id"])
+	if rec.Code != http.StatusOK || rec.Body.String() != "%PDF-1.4" {
+		t.Errorf("download: status %d, body %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/rs/zerolog"
+)
+
+// tagRulesRepo is a DocumentRepository holding tag rules in memory.
+type tagRulesRepo struct {
+	bigquery.DocumentRepository
+	rules map[string]bigquery.TagRuleRow
+}
+
+func (r *tagRulesRepo) InsertTagRule(ctx context.Context, row *bigquery.TagRuleRow) error {
+	r.rules[row.RuleID] = *row
+	return nil
+}
+
+func (r *tagRulesRepo) UpdateTagRule(ctx context.Context, row *bigquery.TagRuleRow) (bool, error) {
+	if _, ok := r.rules[row.RuleID]; !ok {
+		return false, nil
+	}
+	r.rules[row.RuleID] = *row
+	return true, nil
+}
+
+func TestCreateTagRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "merchant rule", body: `{"merchant":"TFL","tags":["commute"," commute "]}`, wantStatus: http.StatusCreated},
+		{name: "pattern rule", body: `{"description_pattern":"^AMZN","tags":["shopping"],"priority":5,"stop_processing":true}`, wantStatus: http.StatusCreated},
+		{name: "no condition", body: `{"tags":["commute"]}`, wantStatus: http.StatusBadRequest},
+		{name: "no tags", body: `{"merchant":"TFL","tags":[]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid pattern", body: `{"description_pattern":"(","tags":["x"]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &tagRulesRepo{rules: map[string]bigquery.TagRuleRow{}}
+			h := NewTagRulesHandler(repo, TagRulesConfig{UserID: "user-1"}, zerolog.Nop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/tag-rules", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.CreateTagRule(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if len(repo.rules) != 0 {
+					t.Errorf("stored %d rules, want none", len(repo.rules))
+				}
+				return
+			}
+
+			var got bigquery.TagRuleRow
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			stored, ok := repo.rules[got.RuleID]
+			if !ok {
+				t.Fatalf("rule %q was not stored", got.RuleID)
+			}
+			if stored.UserID != "user-1" || len(stored.Tags) != 1 {
+				t.Errorf("stored rule = %+v, want user-1 with one tag", stored)
+			}
+		})
+	}
+}
+
+func TestUpdateTagRule_NotFound(t *testing.T) {
+	repo := &tagRulesRepo{rules: map[string]bigquery.TagRuleRow{}}
+	h := NewTagRulesHandler(repo, TagRulesConfig{UserID: "user-1"}, zerolog.Nop())
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tag-rules/rule-1", strings.NewReader(`{"merchant":"TFL","tags":["commute"]}`))
+	rec := httptest.NewRecorder()
+	h.UpdateTagRule(rec, req, "rule-1")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
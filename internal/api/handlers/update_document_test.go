@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/rs/zerolog"
+)
+
+// reassignRepo is a DocumentRepository with one document and one account.
+type reassignRepo struct {
+	bigquery.DocumentRepository
+	reassignedTo string
+}
+
+func (r *reassignRepo) GetDocument(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+	if documentID != "doc-1" {
+		return nil, nil
+	}
+	return &bigquery.DocumentRow{DocumentID: "doc-1", UserID: "user-1", AccountID: "DOC-default"}, nil
+}
+
+func (r *reassignRepo) ReassignDocumentAccount(ctx context.Context, documentID, accountID string) (bool, error) {
+	if accountID != "acc-1" {
+		return false, fmt.Errorf("reassign: %w", bigquery.ErrAccountNotFound)
+	}
+	r.reassignedTo = accountID
+	return true, nil
+}
+
+func TestUpdateDocument(t *testing.T) {
+	tests := []struct {
+		name       string
+		documentID string
+		body       string
+		wantStatus int
+		wantMoved  bool
+	}{
+		{name: "reassigns account", documentID: "doc-1", body: `{"account_id":"acc-1"}`, wantStatus: http.StatusOK, wantMoved: true},
+		{name: "unknown account", documentID: "doc-1", body: `{"account_id":"acc-2"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing account_id", documentID: "doc-1", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", documentID: "doc-1", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "unknown document", documentID: "doc-2", body: `{"account_id":"acc-1"}`, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &reassignRepo{}
+			h := NewDocumentsHandler(repo, nil, nil, DocumentsConfig{UserID: "user-1"}, zerolog.Nop())
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/documents/"+tt.documentID, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.UpdateDocument(rec, req, tt.documentID)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if moved := repo.reassignedTo != ""; moved != tt.wantMoved {
+				t.Errorf("reassigned = %v, want %v", moved, tt.wantMoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Errors returned by verifyUploadURL.
+var (
+	errUploadURLSignature = errors.New("invalid upload URL signature")
+	errUploadURLExpired   = errors.New("upload URL has expired")
+)
+
+// Query parameters that sign a direct upload URL.
+const (
+	uploadURLExpiresParam   = "expires"
+	uploadURLSignatureParam = "signature"
+)
+
+// newUploadURLKey returns a random key for signing upload URLs.
+func newUploadURLKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("handlers: generating upload URL key: " + err.Error())
+	}
+	return key
+}
+
+// uploadURLSignature returns the signature of an upload URL for documentID with the
+// given query, which must not contain the signature itself.
+func uploadURLSignature(key []byte, documentID string, query url.Values) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(documentID + "?" + query.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signUploadURLQuery adds the expiry and signature to the query of an upload URL for
+// documentID.
+func signUploadURLQuery(key []byte, documentID string, query url.Values, expiresAt time.Time) {
+	query.Set(uploadURLExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set(uploadURLSignatureParam, uploadURLSignature(key, documentID, query))
+}
+
+// verifyUploadURL checks that query was signed for documentID by signUploadURLQuery and
+// has not expired at now.
+func verifyUploadURL(key []byte, documentID string, query url.Values, now time.Time) error {
+	signature := query.Get(uploadURLSignatureParam)
+	unsigned := url.Values{}
+	for k, v := range query {
+		if k != uploadURLSignatureParam {
+			unsigned[k] = v
+		}
+	}
+	want := uploadURLSignature(key, documentID, unsigned)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return errUploadURLSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get(uploadURLExpiresParam), 10, 64)
+	if err != nil {
+		return errUploadURLSignature
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return errUploadURLExpired
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestVerifyUploadURL(t *testing.T) {
+	key := []byte("test-key")
+	now := time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)
+	signed := func() url.Values {
+		q := url.Values{"object_name": {"uploads/2024-01-05/abc-statement.pdf"}, "filename": {"statement.pdf"}}
+		signUploadURLQuery(key, uploadedDocumentID, q, now.Add(15*time.Minute))
+		return q
+	}
+
+	tests := []struct {
+		name       string
+		documentID string
+		query      func() url.Values
+		key        []byte
+		now        time.Time
+		want       error
+	}{
+		{name: "valid", documentID: uploadedDocumentID, query: signed, key: key, now: now},
+		{name: "expired", documentID: uploadedDocumentID, query: signed, key: key, now: now.Add(16 * time.Minute), want: errUploadURLExpired},
+		{name: "other document", documentID: "6d3c1a2b-8e9f-4a0b-b1c2-d3e4f5a6b7c8", query: signed, key: key, now: now, want: errUploadURLSignature},
+		{name: "other key", documentID: uploadedDocumentID, query: signed, key: []byte("other"), now: now, want: errUploadURLSignature},
+		{
+			name:       "altered object name",
+			documentID: uploadedDocumentID,
+			query: func() url.Values {
+				q := signed()
+				q.Set("object_name", "uploads/2024-01-05/other.pdf")
+				return q
+			},
+			key:  key,
+			now:  now,
+			want: errUploadURLSignature,
+		},
+		{
+			name:       "extended expiry",
+			documentID: uploadedDocumentID,
+			query: func() url.Values {
+				q := signed()
+				q.Set(uploadURLExpiresParam, "9999999999")
+				return q
+			},
+			key:  key,
+			now:  now,
+			want: errUploadURLSignature,
+		},
+		{
+			name:       "unsigned",
+			documentID: uploadedDocumentID,
+			query: func() url.Values {
+				return url.Values{"object_name": {"uploads/2024-01-05/abc-statement.pdf"}}
+			},
+			key:  key,
+			now:  now,
+			want: errUploadURLSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyUploadURL(tt.key, tt.documentID, tt.query(), tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("verifyUploadURL() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadDocumentRejectsExpiredURL(t *testing.T) {
+	key := []byte("test-key")
+	h := NewDocumentsHandler(&uploadedRepo{}, nil, nil, DocumentsConfig{
+		Bucket:             "bucket",
+		UserID:             "user-1",
+		ObjectNameTemplate: DefaultObjectNameTemplate,
+		UploadURLKey:       key,
+	}, zerolog.Nop())
+
+	q := url.Values{"object_name": {"uploads/2024-01-05/abc-statement.pdf"}, "filename": {"statement.pdf"}}
+	signUploadURLQuery(key, uploadedDocumentID, q, time.Now().Add(-time.Minute))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/upload/"+uploadedDocumentID+"?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+	h.UploadDocument(rec, req, uploadedDocumentID)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+}
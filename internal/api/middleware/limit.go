@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ConcurrencyLimit lets at most max requests through the wrapped routes at once, e.g. to
+// bound the uploads streaming to GCS. Requests beyond the limit are not queued: they get
+// 503 Service Unavailable with a Retry-After header of retryAfter. A max of 0 or less
+// disables the limit.
+func ConcurrencyLimit(max int, retryAfter time.Duration) func(http.Handler) http.Handler {
+	if max <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	sem := semaphore.NewWeighted(int64(max))
+	retrySeconds := strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !sem.TryAcquire(1) {
+				w.Header().Set("Retry-After", retrySeconds)
+				WriteError(w, http.StatusServiceUnavailable, "Server busy, retry later")
+				return
+			}
+			defer sem.Release(1)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AllowMethods reports whether the request method is one of methods, in which case the
+// caller should handle the request. Otherwise it answers the request itself: OPTIONS gets
+// 204 No Content and any other method 405 Method Not Allowed, both with an Allow header
+// listing the supported methods. HEAD is supported wherever GET is; the server discards
+// the body of responses to HEAD requests.
+func AllowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	allowed := make([]string, 0, len(methods)+2)
+	for _, m := range methods {
+		if m == r.Method {
+			return true
+		}
+		allowed = append(allowed, m)
+		if m == http.MethodGet {
+			if r.Method == http.MethodHead {
+				return true
+			}
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	allowed = append(allowed, http.MethodOptions)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	return false
+}
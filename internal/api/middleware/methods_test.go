@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowMethods(t *testing.T) {
+	tests := []struct {
+		method     string
+		allowed    []string
+		wantOK     bool
+		wantStatus int
+		wantAllow  string
+	}{
+		{method: http.MethodGet, allowed: []string{http.MethodGet}, wantOK: true},
+		{method: http.MethodHead, allowed: []string{http.MethodGet}, wantOK: true},
+		{method: http.MethodHead, allowed: []string{http.MethodPost}, wantStatus: http.StatusMethodNotAllowed, wantAllow: "POST, OPTIONS"},
+		{method: http.MethodDelete, allowed: []string{http.MethodGet}, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{method: http.MethodOptions, allowed: []string{http.MethodPost, http.MethodPut}, wantStatus: http.StatusNoContent, wantAllow: "POST, PUT, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/api/documents", nil)
+
+			ok := AllowMethods(w, r, tt.allowed...)
+			if ok != tt.wantOK {
+				t.Fatalf("AllowMethods = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCORSAnswersOnlyPreflights(t *testing.T) {
+	var reached bool
+	h := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		AllowMethods(w, r, http.MethodGet)
+	}))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/jobs", nil)
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, preflight)
+	if reached || w.Code != http.StatusNoContent {
+		t.Errorf("preflight: reached route = %v, status = %d; want answered by CORS with 204", reached, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/api/jobs", nil))
+	if !reached || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("OPTIONS: reached route = %v, Allow = %q; want the route's Allow header", reached, w.Header().Get("Allow"))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -31,15 +32,17 @@ func Logger(log zerolog.Logger) func(http.Handler) http.Handler {
 	}
 }
 
-// CORS adds Cross-Origin Resource Sharing headers.
+// CORS adds Cross-Origin Resource Sharing headers and answers preflight requests.
+// Other OPTIONS requests reach the route, which reports its methods in an Allow header.
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Timezone, X-Start-Date, X-End-Date")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
-		if r.Method == http.MethodOptions {
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -83,6 +86,63 @@ func RequestID(next http.Handler) http.Handler {
 	})
 }
 
+// Timezone reports the application timezone used for calendar dates in the
+// X-Timezone response header, so clients know how default date ranges were computed.
+func Timezone(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Timezone", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Deadlines replaces the server-wide read and write timeouts for the wrapped routes,
+// e.g. to give large uploads longer than the default. A zero duration removes the deadline.
+func Deadlines(log zerolog.Logger, read, write time.Duration) func(http.Handler) http.Handler {
+	deadline := func(d time.Duration) time.Time {
+		if d <= 0 {
+			return time.Time{}
+		}
+		return time.Now().Add(d)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(deadline(read)); err != nil {
+				log.Warn().Err(err).Str("path", r.URL.Path).Msg("Failed to set read deadline")
+			}
+			if err := rc.SetWriteDeadline(deadline(write)); err != nil {
+				log.Warn().Err(err).Str("path", r.URL.Path).Msg("Failed to set write deadline")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PrettyJSON makes WriteJSON indent its output when the request has ?pretty=true.
+// It must wrap the handlers directly so they receive its response writer.
+func PrettyJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+			w = &prettyWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prettyWriter marks a response whose JSON body should be indented.
+type prettyWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (pw *prettyWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
 // Auth is a placeholder for authentication middleware.
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -102,17 +162,27 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Context key for request ID.
 type contextKey string
 
 const requestIDKey contextKey = "requestID"
 
-// WriteJSON writes a JSON response.
+// WriteJSON writes a JSON response. Output is compact unless the request
+// went through PrettyJSON with ?pretty=true.
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if data != nil {
-		json.NewEncoder(w).Encode(data)
+		enc := json.NewEncoder(w)
+		if _, ok := w.(*prettyWriter); ok {
+			enc.SetIndent("", "  ")
+		}
+		enc.Encode(data)
 	}
 }
 
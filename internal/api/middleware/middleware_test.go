@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestDeadlinesOverrideServerWriteTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/default", slow)
+	mux.Handle("/extended", Deadlines(zerolog.Nop(), time.Second, time.Second)(slow))
+
+	srv := httptest.NewUnstartedServer(Logger(zerolog.Nop())(mux))
+	srv.Config.WriteTimeout = 20 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := get("/default"); err == nil && body == "done" {
+		t.Error("expected the server write timeout to abort /default")
+	}
+	if body, err := get("/extended"); err != nil || body != "done" {
+		t.Errorf("/extended = %q, %v; want done", body, err)
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	handler := PrettyJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]int{"count": 1})
+	}))
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "{\"count\":1}\n"},
+		{"?pretty=false", "{\"count\":1}\n"},
+		{"?pretty=true", "{\n  \"count\": 1\n}\n"},
+		{"?pretty=1", "{\n  \"count\": 1\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/documents"+tt.query, nil))
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+	handler := ConcurrencyLimit(1, 1500*time.Millisecond)(blocking)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/upload", nil))
+		close(done)
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/upload", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while saturated = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	close(release)
+	<-done
+
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/upload", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status after release = %d, want 200", rec.Code)
+	}
+}
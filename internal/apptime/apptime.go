@@ -0,0 +1,70 @@
+// Package apptime holds the application timezone, used wherever an instant is turned
+// into a calendar date (default date ranges, "as of today", upload folders).
+package apptime
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+	_ "time/tzdata" // containers may ship without a zoneinfo database
+
+	"cloud.google.com/go/civil"
+)
+
+const (
+	// Env is the environment variable holding the IANA timezone name.
+	Env = "APP_TIMEZONE"
+
+	// DefaultTimezone is used when APP_TIMEZONE is unset. Statements are from UK banks.
+	DefaultTimezone = "Europe/London"
+)
+
+var location atomic.Pointer[time.Location]
+
+func init() {
+	// An invalid APP_TIMEZONE is reported when the command calls Configure
+	if err := Configure(""); err != nil {
+		if err := Configure(DefaultTimezone); err != nil {
+			location.Store(time.UTC)
+		}
+	}
+}
+
+// Configure sets the application timezone from an IANA name such as "Europe/London".
+// An empty name falls back to APP_TIMEZONE and then DefaultTimezone.
+func Configure(name string) error {
+	if name == "" {
+		name = os.Getenv(Env)
+	}
+	if name == "" {
+		name = DefaultTimezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("apptime: invalid timezone %q: %w", name, err)
+	}
+	location.Store(loc)
+	return nil
+}
+
+// Location returns the application timezone.
+func Location() *time.Location {
+	return location.Load()
+}
+
+// Now returns the current time in the application timezone.
+func Now() time.Time {
+	return time.Now().In(Location())
+}
+
+// DateOf returns the calendar date of t in the application timezone.
+func DateOf(t time.Time) civil.Date {
+	return civil.DateOf(t.In(Location()))
+}
+
+// Today returns the current calendar date in the application timezone.
+func Today() civil.Date {
+	return DateOf(time.Now())
+}
@@ -0,0 +1,48 @@
+package apptime
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestDateOf(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultTimezone) })
+
+	// 23:30 UTC during British Summer Time is already the next day in London
+	instant := time.Date(2024, time.July, 31, 23, 30, 0, 0, time.UTC)
+
+	if err := Configure("Europe/London"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := DateOf(instant), (civil.Date{Year: 2024, Month: time.August, Day: 1}); got != want {
+		t.Errorf("DateOf() in London = %s, want %s", got, want)
+	}
+
+	if err := Configure("UTC"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := DateOf(instant), (civil.Date{Year: 2024, Month: time.July, Day: 31}); got != want {
+		t.Errorf("DateOf() in UTC = %s, want %s", got, want)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultTimezone) })
+
+	t.Setenv(Env, "America/New_York")
+	if err := Configure(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Location().String(); got != "America/New_York" {
+		t.Errorf("Location() = %s, want America/New_York from %s", got, Env)
+	}
+
+	if err := Configure("Not/AZone"); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+	if got := Location().String(); got != "America/New_York" {
+		t.Errorf("failed Configure changed Location() to %s", got)
+	}
+}
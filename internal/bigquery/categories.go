@@ -0,0 +1,45 @@
+package bigquery
+
+import "strings"
+
+// MergeUserCategories merges a user's custom categories into the global defaults. rows
+// holds global rows (no user_id) and the rows of a single user. A user row replaces the
+// global row with the same category and subcategory name, compared case-insensitively,
+// in the global row's position; other user rows are appended in their original order.
+func MergeUserCategories(rows []CategoryRow) []CategoryRow {
+	merged := make([]CategoryRow, 0, len(rows))
+	index := make(map[string]int, len(rows))
+
+	for _, row := range rows {
+		if row.UserID.Valid && row.UserID.StringVal != "" {
+			continue
+		}
+		key := categoryKey(row)
+		if _, ok := index[key]; ok {
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, row)
+	}
+
+	for _, row := range rows {
+		if !row.UserID.Valid || row.UserID.StringVal == "" {
+			continue
+		}
+		key := categoryKey(row)
+		if i, ok := index[key]; ok {
+			merged[i] = row
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, row)
+	}
+
+	return merged
+}
+
+// categoryKey identifies a category/subcategory pair regardless of case and spacing.
+func categoryKey(row CategoryRow) string {
+	return strings.ToUpper(strings.TrimSpace(row.CategoryName)) + "|" +
+		strings.ToUpper(strings.TrimSpace(row.SubcategoryName.StringVal))
+}
@@ -0,0 +1,45 @@
+package bigquery
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestMergeUserCategories(t *testing.T) {
+	sub := func(s string) bigquery.NullString { return bigquery.NullString{StringVal: s, Valid: true} }
+	user := sub("alice")
+
+	rows := []CategoryRow{
+		{CategoryID: "food-groceries", CategoryName: "Food & Dining", SubcategoryName: sub("Groceries")},
+		{CategoryID: "food-restaurants", CategoryName: "Food & Dining", SubcategoryName: sub("Restaurants")},
+		{CategoryID: "healthcare", CategoryName: "Healthcare"},
+		// Overrides the global row despite the different case
+		{CategoryID: "alice-groceries", CategoryName: "food & dining", SubcategoryName: sub("groceries"), UserID: user},
+		// Only exists for the user
+		{CategoryID: "alice-hobbies", CategoryName: "Hobbies", SubcategoryName: sub("Climbing"), UserID: user},
+	}
+
+	got := MergeUserCategories(rows)
+
+	want := []string{"alice-groceries", "food-restaurants", "healthcare", "alice-hobbies"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d categories, want %d: %+v", len(got), len(want), got)
+	}
+	for i, id := range want {
+		if got[i].CategoryID != id {
+			t.Errorf("category %d = %s, want %s", i, got[i].CategoryID, id)
+		}
+	}
+}
+
+func TestMergeUserCategories_GlobalOnly(t *testing.T) {
+	rows := []CategoryRow{
+		{CategoryID: "healthcare", CategoryName: "Healthcare"},
+		{CategoryID: "housing-rent", CategoryName: "Housing", SubcategoryName: bigquery.NullString{StringVal: "Rent", Valid: true}},
+	}
+	got := MergeUserCategories(rows)
+	if len(got) != 2 || got[0].CategoryID != "healthcare" || got[1].CategoryID != "housing-rent" {
+		t.Errorf("got %+v, want the global rows unchanged", got)
+	}
+}
@@ -0,0 +1,85 @@
+package bigquery
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DocumentStatus is the parsing_status of a document.
+type DocumentStatus string
+
+const (
+	// DocumentStatusPending is set when a document is registered and waits to be parsed.
+	DocumentStatusPending DocumentStatus = "PENDING"
+	// DocumentStatusProcessing is set while a parsing run for the document is in progress.
+	DocumentStatusProcessing DocumentStatus = "PROCESSING"
+	// DocumentStatusCompleted is set when a parsing run has stored its transactions.
+	DocumentStatusCompleted DocumentStatus = "COMPLETED"
+	// DocumentStatusFailed is set when parsing the document failed.
+	DocumentStatusFailed DocumentStatus = "FAILED"
+	// DocumentStatusSynced is set by external syncs once a completed document's
+	// transactions have been exported.
+	DocumentStatusSynced DocumentStatus = "SYNCED"
+)
+
+// DocumentStatuses lists the known document statuses in lifecycle order.
+var DocumentStatuses = []DocumentStatus{
+	DocumentStatusPending,
+	DocumentStatusProcessing,
+	DocumentStatusCompleted,
+	DocumentStatusFailed,
+	DocumentStatusSynced,
+}
+
+// ErrInvalidStatusTransition is returned when a document cannot move from its current
+// status to the requested one.
+var ErrInvalidStatusTransition = errors.New("invalid document status transition")
+
+// documentTransitions lists the statuses each status may move to. Reprocessing moves
+// a document back to PROCESSING from any settled status.
+var documentTransitions = map[DocumentStatus][]DocumentStatus{
+	DocumentStatusPending:    {DocumentStatusProcessing, DocumentStatusFailed},
+	DocumentStatusProcessing: {DocumentStatusProcessing, DocumentStatusCompleted, DocumentStatusFailed},
+	DocumentStatusCompleted:  {DocumentStatusProcessing, DocumentStatusSynced},
+	DocumentStatusFailed:     {DocumentStatusProcessing},
+	DocumentStatusSynced:     {DocumentStatusProcessing},
+}
+
+// Valid reports whether s is one of the known document statuses.
+func (s DocumentStatus) Valid() bool {
+	_, ok := documentTransitions[s]
+	return ok
+}
+
+// AllowedFrom returns the statuses a document may move to s from.
+func (s DocumentStatus) AllowedFrom() []DocumentStatus {
+	var from []DocumentStatus
+	for _, st := range DocumentStatuses {
+		for _, to := range documentTransitions[st] {
+			if to == s {
+				from = append(from, st)
+				break
+			}
+		}
+	}
+	return from
+}
+
+// ValidateDocumentTransition returns an error wrapping ErrInvalidStatusTransition unless
+// a document may move from one status to the other. Documents with an empty or unknown
+// status, written before statuses were enforced, may move to any known status.
+func ValidateDocumentTransition(from, to DocumentStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, to)
+	}
+	allowed, ok := documentTransitions[from]
+	if !ok {
+		return nil
+	}
+	for _, s := range allowed {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+}
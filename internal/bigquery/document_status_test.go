@@ -0,0 +1,45 @@
+package bigquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDocumentTransition(t *testing.T) {
+	tests := []struct {
+		from, to DocumentStatus
+		ok       bool
+	}{
+		{DocumentStatusPending, DocumentStatusProcessing, true},
+		{DocumentStatusProcessing, DocumentStatusCompleted, true},
+		{DocumentStatusProcessing, DocumentStatusFailed, true},
+		{DocumentStatusCompleted, DocumentStatusSynced, true},
+		{DocumentStatusFailed, DocumentStatusProcessing, true},
+		{DocumentStatusSynced, DocumentStatusProcessing, true},
+		{"", DocumentStatusCompleted, true},
+		{"PARSED", DocumentStatusFailed, true},
+		{DocumentStatusPending, DocumentStatusCompleted, false},
+		{DocumentStatusPending, DocumentStatusSynced, false},
+		{DocumentStatusCompleted, DocumentStatusFailed, false},
+		{DocumentStatusSynced, DocumentStatusPending, false},
+		{DocumentStatusPending, "DONE", false},
+	}
+	for _, tt := range tests {
+		err := ValidateDocumentTransition(tt.from, tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("%q -> %q: unexpected error %v", tt.from, tt.to, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidStatusTransition) {
+			t.Errorf("%q -> %q: error = %v, want ErrInvalidStatusTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestDocumentStatusAllowedFrom(t *testing.T) {
+	got := DocumentStatusFailed.AllowedFrom()
+	want := []DocumentStatus{DocumentStatusPending, DocumentStatusProcessing}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AllowedFrom(FAILED) = %v, want %v", got, want)
+	}
+}
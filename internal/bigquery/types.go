@@ -3,8 +3,10 @@ package bigquery
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/bigquery"
@@ -16,7 +18,12 @@ type DocumentRepository interface {
 	// InsertDocument inserts a single DocumentRow into the database.
 	InsertDocument(ctx context.Context, row *DocumentRow) error
 
-	// InsertTransactions inserts a batch of TransactionRow into the database.
+	// InsertDocumentIfAbsent inserts a single DocumentRow unless a document with the
+	// same document_id exists, and reports whether it was inserted.
+	InsertDocumentIfAbsent(ctx context.Context, row *DocumentRow) (bool, error)
+
+	// InsertTransactions inserts a batch of TransactionRow into the database, skipping
+	// rows whose transaction_id already exists.
 	InsertTransactions(ctx context.Context, rows []*TransactionRow) error
 
 	// InsertModelOutput inserts a single ModelOutputRow into the database.
@@ -31,28 +38,115 @@ type DocumentRepository interface {
 	// MarkParsingRunSucceeded sets status=SUCCESS and finished_ts for a parsing run.
 	MarkParsingRunSucceeded(ctx context.Context, parsingRunID string) error
 
-	// ListActiveCategories retrieves all active categories from the database.
-	ListActiveCategories(ctx context.Context) ([]CategoryRow, error)
+	// ListActiveCategories retrieves the active global categories merged with the
+	// custom categories of userID, as MergeUserCategories does. An empty userID
+	// returns only the global categories.
+	ListActiveCategories(ctx context.Context, userID string) ([]CategoryRow, error)
 
 	// QueryTransactionsByDateRange queries transactions within the specified date range.
 	QueryTransactionsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]*TransactionRow, error)
 
+	// QueryTransactionsWithFilter queries transactions within the date range that match the filter.
+	QueryTransactionsWithFilter(ctx context.Context, startDate, endDate time.Time, filter TransactionFilter) ([]*TransactionRow, error)
+
+	// QueryTransactions returns the transactions matching q.
+	QueryTransactions(ctx context.Context, q TransactionQuery) ([]*TransactionRow, error)
+
 	// ListAllAccounts retrieves all accounts from the database.
 	ListAllAccounts(ctx context.Context) ([]*AccountRow, error)
 
 	// ListAllDocuments retrieves all documents from the database.
 	ListAllDocuments(ctx context.Context) ([]*DocumentRow, error)
 
+	// ListAllDocumentsWithStats retrieves all documents with the transaction totals of their active parsing run.
+	ListAllDocumentsWithStats(ctx context.Context) ([]*DocumentWithStats, error)
+
+	// GetDocument retrieves a document by its ID, or nil if it does not exist.
+	GetDocument(ctx context.Context, documentID string) (*DocumentRow, error)
+
 	// FindDocumentByChecksum retrieves a document by its SHA-256 checksum.
 	FindDocumentByChecksum(ctx context.Context, checksum string) (*DocumentRow, error)
 
 	// MarkParsingRunsAsSuperseded marks all non-running parsing runs for a document as SUPERSEDED.
 	MarkParsingRunsAsSuperseded(ctx context.Context, documentID string) error
+
+	// UpdateDocumentParsingStatus updates the parsing_status field for a document.
+	UpdateDocumentParsingStatus(ctx context.Context, documentID, status string) error
+
+	// TransitionDocumentStatus moves a document to the given status. It returns an error
+	// wrapping ErrInvalidStatusTransition if the document's current status does not allow it.
+	TransitionDocumentStatus(ctx context.Context, documentID string, to DocumentStatus) error
+
+	// UpdateDocumentInstitution sets the institution_id of a document.
+	UpdateDocumentInstitution(ctx context.Context, documentID, institutionID string) error
+
+	// UpdateDocumentMetadata replaces the metadata JSON of a document.
+	UpdateDocumentMetadata(ctx context.Context, documentID string, metadata bigquery.NullJSON) error
+
+	// UpdateDocumentBalances sets the opening and closing balances of a document; nil clears a balance.
+	UpdateDocumentBalances(ctx context.Context, documentID string, opening, closing *big.Rat) error
+
+	// GetLatestModelOutput returns the most recently stored model output for a document, or nil if none exists.
+	GetLatestModelOutput(ctx context.Context, documentID string) (*ModelOutputRow, error)
+
+	// ListModelOutputsByParsingRun returns the model outputs stored for a parsing run, newest first.
+	ListModelOutputsByParsingRun(ctx context.Context, parsingRunID string) ([]*ModelOutputRow, error)
+
+	// FindParsingRunAccountID returns the account_id of a parsing run's transactions, or "" if it has none.
+	FindParsingRunAccountID(ctx context.Context, parsingRunID string) (string, error)
+
+	// FindParsingRun returns the parsing run with the given ID, or nil if there is none.
+	FindParsingRun(ctx context.Context, parsingRunID string) (*ParsingRunRow, error)
+
+	// FlagParsingRunForReview marks a parsing run as needing manual review for the given reasons.
+	FlagParsingRunForReview(ctx context.Context, parsingRunID string, reasons []string) error
+
+	// SetTransactionReviewed marks a transaction as reviewed or unreviewed. It returns
+	// false if no transaction of a successful parsing run has that ID.
+	SetTransactionReviewed(ctx context.Context, transactionID string, reviewed bool) (bool, error)
+
+	// RecategorizeTransactions assigns category to all transactions matching the filter
+	// and returns the number of transactions updated.
+	RecategorizeTransactions(ctx context.Context, filter RecategorizeFilter, category CategoryRow) (int64, error)
+
+	// ListDocumentCategoryAssignments returns the distinct category assignments of the
+	// transactions of a document's successful parsing runs.
+	ListDocumentCategoryAssignments(ctx context.Context, documentID string) ([]CategoryAssignment, error)
+
+	// ReassignDocumentAccount moves a document and all its transactions to another account.
+	// It returns false if the document does not exist, and an error wrapping
+	// ErrAccountNotFound if the account does not.
+	ReassignDocumentAccount(ctx context.Context, documentID, accountID string) (bool, error)
+
+	// UpdateDocumentCategoryIDs sets the category_id of the document's transactions whose
+	// category and subcategory names match an assignment, and returns the number updated.
+	UpdateDocumentCategoryIDs(ctx context.Context, documentID string, assignments []CategoryAssignment) (int64, error)
+
+	// ListDistinctDescriptions returns up to limit distinct descriptions of the user's
+	// transactions starting with prefix (case-insensitively), most frequent first.
+	ListDistinctDescriptions(ctx context.Context, userID, prefix string, limit int) ([]DescriptionCount, error)
+
+	// ListTagRules returns the tag rules of userID ordered by priority, then rule ID.
+	ListTagRules(ctx context.Context, userID string) ([]TagRuleRow, error)
+
+	// InsertTagRule inserts a tag rule.
+	InsertTagRule(ctx context.Context, row *TagRuleRow) error
+
+	// UpdateTagRule replaces the conditions, tags and precedence of the user's tag rule
+	// with row.RuleID. It returns false if the user has no such rule.
+	UpdateTagRule(ctx context.Context, row *TagRuleRow) (bool, error)
+
+	// DeleteTagRule deletes the user's tag rule. It returns false if there is no such rule.
+	DeleteTagRule(ctx context.Context, userID, ruleID string) (bool, error)
+
+	// UpdateTransactionTags sets the tags of transactions of successful parsing runs and
+	// returns the number of transactions updated.
+	UpdateTransactionTags(ctx context.Context, updates []TransactionTags) (int64, error)
 }
 
 // AccountRepository provides an interface for account-related database operations.
 type AccountRepository interface {
-	// UpsertAccount finds an existing account by (account_number, currency) or creates a new one.
+	// UpsertAccount finds an existing account by IBAN, sort code and account number, or creates a new one.
 	UpsertAccount(ctx context.Context, row *AccountRow) (string, error)
 
 	// FindAccountByNumberAndCurrency finds an account by normalized account_number and currency.
@@ -60,12 +154,26 @@ type AccountRepository interface {
 
 	// ListAllAccounts retrieves all accounts from the database.
 	ListAllAccounts(ctx context.Context) ([]*AccountRow, error)
+
+	// GetAccountBalance returns the account's balance as of the given date,
+	// or nil if the account has no transactions on or before that date.
+	GetAccountBalance(ctx context.Context, accountID string, asOf time.Time) (*AccountBalance, error)
+
+	// MergeAccount moves all transactions and documents from one account to another
+	// and retires the source account.
+	MergeAccount(ctx context.Context, fromAccountID, toAccountID string) error
+
+	// GetAccountCoverage returns the statement periods ingested for the account with
+	// the months no statement covers and the periods covered by more than one statement.
+	GetAccountCoverage(ctx context.Context, accountID string) (*AccountCoverage, error)
 }
 
 // CategoryRepository provides an interface for category-related database operations.
 type CategoryRepository interface {
-	// ListActiveCategories retrieves all active categories from the database.
-	ListActiveCategories(ctx context.Context) ([]CategoryRow, error)
+	// ListActiveCategories retrieves the active global categories merged with the
+	// custom categories of userID, as MergeUserCategories does. An empty userID
+	// returns only the global categories.
+	ListActiveCategories(ctx context.Context, userID string) ([]CategoryRow, error)
 }
 
 // DocumentRow represents a document record in BigQuery.
@@ -83,10 +191,15 @@ type DocumentRow struct {
 	StatementStartDate bigquery.NullDate `bigquery:"statement_start_date" json:"statement_start_date,omitempty"`
 	StatementEndDate   bigquery.NullDate `bigquery:"statement_end_date" json:"statement_end_date,omitempty"`
 
+	// OpeningBalance and ClosingBalance are the balances the statement prints for the
+	// start and end of its period, nil if it shows none.
+	OpeningBalance *big.Rat `bigquery:"opening_balance" json:"opening_balance,omitempty"`
+	ClosingBalance *big.Rat `bigquery:"closing_balance" json:"closing_balance,omitempty"`
+
 	UploadTS    time.Time              `bigquery:"upload_ts" json:"upload_ts"`
 	ProcessedTS bigquery.NullTimestamp `bigquery:"processed_ts" json:"processed_ts,omitempty"`
 
-	ParsingStatus string `bigquery:"parsing_status" json:"parsing_status"`
+	ParsingStatus DocumentStatus `bigquery:"parsing_status" json:"parsing_status"`
 
 	OriginalFilename string `bigquery:"original_filename" json:"original_filename"`
 	FileMimeType     string `bigquery:"file_mime_type" json:"file_mime_type,omitempty"`
@@ -98,6 +211,214 @@ type DocumentRow struct {
 	Metadata bigquery.NullJSON `bigquery:"metadata" json:"metadata,omitempty"`
 }
 
+// DocumentWithStats is a document together with totals of the transactions produced
+// by its active (successful) parsing run. Documents without one have zero totals.
+type DocumentWithStats struct {
+	DocumentRow
+
+	TransactionCount int64 `bigquery:"transaction_count" json:"transaction_count"`
+
+	// TotalIn is the sum of positive amounts.
+	TotalIn *big.Rat `bigquery:"total_in" json:"total_in"`
+
+	// TotalOut is the sum of negative amounts, as a positive number.
+	TotalOut *big.Rat `bigquery:"total_out" json:"total_out"`
+}
+
+// MarshalJSON customizes JSON serialization for DocumentRow.
+func (d DocumentRow) MarshalJSON() ([]byte, error) {
+	type Alias DocumentRow
+	return json.Marshal(&struct {
+		OpeningBalance *string `json:"opening_balance,omitempty"`
+		ClosingBalance *string `json:"closing_balance,omitempty"`
+		*Alias
+	}{
+		OpeningBalance: decimalString(d.OpeningBalance),
+		ClosingBalance: decimalString(d.ClosingBalance),
+		Alias:          (*Alias)(&d),
+	})
+}
+
+// decimalString formats a money amount exactly, to the NUMERIC scale, with trailing
+// zeros dropped down to two decimal places. It returns nil for nil.
+func decimalString(r *big.Rat) *string {
+	if r == nil {
+		return nil
+	}
+	s := r.FloatString(bigquery.NumericScaleDigits)
+	s = strings.TrimRight(s, "0")
+	if dot := strings.IndexByte(s, '.'); len(s)-dot-1 < 2 {
+		s += strings.Repeat("0", 2-(len(s)-dot-1))
+	}
+	return &s
+}
+
+// MarshalJSON customizes JSON serialization for DocumentWithStats. The embedded
+// DocumentRow's MarshalJSON would be promoted over the stats fields, so the document
+// and its stats are encoded separately and merged into one object.
+func (d DocumentWithStats) MarshalJSON() ([]byte, error) {
+	format := func(r *big.Rat) string {
+		if r == nil {
+			return "0.00"
+		}
+		f, _ := r.Float64()
+		return fmt.Sprintf("%.2f", f)
+	}
+	doc, err := json.Marshal(d.DocumentRow)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := json.Marshal(&struct {
+		TransactionCount int64  `json:"transaction_count"`
+		TotalIn          string `json:"total_in"`
+		TotalOut         string `json:"total_out"`
+	}{
+		TransactionCount: d.TransactionCount,
+		TotalIn:          format(d.TotalIn),
+		TotalOut:         format(d.TotalOut),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append(doc[:len(doc)-1], ','), stats[1:]...), nil
+}
+
+// TransactionFilter narrows a transaction query beyond its date range.
+// Zero values mean "no filter".
+type TransactionFilter struct {
+	// Direction restricts results to "IN" or "OUT". Rows with a NULL direction
+	// are matched by the sign of their amount.
+	Direction string
+
+	// IsPending restricts results to pending (true) or settled (false) transactions.
+	// Rows with a NULL is_pending are treated as settled.
+	IsPending *bool
+
+	// IsReviewed restricts results to reviewed (true) or unreviewed (false) transactions.
+	// Rows with a NULL is_reviewed are treated as unreviewed.
+	IsReviewed *bool
+
+	// ExcludeZeroAmount drops transactions with a zero amount, such as "balance
+	// brought forward" lines.
+	ExcludeZeroAmount bool
+}
+
+// Date columns a TransactionQuery can filter and sort on.
+const (
+	DateFieldTransaction = "transaction_date"
+	DateFieldPosting     = "posting_date"
+	DateFieldBooking     = "booking_datetime"
+)
+
+// Columns a TransactionQuery can be sorted by, in addition to the date fields.
+const (
+	SortByAmount  = "amount"
+	SortByCreated = "created_ts"
+)
+
+// TransactionQuery describes a transaction search. Zero values mean "no filter";
+// set fields are combined with AND.
+type TransactionQuery struct {
+	// StartDate and EndDate bound DateField, inclusive. A zero time leaves that end open.
+	StartDate time.Time
+	EndDate   time.Time
+
+	// DateField is the date column the range applies to. Defaults to DateFieldTransaction.
+	// Rows with a NULL in the chosen column never match a date range.
+	DateField string
+
+	// AccountID restricts results to one account.
+	AccountID string
+
+	// DocumentID restricts results to one document.
+	DocumentID string
+
+	// ParsingRunID restricts results to one parsing run. Unlike other queries, which
+	// only see successful runs, it matches the run whatever its status, so superseded
+	// runs can be compared with the current one.
+	ParsingRunID string
+
+	// Category and Subcategory match category_name and subcategory_name case-insensitively.
+	Category    string
+	Subcategory string
+
+	// Direction restricts results to "IN" or "OUT". Rows with a NULL direction
+	// are matched by the sign of their amount.
+	Direction string
+
+	// IsPending restricts results to pending (true) or settled (false) transactions.
+	// Rows with a NULL is_pending are treated as settled.
+	IsPending *bool
+
+	// IsReviewed restricts results to reviewed (true) or unreviewed (false) transactions.
+	// Rows with a NULL is_reviewed are treated as unreviewed.
+	IsReviewed *bool
+
+	// Tags matches transactions carrying every one of these tags.
+	Tags []string
+
+	// ExcludeZeroAmount drops transactions with a zero amount.
+	ExcludeZeroAmount bool
+
+	// Text matches transactions whose raw or normalized description contains it,
+	// case-insensitively.
+	Text string
+
+	// Limit caps the number of rows returned; 0 means no limit. Offset skips rows
+	// and is only honoured together with Limit.
+	Limit  int
+	Offset int
+
+	// SortBy is a date field, SortByAmount or SortByCreated. Defaults to DateField.
+	// Ties are broken by created_ts and then transaction_id so pages are stable.
+	SortBy     string
+	Descending bool
+
+	// PageSize is the number of rows fetched from BigQuery per request while reading
+	// the results. It bounds memory when streaming; 0 uses DefaultReadPageSize.
+	PageSize int
+}
+
+// DefaultReadPageSize is the number of rows fetched per request when streaming query
+// results and no page size is configured.
+const DefaultReadPageSize = 1000
+
+// RecategorizeFilter selects the transactions a bulk recategorization applies to.
+// UserID and at least one other field must be set; set fields are combined with AND.
+type RecategorizeFilter struct {
+	// UserID restricts the update to this user's transactions. It is required.
+	UserID string
+
+	// TransactionIDs restricts the update to these transactions.
+	TransactionIDs []string
+
+	// DescriptionContains matches transactions whose description contains this text,
+	// case-insensitively.
+	DescriptionContains string
+}
+
+// IsEmpty reports whether the filter selects nothing in particular within the user's
+// transactions.
+func (f RecategorizeFilter) IsEmpty() bool {
+	return len(f.TransactionIDs) == 0 && f.DescriptionContains == ""
+}
+
+// CategoryAssignment is a category/subcategory name pair of stored transactions with the
+// category_id assigned to them.
+type CategoryAssignment struct {
+	CategoryName     string `bigquery:"category_name" json:"category_name"`
+	SubcategoryName  string `bigquery:"subcategory_name" json:"subcategory_name"`
+	CategoryID       string `bigquery:"category_id" json:"category_id"`
+	TransactionCount int64  `bigquery:"transaction_count" json:"transaction_count"`
+}
+
+// DescriptionCount is a distinct transaction description with the number of
+// transactions carrying it.
+type DescriptionCount struct {
+	Description      string `bigquery:"description" json:"description"`
+	TransactionCount int64  `bigquery:"transaction_count" json:"transaction_count"`
+}
+
 // TransactionRow represents a transaction record in BigQuery.
 type TransactionRow struct {
 	TransactionID string `bigquery:"transaction_id" json:"transaction_id"`
@@ -117,6 +438,11 @@ type TransactionRow struct {
 
 	BalanceAfter *big.Rat `bigquery:"balance_after" json:"balance_after,omitempty"`
 
+	// OriginalAmount and OriginalCurrency hold the foreign-currency amount of a converted
+	// transaction; Amount is in the account currency.
+	OriginalAmount   *big.Rat            `bigquery:"original_amount" json:"original_amount,omitempty"`
+	OriginalCurrency bigquery.NullString `bigquery:"original_currency" json:"original_currency,omitempty"`
+
 	Direction bigquery.NullString `bigquery:"direction" json:"direction,omitempty"`
 
 	RawDescription        string              `bigquery:"raw_description" json:"raw_description"`
@@ -135,6 +461,10 @@ type TransactionRow struct {
 	IsSplitParent      bigquery.NullBool `bigquery:"is_split_parent" json:"is_split_parent,omitempty"`
 	IsSplitChild       bigquery.NullBool `bigquery:"is_split_child" json:"is_split_child,omitempty"`
 
+	// IsReviewed is set once a user has confirmed the transaction; ReviewedTS records when.
+	IsReviewed bigquery.NullBool      `bigquery:"is_reviewed" json:"is_reviewed,omitempty"`
+	ReviewedTS bigquery.NullTimestamp `bigquery:"reviewed_ts" json:"reviewed_ts,omitempty"`
+
 	ExternalReference bigquery.NullString `bigquery:"external_reference" json:"external_reference,omitempty"`
 
 	Tags []string `bigquery:"tags" json:"tags,omitempty"`
@@ -147,8 +477,9 @@ type TransactionRow struct {
 func (t TransactionRow) MarshalJSON() ([]byte, error) {
 	type Alias TransactionRow
 	return json.Marshal(&struct {
-		Amount       string  `json:"amount"`
-		BalanceAfter *string `json:"balance_after,omitempty"`
+		Amount         string  `json:"amount"`
+		BalanceAfter   *string `json:"balance_after,omitempty"`
+		OriginalAmount *string `json:"original_amount,omitempty"`
 		*Alias
 	}{
 		Amount: func() string {
@@ -166,66 +497,184 @@ func (t TransactionRow) MarshalJSON() ([]byte, error) {
 			s := fmt.Sprintf("%.2f", f)
 			return &s
 		}(),
+		OriginalAmount: func() *string {
+			if t.OriginalAmount == nil {
+				return nil
+			}
+			f, _ := t.OriginalAmount.Float64()
+			s := fmt.Sprintf("%.2f", f)
+			return &s
+		}(),
 		Alias: (*Alias)(&t),
 	})
 }
 
+// ErrAccountNotFound is returned when an operation refers to an account that does not exist.
+var ErrAccountNotFound = errors.New("account not found")
+
 // AccountRow represents an account record in BigQuery.
 type AccountRow struct {
-	AccountID string `bigquery:"account_id"`
+	AccountID string `bigquery:"account_id" json:"account_id"`
+
+	UserID        string `bigquery:"user_id" json:"user_id"`
+	InstitutionID string `bigquery:"institution_id" json:"institution_id"`
+	AccountName   string `bigquery:"account_name" json:"account_name"`
+	AccountNumber string `bigquery:"account_number" json:"account_number"`
+	// AccountNumberLast4 holds the last four digits of the account number. Statements
+	// that show only those are matched on them when no full number is available.
+	AccountNumberLast4 string `bigquery:"account_number_last4" json:"account_number_last4"`
+	SortCode           string `bigquery:"sort_code" json:"sort_code"`
+	IBAN               string `bigquery:"iban" json:"iban"`
+	Currency           string `bigquery:"currency" json:"currency"`
+	AccountType        string `bigquery:"account_type" json:"account_type"`
+
+	OpenedDate bigquery.NullDate      `bigquery:"opened_date" json:"opened_date,omitempty"`
+	ClosedDate bigquery.NullDate      `bigquery:"closed_date" json:"closed_date,omitempty"`
+	IsPrimary  bigquery.NullBool      `bigquery:"is_primary" json:"is_primary,omitempty"`
+	Metadata   bigquery.NullJSON      `bigquery:"metadata" json:"metadata,omitempty"`
+	CreatedTS  bigquery.NullTimestamp `bigquery:"created_ts" json:"created_ts,omitempty"`
+	UpdatedTS  bigquery.NullTimestamp `bigquery:"updated_ts" json:"updated_ts,omitempty"`
+}
+
+// AccountBalance is an account's balance as of a given date.
+type AccountBalance struct {
+	AccountID string     `json:"account_id"`
+	AsOf      civil.Date `json:"as_of"`
+	Currency  string     `json:"currency"`
+
+	Balance *big.Rat `json:"balance"`
+
+	// LastTransactionDate is the date of the latest transaction on or before AsOf.
+	LastTransactionDate civil.Date `json:"last_transaction_date"`
 
-	UserID        string `bigquery:"user_id"`
-	InstitutionID string `bigquery:"institution_id"`
-	AccountName   string `bigquery:"account_name"`
-	AccountNumber string `bigquery:"account_number"`
-	SortCode      string `bigquery:"sort_code"`
-	IBAN          string `bigquery:"iban"`
-	Currency      string `bigquery:"currency"`
-	AccountType   string `bigquery:"account_type"`
+	// StatementBalanceDate is the date of the statement balance (balance_after) the
+	// result is anchored on. It is null when no transaction carried a balance.
+	StatementBalanceDate bigquery.NullDate `json:"statement_balance_date,omitempty"`
 
-	OpenedDate bigquery.NullDate      `bigquery:"opened_date"`
-	ClosedDate bigquery.NullDate      `bigquery:"closed_date"`
-	IsPrimary  bigquery.NullBool      `bigquery:"is_primary"`
-	Metadata   bigquery.NullJSON      `bigquery:"metadata"`
-	CreatedTS  bigquery.NullTimestamp `bigquery:"created_ts"`
-	UpdatedTS  bigquery.NullTimestamp `bigquery:"updated_ts"`
+	// DocumentID is the document that provided the anchoring statement balance.
+	DocumentID string `json:"document_id,omitempty"`
+
+	// Reconstructed is true when transaction amounts were summed on top of the
+	// statement balance (or from zero, if there was none) to reach AsOf.
+	Reconstructed bool `json:"reconstructed"`
+}
+
+// MarshalJSON customizes JSON serialization for AccountBalance.
+func (b AccountBalance) MarshalJSON() ([]byte, error) {
+	type Alias AccountBalance
+	return json.Marshal(&struct {
+		Balance string `json:"balance"`
+		*Alias
+	}{
+		Balance: func() string {
+			if b.Balance == nil {
+				return "0"
+			}
+			f, _ := b.Balance.Float64()
+			return fmt.Sprintf("%.2f", f)
+		}(),
+		Alias: (*Alias)(&b),
+	})
 }
 
 // CategoryRow represents a denormalized category-subcategory pair.
 type CategoryRow struct {
-	CategoryID      string              `bigquery:"category_id"`
-	CategoryName    string              `bigquery:"category_name"`
-	SubcategoryName bigquery.NullString `bigquery:"subcategory_name"`
+	CategoryID      string              `bigquery:"category_id" json:"category_id"`
+	CategoryName    string              `bigquery:"category_name" json:"category_name"`
+	SubcategoryName bigquery.NullString `bigquery:"subcategory_name" json:"subcategory_name,omitempty"`
 
-	Slug string `bigquery:"slug"`
+	// UserID scopes a custom category to one user; NULL for the global defaults.
+	UserID bigquery.NullString `bigquery:"user_id" json:"user_id,omitempty"`
 
-	Description bigquery.NullString `bigquery:"description"`
-	IsActive    bigquery.NullBool   `bigquery:"is_active"`
+	Slug string `bigquery:"slug" json:"slug"`
 
-	CreatedTS bigquery.NullTimestamp `bigquery:"created_ts"`
-	RetiredTS bigquery.NullTimestamp `bigquery:"retired_ts"`
+	Description bigquery.NullString `bigquery:"description" json:"description,omitempty"`
+	IsActive    bigquery.NullBool   `bigquery:"is_active" json:"is_active,omitempty"`
 
-	Metadata bigquery.NullJSON `bigquery:"metadata"`
+	CreatedTS bigquery.NullTimestamp `bigquery:"created_ts" json:"created_ts,omitempty"`
+	RetiredTS bigquery.NullTimestamp `bigquery:"retired_ts" json:"retired_ts,omitempty"`
+
+	Metadata bigquery.NullJSON `bigquery:"metadata" json:"metadata,omitempty"`
+}
+
+// TagRuleRow represents a row of the tag_rules table. Transactions matching every
+// condition the rule sets get its tags.
+type TagRuleRow struct {
+	RuleID string `bigquery:"rule_id" json:"rule_id"`
+	UserID string `bigquery:"user_id" json:"user_id"`
+
+	// DescriptionPattern is a regular expression matched case-insensitively against the
+	// raw description.
+	DescriptionPattern bigquery.NullString `bigquery:"description_pattern" json:"description_pattern,omitempty"`
+
+	// Merchant matches transactions whose normalized description (or raw description,
+	// if there is none) contains it, case-insensitively.
+	Merchant bigquery.NullString `bigquery:"merchant" json:"merchant,omitempty"`
+
+	Tags []string `bigquery:"tags" json:"tags"`
+
+	// Priority orders the rules, lowest first. When a rule with StopProcessing matches,
+	// the rules after it are not applied.
+	Priority       int64 `bigquery:"priority" json:"priority"`
+	StopProcessing bool  `bigquery:"stop_processing" json:"stop_processing"`
+
+	CreatedTS time.Time              `bigquery:"created_ts" json:"created_ts"`
+	UpdatedTS bigquery.NullTimestamp `bigquery:"updated_ts" json:"updated_ts,omitempty"`
+}
+
+// TransactionTags is the new set of tags of a transaction.
+type TransactionTags struct {
+	TransactionID string   `bigquery:"transaction_id" json:"transaction_id"`
+	Tags          []string `bigquery:"tags" json:"tags"`
 }
 
 // ParsingRunRow represents a parsing run record in BigQuery.
 type ParsingRunRow struct {
-	ParsingRunID string `bigquery:"parsing_run_id"`
-	DocumentID   string `bigquery:"document_id"`
+	ParsingRunID string `bigquery:"parsing_run_id" json:"parsing_run_id"`
+	DocumentID   string `bigquery:"document_id" json:"document_id"`
 
-	StartedTS  time.Time              `bigquery:"started_ts"`
-	FinishedTS bigquery.NullTimestamp `bigquery:"finished_ts"`
+	StartedTS  time.Time              `bigquery:"started_ts" json:"started_ts"`
+	FinishedTS bigquery.NullTimestamp `bigquery:"finished_ts" json:"finished_ts,omitempty"`
 
-	ParserType    string `bigquery:"parser_type"`
-	ParserVersion string `bigquery:"parser_version"`
+	ParserType    string `bigquery:"parser_type" json:"parser_type"`
+	ParserVersion string `bigquery:"parser_version" json:"parser_version"`
 
-	Status       string `bigquery:"status"`
-	ErrorMessage string `bigquery:"error_message"`
+	Status       string `bigquery:"status" json:"status"`
+	ErrorMessage string `bigquery:"error_message" json:"error_message"`
 
-	TokensInput  bigquery.NullInt64 `bigquery:"tokens_input"`
-	TokensOutput bigquery.NullInt64 `bigquery:"tokens_output"`
+	TokensInput  bigquery.NullInt64 `bigquery:"tokens_input" json:"tokens_input,omitempty"`
+	TokensOutput bigquery.NullInt64 `bigquery:"tokens_output" json:"tokens_output,omitempty"`
 
-	Metadata bigquery.NullJSON `bigquery:"metadata"`
+	Metadata bigquery.NullJSON `bigquery:"metadata" json:"metadata,omitempty"`
+}
+
+// StatementPeriod is the date range covered by one ingested statement.
+type StatementPeriod struct {
+	DocumentID string     `bigquery:"document_id" json:"document_id"`
+	StartDate  civil.Date `bigquery:"start_date" json:"start_date"`
+	EndDate    civil.Date `bigquery:"end_date" json:"end_date"`
+}
+
+// CoverageGap is a date range, between the first and last statement of an account, that
+// no statement covers.
+type CoverageGap struct {
+	StartDate civil.Date `json:"start_date"`
+	EndDate   civil.Date `json:"end_date"`
+}
+
+// CoverageOverlap is a date range covered by more than one statement.
+type CoverageOverlap struct {
+	StartDate   civil.Date `json:"start_date"`
+	EndDate     civil.Date `json:"end_date"`
+	DocumentIDs []string   `json:"document_ids"`
+}
+
+// AccountCoverage describes which periods of an account's history have been ingested.
+type AccountCoverage struct {
+	AccountID string            `json:"account_id"`
+	Periods   []StatementPeriod `json:"periods"`
+	Gaps      []CoverageGap     `json:"gaps"`
+	Overlaps  []CoverageOverlap `json:"overlaps"`
 }
 
 // ModelOutputRow represents a model output record in BigQuery.
@@ -244,4 +693,9 @@ type ModelOutputRow struct {
 	Notes     bigquery.NullString    `bigquery:"notes"`
 
 	Metadata bigquery.NullJSON `bigquery:"metadata"`
+
+	// ArchiveURI is where RawJSON and ExtractedText were archived when the output was pruned.
+	ArchiveURI bigquery.NullString `bigquery:"archive_uri"`
+	// PrunedTS is set once RawJSON and ExtractedText have been cleared.
+	PrunedTS bigquery.NullTimestamp `bigquery:"pruned_ts"`
 }
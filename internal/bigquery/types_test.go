@@ -0,0 +1,89 @@
+package bigquery
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestDocumentWithStatsMarshalJSON(t *testing.T) {
+	doc := DocumentWithStats{
+		DocumentRow:      DocumentRow{DocumentID: "doc-1", ParsingStatus: "PARSED"},
+		TransactionCount: 3,
+		TotalIn:          big.NewRat(2500, 1),
+		TotalOut:         big.NewRat(12345, 100),
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := map[string]interface{}{
+		"document_id":       "doc-1",
+		"parsing_status":    "PARSED",
+		"transaction_count": float64(3),
+		"total_in":          "2500.00",
+		"total_out":         "123.45",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestDocumentRowMarshalJSON_Balances(t *testing.T) {
+	doc := DocumentWithStats{
+		DocumentRow: DocumentRow{
+			DocumentID:     "doc-1",
+			OpeningBalance: big.NewRat(-12345, 100),
+			ClosingBalance: big.NewRat(1000, 1),
+		},
+		TransactionCount: 2,
+	}
+	bhd := DocumentRow{DocumentID: "doc-3", OpeningBalance: big.NewRat(-1235, 1000), ClosingBalance: big.NewRat(12345678901234567, 1000)}
+
+	for _, v := range []interface{}{doc, doc.DocumentRow} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if got["opening_balance"] != "-123.45" || got["closing_balance"] != "1000.00" || got["document_id"] != "doc-1" {
+			t.Errorf("%T: got %s", v, b)
+		}
+	}
+
+	// Balances are not rounded to two decimal places
+	b, _ := json.Marshal(bhd)
+	var exact map[string]interface{}
+	_ = json.Unmarshal(b, &exact)
+	if exact["opening_balance"] != "-1.235" || exact["closing_balance"] != "12345678901234.567" {
+		t.Errorf("three-decimal balances: got %s", b)
+	}
+
+	// The JSON decodes back into the same balances
+	b, _ = json.Marshal(doc.DocumentRow)
+	var back DocumentRow
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatalf("Unmarshal into DocumentRow: %v", err)
+	}
+	if back.OpeningBalance == nil || back.OpeningBalance.Cmp(doc.OpeningBalance) != 0 {
+		t.Errorf("opening balance round-tripped to %v", back.OpeningBalance)
+	}
+
+	b, _ = json.Marshal(DocumentRow{DocumentID: "doc-2"})
+	var got map[string]interface{}
+	_ = json.Unmarshal(b, &got)
+	if _, ok := got["opening_balance"]; ok {
+		t.Errorf("document without balances: got %s", b)
+	}
+}
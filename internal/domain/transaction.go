@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"math/big"
 	"time"
 )
 
@@ -12,11 +13,22 @@ import (
 type Transaction struct {
 	Date         time.Time // parsed from "date" (YYYY-MM-DD)
 	Description  string    // from "description"
-	Amount       float64   // from "amount" (IN = positive, OUT = negative)
+	Amount       *big.Rat  // from "amount" (IN = positive, OUT = negative)
 	Currency     string    // from "currency"
-	BalanceAfter *float64  // from "balance_after" or nil
+	BalanceAfter *big.Rat  // from "balance_after" or nil
+
+	// Foreign-currency transactions keep the amount before conversion. Both are set or neither.
+	OriginalAmount   *big.Rat // from "original_amount", signed like Amount
+	OriginalCurrency string   // from "original_currency"
 
 	Category    string // from "category" (kept for backward compatibility)
 	Subcategory string // from "subcategory" (kept for backward compatibility)
 	CategoryID  string // populated during validation - links to categories table
+
+	StatementLineNo *int64 // from "statement_line_no" or nil (provenance within the statement)
+	StatementPageNo *int64 // from "statement_page_no" or nil (1-based PDF page)
+
+	BookingDatetime *time.Time // from "booking_datetime" or nil, in the application timezone
+
+	Tags []string // added during post-processing, e.g. "zero_amount"
 }
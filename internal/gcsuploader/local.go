@@ -0,0 +1,245 @@
+package gcsuploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// Storage backends selectable with StorageBackendEnv.
+const (
+	StorageBackendGCS   = "gcs"
+	StorageBackendLocal = "local"
+)
+
+// StorageBackendEnv names the environment variable selecting the storage backend:
+// StorageBackendGCS (the default) or StorageBackendLocal.
+const StorageBackendEnv = "STORAGE_BACKEND"
+
+// LocalStorageRootEnv names the environment variable holding the root directory of the
+// local storage backend.
+const LocalStorageRootEnv = "LOCAL_STORAGE_ROOT"
+
+// NewStorageServiceFromEnv returns the storage service selected by StorageBackendEnv.
+func NewStorageServiceFromEnv() (StorageService, error) {
+	store, err := NewObjectStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// LocalStorageService is an ObjectStore that keeps files on the local disk, for
+// development and tests without GCS. Objects of gs://bucket/object URIs are stored at
+// Root/bucket/object, so URIs look the same as with GCS. file:// URIs are read directly
+// if they point inside Root; nothing outside Root is ever read, written or deleted.
+type LocalStorageService struct {
+	// Root is the directory holding one subdirectory per bucket. When empty, no URI
+	// can be accessed.
+	Root string
+}
+
+// NewLocalStorageService creates a LocalStorageService storing objects under root.
+func NewLocalStorageService(root string) *LocalStorageService {
+	return &LocalStorageService{Root: root}
+}
+
+// UploadFile copies a local file to Root/bucketName/objectName.
+func (s *LocalStorageService) UploadFile(ctx context.Context, bucketName, objectName, filePath string) error {
+	dest, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return fmt.Errorf("LocalStorageService.UploadFile: %w", err)
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("open file %q: %w", filePath, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("LocalStorageService.UploadFile: %w", err)
+	}
+	dst, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("LocalStorageService.UploadFile: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("LocalStorageService.UploadFile: copying: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("LocalStorageService.UploadFile: %w", err)
+	}
+	return nil
+}
+
+// FetchFromGCS reads the file behind a gs:// or file:// URI.
+func (s *LocalStorageService) FetchFromGCS(ctx context.Context, uri string) ([]byte, error) {
+	path, err := s.pathOf(uri)
+	if err != nil {
+		return nil, fmt.Errorf("LocalStorageService.FetchFromGCS: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LocalStorageService.FetchFromGCS: %w", err)
+	}
+	return data, nil
+}
+
+// ExtractFilenameFromGCSURI extracts the filename from a gs:// or file:// URI.
+func (s *LocalStorageService) ExtractFilenameFromGCSURI(uri string) string {
+	if path, ok := LocalPathFromURI(uri); ok {
+		return filepath.Base(path)
+	}
+	return ExtractFilenameFromGCSURI(uri)
+}
+
+// WriteObject stores the contents of r at Root/bucketName/objectName. The content type
+// is not kept.
+func (s *LocalStorageService) WriteObject(ctx context.Context, bucketName, objectName, contentType string, r io.Reader) (int64, error) {
+	dest, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return 0, fmt.Errorf("LocalStorageService.WriteObject: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return 0, fmt.Errorf("LocalStorageService.WriteObject: %w", err)
+	}
+	dst, err := os.Create(dest)
+	if err != nil {
+		return 0, fmt.Errorf("LocalStorageService.WriteObject: %w", err)
+	}
+	written, err := io.Copy(dst, r)
+	if err != nil {
+		dst.Close()
+		return written, fmt.Errorf("LocalStorageService.WriteObject: copying: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return written, fmt.Errorf("LocalStorageService.WriteObject: %w", err)
+	}
+	return written, nil
+}
+
+// OpenObject opens the file behind a gs:// or file:// URI. Its content type is guessed
+// from the file extension.
+func (s *LocalStorageService) OpenObject(ctx context.Context, uri string) (*Object, error) {
+	path, err := s.pathOf(uri)
+	if err != nil {
+		return nil, fmt.Errorf("LocalStorageService.OpenObject: %w", err)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LocalStorageService.OpenObject: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("LocalStorageService.OpenObject: %w", err)
+	}
+	return &Object{
+		ReadCloser:  f,
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		Size:        info.Size(),
+	}, nil
+}
+
+// DeleteObject deletes the file behind a gs:// or file:// URI.
+func (s *LocalStorageService) DeleteObject(ctx context.Context, uri string) error {
+	path, err := s.pathOf(uri)
+	if err != nil {
+		return fmt.Errorf("LocalStorageService.DeleteObject: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrObjectNotExist
+		}
+		return fmt.Errorf("LocalStorageService.DeleteObject: %w", err)
+	}
+	return nil
+}
+
+// SignedURL returns ErrSignedURLUnsupported: local files have no URL of their own.
+func (s *LocalStorageService) SignedURL(ctx context.Context, uri string, opts *storage.SignedURLOptions) (string, error) {
+	return "", ErrSignedURLUnsupported
+}
+
+// pathOf returns the local path of a gs:// or file:// URI, rejecting paths outside Root.
+func (s *LocalStorageService) pathOf(uri string) (string, error) {
+	if path, ok := LocalPathFromURI(uri); ok {
+		return s.confine(path)
+	}
+	if !strings.HasPrefix(uri, "gs://") {
+		return "", fmt.Errorf("invalid storage URI: %s", uri)
+	}
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(uri, "gs://"), "/")
+	if !ok {
+		return "", fmt.Errorf("invalid GCS URI (no object path): %s", uri)
+	}
+	return s.objectPath(bucket, object)
+}
+
+// objectPath returns where an object is stored, rejecting names that would escape Root.
+func (s *LocalStorageService) objectPath(bucket, object string) (string, error) {
+	if s.Root == "" {
+		return "", fmt.Errorf("no root directory configured for gs://%s/%s", bucket, object)
+	}
+	if bucket == "" || object == "" {
+		return "", fmt.Errorf("bucket and object name are required")
+	}
+	rel := filepath.Join(bucket, filepath.FromSlash(object))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("object path %q escapes the storage root", bucket+"/"+object)
+	}
+	return filepath.Join(s.Root, rel), nil
+}
+
+// confine returns the cleaned absolute form of path if it lies inside Root.
+func (s *LocalStorageService) confine(path string) (string, error) {
+	if s.Root == "" {
+		return "", fmt.Errorf("no root directory configured for %s", path)
+	}
+	root, err := filepath.Abs(s.Root)
+	if err != nil {
+		return "", fmt.Errorf("resolving storage root: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q is outside the storage root", path)
+	}
+	return abs, nil
+}
+
+// LocalPathFromURI returns the local path of a file:// URI and whether uri is one.
+func LocalPathFromURI(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// FileURI returns the file:// URI of the absolute form of path.
+func FileURI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", path, err)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
@@ -0,0 +1,164 @@
+package gcsuploader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageServiceRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "statement.pdf")
+	if err := os.WriteFile(src, []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewLocalStorageService(t.TempDir())
+	if err := s.UploadFile(ctx, "bucket", "uploads/statement.pdf", src); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	data, err := s.FetchFromGCS(ctx, "gs://bucket/uploads/statement.pdf")
+	if err != nil {
+		t.Fatalf("FetchFromGCS: %v", err)
+	}
+	if string(data) != "%PDF-1.4" {
+		t.Errorf("FetchFromGCS() = %q", data)
+	}
+	if got := s.ExtractFilenameFromGCSURI("gs://bucket/uploads/statement.pdf"); got != "statement.pdf" {
+		t.Errorf("ExtractFilenameFromGCSURI() = %q, want statement.pdf", got)
+	}
+}
+
+func TestLocalStorageServiceFileURI(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a b.pdf")
+	if err := os.WriteFile(path, []byte("pdf"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	uri, err := FileURI(path)
+	if err != nil {
+		t.Fatalf("FileURI: %v", err)
+	}
+
+	s := NewLocalStorageService(root)
+	data, err := s.FetchFromGCS(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("FetchFromGCS(%s): %v", uri, err)
+	}
+	if string(data) != "pdf" {
+		t.Errorf("FetchFromGCS() = %q", data)
+	}
+	if got := s.ExtractFilenameFromGCSURI(uri); got != "a b.pdf" {
+		t.Errorf("ExtractFilenameFromGCSURI() = %q, want %q", got, "a b.pdf")
+	}
+
+	if _, err := NewLocalStorageService("").FetchFromGCS(context.Background(), uri); err == nil {
+		t.Error("FetchFromGCS read a file:// URI without a root directory")
+	}
+	if _, err := NewLocalStorageService("").FetchFromGCS(context.Background(), "gs://bucket/a.pdf"); err == nil {
+		t.Error("FetchFromGCS read a gs:// URI without a root directory")
+	}
+}
+
+func TestLocalStorageServiceRejectsPathsOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "secret.pdf")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewLocalStorageService(root)
+	for _, uri := range []string{
+		"file://" + filepath.ToSlash(outside),
+		"file://" + filepath.ToSlash(root) + "/../secret.pdf",
+		"file:///etc/passwd",
+	} {
+		if _, err := s.FetchFromGCS(context.Background(), uri); err == nil {
+			t.Errorf("FetchFromGCS(%q) succeeded", uri)
+		}
+		if _, err := s.OpenObject(context.Background(), uri); err == nil {
+			t.Errorf("OpenObject(%q) succeeded", uri)
+		}
+		if err := s.DeleteObject(context.Background(), uri); err == nil {
+			t.Errorf("DeleteObject(%q) succeeded", uri)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the root was deleted: %v", err)
+	}
+}
+
+func TestLocalStorageServiceObjects(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorageService(t.TempDir())
+
+	written, err := s.WriteObject(ctx, "bucket", "uploads/statement.pdf", "application/pdf", strings.NewReader("%PDF-1.4"))
+	if err != nil || written != 8 {
+		t.Fatalf("WriteObject() = %d, %v", written, err)
+	}
+
+	obj, err := s.OpenObject(ctx, "gs://bucket/uploads/statement.pdf")
+	if err != nil {
+		t.Fatalf("OpenObject: %v", err)
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil || string(data) != "%PDF-1.4" || obj.Size != 8 || obj.ContentType != "application/pdf" {
+		t.Errorf("OpenObject() = %q (size %d, type %q), %v", data, obj.Size, obj.ContentType, err)
+	}
+
+	if err := s.DeleteObject(ctx, "gs://bucket/uploads/statement.pdf"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := s.OpenObject(ctx, "gs://bucket/uploads/statement.pdf"); !errors.Is(err, ErrObjectNotExist) {
+		t.Errorf("OpenObject after delete = %v, want ErrObjectNotExist", err)
+	}
+	if err := s.DeleteObject(ctx, "gs://bucket/uploads/statement.pdf"); !errors.Is(err, ErrObjectNotExist) {
+		t.Errorf("DeleteObject after delete = %v, want ErrObjectNotExist", err)
+	}
+}
+
+func TestLocalStorageServiceRejectsEscapingPaths(t *testing.T) {
+	s := NewLocalStorageService(t.TempDir())
+	for _, uri := range []string{"gs://bucket/../../etc/passwd", "gs://../x", "gs://bucket/", "gs://bucket"} {
+		if _, err := s.FetchFromGCS(context.Background(), uri); err == nil {
+			t.Errorf("FetchFromGCS(%q) succeeded", uri)
+		}
+	}
+}
+
+func TestNewStorageServiceFromEnv(t *testing.T) {
+	t.Setenv(StorageBackendEnv, "")
+	if s, err := NewStorageServiceFromEnv(); err != nil {
+		t.Fatalf("default backend: %v", err)
+	} else if _, ok := s.(*GCSStorageService); !ok {
+		t.Errorf("default backend = %T, want *GCSStorageService", s)
+	}
+
+	t.Setenv(StorageBackendEnv, "local")
+	t.Setenv(LocalStorageRootEnv, "")
+	if _, err := NewStorageServiceFromEnv(); err == nil {
+		t.Error("local backend without a root directory accepted")
+	}
+
+	t.Setenv(LocalStorageRootEnv, "/data")
+	if s, err := NewStorageServiceFromEnv(); err != nil {
+		t.Fatalf("local backend: %v", err)
+	} else if l, ok := s.(*LocalStorageService); !ok || l.Root != "/data" {
+		t.Errorf("local backend = %#v", s)
+	}
+
+	t.Setenv(StorageBackendEnv, "s3")
+	if _, err := NewStorageServiceFromEnv(); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
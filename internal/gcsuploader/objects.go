@@ -0,0 +1,182 @@
+package gcsuploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// ErrObjectNotExist is returned when an object does not exist.
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// ErrSignedURLUnsupported is returned by backends that cannot sign URLs.
+var ErrSignedURLUnsupported = errors.New("signed URLs are not supported by this storage backend")
+
+// Object is an open stored object.
+type Object struct {
+	io.ReadCloser
+
+	// ContentType is the stored content type, or empty if it is not known.
+	ContentType string
+
+	// Size is the object's size in bytes.
+	Size int64
+}
+
+// ObjectStore is a StorageService that can also stream, open and delete objects, as the
+// API's upload, download and delete endpoints need.
+type ObjectStore interface {
+	StorageService
+
+	// WriteObject stores the contents of r as bucketName/objectName and returns the
+	// number of bytes written.
+	WriteObject(ctx context.Context, bucketName, objectName, contentType string, r io.Reader) (int64, error)
+
+	// OpenObject opens the object behind a storage URI. It returns ErrObjectNotExist
+	// if there is none.
+	OpenObject(ctx context.Context, uri string) (*Object, error)
+
+	// DeleteObject deletes the object behind a storage URI.
+	DeleteObject(ctx context.Context, uri string) error
+
+	// SignedURL returns a signed URL for the object behind a storage URI, or
+	// ErrSignedURLUnsupported.
+	SignedURL(ctx context.Context, uri string, opts *storage.SignedURLOptions) (string, error)
+}
+
+// NewObjectStoreFromEnv returns the object store selected by StorageBackendEnv.
+func NewObjectStoreFromEnv() (ObjectStore, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(os.Getenv(StorageBackendEnv))); backend {
+	case "", StorageBackendGCS:
+		return NewGCSStorageService(), nil
+	case StorageBackendLocal:
+		root := os.Getenv(LocalStorageRootEnv)
+		if root == "" {
+			return nil, fmt.Errorf("%s=%s requires %s", StorageBackendEnv, StorageBackendLocal, LocalStorageRootEnv)
+		}
+		return NewLocalStorageService(root), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown storage backend %q", StorageBackendEnv, backend)
+	}
+}
+
+// WriteObject streams r to gs://bucketName/objectName.
+func (s *GCSStorageService) WriteObject(ctx context.Context, bucketName, objectName, contentType string, r io.Reader) (int64, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("WriteObject: creating storage client: %w", err)
+	}
+	defer client.Close()
+
+	wc := client.Bucket(bucketName).Object(objectName).NewWriter(ctx)
+	wc.ContentType = contentType
+	written, err := io.Copy(wc, r)
+	if err != nil {
+		wc.Close()
+		return written, fmt.Errorf("WriteObject: copying to GCS writer: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return written, fmt.Errorf("WriteObject: finalizing upload: %w", err)
+	}
+	return written, nil
+}
+
+// OpenObject opens the object behind a gs:// URI.
+func (s *GCSStorageService) OpenObject(ctx context.Context, uri string) (*Object, error) {
+	bucketName, objectName, err := splitGCSURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("OpenObject: %w", err)
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("OpenObject: creating storage client: %w", err)
+	}
+
+	reader, err := client.Bucket(bucketName).Object(objectName).NewReader(ctx)
+	if err != nil {
+		client.Close()
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrObjectNotExist
+		}
+		return nil, fmt.Errorf("OpenObject: reading object %s/%s: %w", bucketName, objectName, err)
+	}
+
+	return &Object{
+		ReadCloser:  &gcsObjectReader{Reader: reader, client: client},
+		ContentType: reader.Attrs.ContentType,
+		Size:        reader.Attrs.Size,
+	}, nil
+}
+
+// DeleteObject deletes the object behind a gs:// URI.
+func (s *GCSStorageService) DeleteObject(ctx context.Context, uri string) error {
+	bucketName, objectName, err := splitGCSURI(uri)
+	if err != nil {
+		return fmt.Errorf("DeleteObject: %w", err)
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteObject: creating storage client: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Bucket(bucketName).Object(objectName).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrObjectNotExist
+		}
+		return fmt.Errorf("DeleteObject: %w", err)
+	}
+	return nil
+}
+
+// SignedURL signs a URL for the object behind a gs:// URI. It requires credentials that
+// can sign, e.g. a service account.
+func (s *GCSStorageService) SignedURL(ctx context.Context, uri string, opts *storage.SignedURLOptions) (string, error) {
+	bucketName, objectName, err := splitGCSURI(uri)
+	if err != nil {
+		return "", fmt.Errorf("SignedURL: %w", err)
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("SignedURL: creating storage client: %w", err)
+	}
+	defer client.Close()
+
+	signed, err := client.Bucket(bucketName).SignedURL(objectName, opts)
+	if err != nil {
+		return "", fmt.Errorf("SignedURL: %w", err)
+	}
+	return signed, nil
+}
+
+// gcsObjectReader closes the storage client along with the object reader.
+type gcsObjectReader struct {
+	*storage.Reader
+	client *storage.Client
+}
+
+func (r *gcsObjectReader) Close() error {
+	err := r.Reader.Close()
+	r.client.Close()
+	return err
+}
+
+// splitGCSURI splits a gs://bucket/object URI into its bucket and object name.
+func splitGCSURI(uri string) (bucketName, objectName string, err error) {
+	if !strings.HasPrefix(uri, "gs://") {
+		return "", "", fmt.Errorf("invalid GCS URI: %s", uri)
+	}
+	bucketName, objectName, ok := strings.Cut(strings.TrimPrefix(uri, "gs://"), "/")
+	if !ok || bucketName == "" || objectName == "" {
+		return "", "", fmt.Errorf("invalid GCS URI (no object path): %s", uri)
+	}
+	return bucketName, objectName, nil
+}
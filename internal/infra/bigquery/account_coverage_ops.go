@@ -0,0 +1,128 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// GetAccountCoverage returns the statement periods ingested for an account, with gaps
+// and overlaps between them. Returns nil if the account has no dated statements.
+func GetAccountCoverage(ctx context.Context, accountID string) (*AccountCoverage, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAccountCoverage: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return GetAccountCoverageWithClient(ctx, client, accountID)
+}
+
+// GetAccountCoverageWithClient returns the coverage of an account using the provided
+// BigQuery client. Only documents with a successful parsing run are considered. A
+// document's period is its statement_start_date/statement_end_date, falling back to the
+// dates of its first and last transaction when those are not set.
+func GetAccountCoverageWithClient(ctx context.Context, client *bigquery.Client, accountID string) (*AccountCoverage, error) {
+	if accountID == "" {
+		return nil, fmt.Errorf("GetAccountCoverageWithClient: account_id cannot be empty")
+	}
+
+	query := fmt.Sprintf(`
+		WITH tx AS (
+			SELECT t.document_id, MIN(t.transaction_date) AS min_date, MAX(t.transaction_date) AS max_date
+			FROM `+"`%[1]s.%[2]s.transactions`"+` t
+			INNER JOIN `+"`%[1]s.%[2]s.parsing_runs`"+` pr
+			  ON t.parsing_run_id = pr.parsing_run_id
+			WHERE t.account_id = @account_id
+			  AND pr.status = 'SUCCESS'
+			GROUP BY t.document_id
+		)
+		SELECT
+			d.document_id,
+			COALESCE(d.statement_start_date, tx.min_date) AS start_date,
+			COALESCE(d.statement_end_date, tx.max_date) AS end_date
+		FROM `+"`%[1]s.%[2]s.documents`"+` d
+		INNER JOIN tx ON tx.document_id = d.document_id
+		WHERE COALESCE(d.statement_start_date, tx.min_date) IS NOT NULL
+		  AND COALESCE(d.statement_end_date, tx.max_date) IS NOT NULL
+		ORDER BY start_date, end_date, d.document_id
+	`, projectID, datasetID)
+
+	q := client.Query(query)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "account_id", Value: accountID},
+	}
+
+	it, err := readQuery(ctx, "GetAccountCoverage", q)
+	if err != nil {
+		return nil, fmt.Errorf("GetAccountCoverageWithClient: reading query: %w", err)
+	}
+
+	var periods []StatementPeriod
+	for {
+		var p StatementPeriod
+		err := it.Next(&p)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetAccountCoverageWithClient: iterating: %w", err)
+		}
+		periods = append(periods, p)
+	}
+	if len(periods) == 0 {
+		return nil, nil
+	}
+
+	return computeCoverage(accountID, periods), nil
+}
+
+// computeCoverage finds the days between the first and last period that no period
+// covers, and the date ranges shared by more than one period.
+func computeCoverage(accountID string, periods []StatementPeriod) *AccountCoverage {
+	sorted := make([]StatementPeriod, len(periods))
+	copy(sorted, periods)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].StartDate != sorted[j].StartDate {
+			return sorted[i].StartDate.Before(sorted[j].StartDate)
+		}
+		return sorted[i].EndDate.Before(sorted[j].EndDate)
+	})
+
+	coverage := &AccountCoverage{
+		AccountID: accountID,
+		Periods:   sorted,
+		Gaps:      []CoverageGap{},
+		Overlaps:  []CoverageOverlap{},
+	}
+
+	// Each period is compared with the latest-ending period before it
+	prev := sorted[0]
+	for _, p := range sorted[1:] {
+		if p.StartDate.After(prev.EndDate.AddDays(1)) {
+			coverage.Gaps = append(coverage.Gaps, CoverageGap{
+				StartDate: prev.EndDate.AddDays(1),
+				EndDate:   p.StartDate.AddDays(-1),
+			})
+		}
+		if !p.StartDate.After(prev.EndDate) {
+			end := p.EndDate
+			if prev.EndDate.Before(end) {
+				end = prev.EndDate
+			}
+			coverage.Overlaps = append(coverage.Overlaps, CoverageOverlap{
+				StartDate:   p.StartDate,
+				EndDate:     end,
+				DocumentIDs: []string{prev.DocumentID, p.DocumentID},
+			})
+		}
+		if p.EndDate.After(prev.EndDate) {
+			prev = p
+		}
+	}
+
+	return coverage
+}
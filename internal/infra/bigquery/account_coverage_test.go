@@ -0,0 +1,74 @@
+package bigquery
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/civil"
+)
+
+func period(id, start, end string) StatementPeriod {
+	s, _ := civil.ParseDate(start)
+	e, _ := civil.ParseDate(end)
+	return StatementPeriod{DocumentID: id, StartDate: s, EndDate: e}
+}
+
+func gap(start, end string) CoverageGap {
+	p := period("", start, end)
+	return CoverageGap{StartDate: p.StartDate, EndDate: p.EndDate}
+}
+
+func TestComputeCoverage(t *testing.T) {
+	coverage := computeCoverage("acc-1", []StatementPeriod{
+		period("mar", "2024-03-01", "2024-03-31"),
+		period("jan", "2024-01-01", "2024-01-31"),
+		period("jun", "2024-06-03", "2024-07-02"),
+		// Repeats the end of the June statement
+		period("jul", "2024-07-01", "2024-07-31"),
+	})
+
+	var order []string
+	for _, p := range coverage.Periods {
+		order = append(order, p.DocumentID)
+	}
+	if want := []string{"jan", "mar", "jun", "jul"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("periods = %v, want %v", order, want)
+	}
+
+	wantGaps := []CoverageGap{
+		gap("2024-02-01", "2024-02-29"),
+		gap("2024-04-01", "2024-06-02"),
+	}
+	if !reflect.DeepEqual(coverage.Gaps, wantGaps) {
+		t.Errorf("gaps = %+v, want %+v", coverage.Gaps, wantGaps)
+	}
+
+	if len(coverage.Overlaps) != 1 {
+		t.Fatalf("got %d overlaps, want 1: %+v", len(coverage.Overlaps), coverage.Overlaps)
+	}
+	o := coverage.Overlaps[0]
+	if o.StartDate.String() != "2024-07-01" || o.EndDate.String() != "2024-07-02" || !reflect.DeepEqual(o.DocumentIDs, []string{"jun", "jul"}) {
+		t.Errorf("overlap = %+v, want 2024-07-01..2024-07-02 between jun and jul", o)
+	}
+}
+
+func TestComputeCoverage_Contiguous(t *testing.T) {
+	coverage := computeCoverage("acc-1", []StatementPeriod{
+		period("a", "2023-12-15", "2024-01-14"),
+		period("b", "2024-01-15", "2024-02-14"),
+	})
+	if len(coverage.Gaps) != 0 || len(coverage.Overlaps) != 0 {
+		t.Errorf("expected no gaps or overlaps, got %+v", coverage)
+	}
+}
+
+func TestComputeCoverage_MidMonthGap(t *testing.T) {
+	// The 2024-02-15..2024-03-14 cycle is missing, though both months have a statement
+	coverage := computeCoverage("acc-1", []StatementPeriod{
+		period("a", "2024-01-15", "2024-02-14"),
+		period("c", "2024-03-15", "2024-04-14"),
+	})
+	if want := []CoverageGap{gap("2024-02-15", "2024-03-14")}; !reflect.DeepEqual(coverage.Gaps, want) {
+		t.Errorf("gaps = %+v, want %+v", coverage.Gaps, want)
+	}
+}
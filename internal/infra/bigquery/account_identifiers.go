@@ -0,0 +1,68 @@
+package bigquery
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeSortCode formats a UK sort code as XX-XX-XX. It accepts six digits optionally
+// separated into pairs by hyphens or spaces. Anything else is returned trimmed with ok=false.
+func NormalizeSortCode(sortCode string) (normalized string, ok bool) {
+	trimmed := strings.TrimSpace(sortCode)
+
+	var digits []rune
+	afterSeparator := false
+	for _, r := range trimmed {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, r)
+			afterSeparator = false
+		case (r == '-' || r == ' ') && !afterSeparator && len(digits) > 0 && len(digits) < 6 && len(digits)%2 == 0:
+			// Single separator between digit pairs
+			afterSeparator = true
+		default:
+			return trimmed, false
+		}
+	}
+	if len(digits) != 6 {
+		return trimmed, false
+	}
+
+	d := string(digits)
+	return d[0:2] + "-" + d[2:4] + "-" + d[4:6], true
+}
+
+// NormalizeIBAN removes whitespace from an IBAN, upper-cases it and verifies its
+// mod-97 check digits (ISO 13616). ok is false for an invalid IBAN, which is still
+// returned compacted so it compares equal to other spellings of itself.
+func NormalizeIBAN(iban string) (normalized string, ok bool) {
+	compact := strings.ToUpper(removeRunes(iban, unicode.IsSpace))
+
+	if len(compact) < 15 || len(compact) > 34 {
+		return compact, false
+	}
+	for i, r := range compact {
+		isLetter := r >= 'A' && r <= 'Z'
+		isDigit := r >= '0' && r <= '9'
+		if (i < 2 && !isLetter) || (i >= 2 && i < 4 && !isDigit) || (!isLetter && !isDigit) {
+			return compact, false
+		}
+	}
+
+	// Move the country code and check digits to the end, map letters to 10..35 and
+	// compute the remainder digit by digit to avoid big integers.
+	rearranged := compact[4:] + compact[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		if r >= 'A' {
+			remainder = (remainder*100 + int(r-'A'+10)) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+	if remainder != 1 {
+		return compact, false
+	}
+
+	return compact, true
+}
@@ -0,0 +1,54 @@
+package bigquery
+
+import "testing"
+
+func TestNormalizeSortCode(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{in: "20-00-00", want: "20-00-00", wantOK: true},
+		{in: "200000", want: "20-00-00", wantOK: true},
+		{in: " 20 32 53 ", want: "20-32-53", wantOK: true},
+		{in: "20-0000", want: "20-00-00", wantOK: true},
+		{in: "2-000-00", want: "2-000-00"},
+		{in: "20--00-00", want: "20--00-00"},
+		{in: "20-00-0", want: "20-00-0"},
+		{in: "20-00-000", want: "20-00-000"},
+		{in: "AB-CD-EF", want: "AB-CD-EF"},
+		{in: "-20-00-00", want: "-20-00-00"},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeSortCode(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeSortCode(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNormalizeIBAN(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{in: "GB82 WEST 1234 5698 7654 32", want: "GB82WEST12345698765432", wantOK: true},
+		{in: "gb29nwbk60161331926819", want: "GB29NWBK60161331926819", wantOK: true},
+		{in: "DE89 3704 0044 0532 0130 00", want: "DE89370400440532013000", wantOK: true},
+		{in: "NO93 8601 1117 947", want: "NO9386011117947", wantOK: true},
+		{in: "GB82 WEST 1234 5698 7654 33", want: "GB82WEST12345698765433"}, // bad check digits
+		{in: " GB00WEST12345698765432", want: "GB00WEST12345698765432"},
+		{in: "12345678", want: "12345678"},
+		{in: "gb00 west 1234", want: "GB00WEST1234"},
+		{in: "GB82-WEST-1234-5698-7654-32", want: "GB82-WEST-1234-5698-7654-32"},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeIBAN(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeIBAN(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
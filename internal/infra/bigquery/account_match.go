@@ -0,0 +1,264 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// Match strengths returned by accountMatchScore, strongest last.
+const (
+	noMatch                  = 0
+	matchByLast4             = 1 // last four digits equal, full number unknown on one side
+	matchByNumber            = 2 // account_number equal, sort code unknown on one side
+	matchBySortCodeAndNumber = 3
+	matchByIBAN              = 4
+)
+
+// last4Len is the number of trailing digits statements often show instead of the full
+// account number.
+const last4Len = 4
+
+// maxAccountMatchCandidates bounds the rows fetched when matching an account.
+const maxAccountMatchCandidates = 50
+
+// accountIdentifiers holds the normalized identifiers of an account.
+type accountIdentifiers struct {
+	IBAN          string
+	SortCode      string
+	AccountNumber string // full account number, empty when only the last digits are known
+	Last4         string
+	Currency      string
+}
+
+// identifiersOf normalizes row's identifiers. Sort code and account number missing from
+// a UK IBAN-only row are derived from the IBAN so it can match rows identified by number.
+// A partial account number only sets Last4.
+func identifiersOf(row *AccountRow) accountIdentifiers {
+	number, last4 := splitAccountNumber(normalizeAccountNumber(row.AccountNumber))
+	iban, _ := NormalizeIBAN(row.IBAN)
+	sortCode, _ := NormalizeSortCode(row.SortCode)
+	ids := accountIdentifiers{
+		IBAN:          iban,
+		SortCode:      sortCode,
+		AccountNumber: number,
+		Last4:         last4,
+		Currency:      strings.ToUpper(strings.TrimSpace(row.Currency)),
+	}
+
+	if sortCode, number, ok := ukIBANParts(ids.IBAN); ok {
+		if ids.SortCode == "" {
+			ids.SortCode = sortCode
+		}
+		if ids.AccountNumber == "" {
+			ids.AccountNumber = number
+		}
+	}
+
+	if ids.Last4 == "" {
+		_, ids.Last4 = splitAccountNumber(ids.AccountNumber)
+	}
+	if ids.Last4 == "" {
+		ids.Last4 = normalizeAccountNumber(row.AccountNumberLast4)
+	}
+
+	return ids
+}
+
+// hasAccountIdentifiers reports whether row carries anything an account can be matched on.
+func hasAccountIdentifiers(row *AccountRow) bool {
+	ids := identifiersOf(row)
+	return ids.IBAN != "" || ids.AccountNumber != "" || ids.Last4 != ""
+}
+
+// splitAccountNumber returns the full account number and its last four digits from a
+// normalized account number. Statements often show only the last four digits, on their
+// own or masked ("****1234", "XXXX1234"); for such partial numbers full is empty.
+// last4 is empty when the number does not end in four digits.
+func splitAccountNumber(normalized string) (full, last4 string) {
+	digits := strings.TrimLeftFunc(normalized, isAccountNumberMask)
+	if n := len(digits); n >= last4Len && isDigits(digits[n-last4Len:]) {
+		last4 = digits[n-last4Len:]
+	}
+	if digits != normalized || len(digits) <= last4Len {
+		return "", last4
+	}
+	return normalized, last4
+}
+
+// isAccountNumberMask reports whether r is used to mask the leading digits of an account number.
+func isAccountNumberMask(r rune) bool {
+	return r == '*' || r == 'X' || r == '.' || r == '#' || r == '•'
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// normalizeAccountNumber removes whitespace and hyphens and upper-cases an account number.
+func normalizeAccountNumber(number string) string {
+	return strings.ToUpper(removeRunes(number, func(r rune) bool { return unicode.IsSpace(r) || r == '-' }))
+}
+
+func removeRunes(s string, drop func(rune) bool) string {
+	return strings.Map(func(r rune) rune {
+		if drop(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ukIBANParts extracts the sort code, formatted like NormalizeSortCode, and account
+// number from a normalized UK IBAN (GBkk BBBB SSSSSS NNNNNNNN).
+func ukIBANParts(iban string) (sortCode, accountNumber string, ok bool) {
+	if len(iban) != 22 || !strings.HasPrefix(iban, "GB") {
+		return "", "", false
+	}
+	return iban[8:10] + "-" + iban[10:12] + "-" + iban[12:14], iban[14:], true
+}
+
+// accountMatchScore rates how strongly candidate identifies the same account as row.
+// Conflicting identifiers (different IBANs, sort codes or currencies) never match, and
+// neither does a currency known on one side only.
+func accountMatchScore(row, candidate accountIdentifiers) int {
+	if row.Currency != candidate.Currency {
+		return noMatch
+	}
+
+	if row.IBAN != "" && candidate.IBAN != "" {
+		if row.IBAN == candidate.IBAN {
+			return matchByIBAN
+		}
+		return noMatch
+	}
+
+	if row.SortCode != "" && candidate.SortCode != "" && row.SortCode != candidate.SortCode {
+		return noMatch
+	}
+
+	if row.AccountNumber != "" && candidate.AccountNumber != "" {
+		if row.AccountNumber != candidate.AccountNumber {
+			return noMatch
+		}
+		if row.SortCode != "" && candidate.SortCode != "" {
+			return matchBySortCodeAndNumber
+		}
+		return matchByNumber
+	}
+
+	// One side only knows the last four digits
+	if row.Last4 == "" || row.Last4 != candidate.Last4 {
+		return noMatch
+	}
+	return matchByLast4
+}
+
+// bestAccountMatch returns the candidate that best matches row, or nil if none match.
+// Only accounts of row's user are considered. Ties go to open accounts, then to the
+// earliest candidate in the slice. A match on the last four digits alone is only trusted
+// when a single candidate has them.
+func bestAccountMatch(row *AccountRow, candidates []*AccountRow) *AccountRow {
+	ids := identifiersOf(row)
+
+	var best *AccountRow
+	bestScore, bestCount := noMatch, 0
+	for _, c := range candidates {
+		if c.UserID != row.UserID {
+			continue
+		}
+		score := accountMatchScore(ids, identifiersOf(c))
+		if score == noMatch || score < bestScore {
+			continue
+		}
+		if score > bestScore {
+			best, bestScore, bestCount = c, score, 1
+			continue
+		}
+		bestCount++
+		if best.ClosedDate.Valid && !c.ClosedDate.Valid {
+			best = c
+		}
+	}
+	if bestScore == matchByLast4 && bestCount > 1 {
+		return nil
+	}
+	return best
+}
+
+// FindMatchingAccountWithClient finds the existing account of row's user that best
+// matches row's IBAN, sort code, account number and currency, using the provided
+// BigQuery client. Returns nil if no account matches.
+func FindMatchingAccountWithClient(ctx context.Context, client *bigquery.Client, row *AccountRow) (*AccountRow, error) {
+	ids := identifiersOf(row)
+	if ids.IBAN == "" && ids.AccountNumber == "" && ids.Last4 == "" {
+		return nil, fmt.Errorf("FindMatchingAccountWithClient: account has no IBAN or account number")
+	}
+
+	// Fetch anything sharing an identifier; bestAccountMatch rejects conflicting candidates.
+	query := fmt.Sprintf(`
+		SELECT
+			account_id,
+			user_id,
+			institution_id,
+			account_name,
+			account_number,
+			account_number_last4,
+			sort_code,
+			iban,
+			currency,
+			account_type,
+			opened_date,
+			closed_date,
+			is_primary,
+			metadata,
+			created_ts,
+			updated_ts
+		FROM `+"`%s.%s.accounts`"+`
+		WHERE user_id = @user_id
+		  AND ((@iban != '' AND REGEXP_REPLACE(UPPER(iban), r'\s', '') = @iban)
+		    OR (@account_number != '' AND REGEXP_REPLACE(UPPER(account_number), r'[\s-]', '') = @account_number)
+		    OR (@account_number != '' AND STARTS_WITH(REGEXP_REPLACE(UPPER(iban), r'\s', ''), 'GB')
+		        AND SUBSTR(REGEXP_REPLACE(UPPER(iban), r'\s', ''), -8) = @account_number)
+		    OR (@account_number_last4 != '' AND account_number_last4 = @account_number_last4))
+		ORDER BY created_ts DESC
+		LIMIT %d
+	`, projectID, datasetID, maxAccountMatchCandidates)
+
+	q := client.Query(query)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "user_id", Value: row.UserID},
+		{Name: "iban", Value: ids.IBAN},
+		{Name: "account_number", Value: ids.AccountNumber},
+		{Name: "account_number_last4", Value: ids.Last4},
+	}
+
+	it, err := readQuery(ctx, "FindMatchingAccount", q)
+	if err != nil {
+		return nil, fmt.Errorf("FindMatchingAccountWithClient: reading query: %w", err)
+	}
+
+	var candidates []*AccountRow
+	for {
+		var r AccountRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("FindMatchingAccountWithClient: iterating: %w", err)
+		}
+		candidates = append(candidates, &r)
+	}
+
+	return bestAccountMatch(row, candidates), nil
+}
@@ -0,0 +1,170 @@
+package bigquery
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+)
+
+func TestBestAccountMatch(t *testing.T) {
+	byNumber := &AccountRow{AccountID: "by-number", SortCode: "20-00-00", AccountNumber: "55779911", Currency: "GBP"}
+	byIBAN := &AccountRow{AccountID: "by-iban", IBAN: "GB29 NWBK 6016 1331 9268 19", Currency: "GBP"}
+	otherSortCode := &AccountRow{AccountID: "other-sort-code", SortCode: "30-00-00", AccountNumber: "55779911", Currency: "GBP"}
+	euro := &AccountRow{AccountID: "euro", IBAN: "GB29NWBK60161331926819", Currency: "EUR"}
+
+	tests := []struct {
+		name       string
+		row        *AccountRow
+		candidates []*AccountRow
+		want       string
+	}{
+		{
+			name:       "IBAN-only statement matches account with same IBAN",
+			row:        &AccountRow{IBAN: "gb29nwbk60161331926819", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber, byIBAN},
+			want:       "by-iban",
+		},
+		{
+			name:       "IBAN-only statement matches account known by sort code and number",
+			row:        &AccountRow{IBAN: "GB82 WEST 2000 0055 7799 11", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber},
+			want:       "by-number",
+		},
+		{
+			name:       "sort code and number match account known by IBAN",
+			row:        &AccountRow{SortCode: "601613", AccountNumber: "31926819", Currency: "GBP"},
+			candidates: []*AccountRow{byIBAN},
+			want:       "by-iban",
+		},
+		{
+			name:       "sort code and number prefer the matching sort code",
+			row:        &AccountRow{SortCode: "200000", AccountNumber: "5577 9911", Currency: "gbp"},
+			candidates: []*AccountRow{otherSortCode, byNumber},
+			want:       "by-number",
+		},
+		{
+			name:       "number without sort code matches",
+			row:        &AccountRow{AccountNumber: "55779911", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber},
+			want:       "by-number",
+		},
+		{
+			name:       "different sort code does not match",
+			row:        &AccountRow{SortCode: "40-00-00", AccountNumber: "55779911", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber, otherSortCode},
+		},
+		{
+			name:       "different IBAN does not match even with same number",
+			row:        &AccountRow{IBAN: "GB94BARC10201530093459", AccountNumber: "31926819", Currency: "GBP"},
+			candidates: []*AccountRow{byIBAN},
+		},
+		{
+			name:       "different currency does not match",
+			row:        &AccountRow{IBAN: "GB29NWBK60161331926819", Currency: "GBP"},
+			candidates: []*AccountRow{euro},
+		},
+		{
+			name:       "currency known on one side only does not match",
+			row:        &AccountRow{IBAN: "GB29NWBK60161331926819"},
+			candidates: []*AccountRow{byIBAN},
+		},
+		{
+			name:       "another user's account does not match",
+			row:        &AccountRow{UserID: "user-2", IBAN: "GB29NWBK60161331926819", Currency: "GBP"},
+			candidates: []*AccountRow{byIBAN},
+		},
+		{
+			name: "prefers an open account over a closed one",
+			row:  &AccountRow{AccountNumber: "55779911", Currency: "GBP"},
+			candidates: []*AccountRow{
+				{AccountID: "closed", AccountNumber: "55779911", Currency: "GBP", ClosedDate: bigquery.NullDate{Date: civil.Date{Year: 2024, Month: 1, Day: 1}, Valid: true}},
+				{AccountID: "open", AccountNumber: "55779911", Currency: "GBP"},
+			},
+			want: "open",
+		},
+		{
+			name:       "last four digits match account known by full number",
+			row:        &AccountRow{AccountNumber: "****9911", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber},
+			want:       "by-number",
+		},
+		{
+			name:       "full number matches account known by last four digits",
+			row:        &AccountRow{SortCode: "20-00-00", AccountNumber: "55779911", Currency: "GBP"},
+			candidates: []*AccountRow{{AccountID: "last4", AccountNumber: "9911", Currency: "GBP"}},
+			want:       "last4",
+		},
+		{
+			name:       "last four digits match account known by IBAN",
+			row:        &AccountRow{AccountNumber: "6819", Currency: "GBP"},
+			candidates: []*AccountRow{byIBAN},
+			want:       "by-iban",
+		},
+		{
+			name:       "full number preferred over last four digits",
+			row:        &AccountRow{AccountNumber: "55779911", Currency: "GBP"},
+			candidates: []*AccountRow{{AccountID: "last4", AccountNumber: "9911", Currency: "GBP"}, byNumber},
+			want:       "by-number",
+		},
+		{
+			name: "last four digits shared by several accounts do not match",
+			row:  &AccountRow{AccountNumber: "XXXX9911", Currency: "GBP"},
+			candidates: []*AccountRow{
+				{AccountID: "a", AccountNumber: "11119911", Currency: "GBP"},
+				{AccountID: "b", AccountNumber: "22229911", Currency: "GBP"},
+			},
+		},
+		{
+			name:       "different full numbers with the same last four digits do not match",
+			row:        &AccountRow{AccountNumber: "11119911", Currency: "GBP"},
+			candidates: []*AccountRow{byNumber},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bestAccountMatch(tt.row, tt.candidates)
+			gotID := ""
+			if got != nil {
+				gotID = got.AccountID
+			}
+			if gotID != tt.want {
+				t.Errorf("bestAccountMatch() = %q, want %q", gotID, tt.want)
+			}
+		})
+	}
+}
+
+func TestUKIBANParts(t *testing.T) {
+	iban, _ := NormalizeIBAN("GB82 WEST 1234 5698 7654 32")
+	sortCode, number, ok := ukIBANParts(iban)
+	if !ok || sortCode != "12-34-56" || number != "98765432" {
+		t.Errorf("ukIBANParts() = %q, %q, %v; want 12-34-56, 98765432, true", sortCode, number, ok)
+	}
+
+	if _, _, ok := ukIBANParts("DE89370400440532013000"); ok {
+		t.Error("ukIBANParts() accepted a non-UK IBAN")
+	}
+}
+
+func TestSplitAccountNumber(t *testing.T) {
+	tests := []struct {
+		in, full, last4 string
+	}{
+		{"55779911", "55779911", "9911"},
+		{"9911", "", "9911"},
+		{"****9911", "", "9911"},
+		{"XXXX9911", "", "9911"},
+		{"••••9911", "", "9911"},
+		{"ABC", "", ""},
+		{"12345ABC", "12345ABC", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		full, last4 := splitAccountNumber(tt.in)
+		if full != tt.full || last4 != tt.last4 {
+			t.Errorf("splitAccountNumber(%q) = %q, %q; want %q, %q", tt.in, full, last4, tt.full, tt.last4)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// DefaultAccountPrefix is the account_number prefix of document-scoped fallback
+// accounts created when no account identifiers could be extracted.
+const DefaultAccountPrefix = "DOC-"
+
+// AccountMergeCandidate pairs a document-scoped default account with a real account
+// that a later parse of one of its documents resolved to.
+type AccountMergeCandidate struct {
+	SyntheticAccountID     string `bigquery:"synthetic_account_id"`
+	SyntheticAccountNumber string `bigquery:"synthetic_account_number"`
+	TargetAccountID        string `bigquery:"target_account_id"`
+	TargetAccountNumber    string `bigquery:"target_account_number"`
+	SharedDocuments        int64  `bigquery:"shared_documents"`
+}
+
+// MergeAccount moves all transactions and documents from one account to another and
+// retires the source account.
+func MergeAccount(ctx context.Context, fromAccountID, toAccountID string) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("MergeAccount: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return MergeAccountWithClient(ctx, client, fromAccountID, toAccountID)
+}
+
+// MergeAccountWithClient moves all transactions and documents from one account to another
+// using the provided BigQuery client. The source account is retired by setting closed_date
+// and recording the target in metadata.merged_into. All updates run in a single transaction.
+func MergeAccountWithClient(ctx context.Context, client *bigquery.Client, fromAccountID, toAccountID string) error {
+	if fromAccountID == "" || toAccountID == "" {
+		return fmt.Errorf("MergeAccountWithClient: account IDs cannot be empty")
+	}
+	if fromAccountID == toAccountID {
+		return fmt.Errorf("MergeAccountWithClient: cannot merge account %s into itself", fromAccountID)
+	}
+
+	table := func(name string) string {
+		return "`" + projectID + "." + datasetID + "." + name + "`"
+	}
+
+	q := client.Query(`
+		BEGIN TRANSACTION;
+
+		UPDATE ` + table(transactionsTable) + `
+		SET account_id = @to_account_id,
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE account_id = @from_account_id;
+
+		UPDATE ` + table(documentsTable) + `
+		SET account_id = @to_account_id
+		WHERE account_id = @from_account_id;
+
+		UPDATE ` + table("accounts") + `
+		SET closed_date = CURRENT_DATE(),
+		    metadata = JSON_SET(COALESCE(metadata, JSON '{}'), '$.merged_into', @to_account_id),
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE account_id = @from_account_id;
+
+		COMMIT TRANSACTION;
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "from_account_id", Value: fromAccountID},
+		{Name: "to_account_id", Value: toAccountID},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("MergeAccountWithClient: query run: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("MergeAccountWithClient: job wait: %w", err)
+	}
+	logQueryStats(ctx, "MergeAccount", status)
+
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("MergeAccountWithClient: job error: %w", err)
+	}
+
+	return nil
+}
+
+// FindDefaultAccountMergeCandidates lists open default accounts whose documents also have
+// transactions on a real account, i.e. a later parse extracted the real account number.
+func FindDefaultAccountMergeCandidates(ctx context.Context) ([]*AccountMergeCandidate, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("FindDefaultAccountMergeCandidates: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return FindDefaultAccountMergeCandidatesWithClient(ctx, client)
+}
+
+// FindDefaultAccountMergeCandidatesWithClient lists merge candidates using the provided
+// BigQuery client. A default account may appear more than once if its documents resolved
+// to different real accounts; callers should treat such accounts as ambiguous.
+func FindDefaultAccountMergeCandidatesWithClient(ctx context.Context, client *bigquery.Client) ([]*AccountMergeCandidate, error) {
+	query := fmt.Sprintf(`
+		WITH account_documents AS (
+			SELECT DISTINCT account_id, document_id
+			FROM `+"`%[1]s.%[2]s.transactions`"+`
+		)
+		SELECT
+			s.account_id AS synthetic_account_id,
+			s.account_number AS synthetic_account_number,
+			r.account_id AS target_account_id,
+			r.account_number AS target_account_number,
+			COUNT(DISTINCT sd.document_id) AS shared_documents
+		FROM `+"`%[1]s.%[2]s.accounts`"+` s
+		INNER JOIN account_documents sd
+		  ON sd.account_id = s.account_id
+		INNER JOIN account_documents rd
+		  ON rd.document_id = sd.document_id
+		 AND rd.account_id != s.account_id
+		INNER JOIN `+"`%[1]s.%[2]s.accounts`"+` r
+		  ON r.account_id = rd.account_id
+		WHERE STARTS_WITH(s.account_number, @prefix)
+		  AND s.closed_date IS NULL
+		  AND NOT STARTS_WITH(r.account_number, @prefix)
+		GROUP BY 1, 2, 3, 4
+		ORDER BY synthetic_account_number, shared_documents DESC
+	`, projectID, datasetID)
+
+	q := client.Query(query)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "prefix", Value: DefaultAccountPrefix},
+	}
+
+	it, err := readQuery(ctx, "FindDefaultAccountMergeCandidates", q)
+	if err != nil {
+		return nil, fmt.Errorf("FindDefaultAccountMergeCandidatesWithClient: reading query: %w", err)
+	}
+
+	var candidates []*AccountMergeCandidate
+	for {
+		var row AccountMergeCandidate
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("FindDefaultAccountMergeCandidatesWithClient: iterating: %w", err)
+		}
+		candidates = append(candidates, &row)
+	}
+
+	return candidates, nil
+}
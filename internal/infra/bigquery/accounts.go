@@ -6,3 +6,11 @@ import (
 
 // Re-export types from shared package for backward compatibility
 type AccountRow = bq.AccountRow
+type AccountBalance = bq.AccountBalance
+type AccountCoverage = bq.AccountCoverage
+type StatementPeriod = bq.StatementPeriod
+type CoverageGap = bq.CoverageGap
+type CoverageOverlap = bq.CoverageOverlap
+
+// ErrAccountNotFound is returned when an operation refers to an account that does not exist.
+var ErrAccountNotFound = bq.ErrAccountNotFound
@@ -3,9 +3,12 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 	"google.golang.org/api/iterator"
 )
@@ -30,6 +33,7 @@ func ListAllAccountsWithClient(ctx context.Context, client *bigquery.Client) ([]
 			institution_id,
 			account_name,
 			account_number,
+			account_number_last4,
 			sort_code,
 			iban,
 			currency,
@@ -45,7 +49,7 @@ func ListAllAccountsWithClient(ctx context.Context, client *bigquery.Client) ([]
 	`, projectID, datasetID)
 
 	q := client.Query(query)
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "ListAllAccounts", q)
 	if err != nil {
 		return nil, fmt.Errorf("ListAllAccountsWithClient: reading query: %w", err)
 	}
@@ -99,6 +103,7 @@ func FindAccountByNumberAndCurrencyWithClient(ctx context.Context, client *bigqu
 			institution_id,
 			account_name,
 			account_number,
+			account_number_last4,
 			sort_code,
 			iban,
 			currency,
@@ -122,7 +127,7 @@ func FindAccountByNumberAndCurrencyWithClient(ctx context.Context, client *bigqu
 		{Name: "currency", Value: normCurrency},
 	}
 
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "FindAccountByNumberAndCurrency", q)
 	if err != nil {
 		return nil, fmt.Errorf("FindAccountByNumberAndCurrencyWithClient: reading query: %w", err)
 	}
@@ -140,9 +145,9 @@ func FindAccountByNumberAndCurrencyWithClient(ctx context.Context, client *bigqu
 	return &row, nil
 }
 
-// UpsertAccount finds an existing account by (account_number, currency) or creates a new one.
-// Returns the account_id of the found or created account.
-// If account_number is empty/null, always creates a new account (for document-scoped defaults).
+// UpsertAccount finds an existing account by IBAN, sort code and account number, or creates
+// a new one. Returns the account_id of the found or created account.
+// If the row has no IBAN or account number, always creates a new account.
 func UpsertAccount(ctx context.Context, row *AccountRow) (string, error) {
 	client, err := bigquery.NewClient(ctx, projectID)
 	if err != nil {
@@ -154,10 +159,11 @@ func UpsertAccount(ctx context.Context, row *AccountRow) (string, error) {
 }
 
 // UpsertAccountWithClient finds or creates an account using the provided BigQuery client.
+// See FindMatchingAccountWithClient for how existing accounts are matched.
 func UpsertAccountWithClient(ctx context.Context, client *bigquery.Client, row *AccountRow) (string, error) {
-	// If account_number is provided, try to find existing account
-	if row.AccountNumber != "" && row.Currency != "" {
-		existing, err := FindAccountByNumberAndCurrencyWithClient(ctx, client, row.AccountNumber, row.Currency)
+	// If any identifier is provided, try to find existing account
+	if hasAccountIdentifiers(row) {
+		existing, err := FindMatchingAccountWithClient(ctx, client, row)
 		if err != nil {
 			return "", fmt.Errorf("UpsertAccountWithClient: finding existing account: %w", err)
 		}
@@ -167,22 +173,37 @@ func UpsertAccountWithClient(ctx context.Context, client *bigquery.Client, row *
 		}
 	}
 
-	// No existing account found or account_number is empty - create new account
+	// No existing account found or no identifiers - create new account
 	if row.AccountID == "" {
 		row.AccountID = uuid.NewString()
 	}
 
+	if err := InsertAccountWithClient(ctx, client, row); err != nil {
+		return "", fmt.Errorf("UpsertAccountWithClient: %w", err)
+	}
+
+	return row.AccountID, nil
+}
+
+// InsertAccountWithClient inserts row into finance.accounts as-is, without matching it
+// against existing accounts, using the provided BigQuery client. An empty
+// account_number_last4 is derived from the account number or UK IBAN.
+func InsertAccountWithClient(ctx context.Context, client *bigquery.Client, row *AccountRow) error {
+	if row.AccountNumberLast4 == "" {
+		row.AccountNumberLast4 = identifiersOf(row).Last4
+	}
+
 	q := client.Query(`
 		INSERT INTO ` + "`" + projectID + "." + datasetID + ".accounts" + "`" + ` (
 			account_id, user_id, institution_id,
-			account_name, account_number, sort_code, iban,
+			account_name, account_number, account_number_last4, sort_code, iban,
 			currency, account_type,
 			opened_date, closed_date, is_primary,
 			metadata, created_ts, updated_ts
 		)
 		VALUES (
 			@account_id, @user_id, @institution_id,
-			@account_name, @account_number, @sort_code, @iban,
+			@account_name, @account_number, @account_number_last4, @sort_code, @iban,
 			@currency, @account_type,
 			@opened_date, @closed_date, @is_primary,
 			@metadata, @created_ts, @updated_ts
@@ -195,6 +216,7 @@ func UpsertAccountWithClient(ctx context.Context, client *bigquery.Client, row *
 		{Name: "institution_id", Value: row.InstitutionID},
 		{Name: "account_name", Value: row.AccountName},
 		{Name: "account_number", Value: row.AccountNumber},
+		{Name: "account_number_last4", Value: row.AccountNumberLast4},
 		{Name: "sort_code", Value: row.SortCode},
 		{Name: "iban", Value: row.IBAN},
 		{Name: "currency", Value: row.Currency},
@@ -209,16 +231,129 @@ func UpsertAccountWithClient(ctx context.Context, client *bigquery.Client, row *
 
 	job, err := q.Run(ctx)
 	if err != nil {
-		return "", fmt.Errorf("UpsertAccountWithClient: running insert query: %w", err)
+		return fmt.Errorf("InsertAccount: running insert query: %w", err)
 	}
 
 	status, err := job.Wait(ctx)
 	if err != nil {
-		return "", fmt.Errorf("UpsertAccountWithClient: waiting for job: %w", err)
+		return fmt.Errorf("InsertAccount: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "InsertAccount", status)
 	if err := status.Err(); err != nil {
-		return "", fmt.Errorf("UpsertAccountWithClient: job error: %w", err)
+		return fmt.Errorf("InsertAccount: job error: %w", err)
 	}
 
-	return row.AccountID, nil
+	return nil
+}
+
+// GetAccountBalance returns the account's balance as of the given date.
+func GetAccountBalance(ctx context.Context, accountID string, asOf time.Time) (*AccountBalance, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAccountBalance: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return GetAccountBalanceWithClient(ctx, client, accountID, asOf)
+}
+
+// GetAccountBalanceWithClient returns the account's balance as of the given date using the
+// provided BigQuery client. Only transactions from successful parsing runs are considered.
+//
+// The balance is anchored on the most recent balance_after on or before asOf, regardless of
+// which statement it came from; ties on the same date are broken by statement line and then
+// insertion time. Amounts of any later transactions up to asOf are added on top. If no
+// transaction carries a balance, the result is the sum of all amounts (assuming a zero
+// opening balance) and is flagged as reconstructed.
+//
+// Returns nil if the account has no transactions on or before asOf.
+func GetAccountBalanceWithClient(ctx context.Context, client *bigquery.Client, accountID string, asOf time.Time) (*AccountBalance, error) {
+	if accountID == "" {
+		return nil, fmt.Errorf("GetAccountBalanceWithClient: account_id cannot be empty")
+	}
+
+	query := fmt.Sprintf(`
+		WITH txns AS (
+			SELECT
+				t.transaction_date,
+				t.amount,
+				t.balance_after,
+				t.currency,
+				t.document_id,
+				t.statement_line_no,
+				t.created_ts
+			FROM `+"`%[1]s.%[2]s.transactions`"+` t
+			INNER JOIN `+"`%[1]s.%[2]s.parsing_runs`"+` pr
+			  ON t.parsing_run_id = pr.parsing_run_id
+			WHERE t.account_id = @account_id
+			  AND t.transaction_date <= @as_of
+			  AND pr.status = 'SUCCESS'
+		),
+		anchor AS (
+			SELECT transaction_date, balance_after, document_id
+			FROM txns
+			WHERE balance_after IS NOT NULL
+			ORDER BY transaction_date DESC, statement_line_no DESC, created_ts DESC
+			LIMIT 1
+		)
+		SELECT
+			a.transaction_date AS anchor_date,
+			a.balance_after AS anchor_balance,
+			a.document_id AS anchor_document_id,
+			SUM(IF(a.transaction_date IS NULL OR t.transaction_date > a.transaction_date, t.amount, 0)) AS delta,
+			COUNTIF(a.transaction_date IS NULL OR t.transaction_date > a.transaction_date) AS delta_count,
+			MAX(t.transaction_date) AS last_date,
+			ANY_VALUE(t.currency) AS currency
+		FROM txns t
+		LEFT JOIN anchor a ON TRUE
+		GROUP BY anchor_date, anchor_balance, anchor_document_id
+	`, projectID, datasetID)
+
+	q := client.Query(query)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "account_id", Value: accountID},
+		{Name: "as_of", Value: asOf.Format(dateFormat)},
+	}
+
+	it, err := readQuery(ctx, "GetAccountBalance", q)
+	if err != nil {
+		return nil, fmt.Errorf("GetAccountBalanceWithClient: reading query: %w", err)
+	}
+
+	var row struct {
+		AnchorDate       bigquery.NullDate   `bigquery:"anchor_date"`
+		AnchorBalance    *big.Rat            `bigquery:"anchor_balance"`
+		AnchorDocumentID bigquery.NullString `bigquery:"anchor_document_id"`
+		Delta            *big.Rat            `bigquery:"delta"`
+		DeltaCount       int64               `bigquery:"delta_count"`
+		LastDate         civil.Date          `bigquery:"last_date"`
+		Currency         string              `bigquery:"currency"`
+	}
+	err = it.Next(&row)
+	if err == iterator.Done {
+		// No transactions for this account on or before asOf
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetAccountBalanceWithClient: iterating: %w", err)
+	}
+
+	balance := new(big.Rat)
+	if row.AnchorBalance != nil {
+		balance.Set(row.AnchorBalance)
+	}
+	if row.Delta != nil {
+		balance.Add(balance, row.Delta)
+	}
+
+	return &AccountBalance{
+		AccountID:            accountID,
+		AsOf:                 civil.DateOf(asOf),
+		Currency:             row.Currency,
+		Balance:              balance,
+		LastTransactionDate:  row.LastDate,
+		StatementBalanceDate: row.AnchorDate,
+		DocumentID:           row.AnchorDocumentID.StringVal,
+		Reconstructed:        row.DeltaCount > 0,
+	}, nil
 }
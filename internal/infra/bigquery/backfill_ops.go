@@ -0,0 +1,186 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// BackfillField describes a derived column that can be recomputed for existing rows.
+// Rows are processed in key order so a backfill can be resumed from the last processed key.
+type BackfillField struct {
+	// Name is the identifier used to select the field (e.g. "transactions.direction").
+	Name string
+
+	// Description is a short human-readable explanation shown in listings.
+	Description string
+
+	// Table is the table containing the derived column.
+	Table string
+
+	// KeyColumn is the primary key column used for batching and resuming.
+	KeyColumn string
+
+	// PendingCondition is a SQL predicate (on alias "t") selecting rows that still need the field.
+	PendingCondition string
+
+	// UpdateSQL is the UPDATE statement applied to a batch. It must restrict the update
+	// to `t.<KeyColumn> IN UNNEST(@keys)`. The %s placeholder receives the fully-qualified table.
+	UpdateSQL string
+}
+
+// backfillFields is the registry of supported derived fields.
+var backfillFields = map[string]BackfillField{
+	"transactions.direction": {
+		Name:             "transactions.direction",
+		Description:      "IN/OUT direction derived from the sign of amount",
+		Table:            transactionsTable,
+		KeyColumn:        "transaction_id",
+		PendingCondition: "t.direction IS NULL AND t.amount != 0",
+		UpdateSQL: `
+			UPDATE %s t
+			SET direction = IF(t.amount > 0, 'IN', 'OUT'),
+			    updated_ts = CURRENT_TIMESTAMP()
+			WHERE t.transaction_id IN UNNEST(@keys)
+		`,
+	},
+	"transactions.normalized_description": {
+		Name:             "transactions.normalized_description",
+		Description:      "normalized_description copied from raw_description",
+		Table:            transactionsTable,
+		KeyColumn:        "transaction_id",
+		PendingCondition: "t.normalized_description IS NULL AND t.raw_description != ''",
+		UpdateSQL: `
+			UPDATE %s t
+			SET normalized_description = t.raw_description,
+			    updated_ts = CURRENT_TIMESTAMP()
+			WHERE t.transaction_id IN UNNEST(@keys)
+		`,
+	},
+	"documents.statement_period": {
+		Name:             "documents.statement_period",
+		Description:      "statement_start_date/statement_end_date from the active parsing run's transactions",
+		Table:            documentsTable,
+		KeyColumn:        "document_id",
+		PendingCondition: "(t.statement_start_date IS NULL OR t.statement_end_date IS NULL)",
+		UpdateSQL: `
+			UPDATE %s t
+			SET statement_start_date = s.min_date,
+			    statement_end_date = s.max_date
+			FROM (
+				SELECT tx.document_id, MIN(tx.transaction_date) AS min_date, MAX(tx.transaction_date) AS max_date
+				FROM ` + "`" + projectID + "." + datasetID + ".transactions" + "`" + ` tx
+				INNER JOIN ` + "`" + projectID + "." + datasetID + ".parsing_runs" + "`" + ` pr
+				  ON tx.parsing_run_id = pr.parsing_run_id
+				WHERE pr.status = 'SUCCESS'
+				GROUP BY tx.document_id
+			) s
+			WHERE t.document_id = s.document_id
+			  AND t.document_id IN UNNEST(@keys)
+		`,
+	},
+}
+
+// LookupBackfillField returns the registered backfill field with the given name.
+func LookupBackfillField(name string) (BackfillField, bool) {
+	f, ok := backfillFields[name]
+	return f, ok
+}
+
+// ListBackfillFields returns all registered backfill fields sorted by name.
+func ListBackfillFields() []BackfillField {
+	fields := make([]BackfillField, 0, len(backfillFields))
+	for _, f := range backfillFields {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// BackfillBatch recomputes the field for the next batch of pending rows.
+func BackfillBatch(ctx context.Context, field BackfillField, afterKey string, batchSize int) (int, string, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return BackfillBatchWithClient(ctx, client, field, afterKey, batchSize)
+}
+
+// BackfillBatchWithClient recomputes the field for up to batchSize pending rows whose key
+// is greater than afterKey. It returns the number of rows selected and the last key processed,
+// which callers pass back as afterKey to continue (or resume) the backfill.
+// A returned count of zero means there are no more pending rows.
+func BackfillBatchWithClient(ctx context.Context, client *bigquery.Client, field BackfillField, afterKey string, batchSize int) (int, string, error) {
+	if batchSize <= 0 {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: batch size must be positive, got %d", batchSize)
+	}
+
+	table := "`" + projectID + "." + datasetID + "." + field.Table + "`"
+
+	// 1. Select the next batch of pending keys
+	selectQ := client.Query(fmt.Sprintf(`
+		SELECT t.%s AS key
+		FROM %s t
+		WHERE %s
+		  AND t.%s > @after_key
+		ORDER BY t.%s
+		LIMIT @batch_size
+	`, field.KeyColumn, table, field.PendingCondition, field.KeyColumn, field.KeyColumn))
+	selectQ.Parameters = []bigquery.QueryParameter{
+		{Name: "after_key", Value: afterKey},
+		{Name: "batch_size", Value: batchSize},
+	}
+
+	it, err := readQuery(ctx, "BackfillBatch", selectQ)
+	if err != nil {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: selecting pending keys: %w", err)
+	}
+
+	var keys []string
+	for {
+		var row struct {
+			Key string `bigquery:"key"`
+		}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, afterKey, fmt.Errorf("BackfillBatch: iterating keys: %w", err)
+		}
+		keys = append(keys, row.Key)
+	}
+
+	if len(keys) == 0 {
+		return 0, afterKey, nil
+	}
+
+	// 2. Recompute the field for the selected keys
+	updateQ := client.Query(fmt.Sprintf(field.UpdateSQL, table))
+	updateQ.Parameters = []bigquery.QueryParameter{
+		{Name: "keys", Value: keys},
+	}
+
+	job, err := updateQ.Run(ctx)
+	if err != nil {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "BackfillBatch", status)
+	if err := status.Err(); err != nil {
+		return 0, afterKey, fmt.Errorf("BackfillBatch: job error: %w", err)
+	}
+
+	return len(keys), keys[len(keys)-1], nil
+}
@@ -5,36 +5,44 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/bigquery"
+	bq "github.com/dvloznov/finance-tracker/internal/bigquery"
 	"google.golang.org/api/iterator"
 )
 
-// ListActiveCategories returns all active categories ordered by depth, parent, name.
-func ListActiveCategories(ctx context.Context) ([]CategoryRow, error) {
+// ListActiveCategories returns the active global categories merged with the custom
+// categories of userID, ordered by name. An empty userID returns only the global ones.
+func ListActiveCategories(ctx context.Context, userID string) ([]CategoryRow, error) {
 	client, err := bigquery.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("ListActiveCategories: bigquery client: %w", err)
 	}
 	defer client.Close()
 
-	return ListActiveCategoriesWithClient(ctx, client)
+	return ListActiveCategoriesWithClient(ctx, client, userID)
 }
 
-// ListActiveCategoriesWithClient returns all active categories ordered by depth, parent, name
-// using the provided BigQuery client.
-func ListActiveCategoriesWithClient(ctx context.Context, client *bigquery.Client) ([]CategoryRow, error) {
+// ListActiveCategoriesWithClient returns the active global categories merged with the
+// custom categories of userID using the provided BigQuery client. A user's category
+// replaces the global one with the same category and subcategory name.
+func ListActiveCategoriesWithClient(ctx context.Context, client *bigquery.Client, userID string) ([]CategoryRow, error) {
 	q := client.Query(`
 		SELECT
 		  category_id,
 		  category_name,
 		  subcategory_name,
+		  user_id,
 		  slug,
 		  is_active
 		FROM finance.categories
 		WHERE is_active = TRUE
+		  AND (user_id IS NULL OR user_id = @user_id)
 		ORDER BY category_name, subcategory_name
 	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "user_id", Value: userID},
+	}
 
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "ListActiveCategories", q)
 	if err != nil {
 		return nil, fmt.Errorf("ListActiveCategories: query read: %w", err)
 	}
@@ -52,5 +60,5 @@ func ListActiveCategoriesWithClient(ctx context.Context, client *bigquery.Client
 		rows = append(rows, r)
 	}
 
-	return rows, nil
+	return bq.MergeUserCategories(rows), nil
 }
@@ -0,0 +1,126 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// ListDocumentCategoryAssignments returns the distinct category/subcategory names and
+// category IDs of the transactions of a document's successful parsing runs.
+func ListDocumentCategoryAssignments(ctx context.Context, documentID string) ([]CategoryAssignment, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListDocumentCategoryAssignments: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ListDocumentCategoryAssignmentsWithClient(ctx, client, documentID)
+}
+
+// ListDocumentCategoryAssignmentsWithClient returns the distinct category assignments of
+// a document's transactions using the provided BigQuery client. NULL names and IDs are
+// returned as empty strings.
+func ListDocumentCategoryAssignmentsWithClient(ctx context.Context, client *bigquery.Client, documentID string) ([]CategoryAssignment, error) {
+	q := client.Query(`
+		SELECT
+			IFNULL(t.category_name, '') AS category_name,
+			IFNULL(t.subcategory_name, '') AS subcategory_name,
+			IFNULL(t.category_id, '') AS category_id,
+			COUNT(*) AS transaction_count
+		FROM ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + ` t
+		JOIN ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + ` pr
+		  ON pr.parsing_run_id = t.parsing_run_id
+		WHERE t.document_id = @document_id
+		  AND pr.status = 'SUCCESS'
+		GROUP BY category_name, subcategory_name, category_id
+		ORDER BY category_name, subcategory_name, category_id
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+	}
+
+	it, err := readQuery(ctx, "ListDocumentCategoryAssignments", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListDocumentCategoryAssignments: query read: %w", err)
+	}
+
+	var rows []CategoryAssignment
+	for {
+		var r CategoryAssignment
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListDocumentCategoryAssignments: iter next: %w", err)
+		}
+		rows = append(rows, r)
+	}
+
+	return rows, nil
+}
+
+// UpdateDocumentCategoryIDs sets the category_id of a document's transactions from the
+// given assignments, matched on category and subcategory name.
+func UpdateDocumentCategoryIDs(ctx context.Context, documentID string, assignments []CategoryAssignment) (int64, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("UpdateDocumentCategoryIDs: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateDocumentCategoryIDsWithClient(ctx, client, documentID, assignments)
+}
+
+// UpdateDocumentCategoryIDsWithClient sets the category_id of a document's transactions
+// from the given assignments in a single UPDATE, using the provided BigQuery client. An
+// empty CategoryID clears the column. Only transactions of successful parsing runs whose
+// category_id differs are updated. Returns the number of transactions updated.
+func UpdateDocumentCategoryIDsWithClient(ctx context.Context, client *bigquery.Client, documentID string, assignments []CategoryAssignment) (int64, error) {
+	if len(assignments) == 0 {
+		return 0, nil
+	}
+
+	q := client.Query(`
+		UPDATE ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + ` t
+		SET category_id = NULLIF(a.category_id, ''),
+		    updated_ts = CURRENT_TIMESTAMP()
+		FROM UNNEST(@assignments) a
+		WHERE t.document_id = @document_id
+		  AND IFNULL(t.category_name, '') = a.category_name
+		  AND IFNULL(t.subcategory_name, '') = a.subcategory_name
+		  AND IFNULL(t.category_id, '') != a.category_id
+		  AND t.parsing_run_id IN (
+			SELECT parsing_run_id
+			FROM ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + `
+			WHERE status = 'SUCCESS'
+		  )
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+		{Name: "assignments", Value: assignments},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("UpdateDocumentCategoryIDs: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("UpdateDocumentCategoryIDs: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "UpdateDocumentCategoryIDs", status)
+	if err := status.Err(); err != nil {
+		return 0, fmt.Errorf("UpdateDocumentCategoryIDs: job error: %w", err)
+	}
+
+	var affected int64
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		affected = stats.NumDMLAffectedRows
+	}
+	return affected, nil
+}
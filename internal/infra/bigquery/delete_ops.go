@@ -59,6 +59,7 @@ func deleteTransactions(ctx context.Context, client *bigquery.Client, documentID
 	if err != nil {
 		return fmt.Errorf("wait for job: %w", err)
 	}
+	logQueryStats(ctx, "deleteTransactions", status)
 
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("job error: %w", err)
@@ -85,6 +86,7 @@ func deleteModelOutputs(ctx context.Context, client *bigquery.Client, documentID
 	if err != nil {
 		return fmt.Errorf("wait for job: %w", err)
 	}
+	logQueryStats(ctx, "deleteModelOutputs", status)
 
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("job error: %w", err)
@@ -111,6 +113,7 @@ func deleteParsingRuns(ctx context.Context, client *bigquery.Client, documentID
 	if err != nil {
 		return fmt.Errorf("wait for job: %w", err)
 	}
+	logQueryStats(ctx, "deleteParsingRuns", status)
 
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("job error: %w", err)
@@ -137,6 +140,7 @@ func deleteDocumentRecord(ctx context.Context, client *bigquery.Client, document
 	if err != nil {
 		return fmt.Errorf("wait for job: %w", err)
 	}
+	logQueryStats(ctx, "deleteDocumentRecord", status)
 
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("job error: %w", err)
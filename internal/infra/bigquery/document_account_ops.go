@@ -0,0 +1,94 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// ReassignDocumentAccount moves a document and all its transactions to another account.
+func ReassignDocumentAccount(ctx context.Context, documentID, accountID string) (bool, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ReassignDocumentAccountWithClient(ctx, client, documentID, accountID)
+}
+
+// ReassignDocumentAccountWithClient sets the account_id of a document and of the
+// transactions of all its parsing runs, using the provided BigQuery client. It returns
+// false if the document does not exist and an error wrapping ErrAccountNotFound if the
+// account does not. Both updates run in a single transaction.
+func ReassignDocumentAccountWithClient(ctx context.Context, client *bigquery.Client, documentID, accountID string) (bool, error) {
+	if documentID == "" || accountID == "" {
+		return false, fmt.Errorf("ReassignDocumentAccount: document and account IDs cannot be empty")
+	}
+
+	table := func(name string) string {
+		return "`" + projectID + "." + datasetID + "." + name + "`"
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+		{Name: "account_id", Value: accountID},
+	}
+
+	check := client.Query(`
+		SELECT
+			(SELECT COUNT(*) FROM ` + table(documentsTable) + ` WHERE document_id = @document_id) AS documents,
+			(SELECT COUNT(*) FROM ` + table("accounts") + ` WHERE account_id = @account_id) AS accounts
+	`)
+	check.Parameters = params
+
+	it, err := readQuery(ctx, "ReassignDocumentAccount", check)
+	if err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: query read: %w", err)
+	}
+	var counts struct {
+		Documents int64 `bigquery:"documents"`
+		Accounts  int64 `bigquery:"accounts"`
+	}
+	if err := it.Next(&counts); err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: iter next: %w", err)
+	}
+	if counts.Documents == 0 {
+		return false, nil
+	}
+	if counts.Accounts == 0 {
+		return false, fmt.Errorf("ReassignDocumentAccount: %w: %s", ErrAccountNotFound, accountID)
+	}
+
+	q := client.Query(`
+		BEGIN TRANSACTION;
+
+		UPDATE ` + table(transactionsTable) + `
+		SET account_id = @account_id,
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE document_id = @document_id;
+
+		UPDATE ` + table(documentsTable) + `
+		SET account_id = @account_id
+		WHERE document_id = @document_id;
+
+		COMMIT TRANSACTION;
+	`)
+	q.Parameters = params
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "ReassignDocumentAccount", status)
+	if err := status.Err(); err != nil {
+		return false, fmt.Errorf("ReassignDocumentAccount: job error: %w", err)
+	}
+
+	return true, nil
+}
@@ -6,3 +6,5 @@ import (
 
 // Re-export types from shared package for backward compatibility
 type DocumentRow = bq.DocumentRow
+type DocumentWithStats = bq.DocumentWithStats
+type DocumentStatus = bq.DocumentStatus
@@ -3,8 +3,11 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"math/big"
 
 	"cloud.google.com/go/bigquery"
+	bq "github.com/dvloznov/finance-tracker/internal/bigquery"
+	"google.golang.org/api/iterator"
 )
 
 const documentsTable = "documents"
@@ -24,48 +27,108 @@ func InsertDocument(ctx context.Context, row *DocumentRow) error {
 // using the provided BigQuery client.
 // Uses INSERT query instead of streaming API to allow immediate UPDATEs.
 func InsertDocumentWithClient(ctx context.Context, client *bigquery.Client, row *DocumentRow) error {
+	params, err := documentParams(row)
+	if err != nil {
+		return fmt.Errorf("InsertDocument: %w", err)
+	}
+
 	q := client.Query(fmt.Sprintf(`
-		INSERT %s.%s (
-			document_id,
-			user_id,
-			gcs_uri,
-			document_type,
-			source_system,
-			institution_id,
-			account_id,
-			statement_start_date,
-			statement_end_date,
-			upload_ts,
-			processed_ts,
-			parsing_status,
-			original_filename,
-			file_mime_type,
-			text_gcs_uri,
-			checksum_sha256,
-			metadata
-		)
-		VALUES (
-			@document_id,
-			@user_id,
-			@gcs_uri,
-			@document_type,
-			@source_system,
-			@institution_id,
-			@account_id,
-			@statement_start_date,
-			@statement_end_date,
-			@upload_ts,
-			@processed_ts,
-			@parsing_status,
-			@original_filename,
-			@file_mime_type,
-			@text_gcs_uri,
-			@checksum_sha256,
-			@metadata
-		)
-	`, datasetID, documentsTable))
+		INSERT %s.%s (%s)
+		VALUES (%s)
+	`, datasetID, documentsTable, documentColumns, documentValues))
+	q.Parameters = params
 
-	q.Parameters = []bigquery.QueryParameter{
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertDocument: running insert query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertDocument: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "InsertDocument", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("InsertDocument: job error: %w", err)
+	}
+
+	return nil
+}
+
+// InsertDocumentIfAbsent inserts a DocumentRow into finance.documents unless a document
+// with the same document_id exists. It reports whether the row was inserted.
+func InsertDocumentIfAbsent(ctx context.Context, row *DocumentRow) (bool, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("InsertDocumentIfAbsent: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return InsertDocumentIfAbsentWithClient(ctx, client, row)
+}
+
+// InsertDocumentIfAbsentWithClient inserts a DocumentRow unless its document_id exists,
+// using the provided BigQuery client. The check and the insert are one MERGE statement,
+// so concurrent inserts of the same document_id store a single row.
+func InsertDocumentIfAbsentWithClient(ctx context.Context, client *bigquery.Client, row *DocumentRow) (bool, error) {
+	params, err := documentParams(row)
+	if err != nil {
+		return false, fmt.Errorf("InsertDocumentIfAbsent: %w", err)
+	}
+
+	q := client.Query(fmt.Sprintf(`
+		MERGE %s.%s AS t
+		USING (SELECT @document_id AS document_id) AS s
+		ON t.document_id = s.document_id
+		WHEN NOT MATCHED THEN
+			INSERT (%s)
+			VALUES (%s)
+	`, datasetID, documentsTable, documentColumns, documentValues))
+	q.Parameters = params
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return false, fmt.Errorf("InsertDocumentIfAbsent: running merge query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return false, fmt.Errorf("InsertDocumentIfAbsent: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "InsertDocumentIfAbsent", status)
+	if err := status.Err(); err != nil {
+		return false, fmt.Errorf("InsertDocumentIfAbsent: job error: %w", err)
+	}
+
+	stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics)
+	return ok && stats.NumDMLAffectedRows > 0, nil
+}
+
+// documentColumns and documentValues list the columns of a document row and the query
+// parameters documentParams binds to them.
+const (
+	documentColumns = `document_id, user_id, gcs_uri, document_type, source_system, institution_id,
+			account_id, statement_start_date, statement_end_date, opening_balance, closing_balance,
+			upload_ts, processed_ts, parsing_status, original_filename, file_mime_type, text_gcs_uri,
+			checksum_sha256, metadata`
+	documentValues = `@document_id, @user_id, @gcs_uri, @document_type, @source_system, @institution_id,
+			@account_id, @statement_start_date, @statement_end_date, @opening_balance, @closing_balance,
+			@upload_ts, @processed_ts, @parsing_status, @original_filename, @file_mime_type, @text_gcs_uri,
+			@checksum_sha256, @metadata`
+)
+
+// documentParams returns the query parameters for inserting row.
+func documentParams(row *DocumentRow) ([]bigquery.QueryParameter, error) {
+	openingBalance, err := numericParam(row.OpeningBalance)
+	if err != nil {
+		return nil, fmt.Errorf("opening_balance: %w", err)
+	}
+	closingBalance, err := numericParam(row.ClosingBalance)
+	if err != nil {
+		return nil, fmt.Errorf("closing_balance: %w", err)
+	}
+
+	return []bigquery.QueryParameter{
 		{Name: "document_id", Value: row.DocumentID},
 		{Name: "user_id", Value: row.UserID},
 		{Name: "gcs_uri", Value: row.GCSURI},
@@ -75,6 +138,8 @@ func InsertDocumentWithClient(ctx context.Context, client *bigquery.Client, row
 		{Name: "account_id", Value: row.AccountID},
 		{Name: "statement_start_date", Value: row.StatementStartDate},
 		{Name: "statement_end_date", Value: row.StatementEndDate},
+		{Name: "opening_balance", Value: openingBalance},
+		{Name: "closing_balance", Value: closingBalance},
 		{Name: "upload_ts", Value: row.UploadTS},
 		{Name: "processed_ts", Value: row.ProcessedTS},
 		{Name: "parsing_status", Value: row.ParsingStatus},
@@ -83,22 +148,7 @@ func InsertDocumentWithClient(ctx context.Context, client *bigquery.Client, row
 		{Name: "text_gcs_uri", Value: row.TextGCSURI},
 		{Name: "checksum_sha256", Value: row.ChecksumSHA256},
 		{Name: "metadata", Value: row.Metadata},
-	}
-
-	job, err := q.Run(ctx)
-	if err != nil {
-		return fmt.Errorf("InsertDocument: running insert query: %w", err)
-	}
-
-	status, err := job.Wait(ctx)
-	if err != nil {
-		return fmt.Errorf("InsertDocument: waiting for job: %w", err)
-	}
-	if err := status.Err(); err != nil {
-		return fmt.Errorf("InsertDocument: job error: %w", err)
-	}
-
-	return nil
+	}, nil
 }
 
 // UpdateDocumentParsingStatus updates the parsing_status field for a document.
@@ -113,8 +163,15 @@ func UpdateDocumentParsingStatus(ctx context.Context, documentID, status string)
 }
 
 // UpdateDocumentParsingStatusWithClient updates the parsing_status field for a document
-// using the provided BigQuery client.
+// using the provided BigQuery client. Transient failures are retried with backoff.
 func UpdateDocumentParsingStatusWithClient(ctx context.Context, client *bigquery.Client, documentID, status string) error {
+	return retryStatusUpdate(ctx, "UpdateDocumentParsingStatus", func() error {
+		return updateDocumentParsingStatusWithClient(ctx, client, documentID, status)
+	})
+}
+
+// updateDocumentParsingStatusWithClient runs a single parsing_status update.
+func updateDocumentParsingStatusWithClient(ctx context.Context, client *bigquery.Client, documentID, status string) error {
 	query := client.Query(`
 		UPDATE ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
 		SET parsing_status = @status
@@ -134,6 +191,7 @@ func UpdateDocumentParsingStatusWithClient(ctx context.Context, client *bigquery
 	if err != nil {
 		return fmt.Errorf("UpdateDocumentParsingStatus: job wait: %w", err)
 	}
+	logQueryStats(ctx, "UpdateDocumentParsingStatus", status2)
 
 	if status2.Err() != nil {
 		return fmt.Errorf("UpdateDocumentParsingStatus: job error: %w", status2.Err())
@@ -141,3 +199,262 @@ func UpdateDocumentParsingStatusWithClient(ctx context.Context, client *bigquery
 
 	return nil
 }
+
+// TransitionDocumentStatus moves a document to the given status if its current status
+// allows it. Otherwise it returns an error wrapping ErrInvalidStatusTransition.
+func TransitionDocumentStatus(ctx context.Context, documentID string, to DocumentStatus) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("TransitionDocumentStatus: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return TransitionDocumentStatusWithClient(ctx, client, documentID, to)
+}
+
+// TransitionDocumentStatusWithClient moves a document to the given status using the
+// provided BigQuery client. The check and the update are a single statement, so a
+// concurrent transition cannot slip in between them. Transient failures are retried
+// with backoff.
+func TransitionDocumentStatusWithClient(ctx context.Context, client *bigquery.Client, documentID string, to DocumentStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("TransitionDocumentStatus: %w: unknown status %q", bq.ErrInvalidStatusTransition, to)
+	}
+
+	retrying := false
+	return retryStatusUpdate(ctx, "TransitionDocumentStatus", func() error {
+		err := transitionDocumentStatusWithClient(ctx, client, documentID, to, retrying)
+		retrying = true
+		return err
+	})
+}
+
+// transitionDocumentStatusWithClient runs a single status transition. When retrying, an
+// earlier attempt may have committed despite reporting an error, so a document already
+// in the target status counts as transitioned.
+func transitionDocumentStatusWithClient(ctx context.Context, client *bigquery.Client, documentID string, to DocumentStatus, retrying bool) error {
+	query := client.Query(`
+		UPDATE ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
+		SET parsing_status = @to
+		WHERE document_id = @document_id
+		  AND (parsing_status IS NULL
+		       OR parsing_status IN UNNEST(@allowed_from)
+		       OR parsing_status NOT IN UNNEST(@known))
+	`)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "to", Value: string(to)},
+		{Name: "document_id", Value: documentID},
+		{Name: "allowed_from", Value: statusStrings(to.AllowedFrom())},
+		{Name: "known", Value: statusStrings(bq.DocumentStatuses)},
+	}
+
+	job, err := query.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("TransitionDocumentStatus: query run: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("TransitionDocumentStatus: job wait: %w", err)
+	}
+	logQueryStats(ctx, "TransitionDocumentStatus", status)
+
+	if status.Err() != nil {
+		return fmt.Errorf("TransitionDocumentStatus: job error: %w", status.Err())
+	}
+
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok && stats.NumDMLAffectedRows > 0 {
+		return nil
+	}
+
+	// Nothing was updated: report why
+	current, found, err := documentStatusWithClient(ctx, client, documentID)
+	if err != nil {
+		return fmt.Errorf("TransitionDocumentStatus: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("TransitionDocumentStatus: document %s not found", documentID)
+	}
+	if retrying && current == to {
+		return nil
+	}
+	if err := bq.ValidateDocumentTransition(current, to); err != nil {
+		return fmt.Errorf("TransitionDocumentStatus: document %s: %w", documentID, err)
+	}
+	// The status changed to one allowing the transition after the update ran
+	return fmt.Errorf("TransitionDocumentStatus: document %s: %w: status changed concurrently", documentID, bq.ErrInvalidStatusTransition)
+}
+
+// documentStatusWithClient returns the parsing_status of a document and whether it exists.
+func documentStatusWithClient(ctx context.Context, client *bigquery.Client, documentID string) (DocumentStatus, bool, error) {
+	q := client.Query(`
+		SELECT IFNULL(parsing_status, '') AS parsing_status
+		FROM ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
+		WHERE document_id = @document_id
+		LIMIT 1
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+	}
+
+	it, err := readQuery(ctx, "DocumentStatus", q)
+	if err != nil {
+		return "", false, fmt.Errorf("reading document status: %w", err)
+	}
+
+	var row struct {
+		ParsingStatus string `bigquery:"parsing_status"`
+	}
+	err = it.Next(&row)
+	if err == iterator.Done {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("reading document status: %w", err)
+	}
+	return DocumentStatus(row.ParsingStatus), true, nil
+}
+
+// statusStrings converts statuses to strings for use as an ARRAY<STRING> query parameter.
+func statusStrings(statuses []DocumentStatus) []string {
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = string(s)
+	}
+	return out
+}
+
+// UpdateDocumentInstitution sets the institution_id field of a document.
+func UpdateDocumentInstitution(ctx context.Context, documentID, institutionID string) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentInstitution: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateDocumentInstitutionWithClient(ctx, client, documentID, institutionID)
+}
+
+// UpdateDocumentInstitutionWithClient sets the institution_id field of a document
+// using the provided BigQuery client.
+func UpdateDocumentInstitutionWithClient(ctx context.Context, client *bigquery.Client, documentID, institutionID string) error {
+	query := client.Query(`
+		UPDATE ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
+		SET institution_id = @institution_id
+		WHERE document_id = @document_id
+	`)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "institution_id", Value: institutionID},
+		{Name: "document_id", Value: documentID},
+	}
+
+	job, err := query.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentInstitution: query run: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentInstitution: job wait: %w", err)
+	}
+	logQueryStats(ctx, "UpdateDocumentInstitution", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("UpdateDocumentInstitution: job error: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateDocumentMetadata replaces the metadata JSON of a document.
+func UpdateDocumentMetadata(ctx context.Context, documentID string, metadata bigquery.NullJSON) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentMetadata: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateDocumentMetadataWithClient(ctx, client, documentID, metadata)
+}
+
+// UpdateDocumentMetadataWithClient replaces the metadata JSON of a document using the
+// provided BigQuery client.
+func UpdateDocumentMetadataWithClient(ctx context.Context, client *bigquery.Client, documentID string, metadata bigquery.NullJSON) error {
+	query := client.Query(`
+		UPDATE ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
+		SET metadata = @metadata
+		WHERE document_id = @document_id
+	`)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "metadata", Value: metadata},
+		{Name: "document_id", Value: documentID},
+	}
+
+	job, err := query.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentMetadata: query run: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentMetadata: job wait: %w", err)
+	}
+	logQueryStats(ctx, "UpdateDocumentMetadata", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("UpdateDocumentMetadata: job error: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateDocumentBalances sets the opening and closing balances of a document; nil
+// clears a balance.
+func UpdateDocumentBalances(ctx context.Context, documentID string, opening, closing *big.Rat) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateDocumentBalancesWithClient(ctx, client, documentID, opening, closing)
+}
+
+// UpdateDocumentBalancesWithClient sets the opening and closing balances of a document
+// using the provided BigQuery client.
+func UpdateDocumentBalancesWithClient(ctx context.Context, client *bigquery.Client, documentID string, opening, closing *big.Rat) error {
+	openingParam, err := numericParam(opening)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: opening_balance: %w", err)
+	}
+	closingParam, err := numericParam(closing)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: closing_balance: %w", err)
+	}
+
+	query := client.Query(`
+		UPDATE ` + "`" + projectID + "." + datasetID + "." + documentsTable + "`" + `
+		SET opening_balance = @opening_balance,
+		    closing_balance = @closing_balance
+		WHERE document_id = @document_id
+	`)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "opening_balance", Value: openingParam},
+		{Name: "closing_balance", Value: closingParam},
+		{Name: "document_id", Value: documentID},
+	}
+
+	job, err := query.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: query run: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: job wait: %w", err)
+	}
+	logQueryStats(ctx, "UpdateDocumentBalances", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("UpdateDocumentBalances: job error: %w", err)
+	}
+
+	return nil
+}
@@ -32,6 +32,8 @@ func ListAllDocumentsWithClient(ctx context.Context, client *bigquery.Client) ([
 			account_id,
 			statement_start_date,
 			statement_end_date,
+			opening_balance,
+			closing_balance,
 			upload_ts,
 			processed_ts,
 			parsing_status,
@@ -45,7 +47,7 @@ func ListAllDocumentsWithClient(ctx context.Context, client *bigquery.Client) ([
 	`, projectID, datasetID)
 
 	q := client.Query(query)
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "ListAllDocuments", q)
 	if err != nil {
 		return nil, fmt.Errorf("ListAllDocumentsWithClient: reading query: %w", err)
 	}
@@ -91,6 +93,8 @@ func FindDocumentByChecksumWithClient(ctx context.Context, client *bigquery.Clie
 			account_id,
 			statement_start_date,
 			statement_end_date,
+			opening_balance,
+			closing_balance,
 			upload_ts,
 			processed_ts,
 			parsing_status,
@@ -109,7 +113,7 @@ func FindDocumentByChecksumWithClient(ctx context.Context, client *bigquery.Clie
 		{Name: "checksum", Value: checksum},
 	}
 
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "FindDocumentByChecksum", q)
 	if err != nil {
 		return nil, fmt.Errorf("FindDocumentByChecksumWithClient: reading query: %w", err)
 	}
@@ -126,3 +130,144 @@ func FindDocumentByChecksumWithClient(ctx context.Context, client *bigquery.Clie
 
 	return &row, nil
 }
+
+// GetDocument retrieves a document by its ID.
+// Returns nil if no document with the given ID exists.
+func GetDocument(ctx context.Context, documentID string) (*DocumentRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("GetDocument: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return GetDocumentWithClient(ctx, client, documentID)
+}
+
+// GetDocumentWithClient retrieves a document by ID using the provided BigQuery client.
+func GetDocumentWithClient(ctx context.Context, client *bigquery.Client, documentID string) (*DocumentRow, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			document_id,
+			user_id,
+			gcs_uri,
+			document_type,
+			source_system,
+			institution_id,
+			account_id,
+			statement_start_date,
+			statement_end_date,
+			opening_balance,
+			closing_balance,
+			upload_ts,
+			processed_ts,
+			parsing_status,
+			original_filename,
+			file_mime_type,
+			text_gcs_uri,
+			checksum_sha256,
+			metadata
+		FROM `+"`%s.%s.documents`"+`
+		WHERE document_id = @document_id
+		LIMIT 1
+	`, projectID, datasetID)
+
+	q := client.Query(query)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+	}
+
+	it, err := readQuery(ctx, "GetDocument", q)
+	if err != nil {
+		return nil, fmt.Errorf("GetDocumentWithClient: reading query: %w", err)
+	}
+
+	var row DocumentRow
+	err = it.Next(&row)
+	if err == iterator.Done {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetDocumentWithClient: reading row: %w", err)
+	}
+
+	return &row, nil
+}
+
+// ListAllDocumentsWithStats retrieves all documents together with the transaction count and
+// totals of their active parsing run.
+func ListAllDocumentsWithStats(ctx context.Context) ([]*DocumentWithStats, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListAllDocumentsWithStats: creating client: %w", err)
+	}
+	defer client.Close()
+
+	return ListAllDocumentsWithStatsWithClient(ctx, client)
+}
+
+// ListAllDocumentsWithStatsWithClient retrieves all documents with transaction stats using the
+// provided BigQuery client. Only transactions of SUCCESS parsing runs are counted.
+func ListAllDocumentsWithStatsWithClient(ctx context.Context, client *bigquery.Client) ([]*DocumentWithStats, error) {
+	query := fmt.Sprintf(`
+		WITH stats AS (
+			SELECT
+				t.document_id,
+				COUNT(*) AS transaction_count,
+				SUM(IF(t.amount > 0, t.amount, 0)) AS total_in,
+				SUM(IF(t.amount < 0, -t.amount, 0)) AS total_out
+			FROM `+"`%[1]s.%[2]s.transactions`"+` t
+			INNER JOIN `+"`%[1]s.%[2]s.parsing_runs`"+` pr
+			  ON t.parsing_run_id = pr.parsing_run_id
+			WHERE pr.status = 'SUCCESS'
+			GROUP BY t.document_id
+		)
+		SELECT
+			d.document_id,
+			d.user_id,
+			d.gcs_uri,
+			d.document_type,
+			d.source_system,
+			d.institution_id,
+			d.account_id,
+			d.statement_start_date,
+			d.statement_end_date,
+			d.opening_balance,
+			d.closing_balance,
+			d.upload_ts,
+			d.processed_ts,
+			d.parsing_status,
+			d.original_filename,
+			d.file_mime_type,
+			d.text_gcs_uri,
+			d.checksum_sha256,
+			d.metadata,
+			COALESCE(s.transaction_count, 0) AS transaction_count,
+			COALESCE(s.total_in, 0) AS total_in,
+			COALESCE(s.total_out, 0) AS total_out
+		FROM `+"`%[1]s.%[2]s.documents`"+` d
+		LEFT JOIN stats s
+		  ON s.document_id = d.document_id
+		ORDER BY d.upload_ts DESC
+	`, projectID, datasetID)
+
+	q := client.Query(query)
+	it, err := readQuery(ctx, "ListAllDocumentsWithStats", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListAllDocumentsWithStatsWithClient: reading query: %w", err)
+	}
+
+	var documents []*DocumentWithStats
+	for {
+		var row DocumentWithStats
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListAllDocumentsWithStatsWithClient: iterating: %w", err)
+		}
+		documents = append(documents, &row)
+	}
+
+	return documents, nil
+}
@@ -0,0 +1,161 @@
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// Tables that can be exported and imported.
+const (
+	ExportCategories   = "categories"
+	ExportAccounts     = "accounts"
+	ExportDocuments    = "documents"
+	ExportParsingRuns  = "parsing_runs"
+	ExportTransactions = "transactions"
+)
+
+// ExportTableNames lists the exportable tables in dependency order, so a backup can be
+// restored by importing them in this order.
+var ExportTableNames = []string{
+	ExportCategories,
+	ExportAccounts,
+	ExportDocuments,
+	ExportParsingRuns,
+	ExportTransactions,
+}
+
+// exportTable describes how to read one table for export.
+type exportTable struct {
+	newRow  func() interface{}
+	orderBy string
+}
+
+var exportTables = map[string]exportTable{
+	ExportCategories:   {newRow: func() interface{} { return &CategoryRow{} }, orderBy: "category_id"},
+	ExportAccounts:     {newRow: func() interface{} { return &AccountRow{} }, orderBy: "account_id"},
+	ExportDocuments:    {newRow: func() interface{} { return &DocumentRow{} }, orderBy: "upload_ts, document_id"},
+	ExportParsingRuns:  {newRow: func() interface{} { return &ParsingRunRow{} }, orderBy: "started_ts, parsing_run_id"},
+	ExportTransactions: {newRow: func() interface{} { return &TransactionRow{} }, orderBy: "transaction_date, transaction_id"},
+}
+
+// ExportOptions narrows an export. Zero values mean "everything".
+type ExportOptions struct {
+	// StartDate and EndDate bound transaction_date, inclusive. They apply to transactions only.
+	StartDate time.Time
+	EndDate   time.Time
+
+	// PageSize is the number of rows fetched from BigQuery per request. 0 uses
+	// DefaultReadPageSize.
+	PageSize int
+}
+
+// ExportTable streams every row of table to fn, one at a time. The row passed to fn is a
+// pointer to the table's row type, e.g. *TransactionRow.
+func ExportTable(ctx context.Context, table string, opts ExportOptions, fn func(row interface{}) error) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("ExportTable: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ExportTableWithClient(ctx, client, table, opts, fn)
+}
+
+// ExportTableWithClient streams every row of table to fn using the provided BigQuery client.
+// Rows are read page by page, so the table never has to fit in memory.
+func ExportTableWithClient(ctx context.Context, client *bigquery.Client, table string, opts ExportOptions, fn func(row interface{}) error) error {
+	def, ok := exportTables[table]
+	if !ok {
+		return fmt.Errorf("ExportTable: unsupported table %q", table)
+	}
+
+	where := "TRUE"
+	var params []bigquery.QueryParameter
+	if table == ExportTransactions {
+		if !opts.StartDate.IsZero() {
+			where += " AND transaction_date >= @start_date"
+			params = append(params, bigquery.QueryParameter{Name: "start_date", Value: opts.StartDate.Format(dateFormat)})
+		}
+		if !opts.EndDate.IsZero() {
+			where += " AND transaction_date <= @end_date"
+			params = append(params, bigquery.QueryParameter{Name: "end_date", Value: opts.EndDate.Format(dateFormat)})
+		}
+	}
+
+	q := client.Query(fmt.Sprintf(`
+		SELECT *
+		FROM `+"`%s.%s.%s`"+`
+		WHERE %s
+		ORDER BY %s
+	`, projectID, datasetID, table, where, def.orderBy))
+	q.Parameters = params
+
+	it, err := readQuery(ctx, "ExportTable", q)
+	if err != nil {
+		return fmt.Errorf("ExportTable: reading %s: %w", table, err)
+	}
+	it.PageInfo().MaxSize = readPageSize(opts.PageSize)
+
+	for {
+		row := def.newRow()
+		err := it.Next(row)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ExportTable: iterating %s: %w", table, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// MarshalExportRow encodes a row for an export file. NUMERIC columns are written as
+// decimal strings with all NUMERIC fractional digits, so a backup restores amounts
+// exactly; the JSON encoding the API uses rounds them for display.
+func MarshalExportRow(row interface{}) ([]byte, error) {
+	switch r := row.(type) {
+	case *TransactionRow:
+		type exportRow TransactionRow // without TransactionRow.MarshalJSON
+		return json.Marshal(&struct {
+			Amount         *string `json:"amount"`
+			BalanceAfter   *string `json:"balance_after,omitempty"`
+			OriginalAmount *string `json:"original_amount,omitempty"`
+			*exportRow
+		}{
+			Amount:         exportNumeric(r.Amount),
+			BalanceAfter:   exportNumeric(r.BalanceAfter),
+			OriginalAmount: exportNumeric(r.OriginalAmount),
+			exportRow:      (*exportRow)(r),
+		})
+	case *DocumentRow:
+		type exportRow DocumentRow // without DocumentRow.MarshalJSON
+		return json.Marshal(&struct {
+			OpeningBalance *string `json:"opening_balance,omitempty"`
+			ClosingBalance *string `json:"closing_balance,omitempty"`
+			*exportRow
+		}{
+			OpeningBalance: exportNumeric(r.OpeningBalance),
+			ClosingBalance: exportNumeric(r.ClosingBalance),
+			exportRow:      (*exportRow)(r),
+		})
+	default:
+		return json.Marshal(row)
+	}
+}
+
+// exportNumeric renders a NUMERIC value exactly, or returns nil for NULL.
+func exportNumeric(r *big.Rat) *string {
+	if r == nil {
+		return nil
+	}
+	s := r.FloatString(bigquery.NumericScaleDigits)
+	return &s
+}
@@ -0,0 +1,232 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// importKeyColumns maps each importable table to its primary key column.
+var importKeyColumns = map[string]string{
+	ExportCategories:   "category_id",
+	ExportAccounts:     "account_id",
+	ExportDocuments:    "document_id",
+	ExportParsingRuns:  "parsing_run_id",
+	ExportTransactions: "transaction_id",
+}
+
+// ImportKeys holds the identifiers already present in a table.
+type ImportKeys struct {
+	// IDs holds the table's primary keys.
+	IDs map[string]bool
+
+	// Checksums holds document checksums; it is only populated for documents.
+	Checksums map[string]bool
+}
+
+// NewImportRow returns a pointer to an empty row of table's type, e.g. *TransactionRow,
+// for decoding an exported row into.
+func NewImportRow(table string) (interface{}, error) {
+	def, ok := exportTables[table]
+	if !ok {
+		return nil, fmt.Errorf("NewImportRow: unsupported table %q", table)
+	}
+	return def.newRow(), nil
+}
+
+// ExistingImportKeys returns the identifiers already present in table.
+func ExistingImportKeys(ctx context.Context, table string) (*ImportKeys, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ExistingImportKeys: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ExistingImportKeysWithClient(ctx, client, table)
+}
+
+// ExistingImportKeysWithClient returns the identifiers already present in table using the
+// provided BigQuery client.
+func ExistingImportKeysWithClient(ctx context.Context, client *bigquery.Client, table string) (*ImportKeys, error) {
+	keyColumn, ok := importKeyColumns[table]
+	if !ok {
+		return nil, fmt.Errorf("ExistingImportKeys: unsupported table %q", table)
+	}
+
+	checksumColumn := "CAST(NULL AS STRING)"
+	if table == ExportDocuments {
+		checksumColumn = "checksum_sha256"
+	}
+
+	q := client.Query(fmt.Sprintf(`
+		SELECT %s AS id, %s AS checksum
+		FROM `+"`%s.%s.%s`"+`
+	`, keyColumn, checksumColumn, projectID, datasetID, table))
+
+	it, err := readQuery(ctx, "ExistingImportKeys", q)
+	if err != nil {
+		return nil, fmt.Errorf("ExistingImportKeys: reading %s: %w", table, err)
+	}
+
+	keys := &ImportKeys{IDs: map[string]bool{}, Checksums: map[string]bool{}}
+	for {
+		var r struct {
+			ID       string              `bigquery:"id"`
+			Checksum bigquery.NullString `bigquery:"checksum"`
+		}
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ExistingImportKeys: iterating %s: %w", table, err)
+		}
+		keys.IDs[r.ID] = true
+		if r.Checksum.Valid && r.Checksum.StringVal != "" {
+			keys.Checksums[r.Checksum.StringVal] = true
+		}
+	}
+
+	return keys, nil
+}
+
+// ImportRows inserts rows previously exported from table. Each row must be a pointer to
+// the table's row type, as returned by NewImportRow.
+func ImportRows(ctx context.Context, table string, rows []interface{}) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("ImportRows: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ImportRowsWithClient(ctx, client, table, rows)
+}
+
+// ImportRowsWithClient inserts rows previously exported from table using the provided
+// BigQuery client. Rows keep their original IDs. Transactions are inserted in one statement;
+// other tables are inserted row by row through their regular insert paths.
+func ImportRowsWithClient(ctx context.Context, client *bigquery.Client, table string, rows []interface{}) error {
+	if table == ExportTransactions {
+		txs := make([]*TransactionRow, 0, len(rows))
+		for _, row := range rows {
+			tx, ok := row.(*TransactionRow)
+			if !ok {
+				return fmt.Errorf("ImportRows: %s row is %T", table, row)
+			}
+			txs = append(txs, tx)
+		}
+		return InsertTransactionsWithClient(ctx, client, txs)
+	}
+
+	for _, row := range rows {
+		var err error
+		switch r := row.(type) {
+		case *CategoryRow:
+			err = InsertCategoryWithClient(ctx, client, r)
+		case *AccountRow:
+			err = InsertAccountWithClient(ctx, client, r)
+		case *DocumentRow:
+			err = InsertDocumentWithClient(ctx, client, r)
+		case *ParsingRunRow:
+			err = InsertParsingRunWithClient(ctx, client, r)
+		default:
+			err = fmt.Errorf("unsupported row type %T", row)
+		}
+		if err != nil {
+			return fmt.Errorf("ImportRows: %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// InsertCategoryWithClient inserts row into finance.categories using the provided BigQuery client.
+func InsertCategoryWithClient(ctx context.Context, client *bigquery.Client, row *CategoryRow) error {
+	q := client.Query(`
+		INSERT INTO ` + "`" + projectID + "." + datasetID + ".categories" + "`" + ` (
+			category_id, category_name, subcategory_name, user_id, slug,
+			description, is_active, created_ts, retired_ts, metadata
+		)
+		VALUES (
+			@category_id, @category_name, @subcategory_name, @user_id, @slug,
+			@description, @is_active, @created_ts, @retired_ts, @metadata
+		)
+	`)
+
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "category_id", Value: row.CategoryID},
+		{Name: "category_name", Value: row.CategoryName},
+		{Name: "subcategory_name", Value: row.SubcategoryName},
+		{Name: "user_id", Value: row.UserID},
+		{Name: "slug", Value: row.Slug},
+		{Name: "description", Value: row.Description},
+		{Name: "is_active", Value: row.IsActive},
+		{Name: "created_ts", Value: row.CreatedTS},
+		{Name: "retired_ts", Value: row.RetiredTS},
+		{Name: "metadata", Value: row.Metadata},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertCategory: running insert query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertCategory: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "InsertCategory", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("InsertCategory: job error: %w", err)
+	}
+
+	return nil
+}
+
+// InsertParsingRunWithClient inserts a complete parsing run row into finance.parsing_runs
+// using the provided BigQuery client. New runs are started with StartParsingRun instead.
+func InsertParsingRunWithClient(ctx context.Context, client *bigquery.Client, row *ParsingRunRow) error {
+	q := client.Query(fmt.Sprintf(`
+		INSERT %s.%s (
+			parsing_run_id, document_id, started_ts, finished_ts,
+			parser_type, parser_version, status, error_message,
+			tokens_input, tokens_output, metadata
+		)
+		VALUES (
+			@parsing_run_id, @document_id, @started_ts, @finished_ts,
+			@parser_type, @parser_version, @status, @error_message,
+			@tokens_input, @tokens_output, @metadata
+		)
+	`, datasetID, parsingRunsTable))
+
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "parsing_run_id", Value: row.ParsingRunID},
+		{Name: "document_id", Value: row.DocumentID},
+		{Name: "started_ts", Value: row.StartedTS},
+		{Name: "finished_ts", Value: row.FinishedTS},
+		{Name: "parser_type", Value: row.ParserType},
+		{Name: "parser_version", Value: row.ParserVersion},
+		{Name: "status", Value: row.Status},
+		{Name: "error_message", Value: row.ErrorMessage},
+		{Name: "tokens_input", Value: row.TokensInput},
+		{Name: "tokens_output", Value: row.TokensOutput},
+		{Name: "metadata", Value: row.Metadata},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertParsingRun: running insert query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("InsertParsingRun: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "InsertParsingRun", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("InsertParsingRun: job error: %w", err)
+	}
+
+	return nil
+}
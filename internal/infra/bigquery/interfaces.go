@@ -3,6 +3,7 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	"cloud.google.com/go/bigquery"
@@ -55,6 +56,21 @@ func (r *BigQueryAccountRepository) ListAllAccounts(ctx context.Context) ([]*Acc
 	return ListAllAccountsWithClient(ctx, r.client)
 }
 
+// GetAccountBalance delegates to the existing GetAccountBalance function with the shared client.
+func (r *BigQueryAccountRepository) GetAccountBalance(ctx context.Context, accountID string, asOf time.Time) (*AccountBalance, error) {
+	return GetAccountBalanceWithClient(ctx, r.client, accountID, asOf)
+}
+
+// MergeAccount delegates to the existing MergeAccount function with the shared client.
+func (r *BigQueryAccountRepository) MergeAccount(ctx context.Context, fromAccountID, toAccountID string) error {
+	return MergeAccountWithClient(ctx, r.client, fromAccountID, toAccountID)
+}
+
+// GetAccountCoverage delegates to the existing GetAccountCoverage function with the shared client.
+func (r *BigQueryAccountRepository) GetAccountCoverage(ctx context.Context, accountID string) (*AccountCoverage, error) {
+	return GetAccountCoverageWithClient(ctx, r.client, accountID)
+}
+
 // BigQueryDocumentRepository is the concrete implementation of DocumentRepository
 // that interacts with BigQuery. It holds a shared BigQuery client to avoid
 // creating a new connection for each operation.
@@ -88,6 +104,11 @@ func (r *BigQueryDocumentRepository) InsertDocument(ctx context.Context, row *Do
 	return InsertDocumentWithClient(ctx, r.client, row)
 }
 
+// InsertDocumentIfAbsent delegates to the existing InsertDocumentIfAbsent function with the shared client.
+func (r *BigQueryDocumentRepository) InsertDocumentIfAbsent(ctx context.Context, row *DocumentRow) (bool, error) {
+	return InsertDocumentIfAbsentWithClient(ctx, r.client, row)
+}
+
 // InsertTransactions delegates to the existing InsertTransactions function with the shared client.
 func (r *BigQueryDocumentRepository) InsertTransactions(ctx context.Context, rows []*TransactionRow) error {
 	return InsertTransactionsWithClient(ctx, r.client, rows)
@@ -114,8 +135,8 @@ func (r *BigQueryDocumentRepository) MarkParsingRunSucceeded(ctx context.Context
 }
 
 // ListActiveCategories delegates to the existing ListActiveCategories function with the shared client.
-func (r *BigQueryDocumentRepository) ListActiveCategories(ctx context.Context) ([]CategoryRow, error) {
-	return ListActiveCategoriesWithClient(ctx, r.client)
+func (r *BigQueryDocumentRepository) ListActiveCategories(ctx context.Context, userID string) ([]CategoryRow, error) {
+	return ListActiveCategoriesWithClient(ctx, r.client, userID)
 }
 
 // QueryTransactionsByDateRange delegates to the existing QueryTransactionsByDateRange function with the shared client.
@@ -123,6 +144,16 @@ func (r *BigQueryDocumentRepository) QueryTransactionsByDateRange(ctx context.Co
 	return QueryTransactionsByDateRangeWithClient(ctx, r.client, startDate, endDate)
 }
 
+// QueryTransactionsWithFilter delegates to the existing QueryTransactionsWithFilter function with the shared client.
+func (r *BigQueryDocumentRepository) QueryTransactionsWithFilter(ctx context.Context, startDate, endDate time.Time, filter TransactionFilter) ([]*TransactionRow, error) {
+	return QueryTransactionsWithFilterWithClient(ctx, r.client, startDate, endDate, filter)
+}
+
+// QueryTransactions delegates to the existing QueryTransactions function with the shared client.
+func (r *BigQueryDocumentRepository) QueryTransactions(ctx context.Context, q TransactionQuery) ([]*TransactionRow, error) {
+	return QueryTransactionsWithClient(ctx, r.client, q)
+}
+
 // ListAllAccounts delegates to the existing ListAllAccounts function with the shared client.
 func (r *BigQueryDocumentRepository) ListAllAccounts(ctx context.Context) ([]*AccountRow, error) {
 	return ListAllAccountsWithClient(ctx, r.client)
@@ -133,6 +164,16 @@ func (r *BigQueryDocumentRepository) ListAllDocuments(ctx context.Context) ([]*D
 	return ListAllDocumentsWithClient(ctx, r.client)
 }
 
+// ListAllDocumentsWithStats delegates to the existing ListAllDocumentsWithStats function with the shared client.
+func (r *BigQueryDocumentRepository) ListAllDocumentsWithStats(ctx context.Context) ([]*DocumentWithStats, error) {
+	return ListAllDocumentsWithStatsWithClient(ctx, r.client)
+}
+
+// GetDocument delegates to the existing GetDocument function with the shared client.
+func (r *BigQueryDocumentRepository) GetDocument(ctx context.Context, documentID string) (*DocumentRow, error) {
+	return GetDocumentWithClient(ctx, r.client, documentID)
+}
+
 // FindDocumentByChecksum delegates to the existing FindDocumentByChecksum function with the shared client.
 func (r *BigQueryDocumentRepository) FindDocumentByChecksum(ctx context.Context, checksum string) (*DocumentRow, error) {
 	return FindDocumentByChecksumWithClient(ctx, r.client, checksum)
@@ -142,3 +183,108 @@ func (r *BigQueryDocumentRepository) FindDocumentByChecksum(ctx context.Context,
 func (r *BigQueryDocumentRepository) MarkParsingRunsAsSuperseded(ctx context.Context, documentID string) error {
 	return MarkParsingRunsAsSupersededWithClient(ctx, r.client, documentID)
 }
+
+// UpdateDocumentParsingStatus delegates to the existing UpdateDocumentParsingStatus function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateDocumentParsingStatus(ctx context.Context, documentID, status string) error {
+	return UpdateDocumentParsingStatusWithClient(ctx, r.client, documentID, status)
+}
+
+// TransitionDocumentStatus delegates to the existing TransitionDocumentStatus function with the shared client.
+func (r *BigQueryDocumentRepository) TransitionDocumentStatus(ctx context.Context, documentID string, to DocumentStatus) error {
+	return TransitionDocumentStatusWithClient(ctx, r.client, documentID, to)
+}
+
+// UpdateDocumentInstitution delegates to the existing UpdateDocumentInstitution function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateDocumentInstitution(ctx context.Context, documentID, institutionID string) error {
+	return UpdateDocumentInstitutionWithClient(ctx, r.client, documentID, institutionID)
+}
+
+// FindParsingRun delegates to the existing FindParsingRun function with the shared client.
+func (r *BigQueryDocumentRepository) FindParsingRun(ctx context.Context, parsingRunID string) (*ParsingRunRow, error) {
+	return FindParsingRunWithClient(ctx, r.client, parsingRunID)
+}
+
+// UpdateDocumentBalances delegates to the existing UpdateDocumentBalances function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateDocumentBalances(ctx context.Context, documentID string, opening, closing *big.Rat) error {
+	return UpdateDocumentBalancesWithClient(ctx, r.client, documentID, opening, closing)
+}
+
+// UpdateDocumentMetadata delegates to the existing UpdateDocumentMetadata function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateDocumentMetadata(ctx context.Context, documentID string, metadata bigquery.NullJSON) error {
+	return UpdateDocumentMetadataWithClient(ctx, r.client, documentID, metadata)
+}
+
+// GetLatestModelOutput delegates to the existing GetLatestModelOutput function with the shared client.
+func (r *BigQueryDocumentRepository) GetLatestModelOutput(ctx context.Context, documentID string) (*ModelOutputRow, error) {
+	return GetLatestModelOutputWithClient(ctx, r.client, documentID)
+}
+
+// ListModelOutputsByParsingRun delegates to the existing ListModelOutputsByParsingRun function with the shared client.
+func (r *BigQueryDocumentRepository) ListModelOutputsByParsingRun(ctx context.Context, parsingRunID string) ([]*ModelOutputRow, error) {
+	return ListModelOutputsByParsingRunWithClient(ctx, r.client, parsingRunID)
+}
+
+// FindParsingRunAccountID delegates to the existing FindParsingRunAccountID function with the shared client.
+func (r *BigQueryDocumentRepository) FindParsingRunAccountID(ctx context.Context, parsingRunID string) (string, error) {
+	return FindParsingRunAccountIDWithClient(ctx, r.client, parsingRunID)
+}
+
+// SetTransactionReviewed delegates to the existing SetTransactionReviewed function with the shared client.
+func (r *BigQueryDocumentRepository) SetTransactionReviewed(ctx context.Context, transactionID string, reviewed bool) (bool, error) {
+	return SetTransactionReviewedWithClient(ctx, r.client, transactionID, reviewed)
+}
+
+// ReassignDocumentAccount delegates to the existing ReassignDocumentAccount function with the shared client.
+func (r *BigQueryDocumentRepository) ReassignDocumentAccount(ctx context.Context, documentID, accountID string) (bool, error) {
+	return ReassignDocumentAccountWithClient(ctx, r.client, documentID, accountID)
+}
+
+// RecategorizeTransactions delegates to the existing RecategorizeTransactions function with the shared client.
+func (r *BigQueryDocumentRepository) RecategorizeTransactions(ctx context.Context, filter RecategorizeFilter, category CategoryRow) (int64, error) {
+	return RecategorizeTransactionsWithClient(ctx, r.client, filter, category)
+}
+
+// ListDocumentCategoryAssignments delegates to the existing ListDocumentCategoryAssignments function with the shared client.
+func (r *BigQueryDocumentRepository) ListDocumentCategoryAssignments(ctx context.Context, documentID string) ([]CategoryAssignment, error) {
+	return ListDocumentCategoryAssignmentsWithClient(ctx, r.client, documentID)
+}
+
+// UpdateDocumentCategoryIDs delegates to the existing UpdateDocumentCategoryIDs function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateDocumentCategoryIDs(ctx context.Context, documentID string, assignments []CategoryAssignment) (int64, error) {
+	return UpdateDocumentCategoryIDsWithClient(ctx, r.client, documentID, assignments)
+}
+
+// ListDistinctDescriptions delegates to the existing ListDistinctDescriptions function with the shared client.
+func (r *BigQueryDocumentRepository) ListDistinctDescriptions(ctx context.Context, userID, prefix string, limit int) ([]DescriptionCount, error) {
+	return ListDistinctDescriptionsWithClient(ctx, r.client, userID, prefix, limit)
+}
+
+// FlagParsingRunForReview delegates to the existing FlagParsingRunForReview function with the shared client.
+func (r *BigQueryDocumentRepository) FlagParsingRunForReview(ctx context.Context, parsingRunID string, reasons []string) error {
+	return FlagParsingRunForReviewWithClient(ctx, r.client, parsingRunID, reasons)
+}
+
+// ListTagRules delegates to the existing ListTagRules function with the shared client.
+func (r *BigQueryDocumentRepository) ListTagRules(ctx context.Context, userID string) ([]TagRuleRow, error) {
+	return ListTagRulesWithClient(ctx, r.client, userID)
+}
+
+// InsertTagRule delegates to the existing InsertTagRule function with the shared client.
+func (r *BigQueryDocumentRepository) InsertTagRule(ctx context.Context, row *TagRuleRow) error {
+	return InsertTagRuleWithClient(ctx, r.client, row)
+}
+
+// UpdateTagRule delegates to the existing UpdateTagRule function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateTagRule(ctx context.Context, row *TagRuleRow) (bool, error) {
+	return UpdateTagRuleWithClient(ctx, r.client, row)
+}
+
+// DeleteTagRule delegates to the existing DeleteTagRule function with the shared client.
+func (r *BigQueryDocumentRepository) DeleteTagRule(ctx context.Context, userID, ruleID string) (bool, error) {
+	return DeleteTagRuleWithClient(ctx, r.client, userID, ruleID)
+}
+
+// UpdateTransactionTags delegates to the existing UpdateTransactionTags function with the shared client.
+func (r *BigQueryDocumentRepository) UpdateTransactionTags(ctx context.Context, updates []TransactionTags) (int64, error) {
+	return UpdateTransactionTagsWithClient(ctx, r.client, updates)
+}
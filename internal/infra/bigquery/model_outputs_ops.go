@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
 )
 
 const (
@@ -62,9 +63,113 @@ func InsertModelOutputWithClient(ctx context.Context, client *bigquery.Client, r
 	if err != nil {
 		return fmt.Errorf("InsertModelOutput: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "InsertModelOutput", status)
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("InsertModelOutput: job error: %w", err)
 	}
 
 	return nil
 }
+
+// modelOutputColumns is the column list selected when reading model_outputs rows.
+const modelOutputColumns = `
+			output_id,
+			parsing_run_id,
+			document_id,
+			model_name,
+			model_version,
+			raw_json,
+			extracted_text,
+			created_ts,
+			notes,
+			metadata,
+			archive_uri,
+			pruned_ts`
+
+// GetLatestModelOutput returns the most recently stored model output for a document,
+// or nil if none exists.
+func GetLatestModelOutput(ctx context.Context, documentID string) (*ModelOutputRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestModelOutput: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return GetLatestModelOutputWithClient(ctx, client, documentID)
+}
+
+// GetLatestModelOutputWithClient returns the most recently stored model output for a document
+// using the provided BigQuery client, or nil if none exists.
+func GetLatestModelOutputWithClient(ctx context.Context, client *bigquery.Client, documentID string) (*ModelOutputRow, error) {
+	q := client.Query(`
+		SELECT` + modelOutputColumns + `
+		FROM ` + "`" + moProjectID + "." + moDatasetID + "." + modelOutputsTable + "`" + `
+		WHERE document_id = @document_id
+		ORDER BY created_ts DESC
+		LIMIT 1
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "document_id", Value: documentID},
+	}
+
+	it, err := readQuery(ctx, "GetLatestModelOutput", q)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestModelOutput: query read: %w", err)
+	}
+
+	var row ModelOutputRow
+	err = it.Next(&row)
+	if err == iterator.Done {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestModelOutput: iter next: %w", err)
+	}
+
+	return &row, nil
+}
+
+// ListModelOutputsByParsingRun returns the model outputs stored for a parsing run, newest first.
+func ListModelOutputsByParsingRun(ctx context.Context, parsingRunID string) ([]*ModelOutputRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListModelOutputsByParsingRun: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ListModelOutputsByParsingRunWithClient(ctx, client, parsingRunID)
+}
+
+// ListModelOutputsByParsingRunWithClient returns the model outputs stored for a parsing run,
+// newest first, using the provided BigQuery client.
+func ListModelOutputsByParsingRunWithClient(ctx context.Context, client *bigquery.Client, parsingRunID string) ([]*ModelOutputRow, error) {
+	q := client.Query(`
+		SELECT` + modelOutputColumns + `
+		FROM ` + "`" + moProjectID + "." + moDatasetID + "." + modelOutputsTable + "`" + `
+		WHERE parsing_run_id = @parsing_run_id
+		ORDER BY created_ts DESC
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "parsing_run_id", Value: parsingRunID},
+	}
+
+	it, err := readQuery(ctx, "ListModelOutputsByParsingRun", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListModelOutputsByParsingRun: query read: %w", err)
+	}
+
+	var rows []*ModelOutputRow
+	for {
+		var r ModelOutputRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListModelOutputsByParsingRun: iter next: %w", err)
+		}
+		rows = append(rows, &r)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,132 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// PrunedModelOutput identifies a model output whose raw content is being pruned.
+type PrunedModelOutput struct {
+	OutputID string `bigquery:"output_id"`
+
+	// ArchiveURI is where the content was archived, or empty when it was discarded.
+	ArchiveURI string `bigquery:"archive_uri"`
+}
+
+// ListPrunableModelOutputs returns up to limit model outputs created before cutoff that
+// still hold their raw content and do not belong to a successful parsing run, oldest first.
+// Outputs of successful runs are kept because reprocessing reads them.
+func ListPrunableModelOutputs(ctx context.Context, cutoff time.Time, limit int) ([]*ModelOutputRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListPrunableModelOutputs: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ListPrunableModelOutputsWithClient(ctx, client, cutoff, limit)
+}
+
+// ListPrunableModelOutputsWithClient returns up to limit prunable model outputs created
+// before cutoff using the provided BigQuery client.
+func ListPrunableModelOutputsWithClient(ctx context.Context, client *bigquery.Client, cutoff time.Time, limit int) ([]*ModelOutputRow, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("ListPrunableModelOutputs: limit must be positive, got %d", limit)
+	}
+
+	q := client.Query(`
+		SELECT` + modelOutputColumns + `
+		FROM ` + "`" + moProjectID + "." + moDatasetID + "." + modelOutputsTable + "`" + ` mo
+		WHERE mo.pruned_ts IS NULL
+		  AND mo.created_ts < @cutoff
+		  AND mo.parsing_run_id NOT IN (
+			SELECT parsing_run_id
+			FROM ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + `
+			WHERE status = 'SUCCESS'
+		  )
+		ORDER BY mo.created_ts, mo.output_id
+		LIMIT @limit
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "cutoff", Value: cutoff},
+		{Name: "limit", Value: limit},
+	}
+
+	it, err := readQuery(ctx, "ListPrunableModelOutputs", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListPrunableModelOutputs: query read: %w", err)
+	}
+
+	var rows []*ModelOutputRow
+	for {
+		var r ModelOutputRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListPrunableModelOutputs: iter next: %w", err)
+		}
+		rows = append(rows, &r)
+	}
+
+	return rows, nil
+}
+
+// MarkModelOutputsPruned clears raw_json and extracted_text of the given outputs, recording
+// their archive URI and the prune time. Outputs that were already pruned are left untouched.
+// It returns the number of rows updated.
+func MarkModelOutputsPruned(ctx context.Context, outputs []PrunedModelOutput) (int64, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("MarkModelOutputsPruned: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return MarkModelOutputsPrunedWithClient(ctx, client, outputs)
+}
+
+// MarkModelOutputsPrunedWithClient clears the raw content of the given outputs using the
+// provided BigQuery client and returns the number of rows updated.
+func MarkModelOutputsPrunedWithClient(ctx context.Context, client *bigquery.Client, outputs []PrunedModelOutput) (int64, error) {
+	if len(outputs) == 0 {
+		return 0, nil
+	}
+
+	q := client.Query(`
+		UPDATE ` + "`" + moProjectID + "." + moDatasetID + "." + modelOutputsTable + "`" + ` mo
+		SET raw_json = NULL,
+		    extracted_text = NULL,
+		    archive_uri = NULLIF(p.archive_uri, ''),
+		    pruned_ts = CURRENT_TIMESTAMP()
+		FROM UNNEST(@outputs) p
+		WHERE mo.output_id = p.output_id
+		  AND mo.pruned_ts IS NULL
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "outputs", Value: outputs},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("MarkModelOutputsPruned: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("MarkModelOutputsPruned: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "MarkModelOutputsPruned", status)
+	if err := status.Err(); err != nil {
+		return 0, fmt.Errorf("MarkModelOutputsPruned: job error: %w", err)
+	}
+
+	var affected int64
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		affected = stats.NumDMLAffectedRows
+	}
+	return affected, nil
+}
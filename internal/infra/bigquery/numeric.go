@@ -0,0 +1,47 @@
+package bigquery
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// ErrNumericOutOfRange is returned when a value has more integer digits than a BigQuery
+// NUMERIC column can hold.
+var ErrNumericOutOfRange = errors.New("value out of range for NUMERIC")
+
+// maxNumeric is the largest value of the NUMERIC type: 29 integer and 9 fractional digits.
+var maxNumeric, _ = new(big.Rat).SetString("99999999999999999999999999999.999999999")
+
+// NumericString renders r as a decimal string that fits a BigQuery NUMERIC column. It
+// is rounded to the NUMERIC scale of 9 fractional digits, halves away from zero, and an
+// error wrapping ErrNumericOutOfRange is returned when the rounded value does not fit,
+// rather than leaving BigQuery to reject or truncate it.
+func NumericString(r *big.Rat) (string, error) {
+	s := r.FloatString(bigquery.NumericScaleDigits)
+	rounded, _ := new(big.Rat).SetString(s)
+	if new(big.Rat).Abs(rounded).Cmp(maxNumeric) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrNumericOutOfRange, s)
+	}
+	return s, nil
+}
+
+// numericParam returns an explicitly typed NUMERIC query parameter value for r, or a
+// NULL NUMERIC when r is nil.
+func numericParam(r *big.Rat) (*bigquery.QueryParameterValue, error) {
+	p := &bigquery.QueryParameterValue{
+		Type:  bigquery.StandardSQLDataType{TypeKind: "NUMERIC"},
+		Value: bigquery.NullString{},
+	}
+	if r == nil {
+		return p, nil
+	}
+	s, err := NumericString(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Value = s
+	return p, nil
+}
@@ -0,0 +1,64 @@
+package bigquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestNumericString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0.000000000"},
+		{"-12.5", "-12.500000000"},
+		{"1/3", "0.333333333"},
+		{"2/3", "0.666666667"},
+		{"0.0000000005", "0.000000001"},
+		{"-0.0000000005", "-0.000000001"},
+		{"0.30000000000000004", "0.300000000"},
+		{"99999999999999999999999999999.999999999", "99999999999999999999999999999.999999999"},
+		{"-99999999999999999999999999999.999999999", "-99999999999999999999999999999.999999999"},
+	}
+	for _, tt := range tests {
+		r, _ := new(big.Rat).SetString(tt.in)
+		got, err := NumericString(r)
+		if err != nil {
+			t.Errorf("NumericString(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NumericString(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNumericStringOutOfRange(t *testing.T) {
+	for _, in := range []string{
+		"100000000000000000000000000000",
+		"-100000000000000000000000000000",
+		// Rounds up past the largest NUMERIC
+		"99999999999999999999999999999.9999999995",
+	} {
+		r, _ := new(big.Rat).SetString(in)
+		if _, err := NumericString(r); !errors.Is(err, ErrNumericOutOfRange) {
+			t.Errorf("NumericString(%s) error = %v, want ErrNumericOutOfRange", in, err)
+		}
+	}
+}
+
+func TestNumericParamNil(t *testing.T) {
+	p, err := numericParam(nil)
+	if err != nil {
+		t.Fatalf("numericParam(nil): %v", err)
+	}
+	if p.Type.TypeKind != "NUMERIC" {
+		t.Errorf("TypeKind = %q, want NUMERIC", p.Type.TypeKind)
+	}
+	if v, ok := p.Value.(bigquery.NullString); !ok || v.Valid {
+		t.Errorf("Value = %#v, want invalid NullString", p.Value)
+	}
+}
@@ -3,11 +3,12 @@ package bigquery
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/logger"
 	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
 )
 
 const (
@@ -32,7 +33,7 @@ func StartParsingRun(ctx context.Context, documentID string) (string, error) {
 // and returns the generated parsing_run_id using the provided BigQuery client.
 func StartParsingRunWithClient(ctx context.Context, client *bigquery.Client, documentID string) (string, error) {
 	parsingRunID := uuid.NewString()
-	started := time.Now()
+	started := apptime.Now()
 
 	q := client.Query(fmt.Sprintf(`
 		INSERT %s.%s (
@@ -71,6 +72,7 @@ func StartParsingRunWithClient(ctx context.Context, client *bigquery.Client, doc
 	if err != nil {
 		return "", fmt.Errorf("StartParsingRun: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "StartParsingRun", status)
 	if err := status.Err(); err != nil {
 		return "", fmt.Errorf("StartParsingRun: job error: %w", err)
 	}
@@ -119,7 +121,7 @@ func MarkParsingRunFailedWithClient(ctx context.Context, client *bigquery.Client
 
 	q.Parameters = []bigquery.QueryParameter{
 		{Name: "status", Value: "FAILED"},
-		{Name: "finished_ts", Value: time.Now()},
+		{Name: "finished_ts", Value: apptime.Now()},
 		{Name: "error_message", Value: errMsg},
 		{Name: "parsing_run_id", Value: parsingRunID},
 	}
@@ -141,6 +143,7 @@ func MarkParsingRunFailedWithClient(ctx context.Context, client *bigquery.Client
 			Msg("MarkParsingRunFailed: waiting for job")
 		return
 	}
+	logQueryStats(ctx, "MarkParsingRunFailed", status)
 	if err := status.Err(); err != nil {
 		log.Error().
 			Err(err).
@@ -173,7 +176,7 @@ func MarkParsingRunSucceededWithClient(ctx context.Context, client *bigquery.Cli
 
 	q.Parameters = []bigquery.QueryParameter{
 		{Name: "status", Value: "SUCCESS"},
-		{Name: "finished_ts", Value: time.Now()},
+		{Name: "finished_ts", Value: apptime.Now()},
 		{Name: "parsing_run_id", Value: parsingRunID},
 	}
 
@@ -186,6 +189,7 @@ func MarkParsingRunSucceededWithClient(ctx context.Context, client *bigquery.Cli
 	if err != nil {
 		return fmt.Errorf("MarkParsingRunSucceeded: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "MarkParsingRunSucceeded", status)
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("MarkParsingRunSucceeded: job error: %w", err)
 	}
@@ -229,9 +233,98 @@ func MarkParsingRunsAsSupersededWithClient(ctx context.Context, client *bigquery
 	if err != nil {
 		return fmt.Errorf("MarkParsingRunsAsSuperseded: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "MarkParsingRunsAsSuperseded", status)
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("MarkParsingRunsAsSuperseded: job error: %w", err)
 	}
 
 	return nil
 }
+
+// FlagParsingRunForReview records in a parsing run's metadata that its output looks suspicious
+// and should be checked by a person.
+func FlagParsingRunForReview(ctx context.Context, parsingRunID string, reasons []string) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("FlagParsingRunForReview: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return FlagParsingRunForReviewWithClient(ctx, client, parsingRunID, reasons)
+}
+
+// FlagParsingRunForReviewWithClient sets metadata.needs_review and metadata.review_reasons on a
+// parsing run using the provided BigQuery client. Existing metadata keys are preserved.
+func FlagParsingRunForReviewWithClient(ctx context.Context, client *bigquery.Client, parsingRunID string, reasons []string) error {
+	q := client.Query(fmt.Sprintf(`
+		UPDATE %s.%s
+		SET metadata = JSON_SET(
+		      COALESCE(metadata, JSON '{}'),
+		      '$.needs_review', TRUE,
+		      '$.review_reasons', TO_JSON(@reasons)
+		    )
+		WHERE parsing_run_id = @parsing_run_id
+	`, datasetID, parsingRunsTable))
+
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "reasons", Value: reasons},
+		{Name: "parsing_run_id", Value: parsingRunID},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("FlagParsingRunForReview: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("FlagParsingRunForReview: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "FlagParsingRunForReview", status)
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("FlagParsingRunForReview: job error: %w", err)
+	}
+
+	return nil
+}
+
+// FindParsingRun returns the parsing run with the given ID, or nil if there is none.
+func FindParsingRun(ctx context.Context, parsingRunID string) (*ParsingRunRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("FindParsingRun: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return FindParsingRunWithClient(ctx, client, parsingRunID)
+}
+
+// FindParsingRunWithClient returns the parsing run with the given ID using the provided
+// BigQuery client, or nil if there is none.
+func FindParsingRunWithClient(ctx context.Context, client *bigquery.Client, parsingRunID string) (*ParsingRunRow, error) {
+	q := client.Query(fmt.Sprintf(`
+		SELECT parsing_run_id, document_id, started_ts, parser_type, parser_version, status
+		FROM %s.%s
+		WHERE parsing_run_id = @parsing_run_id
+		LIMIT 1
+	`, datasetID, parsingRunsTable))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "parsing_run_id", Value: parsingRunID},
+	}
+
+	it, err := readQuery(ctx, "FindParsingRun", q)
+	if err != nil {
+		return nil, fmt.Errorf("FindParsingRun: query read: %w", err)
+	}
+
+	var row ParsingRunRow
+	err = it.Next(&row)
+	if err == iterator.Done {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("FindParsingRun: iter next: %w", err)
+	}
+
+	return &row, nil
+}
@@ -0,0 +1,97 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
+	"google.golang.org/api/iterator"
+)
+
+// FailStaleParsingRuns marks parsing runs that are still RUNNING but started before
+// startedBefore as FAILED with the given reason, and returns them. Such runs were
+// abandoned by a worker that crashed or was killed before it could record an outcome.
+func FailStaleParsingRuns(ctx context.Context, startedBefore time.Time, reason string) ([]*ParsingRunRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("FailStaleParsingRuns: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return FailStaleParsingRunsWithClient(ctx, client, startedBefore, reason)
+}
+
+// FailStaleParsingRunsWithClient marks RUNNING parsing runs started before startedBefore
+// as FAILED using the provided BigQuery client and returns them.
+func FailStaleParsingRunsWithClient(ctx context.Context, client *bigquery.Client, startedBefore time.Time, reason string) ([]*ParsingRunRow, error) {
+	q := client.Query(fmt.Sprintf(`
+		SELECT parsing_run_id, document_id, started_ts, parser_type, parser_version, status
+		FROM %s.%s
+		WHERE status = 'RUNNING'
+		  AND started_ts < @started_before
+		ORDER BY started_ts
+	`, datasetID, parsingRunsTable))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "started_before", Value: startedBefore},
+	}
+
+	it, err := readQuery(ctx, "FailStaleParsingRuns", q)
+	if err != nil {
+		return nil, fmt.Errorf("FailStaleParsingRuns: query read: %w", err)
+	}
+
+	var runs []*ParsingRunRow
+	var ids []string
+	for {
+		var r ParsingRunRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("FailStaleParsingRuns: iter next: %w", err)
+		}
+		runs = append(runs, &r)
+		ids = append(ids, r.ParsingRunID)
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+
+	// Only runs still RUNNING are failed, in case one finished since it was listed
+	update := client.Query(fmt.Sprintf(`
+		UPDATE %s.%s
+		SET status = 'FAILED',
+		    finished_ts = @finished_ts,
+		    error_message = @error_message
+		WHERE parsing_run_id IN UNNEST(@parsing_run_ids)
+		  AND status = 'RUNNING'
+	`, datasetID, parsingRunsTable))
+	update.Parameters = []bigquery.QueryParameter{
+		{Name: "finished_ts", Value: apptime.Now()},
+		{Name: "error_message", Value: reason},
+		{Name: "parsing_run_ids", Value: ids},
+	}
+
+	job, err := update.Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FailStaleParsingRuns: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FailStaleParsingRuns: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "FailStaleParsingRuns", status)
+	if err := status.Err(); err != nil {
+		return nil, fmt.Errorf("FailStaleParsingRuns: job error: %w", err)
+	}
+
+	for _, r := range runs {
+		r.Status = "FAILED"
+		r.ErrorMessage = reason
+	}
+	return runs, nil
+}
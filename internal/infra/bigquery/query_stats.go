@@ -0,0 +1,60 @@
+package bigquery
+
+import (
+	"context"
+	"sync/atomic"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// totalBytesProcessed accumulates the bytes processed by the queries this process has run.
+var totalBytesProcessed atomic.Int64
+
+// TotalBytesProcessed returns the bytes processed by all queries this process has run so far.
+// BigQuery bills on-demand queries by bytes processed, so this approximates their cost.
+func TotalBytesProcessed() int64 {
+	return totalBytesProcessed.Load()
+}
+
+// logQueryStats logs the bytes processed by a completed query job at debug level and
+// adds them to TotalBytesProcessed. op names the operation, e.g. "ListAllDocuments".
+func logQueryStats(ctx context.Context, op string, status *bigquery.JobStatus) {
+	if status == nil || status.Statistics == nil {
+		return
+	}
+	stats := status.Statistics
+	totalBytesProcessed.Add(stats.TotalBytesProcessed)
+
+	log := logger.FromContext(ctx)
+	event := log.Debug().
+		Str("op", op).
+		Int64("bytes_processed", stats.TotalBytesProcessed).
+		Dur("duration", stats.EndTime.Sub(stats.StartTime))
+	if q, ok := stats.Details.(*bigquery.QueryStatistics); ok {
+		event = event.
+			Int64("bytes_billed", q.TotalBytesBilled).
+			Bool("cache_hit", q.CacheHit)
+	}
+	event.Msg("BigQuery query completed")
+}
+
+// readQuery runs q, waits for it to complete, logs its statistics and returns its rows.
+// It is used instead of q.Read so that every read query reports bytes processed.
+func readQuery(ctx context.Context, op string, q *bigquery.Query) (*bigquery.RowIterator, error) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := status.Err(); err != nil {
+		return nil, err
+	}
+	logQueryStats(ctx, op, status)
+
+	return job.Read(ctx)
+}
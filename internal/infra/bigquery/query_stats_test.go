@@ -0,0 +1,34 @@
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+func TestLogQueryStats(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := logger.WithContext(context.Background(), logger.NewWithWriter(buf))
+
+	before := TotalBytesProcessed()
+	logQueryStats(ctx, "ListAllDocuments", &bigquery.JobStatus{
+		Statistics: &bigquery.JobStatistics{
+			TotalBytesProcessed: 2048,
+			Details:             &bigquery.QueryStatistics{TotalBytesBilled: 10485760},
+		},
+	})
+	logQueryStats(ctx, "ListAllDocuments", &bigquery.JobStatus{})
+
+	if got := TotalBytesProcessed() - before; got != 2048 {
+		t.Errorf("TotalBytesProcessed grew by %d, want 2048", got)
+	}
+	for _, want := range []string{`"op":"ListAllDocuments"`, `"bytes_processed":2048`, `"bytes_billed":10485760`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %s: %s", want, buf.String())
+		}
+	}
+}
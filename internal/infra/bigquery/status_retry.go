@@ -0,0 +1,90 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+	"google.golang.org/api/googleapi"
+)
+
+// statusUpdateAttempts is how many times a document status update is tried before its
+// error is returned.
+const statusUpdateAttempts = 4
+
+// statusUpdateBackoff is the wait before the first retry of a status update; it doubles
+// with every attempt.
+var statusUpdateBackoff = time.Second
+
+// retryStatusUpdate runs update until it succeeds, fails with an error that is not
+// transient, or has been tried statusUpdateAttempts times. A status left behind by a
+// failed update makes the document look stuck, so transient BigQuery errors such as
+// rate limits and concurrent DML conflicts are retried with exponential backoff.
+func retryStatusUpdate(ctx context.Context, op string, update func() error) error {
+	log := logger.FromContext(ctx)
+	backoff := statusUpdateBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := update()
+		if err == nil || !isTransientError(err) {
+			return err
+		}
+		if attempt >= statusUpdateAttempts {
+			return fmt.Errorf("%w (gave up after %d attempts)", err, attempt)
+		}
+
+		log.Warn().
+			Err(err).
+			Str("op", op).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("Status update failed, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (retry cancelled: %w)", err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientError reports whether err is a BigQuery failure that may succeed when
+// retried: rate limits, server errors and DML statements that conflicted with a
+// concurrent update of the same table.
+func isTransientError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		for _, item := range apiErr.Errors {
+			if transientReason(item.Reason) {
+				return true
+			}
+		}
+	}
+
+	var bqErr *bigquery.Error
+	if errors.As(err, &bqErr) && transientReason(bqErr.Reason) {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "Could not serialize access")
+}
+
+// transientReason reports whether a BigQuery error reason denotes a transient failure.
+func transientReason(reason string) bool {
+	switch reason {
+	case "backendError", "internalError", "rateLimitExceeded", "jobRateLimitExceeded":
+		return true
+	}
+	return false
+}
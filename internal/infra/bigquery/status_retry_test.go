@@ -0,0 +1,71 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	bq "github.com/dvloznov/finance-tracker/internal/bigquery"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"service unavailable", fmt.Errorf("query run: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), true},
+		{"rate limit reason", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, true},
+		{"job backend error", fmt.Errorf("job error: %w", &bigquery.Error{Reason: "backendError"}), true},
+		{"concurrent update", errors.New("Could not serialize access to table documents due to concurrent update"), true},
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"invalid transition", fmt.Errorf("document d: %w", bq.ErrInvalidStatusTransition), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryStatusUpdate(t *testing.T) {
+	defer func(b time.Duration) { statusUpdateBackoff = b }(statusUpdateBackoff)
+	statusUpdateBackoff = time.Millisecond
+
+	transient := &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	calls := 0
+	err := retryStatusUpdate(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("recovering update: err = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	calls = 0
+	err = retryStatusUpdate(context.Background(), "test", func() error {
+		calls++
+		return transient
+	})
+	if !errors.As(err, new(*googleapi.Error)) || calls != statusUpdateAttempts {
+		t.Errorf("failing update: err = %v after %d calls, want the API error after %d", err, calls, statusUpdateAttempts)
+	}
+
+	calls = 0
+	err = retryStatusUpdate(context.Background(), "test", func() error {
+		calls++
+		return bq.ErrInvalidStatusTransition
+	})
+	if !errors.Is(err, bq.ErrInvalidStatusTransition) || calls != 1 {
+		t.Errorf("permanent error: err = %v after %d calls, want it returned at once", err, calls)
+	}
+}
@@ -0,0 +1,9 @@
+package bigquery
+
+import (
+	bq "github.com/dvloznov/finance-tracker/internal/bigquery"
+)
+
+// Re-export types from shared package for backward compatibility
+type TagRuleRow = bq.TagRuleRow
+type TransactionTags = bq.TransactionTags
@@ -0,0 +1,249 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+const tagRulesTable = "tag_rules"
+
+// tagRulesTableRef is the fully qualified tag_rules table.
+var tagRulesTableRef = "`" + projectID + "." + datasetID + "." + tagRulesTable + "`"
+
+// ListTagRules returns the tag rules of userID ordered by priority, then rule ID.
+func ListTagRules(ctx context.Context, userID string) ([]TagRuleRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListTagRules: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ListTagRulesWithClient(ctx, client, userID)
+}
+
+// ListTagRulesWithClient returns the tag rules of userID using the provided BigQuery client.
+func ListTagRulesWithClient(ctx context.Context, client *bigquery.Client, userID string) ([]TagRuleRow, error) {
+	q := client.Query(`
+		SELECT
+		  rule_id,
+		  user_id,
+		  description_pattern,
+		  merchant,
+		  tags,
+		  priority,
+		  stop_processing,
+		  created_ts,
+		  updated_ts
+		FROM ` + tagRulesTableRef + `
+		WHERE user_id = @user_id
+		ORDER BY priority, rule_id
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "user_id", Value: userID},
+	}
+
+	it, err := readQuery(ctx, "ListTagRules", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListTagRules: query read: %w", err)
+	}
+
+	var rows []TagRuleRow
+	for {
+		var r TagRuleRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListTagRules: iter next: %w", err)
+		}
+		rows = append(rows, r)
+	}
+
+	return rows, nil
+}
+
+// InsertTagRule inserts a tag rule.
+func InsertTagRule(ctx context.Context, row *TagRuleRow) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("InsertTagRule: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return InsertTagRuleWithClient(ctx, client, row)
+}
+
+// InsertTagRuleWithClient inserts a tag rule using the provided BigQuery client.
+func InsertTagRuleWithClient(ctx context.Context, client *bigquery.Client, row *TagRuleRow) error {
+	q := client.Query(`
+		INSERT ` + tagRulesTableRef + ` (
+			rule_id,
+			user_id,
+			description_pattern,
+			merchant,
+			tags,
+			priority,
+			stop_processing,
+			created_ts
+		)
+		VALUES (
+			@rule_id,
+			@user_id,
+			@description_pattern,
+			@merchant,
+			@tags,
+			@priority,
+			@stop_processing,
+			@created_ts
+		)
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "rule_id", Value: row.RuleID},
+		{Name: "user_id", Value: row.UserID},
+		{Name: "description_pattern", Value: row.DescriptionPattern},
+		{Name: "merchant", Value: row.Merchant},
+		{Name: "tags", Value: row.Tags},
+		{Name: "priority", Value: row.Priority},
+		{Name: "stop_processing", Value: row.StopProcessing},
+		{Name: "created_ts", Value: row.CreatedTS},
+	}
+
+	if _, err := runTagRuleDML(ctx, "InsertTagRule", q); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateTagRule replaces the conditions, tags and precedence of a tag rule.
+func UpdateTagRule(ctx context.Context, row *TagRuleRow) (bool, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("UpdateTagRule: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateTagRuleWithClient(ctx, client, row)
+}
+
+// UpdateTagRuleWithClient replaces the conditions, tags and precedence of the tag rule
+// of row.UserID with row.RuleID using the provided BigQuery client. Returns false if
+// there is no such rule.
+func UpdateTagRuleWithClient(ctx context.Context, client *bigquery.Client, row *TagRuleRow) (bool, error) {
+	q := client.Query(`
+		UPDATE ` + tagRulesTableRef + `
+		SET description_pattern = @description_pattern,
+		    merchant = @merchant,
+		    tags = @tags,
+		    priority = @priority,
+		    stop_processing = @stop_processing,
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE rule_id = @rule_id
+		  AND user_id = @user_id
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "rule_id", Value: row.RuleID},
+		{Name: "user_id", Value: row.UserID},
+		{Name: "description_pattern", Value: row.DescriptionPattern},
+		{Name: "merchant", Value: row.Merchant},
+		{Name: "tags", Value: row.Tags},
+		{Name: "priority", Value: row.Priority},
+		{Name: "stop_processing", Value: row.StopProcessing},
+	}
+
+	affected, err := runTagRuleDML(ctx, "UpdateTagRule", q)
+	return affected > 0, err
+}
+
+// DeleteTagRule deletes a tag rule.
+func DeleteTagRule(ctx context.Context, userID, ruleID string) (bool, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("DeleteTagRule: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return DeleteTagRuleWithClient(ctx, client, userID, ruleID)
+}
+
+// DeleteTagRuleWithClient deletes the tag rule of userID with ruleID using the provided
+// BigQuery client. Tags it already gave transactions are kept. Returns false if there is
+// no such rule.
+func DeleteTagRuleWithClient(ctx context.Context, client *bigquery.Client, userID, ruleID string) (bool, error) {
+	q := client.Query(`
+		DELETE FROM ` + tagRulesTableRef + `
+		WHERE rule_id = @rule_id
+		  AND user_id = @user_id
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "rule_id", Value: ruleID},
+		{Name: "user_id", Value: userID},
+	}
+
+	affected, err := runTagRuleDML(ctx, "DeleteTagRule", q)
+	return affected > 0, err
+}
+
+// UpdateTransactionTags sets the tags of transactions.
+func UpdateTransactionTags(ctx context.Context, updates []TransactionTags) (int64, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("UpdateTransactionTags: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return UpdateTransactionTagsWithClient(ctx, client, updates)
+}
+
+// UpdateTransactionTagsWithClient sets the tags of transactions of successful parsing
+// runs in a single UPDATE, using the provided BigQuery client. Returns the number of
+// transactions updated.
+func UpdateTransactionTagsWithClient(ctx context.Context, client *bigquery.Client, updates []TransactionTags) (int64, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	q := client.Query(`
+		UPDATE ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + ` t
+		SET tags = u.tags,
+		    updated_ts = CURRENT_TIMESTAMP()
+		FROM UNNEST(@updates) u
+		WHERE t.transaction_id = u.transaction_id
+		  AND t.parsing_run_id IN (
+			SELECT parsing_run_id
+			FROM ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + `
+			WHERE status = 'SUCCESS'
+		  )
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "updates", Value: updates},
+	}
+
+	return runTagRuleDML(ctx, "UpdateTransactionTags", q)
+}
+
+// runTagRuleDML runs a DML statement and returns the number of rows it affected.
+func runTagRuleDML(ctx context.Context, op string, q *bigquery.Query) (int64, error) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: running query: %w", op, err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: waiting for job: %w", op, err)
+	}
+	logQueryStats(ctx, op, status)
+	if err := status.Err(); err != nil {
+		return 0, fmt.Errorf("%s: job error: %w", op, err)
+	}
+
+	var affected int64
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		affected = stats.NumDMLAffectedRows
+	}
+	return affected, nil
+}
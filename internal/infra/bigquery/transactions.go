@@ -6,3 +6,18 @@ import (
 
 // Re-export types from shared package for backward compatibility
 type TransactionRow = bq.TransactionRow
+type TransactionFilter = bq.TransactionFilter
+type TransactionQuery = bq.TransactionQuery
+type RecategorizeFilter = bq.RecategorizeFilter
+type DescriptionCount = bq.DescriptionCount
+type CategoryAssignment = bq.CategoryAssignment
+
+// Re-export query field names from shared package
+const (
+	DateFieldTransaction = bq.DateFieldTransaction
+	DateFieldPosting     = bq.DateFieldPosting
+	DateFieldBooking     = bq.DateFieldBooking
+	SortByAmount         = bq.SortByAmount
+	SortByCreated        = bq.SortByCreated
+	DefaultReadPageSize  = bq.DefaultReadPageSize
+)
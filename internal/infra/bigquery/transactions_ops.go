@@ -3,6 +3,7 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/bigquery"
@@ -30,42 +31,28 @@ func InsertTransactions(ctx context.Context, rows []*TransactionRow) error {
 }
 
 // InsertTransactionsWithClient inserts a batch of TransactionRow into finance.transactions
-// using the provided BigQuery client. Uses DML INSERT to avoid streaming buffer issues.
+// using the provided BigQuery client. Uses DML to avoid streaming buffer issues. Rows whose
+// transaction_id already exists are skipped, so retrying a partially applied insert is safe.
 func InsertTransactionsWithClient(ctx context.Context, client *bigquery.Client, rows []*TransactionRow) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
-	// Build INSERT statement with multiple rows
-	queryStr := `
-		INSERT INTO ` + "`" + txProjectID + "." + txDatasetID + ".transactions" + "`" + ` (
-			transaction_id, user_id, account_id, document_id, parsing_run_id,
-			transaction_date, posting_date, booking_datetime,
-			amount, currency, balance_after, direction,
-			raw_description, normalized_description,
-			category_id, category_name, subcategory_name,
-			statement_line_no, statement_page_no,
-			is_pending, is_refund, is_internal_transfer, is_split_parent, is_split_child,
-			external_reference, tags, created_ts, updated_ts
-		)
-		VALUES
-	`
-
 	// Build parameters for each row
 	var params []bigquery.QueryParameter
 	for i, row := range rows {
-		if i > 0 {
-			queryStr += ","
+		amount, err := numericParam(row.Amount)
+		if err != nil {
+			return fmt.Errorf("InsertTransactions: amount of %s: %w", row.TransactionID, err)
+		}
+		balanceAfter, err := numericParam(row.BalanceAfter)
+		if err != nil {
+			return fmt.Errorf("InsertTransactions: balance_after of %s: %w", row.TransactionID, err)
+		}
+		originalAmount, err := numericParam(row.OriginalAmount)
+		if err != nil {
+			return fmt.Errorf("InsertTransactions: original_amount of %s: %w", row.TransactionID, err)
 		}
-		queryStr += fmt.Sprintf(`
-			(@transaction_id_%d, @user_id_%d, @account_id_%d, @document_id_%d, @parsing_run_id_%d,
-			 @transaction_date_%d, @posting_date_%d, @booking_datetime_%d,
-			 @amount_%d, @currency_%d, @balance_after_%d, @direction_%d,
-			 @raw_description_%d, @normalized_description_%d,
-			 @category_id_%d, @category_name_%d, @subcategory_name_%d,
-			 @statement_line_no_%d, @statement_page_no_%d,
-			 @is_pending_%d, @is_refund_%d, @is_internal_transfer_%d, @is_split_parent_%d, @is_split_child_%d,
-			 @external_reference_%d, @tags_%d, @created_ts_%d, @updated_ts_%d)`, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i, i)
 
 		params = append(params,
 			bigquery.QueryParameter{Name: fmt.Sprintf("transaction_id_%d", i), Value: row.TransactionID},
@@ -76,9 +63,9 @@ func InsertTransactionsWithClient(ctx context.Context, client *bigquery.Client,
 			bigquery.QueryParameter{Name: fmt.Sprintf("transaction_date_%d", i), Value: row.TransactionDate},
 			bigquery.QueryParameter{Name: fmt.Sprintf("posting_date_%d", i), Value: row.PostingDate},
 			bigquery.QueryParameter{Name: fmt.Sprintf("booking_datetime_%d", i), Value: row.BookingDatetime},
-			bigquery.QueryParameter{Name: fmt.Sprintf("amount_%d", i), Value: row.Amount},
+			bigquery.QueryParameter{Name: fmt.Sprintf("amount_%d", i), Value: amount},
 			bigquery.QueryParameter{Name: fmt.Sprintf("currency_%d", i), Value: row.Currency},
-			bigquery.QueryParameter{Name: fmt.Sprintf("balance_after_%d", i), Value: row.BalanceAfter},
+			bigquery.QueryParameter{Name: fmt.Sprintf("balance_after_%d", i), Value: balanceAfter},
 			bigquery.QueryParameter{Name: fmt.Sprintf("direction_%d", i), Value: row.Direction},
 			bigquery.QueryParameter{Name: fmt.Sprintf("raw_description_%d", i), Value: row.RawDescription},
 			bigquery.QueryParameter{Name: fmt.Sprintf("normalized_description_%d", i), Value: row.NormalizedDescription},
@@ -96,10 +83,14 @@ func InsertTransactionsWithClient(ctx context.Context, client *bigquery.Client,
 			bigquery.QueryParameter{Name: fmt.Sprintf("tags_%d", i), Value: row.Tags},
 			bigquery.QueryParameter{Name: fmt.Sprintf("created_ts_%d", i), Value: row.CreatedTS},
 			bigquery.QueryParameter{Name: fmt.Sprintf("updated_ts_%d", i), Value: row.UpdatedTS},
+			bigquery.QueryParameter{Name: fmt.Sprintf("is_reviewed_%d", i), Value: row.IsReviewed},
+			bigquery.QueryParameter{Name: fmt.Sprintf("reviewed_ts_%d", i), Value: row.ReviewedTS},
+			bigquery.QueryParameter{Name: fmt.Sprintf("original_amount_%d", i), Value: originalAmount},
+			bigquery.QueryParameter{Name: fmt.Sprintf("original_currency_%d", i), Value: row.OriginalCurrency},
 		)
 	}
 
-	q := client.Query(queryStr)
+	q := client.Query(buildTransactionInsertSQL(len(rows)))
 	q.Parameters = params
 
 	job, err := q.Run(ctx)
@@ -111,6 +102,7 @@ func InsertTransactionsWithClient(ctx context.Context, client *bigquery.Client,
 	if err != nil {
 		return fmt.Errorf("InsertTransactions: waiting for job: %w", err)
 	}
+	logQueryStats(ctx, "InsertTransactions", status)
 	if err := status.Err(); err != nil {
 		return fmt.Errorf("InsertTransactions: job error: %w", err)
 	}
@@ -118,6 +110,45 @@ func InsertTransactionsWithClient(ctx context.Context, client *bigquery.Client,
 	return nil
 }
 
+// transactionInsertColumns are the columns written by InsertTransactions. Each has a
+// query parameter named <column>_<row index>.
+var transactionInsertColumns = []string{
+	"transaction_id", "user_id", "account_id", "document_id", "parsing_run_id",
+	"transaction_date", "posting_date", "booking_datetime",
+	"amount", "currency", "balance_after", "direction",
+	"raw_description", "normalized_description",
+	"category_id", "category_name", "subcategory_name",
+	"statement_line_no", "statement_page_no",
+	"is_pending", "is_refund", "is_internal_transfer", "is_split_parent", "is_split_child",
+	"external_reference", "tags", "created_ts", "updated_ts",
+	"is_reviewed", "reviewed_ts", "original_amount", "original_currency",
+}
+
+// buildTransactionInsertSQL returns a MERGE inserting n parameterized rows that are not
+// in the table yet.
+func buildTransactionInsertSQL(n int) string {
+	var b strings.Builder
+	b.WriteString("\n\t\tMERGE `" + txProjectID + "." + txDatasetID + ".transactions" + "` t\n\t\tUSING (")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString("\n\t\t\tUNION ALL")
+		}
+		b.WriteString("\n\t\t\tSELECT ")
+		for j, col := range transactionInsertColumns {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "@%s_%d AS %s", col, i, col)
+		}
+	}
+	b.WriteString("\n\t\t) s\n\t\tON t.transaction_id = s.transaction_id\n\t\tWHEN NOT MATCHED THEN\n\t\t\tINSERT (")
+	b.WriteString(strings.Join(transactionInsertColumns, ", "))
+	b.WriteString(")\n\t\t\tVALUES (s.")
+	b.WriteString(strings.Join(transactionInsertColumns, ", s."))
+	b.WriteString(")\n")
+	return b.String()
+}
+
 // QueryTransactionsByDateRange queries transactions within the specified date range.
 func QueryTransactionsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]*TransactionRow, error) {
 	client, err := bigquery.NewClient(ctx, projectID)
@@ -133,7 +164,222 @@ func QueryTransactionsByDateRange(ctx context.Context, startDate, endDate time.T
 // using the provided BigQuery client. Only includes transactions from successful parsing runs,
 // excluding transactions from superseded runs.
 func QueryTransactionsByDateRangeWithClient(ctx context.Context, client *bigquery.Client, startDate, endDate time.Time) ([]*TransactionRow, error) {
-	q := client.Query(`
+	return QueryTransactionsWithFilterWithClient(ctx, client, startDate, endDate, TransactionFilter{})
+}
+
+// QueryTransactionsWithFilter queries transactions within the date range that match the filter.
+func QueryTransactionsWithFilter(ctx context.Context, startDate, endDate time.Time, filter TransactionFilter) ([]*TransactionRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("QueryTransactionsWithFilter: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return QueryTransactionsWithFilterWithClient(ctx, client, startDate, endDate, filter)
+}
+
+// QueryTransactionsWithFilterWithClient queries transactions within the date range that match
+// the filter, using the provided BigQuery client. Only includes transactions from successful
+// parsing runs.
+func QueryTransactionsWithFilterWithClient(ctx context.Context, client *bigquery.Client, startDate, endDate time.Time, filter TransactionFilter) ([]*TransactionRow, error) {
+	return QueryTransactionsWithClient(ctx, client, TransactionQuery{
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Direction:  filter.Direction,
+		IsPending:  filter.IsPending,
+		IsReviewed: filter.IsReviewed,
+
+		ExcludeZeroAmount: filter.ExcludeZeroAmount,
+	})
+}
+
+// QueryTransactions returns the transactions matching q.
+func QueryTransactions(ctx context.Context, q TransactionQuery) ([]*TransactionRow, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("QueryTransactions: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return QueryTransactionsWithClient(ctx, client, q)
+}
+
+// QueryTransactionsWithClient returns the transactions matching q using the provided
+// BigQuery client. Only includes transactions from successful parsing runs.
+func QueryTransactionsWithClient(ctx context.Context, client *bigquery.Client, tq TransactionQuery) ([]*TransactionRow, error) {
+	var rows []*TransactionRow
+	err := StreamTransactionsWithClient(ctx, client, tq, func(r *TransactionRow) error {
+		rows = append(rows, r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("QueryTransactions: %w", err)
+	}
+
+	return rows, nil
+}
+
+// StreamTransactions calls fn for every transaction matching tq, in query order, without
+// collecting them in memory. Reading stops at the first error returned by fn.
+func StreamTransactions(ctx context.Context, tq TransactionQuery, fn func(*TransactionRow) error) error {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("StreamTransactions: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return StreamTransactionsWithClient(ctx, client, tq, fn)
+}
+
+// StreamTransactionsWithClient calls fn for every transaction matching tq using the provided
+// BigQuery client. Rows are fetched tq.PageSize at a time, so at most one page is held in memory.
+func StreamTransactionsWithClient(ctx context.Context, client *bigquery.Client, tq TransactionQuery, fn func(*TransactionRow) error) error {
+	if tq.PageSize < 0 {
+		return fmt.Errorf("StreamTransactions: page size must not be negative")
+	}
+	sql, params, err := buildTransactionQuery(tq)
+	if err != nil {
+		return fmt.Errorf("StreamTransactions: %w", err)
+	}
+
+	q := client.Query(sql)
+	q.Parameters = params
+
+	it, err := readQuery(ctx, "StreamTransactions", q)
+	if err != nil {
+		return fmt.Errorf("StreamTransactions: query read: %w", err)
+	}
+	it.PageInfo().MaxSize = readPageSize(tq.PageSize)
+
+	for {
+		var r TransactionRow
+		err := it.Next(&r)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("StreamTransactions: iter next: %w", err)
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+}
+
+// readPageSize returns the configured page size, or DefaultReadPageSize if it is unset.
+func readPageSize(n int) int {
+	if n <= 0 {
+		return DefaultReadPageSize
+	}
+	return n
+}
+
+// transactionDateColumns maps the date fields a query may use to DATE-typed SQL expressions.
+var transactionDateColumns = map[string]string{
+	DateFieldTransaction: "t.transaction_date",
+	DateFieldPosting:     "t.posting_date",
+	DateFieldBooking:     "DATE(t.booking_datetime)",
+}
+
+// transactionSortColumns maps the SortBy values a query may use to SQL expressions.
+var transactionSortColumns = map[string]string{
+	DateFieldTransaction: "t.transaction_date",
+	DateFieldPosting:     "t.posting_date",
+	DateFieldBooking:     "t.booking_datetime",
+	SortByAmount:         "t.amount",
+	SortByCreated:        "t.created_ts",
+}
+
+// buildTransactionQuery renders tq as SQL and its parameters. Column names come only
+// from the allowlists above; every user-supplied value is passed as a parameter.
+func buildTransactionQuery(tq TransactionQuery) (string, []bigquery.QueryParameter, error) {
+	dateField := tq.DateField
+	if dateField == "" {
+		dateField = DateFieldTransaction
+	}
+	dateColumn, ok := transactionDateColumns[dateField]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported date field %q", tq.DateField)
+	}
+
+	sortBy := tq.SortBy
+	if sortBy == "" {
+		sortBy = dateField
+	}
+	sortColumn, ok := transactionSortColumns[sortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported sort field %q", tq.SortBy)
+	}
+
+	if tq.Limit < 0 || tq.Offset < 0 {
+		return "", nil, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	var conditions []string
+	var params []bigquery.QueryParameter
+
+	if tq.ParsingRunID != "" {
+		conditions = append(conditions, "t.parsing_run_id = @parsing_run_id")
+		params = append(params, bigquery.QueryParameter{Name: "parsing_run_id", Value: tq.ParsingRunID})
+	} else {
+		conditions = append(conditions, "pr.status = 'SUCCESS'")
+	}
+	if tq.DocumentID != "" {
+		conditions = append(conditions, "t.document_id = @document_id")
+		params = append(params, bigquery.QueryParameter{Name: "document_id", Value: tq.DocumentID})
+	}
+
+	if !tq.StartDate.IsZero() {
+		conditions = append(conditions, dateColumn+" >= @start_date")
+		params = append(params, bigquery.QueryParameter{Name: "start_date", Value: tq.StartDate.Format(dateFormat)})
+	}
+	if !tq.EndDate.IsZero() {
+		conditions = append(conditions, dateColumn+" <= @end_date")
+		params = append(params, bigquery.QueryParameter{Name: "end_date", Value: tq.EndDate.Format(dateFormat)})
+	}
+	if tq.AccountID != "" {
+		conditions = append(conditions, "t.account_id = @account_id")
+		params = append(params, bigquery.QueryParameter{Name: "account_id", Value: tq.AccountID})
+	}
+	if tq.Category != "" {
+		conditions = append(conditions, "LOWER(t.category_name) = LOWER(@category)")
+		params = append(params, bigquery.QueryParameter{Name: "category", Value: tq.Category})
+	}
+	if tq.Subcategory != "" {
+		conditions = append(conditions, "LOWER(t.subcategory_name) = LOWER(@subcategory)")
+		params = append(params, bigquery.QueryParameter{Name: "subcategory", Value: tq.Subcategory})
+	}
+	if tq.Direction != "" {
+		// Older rows may predate the direction column; fall back to the amount's sign.
+		conditions = append(conditions, "COALESCE(t.direction, IF(t.amount > 0, 'IN', 'OUT')) = @direction")
+		params = append(params, bigquery.QueryParameter{Name: "direction", Value: tq.Direction})
+	}
+	if tq.IsPending != nil {
+		conditions = append(conditions, "COALESCE(t.is_pending, FALSE) = @is_pending")
+		params = append(params, bigquery.QueryParameter{Name: "is_pending", Value: *tq.IsPending})
+	}
+	if tq.IsReviewed != nil {
+		conditions = append(conditions, "COALESCE(t.is_reviewed, FALSE) = @is_reviewed")
+		params = append(params, bigquery.QueryParameter{Name: "is_reviewed", Value: *tq.IsReviewed})
+	}
+	if len(tq.Tags) > 0 {
+		conditions = append(conditions, "(SELECT COUNT(DISTINCT tag) FROM UNNEST(t.tags) AS tag WHERE tag IN UNNEST(@tags)) = ARRAY_LENGTH(@tags)")
+		params = append(params, bigquery.QueryParameter{Name: "tags", Value: uniqueStrings(tq.Tags)})
+	}
+	if tq.ExcludeZeroAmount {
+		conditions = append(conditions, "t.amount != 0")
+	}
+	if tq.Text != "" {
+		conditions = append(conditions, "(STRPOS(LOWER(t.raw_description), LOWER(@text)) > 0 OR STRPOS(LOWER(COALESCE(t.normalized_description, '')), LOWER(@text)) > 0)")
+		params = append(params, bigquery.QueryParameter{Name: "text", Value: tq.Text})
+	}
+
+	order := "ASC"
+	if tq.Descending {
+		order = "DESC"
+	}
+
+	sql := `
 		SELECT
 			t.transaction_id,
 			t.user_id,
@@ -146,6 +392,8 @@ func QueryTransactionsByDateRangeWithClient(ctx context.Context, client *bigquer
 			t.amount,
 			t.currency,
 			t.balance_after,
+			t.original_amount,
+			t.original_currency,
 			t.direction,
 			t.raw_description,
 			t.normalized_description,
@@ -159,6 +407,8 @@ func QueryTransactionsByDateRangeWithClient(ctx context.Context, client *bigquer
 			t.is_internal_transfer,
 			t.is_split_parent,
 			t.is_split_child,
+			t.is_reviewed,
+			t.reviewed_ts,
 			t.external_reference,
 			t.tags,
 			t.created_ts,
@@ -167,33 +417,301 @@ func QueryTransactionsByDateRangeWithClient(ctx context.Context, client *bigquer
 		FROM finance.transactions t
 		INNER JOIN finance.parsing_runs pr
 		  ON t.parsing_run_id = pr.parsing_run_id
-		WHERE t.transaction_date >= @start_date
-		  AND t.transaction_date <= @end_date
-		  AND pr.status = 'SUCCESS'
-		ORDER BY t.transaction_date, t.created_ts
+		WHERE ` + strings.Join(conditions, "\n\t\t  AND ") + fmt.Sprintf(`
+		ORDER BY %s %s, t.created_ts %s, t.transaction_id %s
+	`, sortColumn, order, order, order)
+
+	if tq.Limit > 0 {
+		sql += "LIMIT @limit OFFSET @offset\n"
+		params = append(params,
+			bigquery.QueryParameter{Name: "limit", Value: tq.Limit},
+			bigquery.QueryParameter{Name: "offset", Value: tq.Offset},
+		)
+	}
+
+	return sql, params, nil
+}
+
+// uniqueStrings returns ss without duplicates, keeping the first occurrence of each.
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	out := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// FindParsingRunAccountID returns the account_id the transactions of a parsing run were
+// assigned to, or "" if the run has no transactions.
+func FindParsingRunAccountID(ctx context.Context, parsingRunID string) (string, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return "", fmt.Errorf("FindParsingRunAccountID: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return FindParsingRunAccountIDWithClient(ctx, client, parsingRunID)
+}
+
+// FindParsingRunAccountIDWithClient returns the account_id of a parsing run's transactions
+// using the provided BigQuery client.
+func FindParsingRunAccountIDWithClient(ctx context.Context, client *bigquery.Client, parsingRunID string) (string, error) {
+	q := client.Query(`
+		SELECT account_id
+		FROM ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + `
+		WHERE parsing_run_id = @parsing_run_id
+		  AND account_id IS NOT NULL
+		LIMIT 1
 	`)
 	q.Parameters = []bigquery.QueryParameter{
-		{Name: "start_date", Value: startDate.Format(dateFormat)},
-		{Name: "end_date", Value: endDate.Format(dateFormat)},
+		{Name: "parsing_run_id", Value: parsingRunID},
 	}
 
-	it, err := q.Read(ctx)
+	it, err := readQuery(ctx, "FindParsingRunAccountID", q)
 	if err != nil {
-		return nil, fmt.Errorf("QueryTransactionsByDateRange: query read: %w", err)
+		return "", fmt.Errorf("FindParsingRunAccountID: query read: %w", err)
 	}
 
-	var rows []*TransactionRow
+	var row struct {
+		AccountID string `bigquery:"account_id"`
+	}
+	err = it.Next(&row)
+	if err == iterator.Done {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("FindParsingRunAccountID: iter next: %w", err)
+	}
+
+	return row.AccountID, nil
+}
+
+// RecategorizeTransactions assigns category to all transactions matching the filter.
+func RecategorizeTransactions(ctx context.Context, filter RecategorizeFilter, category CategoryRow) (int64, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("RecategorizeTransactions: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return RecategorizeTransactionsWithClient(ctx, client, filter, category)
+}
+
+// RecategorizeTransactionsWithClient assigns category to all transactions matching the filter
+// in a single UPDATE, using the provided BigQuery client. Only transactions from successful
+// parsing runs are updated. Returns the number of transactions updated.
+func RecategorizeTransactionsWithClient(ctx context.Context, client *bigquery.Client, filter RecategorizeFilter, category CategoryRow) (int64, error) {
+	sql, params, err := buildRecategorizeQuery(filter, category)
+	if err != nil {
+		return 0, fmt.Errorf("RecategorizeTransactions: %w", err)
+	}
+
+	q := client.Query(sql)
+	q.Parameters = params
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("RecategorizeTransactions: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("RecategorizeTransactions: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "RecategorizeTransactions", status)
+	if err := status.Err(); err != nil {
+		return 0, fmt.Errorf("RecategorizeTransactions: job error: %w", err)
+	}
+
+	var affected int64
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		affected = stats.NumDMLAffectedRows
+	}
+
+	return affected, nil
+}
+
+// buildRecategorizeQuery returns the UPDATE of RecategorizeTransactions and its
+// parameters. The update is always restricted to filter.UserID's transactions.
+func buildRecategorizeQuery(filter RecategorizeFilter, category CategoryRow) (string, []bigquery.QueryParameter, error) {
+	if filter.UserID == "" {
+		return "", nil, fmt.Errorf("user ID cannot be empty")
+	}
+	if filter.IsEmpty() {
+		return "", nil, fmt.Errorf("filter cannot be empty")
+	}
+	if category.CategoryID == "" {
+		return "", nil, fmt.Errorf("category ID cannot be empty")
+	}
+
+	conditions := []string{
+		"user_id = @user_id",
+		"parsing_run_id IN (SELECT parsing_run_id FROM `" + projectID + "." + datasetID + "." + parsingRunsTable + "` WHERE status = 'SUCCESS')",
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "user_id", Value: filter.UserID},
+		{Name: "category_id", Value: category.CategoryID},
+		{Name: "category_name", Value: category.CategoryName},
+		{Name: "subcategory_name", Value: category.SubcategoryName},
+	}
+
+	if len(filter.TransactionIDs) > 0 {
+		conditions = append(conditions, "transaction_id IN UNNEST(@transaction_ids)")
+		params = append(params, bigquery.QueryParameter{Name: "transaction_ids", Value: filter.TransactionIDs})
+	}
+	if filter.DescriptionContains != "" {
+		conditions = append(conditions,
+			"STRPOS(LOWER(COALESCE(normalized_description, raw_description)), LOWER(@description)) > 0")
+		params = append(params, bigquery.QueryParameter{Name: "description", Value: filter.DescriptionContains})
+	}
+
+	sql := `
+		UPDATE ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + `
+		SET category_id = @category_id,
+		    category_name = @category_name,
+		    subcategory_name = @subcategory_name,
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE ` + strings.Join(conditions, "\n\t\t  AND ") + `
+	`
+	return sql, params, nil
+}
+
+// SetTransactionReviewed marks a transaction as reviewed or unreviewed.
+func SetTransactionReviewed(ctx context.Context, transactionID string, reviewed bool) (bool, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("SetTransactionReviewed: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return SetTransactionReviewedWithClient(ctx, client, transactionID, reviewed)
+}
+
+// SetTransactionReviewedWithClient sets is_reviewed on a transaction of a successful parsing run
+// using the provided BigQuery client. reviewed_ts is set to the current time when reviewed and
+// cleared otherwise. Returns false if no such transaction exists.
+func SetTransactionReviewedWithClient(ctx context.Context, client *bigquery.Client, transactionID string, reviewed bool) (bool, error) {
+	if transactionID == "" {
+		return false, fmt.Errorf("SetTransactionReviewed: transaction ID cannot be empty")
+	}
+
+	q := client.Query(`
+		UPDATE ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + `
+		SET is_reviewed = @reviewed,
+		    reviewed_ts = IF(@reviewed, CURRENT_TIMESTAMP(), NULL),
+		    updated_ts = CURRENT_TIMESTAMP()
+		WHERE transaction_id = @transaction_id
+		  AND parsing_run_id IN (SELECT parsing_run_id FROM ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + ` WHERE status = 'SUCCESS')
+	`)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "reviewed", Value: reviewed},
+		{Name: "transaction_id", Value: transactionID},
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return false, fmt.Errorf("SetTransactionReviewed: running update query: %w", err)
+	}
+
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return false, fmt.Errorf("SetTransactionReviewed: waiting for job: %w", err)
+	}
+	logQueryStats(ctx, "SetTransactionReviewed", status)
+	if err := status.Err(); err != nil {
+		return false, fmt.Errorf("SetTransactionReviewed: job error: %w", err)
+	}
+
+	var affected int64
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		affected = stats.NumDMLAffectedRows
+	}
+
+	return affected > 0, nil
+}
+
+// ListDistinctDescriptions returns distinct transaction descriptions starting with prefix
+// with their transaction counts, most frequent first.
+func ListDistinctDescriptions(ctx context.Context, userID, prefix string, limit int) ([]DescriptionCount, error) {
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ListDistinctDescriptions: bigquery client: %w", err)
+	}
+	defer client.Close()
+
+	return ListDistinctDescriptionsWithClient(ctx, client, userID, prefix, limit)
+}
+
+// ListDistinctDescriptionsWithClient returns distinct transaction descriptions starting
+// with prefix using the provided BigQuery client. Only transactions from successful
+// parsing runs are counted.
+func ListDistinctDescriptionsWithClient(ctx context.Context, client *bigquery.Client, userID, prefix string, limit int) ([]DescriptionCount, error) {
+	sql, params, err := buildDistinctDescriptionsQuery(userID, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListDistinctDescriptions: %w", err)
+	}
+
+	q := client.Query(sql)
+	q.Parameters = params
+
+	it, err := readQuery(ctx, "ListDistinctDescriptions", q)
+	if err != nil {
+		return nil, fmt.Errorf("ListDistinctDescriptions: query read: %w", err)
+	}
+
+	var rows []DescriptionCount
 	for {
-		var r TransactionRow
+		var r DescriptionCount
 		err := it.Next(&r)
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("QueryTransactionsByDateRange: iter next: %w", err)
+			return nil, fmt.Errorf("ListDistinctDescriptions: iter next: %w", err)
 		}
-		rows = append(rows, &r)
+		rows = append(rows, r)
 	}
 
 	return rows, nil
 }
+
+// buildDistinctDescriptionsQuery renders the description aggregation and its parameters.
+// Descriptions are compared after trimming, so variants differing only in surrounding
+// whitespace are counted together.
+func buildDistinctDescriptionsQuery(userID, prefix string, limit int) (string, []bigquery.QueryParameter, error) {
+	if limit <= 0 {
+		return "", nil, fmt.Errorf("limit must be positive, got %d", limit)
+	}
+
+	conditions := []string{
+		"t.user_id = @user_id",
+		"pr.status = 'SUCCESS'",
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "user_id", Value: userID},
+		{Name: "limit", Value: limit},
+	}
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		conditions = append(conditions, "STARTS_WITH(LOWER(TRIM(COALESCE(t.normalized_description, t.raw_description))), LOWER(@prefix))")
+		params = append(params, bigquery.QueryParameter{Name: "prefix", Value: prefix})
+	}
+
+	sql := `
+		SELECT
+			TRIM(COALESCE(t.normalized_description, t.raw_description)) AS description,
+			COUNT(*) AS transaction_count
+		FROM ` + "`" + txProjectID + "." + txDatasetID + "." + transactionsTable + "`" + ` t
+		JOIN ` + "`" + projectID + "." + datasetID + "." + parsingRunsTable + "`" + ` pr
+		  ON pr.parsing_run_id = t.parsing_run_id
+		WHERE ` + strings.Join(conditions, "\n\t\t  AND ") + `
+		GROUP BY description
+		HAVING description != ''
+		ORDER BY transaction_count DESC, description
+		LIMIT @limit
+	`
+	return sql, params, nil
+}
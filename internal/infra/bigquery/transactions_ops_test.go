@@ -0,0 +1,198 @@
+package bigquery
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildTransactionQuery(t *testing.T) {
+	pending, reviewed := true, false
+	sql, params, err := buildTransactionQuery(TransactionQuery{
+		StartDate:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		DateField:  DateFieldBooking,
+		AccountID:  "acc-1",
+		Category:   "Groceries",
+		Direction:  "OUT",
+		IsPending:  &pending,
+		IsReviewed: &reviewed,
+		Tags:       []string{"work", "work", "travel"},
+		Text:       "tesco",
+		Limit:      50,
+		Offset:     100,
+		SortBy:     SortByAmount,
+		Descending: true,
+
+		ExcludeZeroAmount: true,
+	})
+	if err != nil {
+		t.Fatalf("buildTransactionQuery: %v", err)
+	}
+
+	for _, want := range []string{
+		"DATE(t.booking_datetime) >= @start_date",
+		"t.account_id = @account_id",
+		"LOWER(t.category_name) = LOWER(@category)",
+		"= @direction",
+		"= @is_pending",
+		"COALESCE(t.is_reviewed, FALSE) = @is_reviewed",
+		"UNNEST(@tags)",
+		"LOWER(@text)",
+		"t.amount != 0",
+		"ORDER BY t.amount DESC, t.created_ts DESC, t.transaction_id DESC",
+		"LIMIT @limit OFFSET @offset",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "@end_date") {
+		t.Errorf("SQL bounds end_date although EndDate is zero:\n%s", sql)
+	}
+
+	got := map[string]interface{}{}
+	for _, p := range params {
+		got[p.Name] = p.Value
+	}
+	if got["start_date"] != "2024-01-01" || got["limit"] != 50 || got["offset"] != 100 || got["is_reviewed"] != false {
+		t.Errorf("params = %v", got)
+	}
+	if tags, _ := got["tags"].([]string); len(tags) != 2 {
+		t.Errorf("tags = %v, want duplicates removed", got["tags"])
+	}
+}
+
+func TestBuildTransactionQuery_Defaults(t *testing.T) {
+	sql, params, err := buildTransactionQuery(TransactionQuery{})
+	if err != nil {
+		t.Fatalf("buildTransactionQuery: %v", err)
+	}
+	if !strings.Contains(sql, "ORDER BY t.transaction_date ASC") || strings.Contains(sql, "LIMIT") {
+		t.Errorf("unexpected default SQL:\n%s", sql)
+	}
+	if len(params) != 0 {
+		t.Errorf("params = %v, want none", params)
+	}
+}
+
+func TestBuildTransactionQuery_ParsingRun(t *testing.T) {
+	sql, params, err := buildTransactionQuery(TransactionQuery{DocumentID: "doc-1", ParsingRunID: "run-1"})
+	if err != nil {
+		t.Fatalf("buildTransactionQuery: %v", err)
+	}
+	if !strings.Contains(sql, "t.parsing_run_id = @parsing_run_id") || !strings.Contains(sql, "t.document_id = @document_id") {
+		t.Errorf("SQL does not filter by run and document:\n%s", sql)
+	}
+	// Superseded runs must stay visible when asked for by ID
+	if strings.Contains(sql, "pr.status = 'SUCCESS'") {
+		t.Errorf("SQL restricts a single run to successful runs:\n%s", sql)
+	}
+	if len(params) != 2 {
+		t.Errorf("params = %v, want parsing_run_id and document_id", params)
+	}
+}
+
+func TestBuildTransactionQuery_RejectsUnknownFields(t *testing.T) {
+	for _, q := range []TransactionQuery{
+		{DateField: "updated_ts; DROP TABLE x"},
+		{SortBy: "raw_description"},
+		{Limit: -1},
+	} {
+		if _, _, err := buildTransactionQuery(q); err == nil {
+			t.Errorf("buildTransactionQuery(%+v) succeeded, want error", q)
+		}
+	}
+}
+
+func TestBuildTransactionInsertSQL(t *testing.T) {
+	sql := buildTransactionInsertSQL(2)
+
+	for _, want := range []string{
+		"MERGE `",
+		"UNION ALL",
+		"ON t.transaction_id = s.transaction_id",
+		"WHEN NOT MATCHED THEN",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+	for _, col := range transactionInsertColumns {
+		for _, i := range []string{"0", "1"} {
+			if !strings.Contains(sql, "@"+col+"_"+i+" AS "+col) {
+				t.Errorf("SQL does not select @%s_%s:\n%s", col, i, sql)
+			}
+		}
+	}
+	if strings.Contains(sql, "@transaction_id_2") {
+		t.Errorf("SQL selects a third row:\n%s", sql)
+	}
+}
+
+func TestBuildDistinctDescriptionsQuery(t *testing.T) {
+	sql, params, err := buildDistinctDescriptionsQuery("user-1", "  tes ", 20)
+	if err != nil {
+		t.Fatalf("buildDistinctDescriptionsQuery: %v", err)
+	}
+	for _, want := range []string{"t.user_id = @user_id", "pr.status = 'SUCCESS'", "LOWER(@prefix)", "GROUP BY description", "LIMIT @limit"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+	got := map[string]interface{}{}
+	for _, p := range params {
+		got[p.Name] = p.Value
+	}
+	if got["prefix"] != "tes" || got["user_id"] != "user-1" || got["limit"] != 20 {
+		t.Errorf("params = %v", got)
+	}
+
+	sql, _, err = buildDistinctDescriptionsQuery("user-1", "", 20)
+	if err != nil {
+		t.Fatalf("buildDistinctDescriptionsQuery without prefix: %v", err)
+	}
+	if strings.Contains(sql, "@prefix") {
+		t.Errorf("SQL without prefix filters on it:\n%s", sql)
+	}
+
+	if _, _, err := buildDistinctDescriptionsQuery("user-1", "", 0); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
+
+func TestBuildRecategorizeQuery(t *testing.T) {
+	category := CategoryRow{CategoryID: "cat-1", CategoryName: "Groceries"}
+	sql, params, err := buildRecategorizeQuery(RecategorizeFilter{UserID: "user-1", DescriptionContains: "tesco"}, category)
+	if err != nil {
+		t.Fatalf("buildRecategorizeQuery: %v", err)
+	}
+	for _, want := range []string{"user_id = @user_id", "status = 'SUCCESS'", "LOWER(@description)", "SET category_id = @category_id"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+	got := map[string]interface{}{}
+	for _, p := range params {
+		got[p.Name] = p.Value
+	}
+	if got["user_id"] != "user-1" || got["description"] != "tesco" || got["category_id"] != "cat-1" {
+		t.Errorf("params = %v", got)
+	}
+
+	// Without a user the update would reach every user's transactions
+	if _, _, err := buildRecategorizeQuery(RecategorizeFilter{DescriptionContains: "tesco"}, category); err == nil {
+		t.Error("expected an error without a user ID")
+	}
+	if _, _, err := buildRecategorizeQuery(RecategorizeFilter{UserID: "user-1"}, category); err == nil {
+		t.Error("expected an error for an empty filter")
+	}
+}
+
+func TestReadPageSize(t *testing.T) {
+	if got := readPageSize(0); got != DefaultReadPageSize {
+		t.Errorf("readPageSize(0) = %d, want %d", got, DefaultReadPageSize)
+	}
+	if got := readPageSize(250); got != 250 {
+		t.Errorf("readPageSize(250) = %d, want 250", got)
+	}
+}
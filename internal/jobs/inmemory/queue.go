@@ -2,8 +2,10 @@ package inmemory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dvloznov/finance-tracker/internal/jobs"
@@ -21,21 +23,62 @@ type Queue struct {
 	mu        sync.RWMutex
 	store     jobs.JobStore
 	closed    bool
+	inFlight  atomic.Int64
+	workers   atomic.Int64
+
+	jobTimeout time.Duration
+}
+
+// DefaultJobTimeout is the per-job deadline used when none is configured.
+// It matches the timeout used by the CLI ingest commands.
+const DefaultJobTimeout = 5 * time.Minute
+
+// DefaultBufferSize is the number of pending jobs a queue holds when none is configured.
+const DefaultBufferSize = 100
+
+// QueueConfig holds settings for an in-memory queue.
+type QueueConfig struct {
+	// BufferSize is how many jobs can wait in the queue; once it is full,
+	// PublishParseDocument returns jobs.ErrQueueFull. Zero means DefaultBufferSize.
+	BufferSize int
+
+	// JobTimeout bounds a single handler invocation. Zero means DefaultJobTimeout.
+	JobTimeout time.Duration
 }
 
 // NewQueue creates a new in-memory job queue.
-// bufferSize determines how many jobs can be queued before PublishParseDocument blocks.
+// bufferSize determines how many jobs can be queued before PublishParseDocument
+// returns jobs.ErrQueueFull.
 func NewQueue(bufferSize int, store jobs.JobStore) *Queue {
+	return NewQueueWithConfig(QueueConfig{BufferSize: bufferSize}, store)
+}
+
+// NewQueueWithConfig creates a new in-memory job queue with the given settings.
+func NewQueueWithConfig(cfg QueueConfig, store jobs.JobStore) *Queue {
+	if cfg.JobTimeout <= 0 {
+		cfg.JobTimeout = DefaultJobTimeout
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
 	return &Queue{
-		jobChan:   make(chan *jobs.ParseDocumentJob, bufferSize),
-		closeChan: make(chan struct{}),
-		store:     store,
+		jobChan:    make(chan *jobs.ParseDocumentJob, cfg.BufferSize),
+		closeChan:  make(chan struct{}),
+		store:      store,
+		jobTimeout: cfg.JobTimeout,
 	}
 }
 
 // PublishParseDocument implements the Publisher interface.
-// It enqueues a document parsing job for asynchronous processing.
+// It enqueues a document parsing job for asynchronous processing, returning
+// jobs.ErrQueueFull without waiting if the buffer is full.
 func (q *Queue) PublishParseDocument(ctx context.Context, job *jobs.ParseDocumentJob) error {
+	return q.publish(ctx, job, false)
+}
+
+// publish enqueues job. If the buffer is full it waits for room when wait is set,
+// and otherwise fails with jobs.ErrQueueFull.
+func (q *Queue) publish(ctx context.Context, job *jobs.ParseDocumentJob, wait bool) error {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
@@ -43,6 +86,11 @@ func (q *Queue) PublishParseDocument(ctx context.Context, job *jobs.ParseDocumen
 		return fmt.Errorf("queue is closed")
 	}
 
+	// Fail fast so a rejected job is not recorded
+	if !wait && len(q.jobChan) == cap(q.jobChan) {
+		return jobs.ErrQueueFull
+	}
+
 	// Generate job ID if not provided
 	if job.JobID == "" {
 		job.JobID = uuid.New().String()
@@ -66,6 +114,19 @@ func (q *Queue) PublishParseDocument(ctx context.Context, job *jobs.ParseDocumen
 		}
 	}
 
+	if !wait {
+		select {
+		case q.jobChan <- job:
+			return nil
+		default:
+			// Filled up since the check above; the saved job will never run
+			if q.store != nil {
+				_ = q.store.UpdateJobStatus(ctx, job.JobID, jobs.JobStatusFailed, jobs.ErrQueueFull.Error())
+			}
+			return jobs.ErrQueueFull
+		}
+	}
+
 	// Enqueue job with context cancellation support
 	select {
 	case q.jobChan <- job:
@@ -90,6 +151,7 @@ func (q *Queue) Start(ctx context.Context, handler jobs.JobHandler) error {
 
 	// Start worker goroutines
 	workerCount := 5 // Configurable number of concurrent workers
+	q.workers.Add(int64(workerCount))
 	for i := 0; i < workerCount; i++ {
 		q.wg.Add(1)
 		go q.worker(ctx, handler)
@@ -101,12 +163,19 @@ func (q *Queue) Start(ctx context.Context, handler jobs.JobHandler) error {
 // worker processes jobs from the queue.
 func (q *Queue) worker(ctx context.Context, handler jobs.JobHandler) {
 	defer q.wg.Done()
+	defer q.workers.Add(-1)
 
 	for {
+		// A cancelled worker takes no more jobs, even if some are ready
+		if ctx.Err() != nil {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			return
 		case <-q.closeChan:
+			q.drain(ctx, handler)
 			return
 		case job := <-q.jobChan:
 			if job == nil {
@@ -118,6 +187,22 @@ func (q *Queue) worker(ctx context.Context, handler jobs.JobHandler) {
 	}
 }
 
+// drain runs the jobs still buffered once the queue is stopped, until the buffer is
+// empty or ctx is cancelled. Jobs left behind are picked up by Abandon.
+func (q *Queue) drain(ctx context.Context, handler jobs.JobHandler) {
+	for ctx.Err() == nil {
+		select {
+		case job := <-q.jobChan:
+			if job == nil {
+				return
+			}
+			q.processJob(ctx, job, handler)
+		default:
+			return
+		}
+	}
+}
+
 // processJob executes a single job with retry logic.
 func (q *Queue) processJob(ctx context.Context, job *jobs.ParseDocumentJob, handler jobs.JobHandler) {
 	// Update job status to running
@@ -129,30 +214,40 @@ func (q *Queue) processJob(ctx context.Context, job *jobs.ParseDocumentJob, hand
 		_ = q.store.SaveJob(ctx, job)
 	}
 
-	// Execute the job handler
-	err := handler(ctx, job)
+	// Execute the job handler with a per-job deadline
+	jobCtx, cancel := context.WithTimeout(ctx, q.jobTimeout)
+	q.inFlight.Add(1)
+	err := handler(jobCtx, job)
+	q.inFlight.Add(-1)
+	// Only the job's own deadline counts as a timeout, not the worker shutting down
+	if err != nil && errors.Is(jobCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		err = fmt.Errorf("job timed out after %s: %w", q.jobTimeout, err)
+	}
+	cancel()
 
 	// Update job status based on result
 	completedAt := time.Now()
 	job.CompletedAt = &completedAt
 
+	attempt := jobs.JobAttempt{
+		Attempt:     len(job.Attempts) + 1,
+		StartedAt:   now,
+		CompletedAt: completedAt,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	job.Attempts = append(job.Attempts, attempt)
+
+	retry := false
 	if err != nil {
 		job.Error = err.Error()
 
 		// Check if we should retry
-		if job.RetryCount < job.MaxRetries {
+		if job.RetryCount < job.MaxRetries && !errors.Is(err, jobs.ErrPermanent) {
 			job.RetryCount++
 			job.Status = jobs.JobStatusRetrying
-
-			// Re-enqueue with exponential backoff
-			backoff := time.Duration(job.RetryCount) * time.Second
-			time.AfterFunc(backoff, func() {
-				// Reset for retry
-				job.Status = jobs.JobStatusPending
-				job.StartedAt = nil
-				job.CompletedAt = nil
-				_ = q.PublishParseDocument(ctx, job)
-			})
+			retry = true
 		} else {
 			job.Status = jobs.JobStatusFailed
 		}
@@ -164,10 +259,46 @@ func (q *Queue) processJob(ctx context.Context, job *jobs.ParseDocumentJob, hand
 	if q.store != nil {
 		_ = q.store.SaveJob(ctx, job)
 	}
+
+	// Schedule the retry only after saving, as the retry modifies the job
+	if retry {
+		// Re-enqueue with exponential backoff
+		backoff := time.Duration(job.RetryCount) * time.Second
+		time.AfterFunc(backoff, func() {
+			// Reset for retry
+			job.Status = jobs.JobStatusPending
+			job.StartedAt = nil
+			job.CompletedAt = nil
+			// A retry was already accepted, so wait for room rather than drop it
+			_ = q.publish(ctx, job, true)
+		})
+	}
+}
+
+// Depth returns the number of jobs waiting in the queue buffer.
+func (q *Queue) Depth() int {
+	return len(q.jobChan)
+}
+
+// InFlight returns the number of jobs currently being processed by workers.
+func (q *Queue) InFlight() int {
+	return int(q.inFlight.Load())
+}
+
+// Capacity returns the number of jobs the queue buffer can hold.
+func (q *Queue) Capacity() int {
+	return cap(q.jobChan)
+}
+
+// Workers returns the number of running worker goroutines; it is zero until Start
+// is called and after the workers stop.
+func (q *Queue) Workers() int {
+	return int(q.workers.Load())
 }
 
 // Stop implements the Consumer interface.
-// It stops the queue and waits for all in-flight jobs to complete.
+// It stops accepting jobs and waits, bounded by ctx, for the workers to finish the
+// in-flight jobs and run the ones still buffered.
 func (q *Queue) Stop(ctx context.Context) error {
 	q.mu.Lock()
 	if q.closed {
@@ -193,6 +324,29 @@ func (q *Queue) Stop(ctx context.Context) error {
 	}
 }
 
+// Abandon removes the jobs still buffered, marks them failed and returns them so the
+// caller can fail their documents. It is meant for after Stop timed out and the worker
+// context was cancelled; the queue must already be stopped.
+func (q *Queue) Abandon(ctx context.Context) []*jobs.ParseDocumentJob {
+	var abandoned []*jobs.ParseDocumentJob
+	for {
+		select {
+		case job := <-q.jobChan:
+			if job == nil {
+				continue
+			}
+			job.Status = jobs.JobStatusFailed
+			job.Error = "abandoned: queue stopped before the job ran"
+			if q.store != nil {
+				_ = q.store.SaveJob(ctx, job)
+			}
+			abandoned = append(abandoned, job)
+		default:
+			return abandoned
+		}
+	}
+}
+
 // Close implements the Publisher interface.
 // It closes the queue and releases resources.
 func (q *Queue) Close() error {
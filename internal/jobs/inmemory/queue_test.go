@@ -0,0 +1,275 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+)
+
+// waitForJob polls the store until cond holds for the job or the deadline passes.
+func waitForJob(t *testing.T, store *Store, jobID string, cond func(*jobs.ParseDocumentJob) bool) *jobs.ParseDocumentJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := store.GetJob(context.Background(), jobID)
+		if err == nil && cond(job) {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for job %s", jobID)
+	return nil
+}
+
+func TestQueueJobTimeout(t *testing.T) {
+	store := NewStore()
+	queue := NewQueueWithConfig(QueueConfig{BufferSize: 1, JobTimeout: 20 * time.Millisecond}, store)
+	defer queue.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handler that blocks until its context is cancelled, like a stuck model call
+	handler := func(ctx context.Context, job jobs.Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := queue.Start(ctx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	job := &jobs.ParseDocumentJob{JobID: "job-1", MaxRetries: 1}
+	if err := queue.PublishParseDocument(ctx, job); err != nil {
+		t.Fatalf("PublishParseDocument: %v", err)
+	}
+
+	got := waitForJob(t, store, "job-1", func(j *jobs.ParseDocumentJob) bool {
+		return j.Status == jobs.JobStatusRetrying
+	})
+	if !strings.Contains(got.Error, "timed out") {
+		t.Errorf("job error = %q, want a timeout error", got.Error)
+	}
+}
+
+func TestQueuePermanentErrorNotRetried(t *testing.T) {
+	store := NewStore()
+	queue := NewQueue(1, store)
+	defer queue.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := func(ctx context.Context, job jobs.Job) error {
+		return fmt.Errorf("%w: invalid category", jobs.ErrPermanent)
+	}
+	if err := queue.Start(ctx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	job := &jobs.ParseDocumentJob{JobID: "job-1", MaxRetries: 3}
+	if err := queue.PublishParseDocument(ctx, job); err != nil {
+		t.Fatalf("PublishParseDocument: %v", err)
+	}
+
+	got := waitForJob(t, store, "job-1", func(j *jobs.ParseDocumentJob) bool {
+		return j.Status == jobs.JobStatusFailed || j.Status == jobs.JobStatusRetrying
+	})
+	if got.Status != jobs.JobStatusFailed || got.RetryCount != 0 {
+		t.Errorf("status = %s, retries = %d; want failed with no retries", got.Status, got.RetryCount)
+	}
+}
+
+func TestQueueDefaultJobTimeout(t *testing.T) {
+	queue := NewQueue(1, nil)
+	defer queue.Close()
+
+	if queue.jobTimeout != DefaultJobTimeout {
+		t.Errorf("jobTimeout = %s, want %s", queue.jobTimeout, DefaultJobTimeout)
+	}
+}
+
+func TestQueueRecordsAttempts(t *testing.T) {
+	store := NewStore()
+	queue := NewQueue(1, store)
+	defer queue.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Fails on the first run and succeeds on the retry
+	runs := 0
+	handler := func(ctx context.Context, job jobs.Job) error {
+		runs++
+		if runs == 1 {
+			return fmt.Errorf("model unavailable")
+		}
+		return nil
+	}
+	if err := queue.Start(ctx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	job := &jobs.ParseDocumentJob{JobID: "job-1", MaxRetries: 3}
+	if err := queue.PublishParseDocument(ctx, job); err != nil {
+		t.Fatalf("PublishParseDocument: %v", err)
+	}
+
+	got := waitForJob(t, store, "job-1", func(j *jobs.ParseDocumentJob) bool {
+		return j.Status == jobs.JobStatusCompleted
+	})
+	if len(got.Attempts) != 2 {
+		t.Fatalf("attempts = %+v, want 2", got.Attempts)
+	}
+	first, second := got.Attempts[0], got.Attempts[1]
+	if first.Attempt != 1 || first.Error != "model unavailable" {
+		t.Errorf("first attempt = %+v, want attempt 1 failing with the handler error", first)
+	}
+	if second.Attempt != 2 || second.Error != "" {
+		t.Errorf("second attempt = %+v, want attempt 2 without error", second)
+	}
+	if first.CompletedAt.Before(first.StartedAt) || second.StartedAt.Before(first.CompletedAt) {
+		t.Errorf("attempt times out of order: %+v", got.Attempts)
+	}
+}
+
+func TestQueuePublishFullQueue(t *testing.T) {
+	store := NewStore()
+	queue := NewQueue(1, store)
+	defer queue.Close()
+
+	// No consumer is running, so the first job fills the buffer
+	ctx := context.Background()
+	if err := queue.PublishParseDocument(ctx, &jobs.ParseDocumentJob{JobID: "job-1"}); err != nil {
+		t.Fatalf("PublishParseDocument(job-1): %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- queue.PublishParseDocument(ctx, &jobs.ParseDocumentJob{JobID: "job-2"})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, jobs.ErrQueueFull) {
+			t.Fatalf("PublishParseDocument(job-2) = %v, want ErrQueueFull", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PublishParseDocument blocked on a full queue")
+	}
+
+	if _, err := store.GetJob(ctx, "job-2"); err == nil {
+		t.Error("rejected job was saved to the store")
+	}
+	if depth := queue.Depth(); depth != 1 {
+		t.Errorf("Depth() = %d, want 1", depth)
+	}
+}
+
+func TestQueueDefaultBufferSize(t *testing.T) {
+	queue := NewQueueWithConfig(QueueConfig{}, nil)
+	defer queue.Close()
+	if got := cap(queue.jobChan); got != DefaultBufferSize {
+		t.Errorf("buffer size = %d, want %d", got, DefaultBufferSize)
+	}
+}
+
+func TestQueueWorkers(t *testing.T) {
+	queue := NewQueue(10, nil)
+	if got := queue.Workers(); got != 0 {
+		t.Errorf("Workers() before Start = %d, want 0", got)
+	}
+	if got := queue.Capacity(); got != 10 {
+		t.Errorf("Capacity() = %d, want 10", got)
+	}
+
+	if err := queue.Start(context.Background(), func(ctx context.Context, job jobs.Job) error { return nil }); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got := queue.Workers(); got != 5 {
+		t.Errorf("Workers() after Start = %d, want 5", got)
+	}
+
+	if err := queue.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := queue.Workers(); got != 0 {
+		t.Errorf("Workers() after Stop = %d, want 0", got)
+	}
+}
+
+func TestQueueStopRunsBufferedJobs(t *testing.T) {
+	store := NewStore()
+	queue := NewQueue(20, store)
+
+	// No consumer yet, so every job waits in the buffer
+	ctx := context.Background()
+	for i := 0; i < 12; i++ {
+		if err := queue.PublishParseDocument(ctx, &jobs.ParseDocumentJob{JobID: fmt.Sprintf("job-%d", i)}); err != nil {
+			t.Fatalf("PublishParseDocument: %v", err)
+		}
+	}
+
+	release := make(chan struct{})
+	handler := func(ctx context.Context, job jobs.Job) error {
+		<-release
+		return nil
+	}
+	if err := queue.Start(ctx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- queue.Stop(ctx) }()
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if depth := queue.Depth(); depth != 0 {
+		t.Errorf("Depth() after Stop = %d, want 0", depth)
+	}
+	for i := 0; i < 12; i++ {
+		job, err := store.GetJob(ctx, fmt.Sprintf("job-%d", i))
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if job.Status != jobs.JobStatusCompleted {
+			t.Errorf("job-%d status = %s, want %s", i, job.Status, jobs.JobStatusCompleted)
+		}
+	}
+}
+
+func TestQueueAbandon(t *testing.T) {
+	store := NewStore()
+	queue := NewQueue(5, store)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := queue.PublishParseDocument(ctx, &jobs.ParseDocumentJob{JobID: fmt.Sprintf("job-%d", i)}); err != nil {
+			t.Fatalf("PublishParseDocument: %v", err)
+		}
+	}
+	// Stopped without ever starting, so nothing ran
+	if err := queue.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	abandoned := queue.Abandon(ctx)
+	if len(abandoned) != 3 {
+		t.Fatalf("Abandon returned %d jobs, want 3", len(abandoned))
+	}
+	if depth := queue.Depth(); depth != 0 {
+		t.Errorf("Depth() after Abandon = %d, want 0", depth)
+	}
+	job, err := store.GetJob(ctx, "job-0")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.Status != jobs.JobStatusFailed || !strings.Contains(job.Error, "abandoned") {
+		t.Errorf("abandoned job = %s %q, want FAILED with an abandoned error", job.Status, job.Error)
+	}
+}
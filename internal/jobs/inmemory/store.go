@@ -3,6 +3,7 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/dvloznov/finance-tracker/internal/jobs"
@@ -34,8 +35,7 @@ func (s *Store) SaveJob(ctx context.Context, job *jobs.ParseDocumentJob) error {
 	defer s.mu.Unlock()
 
 	// Create a copy to avoid external modifications
-	jobCopy := *job
-	s.jobs[job.JobID] = &jobCopy
+	s.jobs[job.JobID] = job.Clone()
 
 	return nil
 }
@@ -52,8 +52,7 @@ func (s *Store) GetJob(ctx context.Context, jobID string) (*jobs.ParseDocumentJo
 	}
 
 	// Return a copy to avoid external modifications
-	jobCopy := *job
-	return &jobCopy, nil
+	return job.Clone(), nil
 }
 
 // ListJobs implements the JobStore interface.
@@ -66,18 +65,27 @@ func (s *Store) ListJobs(ctx context.Context, filter jobs.JobFilter) ([]*jobs.Pa
 
 	for _, job := range s.jobs {
 		// Apply filters
-		if filter.DocumentID != "" && job.DocumentID != filter.DocumentID {
-			continue
-		}
-		if filter.Status != "" && job.Status != filter.Status {
+		if !filter.Matches(job) {
 			continue
 		}
 
 		// Create a copy to avoid external modifications
-		jobCopy := *job
-		result = append(result, &jobCopy)
+		result = append(result, job.Clone())
 	}
 
+	// Map iteration order is random; sort so offset pagination is stable. Jobs
+	// created at the same instant are ordered by ID.
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			if filter.Order == jobs.JobOrderCreatedAsc {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.JobID < b.JobID
+	})
+
 	// Apply limit and offset
 	if filter.Offset > 0 {
 		if filter.Offset >= len(result) {
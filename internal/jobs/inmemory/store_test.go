@@ -0,0 +1,115 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+)
+
+func TestStoreListJobs_TimeRangeAndOrder(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"job-1", "job-2", "job-3", "job-4"} {
+		job := &jobs.ParseDocumentJob{JobID: id, Status: jobs.JobStatusPending, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := store.SaveJob(ctx, job); err != nil {
+			t.Fatalf("SaveJob: %v", err)
+		}
+	}
+
+	ids := func(list []*jobs.ParseDocumentJob) []string {
+		out := make([]string, len(list))
+		for i, j := range list {
+			out[i] = j.JobID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter jobs.JobFilter
+		want   []string
+	}{
+		{"newest first by default", jobs.JobFilter{}, []string{"job-4", "job-3", "job-2", "job-1"}},
+		{"oldest first", jobs.JobFilter{Order: jobs.JobOrderCreatedAsc}, []string{"job-1", "job-2", "job-3", "job-4"}},
+		{"created after is inclusive", jobs.JobFilter{CreatedAfter: base.Add(time.Hour)}, []string{"job-4", "job-3", "job-2"}},
+		{"created before is exclusive", jobs.JobFilter{CreatedBefore: base.Add(2 * time.Hour)}, []string{"job-2", "job-1"}},
+		{"range with paging", jobs.JobFilter{CreatedAfter: base, CreatedBefore: base.Add(3 * time.Hour), Offset: 1, Limit: 1}, []string{"job-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := store.ListJobs(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListJobs: %v", err)
+			}
+			got := ids(list)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreListJobs_StableOrder(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	// Several jobs share a creation time, so only the tie-break keeps them in order
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		job := &jobs.ParseDocumentJob{
+			JobID:     fmt.Sprintf("job-%02d", i),
+			Status:    jobs.JobStatusPending,
+			CreatedAt: created.Add(time.Duration(i/4) * time.Minute),
+		}
+		if err := store.SaveJob(ctx, job); err != nil {
+			t.Fatalf("SaveJob: %v", err)
+		}
+	}
+
+	first, err := store.ListJobs(ctx, jobs.JobFilter{})
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	for call := 0; call < 10; call++ {
+		again, err := store.ListJobs(ctx, jobs.JobFilter{})
+		if err != nil {
+			t.Fatalf("ListJobs: %v", err)
+		}
+		for i := range first {
+			if again[i].JobID != first[i].JobID {
+				t.Fatalf("call %d: position %d is %s, was %s", call, i, again[i].JobID, first[i].JobID)
+			}
+		}
+	}
+
+	// Pages of 3 cover every job exactly once, newest first
+	seen := map[string]bool{}
+	var prev *jobs.ParseDocumentJob
+	for offset := 0; offset < len(first); offset += 3 {
+		page, err := store.ListJobs(ctx, jobs.JobFilter{Offset: offset, Limit: 3})
+		if err != nil {
+			t.Fatalf("ListJobs: %v", err)
+		}
+		for _, job := range page {
+			if seen[job.JobID] {
+				t.Errorf("job %s returned on more than one page", job.JobID)
+			}
+			seen[job.JobID] = true
+			if prev != nil && job.CreatedAt.After(prev.CreatedAt) {
+				t.Errorf("job %s is newer than the job before it", job.JobID)
+			}
+			prev = job
+		}
+	}
+	if len(seen) != len(first) {
+		t.Errorf("pages returned %d jobs, want %d", len(seen), len(first))
+	}
+}
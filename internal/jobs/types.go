@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -40,6 +42,14 @@ type ParseDocumentJob struct {
 	// GCSURI is the GCS URI of the document to parse.
 	GCSURI string `json:"gcs_uri"`
 
+	// InstitutionID is the issuing bank given by the client; empty means it is
+	// detected from the statement.
+	InstitutionID string `json:"institution_id,omitempty"`
+
+	// PDFPassword opens a password-protected statement PDF. It is never serialized, so
+	// it is not returned by the jobs API.
+	PDFPassword string `json:"-"`
+
 	// ParsingRunID is the ID of the parsing run in BigQuery.
 	ParsingRunID string `json:"parsing_run_id,omitempty"`
 
@@ -63,6 +73,32 @@ type ParseDocumentJob struct {
 
 	// MaxRetries is the maximum number of retries allowed.
 	MaxRetries int `json:"max_retries"`
+
+	// Attempts records every run of the job, oldest first, so the errors that
+	// led to retries remain visible after a later attempt succeeds.
+	Attempts []JobAttempt `json:"attempts,omitempty"`
+}
+
+// JobAttempt is a single run of a job.
+type JobAttempt struct {
+	// Attempt is the 1-based number of the run.
+	Attempt int `json:"attempt"`
+
+	// StartedAt is when the run started.
+	StartedAt time.Time `json:"started_at"`
+
+	// CompletedAt is when the run finished.
+	CompletedAt time.Time `json:"completed_at"`
+
+	// Error is the error the run failed with, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Clone returns a copy of the job that shares no mutable state with it.
+func (j *ParseDocumentJob) Clone() *ParseDocumentJob {
+	c := *j
+	c.Attempts = append([]JobAttempt(nil), j.Attempts...)
+	return &c
 }
 
 // Job is a generic interface for all job types.
@@ -96,6 +132,7 @@ func (j *ParseDocumentJob) GetStatus() JobStatus {
 // This abstraction allows for different queue implementations (in-memory, Cloud Tasks, Pub/Sub).
 type Publisher interface {
 	// PublishParseDocument publishes a document parsing job.
+	// It returns ErrQueueFull instead of blocking when the queue has no room.
 	PublishParseDocument(ctx context.Context, job *ParseDocumentJob) error
 
 	// Close closes the publisher and releases resources.
@@ -115,8 +152,16 @@ type Consumer interface {
 
 // JobHandler is a function that processes a job.
 // It should return an error if the job failed and should be retried.
+// Errors wrapping ErrPermanent fail the job without retrying.
 type JobHandler func(ctx context.Context, job Job) error
 
+// ErrPermanent marks a job failure that retrying cannot fix.
+var ErrPermanent = errors.New("permanent failure")
+
+// ErrQueueFull is returned by a Publisher that cannot take more jobs right now.
+// Callers should ask the client to retry later rather than wait.
+var ErrQueueFull = errors.New("job queue is full")
+
 // JobStore defines the interface for storing and retrieving job status.
 // This allows tracking job execution across service restarts.
 type JobStore interface {
@@ -141,9 +186,57 @@ type JobFilter struct {
 	// Status filters jobs by status.
 	Status JobStatus
 
+	// CreatedAfter, when non-zero, keeps only jobs created at or after it.
+	CreatedAfter time.Time
+
+	// CreatedBefore, when non-zero, keeps only jobs created before it.
+	CreatedBefore time.Time
+
+	// Order sorts the results by creation time; empty means JobOrderCreatedDesc.
+	Order JobOrder
+
 	// Limit limits the number of results.
 	Limit int
 
 	// Offset for pagination.
 	Offset int
 }
+
+// JobOrder is the sort order of ListJobs results.
+type JobOrder string
+
+const (
+	// JobOrderCreatedDesc lists the newest jobs first.
+	JobOrderCreatedDesc JobOrder = "created_desc"
+	// JobOrderCreatedAsc lists the oldest jobs first.
+	JobOrderCreatedAsc JobOrder = "created_asc"
+)
+
+// ParseJobOrder parses a JobOrder; "" is JobOrderCreatedDesc.
+func ParseJobOrder(s string) (JobOrder, error) {
+	switch o := JobOrder(s); o {
+	case "":
+		return JobOrderCreatedDesc, nil
+	case JobOrderCreatedDesc, JobOrderCreatedAsc:
+		return o, nil
+	default:
+		return "", fmt.Errorf("invalid job order %q: must be %s or %s", s, JobOrderCreatedDesc, JobOrderCreatedAsc)
+	}
+}
+
+// Matches reports whether job passes the filter's document, status and time criteria.
+func (f JobFilter) Matches(job *ParseDocumentJob) bool {
+	if f.DocumentID != "" && job.DocumentID != f.DocumentID {
+		return false
+	}
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && job.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !job.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
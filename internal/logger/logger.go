@@ -2,8 +2,11 @@ package logger
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -17,13 +20,57 @@ const (
 	LoggerKey ContextKey = "logger"
 )
 
-// New creates a new structured logger with default configuration
+// FormatEnv is the environment variable selecting the log output format.
+const FormatEnv = "LOG_FORMAT"
+
+// Log output formats.
+const (
+	// FormatJSON writes one JSON object per line. This is the default, for production.
+	FormatJSON = "json"
+
+	// FormatConsole writes human-readable, coloured lines for local development.
+	FormatConsole = "console"
+)
+
+// FormatFromEnv returns the log format set in FormatEnv, or FormatJSON if it is unset.
+func FormatFromEnv() string {
+	if format := os.Getenv(FormatEnv); format != "" {
+		return format
+	}
+	return FormatJSON
+}
+
+// FormatFlag registers a -log-format flag on fs, defaulting to FormatFromEnv.
+func FormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("log-format", FormatFromEnv(), "Log output format: json or console (or set LOG_FORMAT env)")
+}
+
+// New creates a new structured logger in the format set by LOG_FORMAT.
+// An unknown format falls back to JSON; use NewWithFormat to report it.
 func New() zerolog.Logger {
-	output := zerolog.ConsoleWriter{
-		Out:        os.Stdout,
-		TimeFormat: time.RFC3339,
+	log, _ := NewWithFormat(FormatFromEnv())
+	return log
+}
+
+// NewWithFormat creates a new structured logger writing to stdout in the given format.
+// For an unknown format it returns a JSON logger along with the error, so the caller
+// can log it.
+func NewWithFormat(format string) (zerolog.Logger, error) {
+	return newWithFormat(os.Stdout, format)
+}
+
+func newWithFormat(w io.Writer, format string) (zerolog.Logger, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatJSON:
+		return NewWithWriter(w), nil
+	case FormatConsole:
+		return NewWithWriter(zerolog.ConsoleWriter{
+			Out:        w,
+			TimeFormat: time.RFC3339,
+		}), nil
+	default:
+		return NewWithWriter(w), fmt.Errorf("unknown log format %q: must be %s or %s", format, FormatJSON, FormatConsole)
 	}
-	return zerolog.New(output).With().Timestamp().Caller().Logger()
 }
 
 // NewWithWriter creates a new structured logger with a custom writer
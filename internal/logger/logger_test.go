@@ -3,6 +3,7 @@ package logger
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -83,3 +84,59 @@ func TestWithFields(t *testing.T) {
 		t.Errorf("Expected output to contain action field, got: %s", output)
 	}
 }
+
+func TestNewWithFormat(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log, err := newWithFormat(buf, FormatJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		log.Info().Msg("hello")
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("output is not JSON: %v: %s", err, buf.String())
+		}
+		if entry["message"] != "hello" {
+			t.Errorf("message = %v, want hello", entry["message"])
+		}
+	})
+
+	t.Run("console", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log, err := newWithFormat(buf, "Console")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		log.Info().Msg("hello")
+
+		if json.Valid(buf.Bytes()) || !strings.Contains(buf.String(), "hello") {
+			t.Errorf("expected console output, got: %s", buf.String())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log, err := newWithFormat(buf, "xml")
+		if err == nil {
+			t.Fatal("expected error for unknown format")
+		}
+		log.Info().Msg("hello")
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("expected JSON fallback, got: %s", buf.String())
+		}
+	})
+}
+
+func TestFormatFromEnv(t *testing.T) {
+	t.Setenv(FormatEnv, "")
+	if got := FormatFromEnv(); got != FormatJSON {
+		t.Errorf("FormatFromEnv() = %q, want %q", got, FormatJSON)
+	}
+
+	t.Setenv(FormatEnv, FormatConsole)
+	if got := FormatFromEnv(); got != FormatConsole {
+		t.Errorf("FormatFromEnv() = %q, want %q", got, FormatConsole)
+	}
+}
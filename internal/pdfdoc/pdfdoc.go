@@ -0,0 +1,165 @@
+// Package pdfdoc reads the page tree of statement PDFs and writes selected pages to a
+// new, self-contained PDF, so long statements can be sent to the model a few pages at a
+// time and password-protected ones can be sent decrypted.
+package pdfdoc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxTreeDepth bounds how deep the page tree and page objects are followed, so that a
+// malformed or cyclic file fails instead of recursing forever.
+const maxTreeDepth = 64
+
+// ErrPasswordRequired is returned when opening an encrypted PDF that needs a password
+// without one.
+var ErrPasswordRequired = errors.New("pdfdoc: password required")
+
+// ErrPasswordIncorrect is returned when the password given for an encrypted PDF does not
+// open it.
+var ErrPasswordIncorrect = errors.New("pdfdoc: password incorrect")
+
+// minRC4KeyBits is the shortest RC4 key the reader decrypts correctly: it derives object
+// keys from shorter ones with too many bytes.
+const minRC4KeyBits = 88
+
+// ErrUnsupportedImage is returned by Extract for pages showing an image the reader cannot
+// decode, such as a JPEG scan, as the copy would show it blank.
+var ErrUnsupportedImage = errors.New("pdfdoc: unsupported image encoding")
+
+// Document is a parsed PDF.
+type Document struct {
+	pages     []pdf.Value
+	encrypted bool
+	aes       bool
+}
+
+// Open parses a PDF and collects its pages. Encrypted PDFs only open if they have an
+// empty user password; use OpenWithPassword for the others.
+func Open(data []byte) (*Document, error) {
+	return OpenWithPassword(data, "")
+}
+
+// OpenWithPassword parses a PDF that may be encrypted with the user password password,
+// and collects its pages, which are read decrypted. It returns ErrPasswordRequired or
+// ErrPasswordIncorrect if the PDF does not open without a password or with the given
+// one. RC4 with keys of 88 bits or more and AES-128 encryption are supported.
+func OpenWithPassword(data []byte, password string) (doc *Document, err error) {
+	// The PDF reader panics on malformed input
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("pdfdoc: malformed PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if errors.Is(err, pdf.ErrInvalidPassword) {
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+		// The reader asks for passwords until it gets an empty one
+		tried := false
+		r, err = pdf.NewReaderEncrypted(bytes.NewReader(data), int64(len(data)), func() string {
+			if tried {
+				return ""
+			}
+			tried = true
+			return password
+		})
+		if errors.Is(err, pdf.ErrInvalidPassword) {
+			return nil, ErrPasswordIncorrect
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pdfdoc: %w", err)
+	}
+	return newDocument(r)
+}
+
+// newDocument collects the pages of r in document order.
+func newDocument(r *pdf.Reader) (*Document, error) {
+	root := r.Trailer().Key("Root").Key("Pages")
+	if root.Kind() != pdf.Dict {
+		return nil, fmt.Errorf("pdfdoc: malformed PDF: no page tree")
+	}
+	encrypt := r.Trailer().Key("Encrypt")
+	doc := &Document{encrypted: !encrypt.IsNull(), aes: encrypt.Key("V").Int64() == 4}
+	if doc.encrypted && !doc.aes {
+		bits := encrypt.Key("Length").Int64()
+		if bits == 0 {
+			bits = 40
+		}
+		if bits < minRC4KeyBits {
+			return nil, fmt.Errorf("pdfdoc: unsupported PDF: %d-bit RC4 encryption", bits)
+		}
+	}
+	if err := doc.collectPages(root, 0); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// collectPages appends the pages below the page tree node to d.pages.
+func (d *Document) collectPages(node pdf.Value, depth int) error {
+	if depth > maxTreeDepth {
+		return fmt.Errorf("pdfdoc: malformed PDF: page tree deeper than %d levels", maxTreeDepth)
+	}
+	kids := node.Key("Kids")
+	if kids.Kind() != pdf.Array {
+		d.pages = append(d.pages, node)
+		return nil
+	}
+	for i := 0; i < kids.Len(); i++ {
+		if err := d.collectPages(kids.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NumPages returns the number of pages.
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// Encrypted reports whether the PDF is encrypted. Pages extracted from it are not.
+func (d *Document) Encrypted() bool {
+	return d.encrypted
+}
+
+// Extract returns a new PDF holding pages first to last (1-based, inclusive). Only what
+// the pages display is kept: annotations, links and the outline are dropped. Every
+// stream is decoded and compressed again, so it fails if a page uses content the reader
+// cannot decode, with ErrUnsupportedImage for images such as JPEG scans.
+func (d *Document) Extract(first, last int) (out []byte, err error) {
+	if first < 1 || last > len(d.pages) || first > last {
+		return nil, fmt.Errorf("pdfdoc: pages %d-%d out of range 1-%d", first, last, len(d.pages))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, fmt.Errorf("pdfdoc: extracting pages %d-%d: %v", first, last, r)
+		}
+	}()
+
+	w := newWriter(d.aes)
+	for _, page := range d.pages[first-1 : last] {
+		if err := w.writePage(page); err != nil {
+			return nil, fmt.Errorf("pdfdoc: extracting pages %d-%d: %w", first, last, err)
+		}
+	}
+	return w.finish(), nil
+}
+
+// PageCount returns the number of pages in a PDF, or 0 if it cannot be read.
+func PageCount(data []byte) int {
+	doc, err := Open(data)
+	if err != nil {
+		return 0
+	}
+	return doc.NumPages()
+}
@@ -0,0 +1,466 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// streamObject returns a stream object with the given dictionary entries and data.
+func streamObject(entries, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", entries, len(data), data)
+}
+
+// flateStreamObject returns a Flate-compressed stream object.
+func flateStreamObject(entries, data string) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	return streamObject(entries+" /Filter /FlateDecode", buf.String())
+}
+
+// buildPDF returns a PDF with the given objects, numbered from 1, indexed by a classic
+// cross-reference table. Object 1 must be the catalog.
+func buildPDF(objects ...string) []byte {
+	return buildPDFWithTrailer("", objects...)
+}
+
+// buildPDFWithTrailer returns a PDF like buildPDF with extra trailer entries.
+func buildPDFWithTrailer(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+// buildCompressedPDF returns a PDF like buildPDF, but with every object that is not a
+// stream stored in a compressed object stream and a cross-reference stream instead of
+// the table, as most current producers write them.
+func buildCompressedPDF(objects ...string) []byte {
+	objStm := len(objects) + 1
+	xrefStm := len(objects) + 2
+
+	var header, body bytes.Buffer
+	index := make(map[int]int)
+	for i, obj := range objects {
+		if strings.Contains(obj, "stream\n") {
+			continue
+		}
+		index[i+1] = len(index)
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(obj + "\n")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := make(map[int]int)
+	for i, obj := range objects {
+		if _, ok := index[i+1]; ok {
+			continue
+		}
+		offsets[i+1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	offsets[objStm] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", objStm, flateStreamObject(
+		fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(index), header.Len()), header.String()+body.String()))
+
+	offsets[xrefStm] = buf.Len()
+	var entries bytes.Buffer
+	entry := func(typ byte, field2 uint32, field3 uint16) {
+		entries.WriteByte(typ)
+		binary.Write(&entries, binary.BigEndian, field2)
+		binary.Write(&entries, binary.BigEndian, field3)
+	}
+	entry(0, 0, 65535)
+	for num := 1; num <= xrefStm; num++ {
+		if i, ok := index[num]; ok {
+			entry(2, uint32(objStm), uint16(i))
+		} else {
+			entry(1, uint32(offsets[num]), 0)
+		}
+	}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", xrefStm, streamObject(
+		fmt.Sprintf("/Type /XRef /Size %d /W [1 4 2] /Root 1 0 R", xrefStm+1), entries.String()))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", offsets[xrefStm])
+	return buf.Bytes()
+}
+
+// passwordPad pads passwords to 32 bytes in the PDF standard security handler.
+var passwordPad = []byte("\x28\xbf\x4e\x5e\x4e\x75\x8a\x41\x64\x00\x4e\x56\xff\xfa\x01\x08" +
+	"\x2e\x2e\x00\xb6\xd0\x68\x3e\x80\x2f\x0c\xa9\xfe\x64\x53\x69\x7a")
+
+// buildEncryptedPDF returns a PDF like buildPDF with its streams encrypted with 128-bit
+// RC4 (revision 3 of the standard security handler) for the user password password.
+func buildEncryptedPDF(password string, objects ...string) []byte {
+	id := []byte("0123456789abcdef")
+	owner := bytes.Repeat([]byte{0x4f}, 32) // only checked for the owner password
+	const permissions = -4
+
+	h := md5.New()
+	h.Write(append([]byte(password), passwordPad...)[:32])
+	h.Write(owner)
+	binary.Write(h, binary.LittleEndian, int32(permissions))
+	h.Write(id)
+	key := h.Sum(nil)
+	for i := 0; i < 50; i++ {
+		sum := md5.Sum(key)
+		key = sum[:]
+	}
+
+	h.Reset()
+	h.Write(passwordPad)
+	h.Write(id)
+	user := h.Sum(nil)
+	for i := 0; i <= 19; i++ {
+		roundKey := make([]byte, len(key))
+		for j := range key {
+			roundKey[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(roundKey)
+		c.XORKeyStream(user, user)
+	}
+	user = append(user, make([]byte, 16)...)
+
+	encrypted := make([]string, len(objects))
+	for i, obj := range objects {
+		start := strings.Index(obj, "stream\n") + len("stream\n")
+		end := strings.LastIndex(obj, "\nendstream")
+		if start < len("stream\n") || end < start {
+			encrypted[i] = obj
+			continue
+		}
+		num := i + 1
+		sum := md5.Sum(append(append([]byte{}, key...), byte(num), byte(num>>8), byte(num>>16), 0, 0))
+		c, _ := rc4.NewCipher(sum[:])
+		data := []byte(obj[start:end])
+		c.XORKeyStream(data, data)
+		encrypted[i] = obj[:start] + string(data) + obj[end:]
+	}
+
+	trailer := fmt.Sprintf(" /ID [<%x> <%x>] /Encrypt << /Filter /Standard /V 2 /R 3 /Length 128 /P %d /O <%s> /U <%s> >>",
+		id, id, permissions, hex.EncodeToString(owner), hex.EncodeToString(user))
+	return buildPDFWithTrailer(trailer, encrypted...)
+}
+
+// logoPixels are the RGB samples of the 2x2 logo on page 1 of statementObjects.
+const logoPixels = "\xff\x00\x00\x00\xff\x00\x00\x00\xff\xff\xff\xff"
+
+// jpegLogo returns objects with the logo of statementObjects stored as a JPEG, which the
+// reader cannot decode.
+func jpegLogo(objects []string) []string {
+	objects[3] = streamObject("/Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", "\xff\xd8 not really a JPEG")
+	return objects
+}
+
+// logoData returns the decoded samples of the logo on page.
+func logoData(t *testing.T, page pdf.Value) string {
+	t.Helper()
+	logo := page.Key("Resources").Key("XObject").Key("Logo")
+	if logo.Key("Width").Int64() != 2 || logo.Key("ColorSpace").Name() != "DeviceRGB" {
+		t.Errorf("logo = %v, want a 2x2 RGB image", logo)
+	}
+	data, err := readStream(logo)
+	if err != nil {
+		t.Fatalf("logo: %v", err)
+	}
+	return string(data)
+}
+
+// statementObjects returns the objects of a statement with the given number of pages in
+// a two-level page tree. The pages inherit their resources and media box, page 1 has a
+// logo image and page n shows "page n".
+func statementObjects(pages int) []string {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // page tree root, filled in below
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		flateStreamObject("/Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceRGB /BitsPerComponent 8", logoPixels),
+	}
+	var kids []string
+	for first := 1; first <= pages; first += 2 {
+		node := len(objects) + 1
+		objects = append(objects, "")
+		var leaves []string
+		for n := first; n <= pages && n < first+2; n++ {
+			content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (page %d) Tj ET", n)
+			entries := ""
+			if n == 1 {
+				content += " q 50 0 0 50 72 72 cm /Logo Do Q"
+				entries = " /Resources << /Font << /F1 3 0 R >> /XObject << /Logo 4 0 R >> >>"
+			}
+			objects = append(objects, flateStreamObject("", content))
+			objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R%s >>", node, len(objects), entries))
+			leaves = append(leaves, fmt.Sprintf("%d 0 R", len(objects)))
+		}
+		objects[node-1] = fmt.Sprintf("<< /Type /Pages /Parent 2 0 R /Kids [%s] /Count %d >>", strings.Join(leaves, " "), len(leaves))
+		kids = append(kids, fmt.Sprintf("%d 0 R", node))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> >>", strings.Join(kids, " "), pages)
+	return objects
+}
+
+// pageContent returns the decoded content stream of page n of doc.
+func pageContent(t *testing.T, doc *Document, n int) string {
+	t.Helper()
+	data, err := readStream(doc.pages[n-1].Key("Contents"))
+	if err != nil {
+		t.Fatalf("page %d: %v", n, err)
+	}
+	return string(data)
+}
+
+func TestOpenCountsPages(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  []byte
+	}{
+		{name: "cross-reference table", pdf: buildPDF(statementObjects(5)...)},
+		{name: "object streams", pdf: buildCompressedPDF(statementObjects(5)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Open(tt.pdf)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if doc.NumPages() != 5 {
+				t.Errorf("NumPages() = %d, want 5", doc.NumPages())
+			}
+			if got := pageContent(t, doc, 4); !strings.Contains(got, "(page 4)") {
+				t.Errorf("page 4 content = %q", got)
+			}
+			if got := PageCount(tt.pdf); got != 5 {
+				t.Errorf("PageCount() = %d, want 5", got)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsMalformedPDFs(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("not a PDF"), []byte("%PDF-1.4\ntruncated")} {
+		if _, err := Open(data); err == nil {
+			t.Errorf("Open(%q) succeeded", data)
+		}
+		if got := PageCount(data); got != 0 {
+			t.Errorf("PageCount(%q) = %d, want 0", data, got)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	doc, err := Open(buildCompressedPDF(statementObjects(5)...))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	out, err := doc.Extract(1, 3)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	chunk, err := Open(out)
+	if err != nil {
+		t.Fatalf("Open(extracted): %v", err)
+	}
+	if chunk.NumPages() != 3 {
+		t.Fatalf("extracted %d pages, want 3", chunk.NumPages())
+	}
+	for n := 1; n <= 3; n++ {
+		if got := pageContent(t, chunk, n); !strings.Contains(got, fmt.Sprintf("(page %d)", n)) {
+			t.Errorf("extracted page %d content = %q", n, got)
+		}
+	}
+
+	page := chunk.pages[0]
+	if got := page.Key("MediaBox").Index(3).Int64(); got != 842 {
+		t.Errorf("inherited MediaBox height = %d, want 842", got)
+	}
+	if got := page.Key("Resources").Key("Font").Key("F1").Key("BaseFont").Name(); got != "Helvetica" {
+		t.Errorf("font = %q, want Helvetica", got)
+	}
+	if got := chunk.pages[1].Key("Resources").Key("Font").Key("F1").Key("BaseFont").Name(); got != "Helvetica" {
+		t.Errorf("inherited font = %q, want Helvetica", got)
+	}
+	if got := logoData(t, page); got != logoPixels {
+		t.Errorf("logo samples = %q, want %q", got, logoPixels)
+	}
+
+	last, err := doc.Extract(5, 5)
+	if err != nil {
+		t.Fatalf("Extract(5, 5): %v", err)
+	}
+	if n := PageCount(last); n != 1 {
+		t.Errorf("Extract(5, 5) has %d pages, want 1", n)
+	}
+	if len(last) >= len(out) {
+		t.Errorf("one page (%d bytes) is not smaller than three (%d bytes)", len(last), len(out))
+	}
+}
+
+func TestOpenWithPassword(t *testing.T) {
+	encrypted := buildEncryptedPDF("s3cret", statementObjects(3)...)
+	tests := []struct {
+		name     string
+		pdf      []byte
+		password string
+		want     error
+	}{
+		{name: "no password", pdf: encrypted, want: ErrPasswordRequired},
+		{name: "incorrect password", pdf: encrypted, password: "guess", want: ErrPasswordIncorrect},
+		{name: "correct password", pdf: encrypted, password: "s3cret"},
+		{name: "empty user password", pdf: buildEncryptedPDF("", statementObjects(3)...)},
+		{name: "not encrypted", pdf: buildPDF(statementObjects(3)...), password: "s3cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := OpenWithPassword(tt.pdf, tt.password)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("OpenWithPassword() error = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil {
+				return
+			}
+			if got := pageContent(t, doc, 2); !strings.Contains(got, "(page 2)") {
+				t.Errorf("decrypted page 2 content = %q", got)
+			}
+		})
+	}
+}
+
+func TestExtractDecrypts(t *testing.T) {
+	doc, err := OpenWithPassword(buildEncryptedPDF("s3cret", statementObjects(3)...), "s3cret")
+	if err != nil {
+		t.Fatalf("OpenWithPassword: %v", err)
+	}
+	if !doc.Encrypted() {
+		t.Error("Encrypted() = false, want true")
+	}
+	out, err := doc.Extract(1, doc.NumPages())
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	// The copy opens without a password
+	plain, err := Open(out)
+	if err != nil {
+		t.Fatalf("Open(extracted): %v", err)
+	}
+	if plain.Encrypted() || plain.NumPages() != 3 {
+		t.Errorf("extracted PDF encrypted = %v with %d pages, want false with 3", plain.Encrypted(), plain.NumPages())
+	}
+	if got := pageContent(t, plain, 3); !strings.Contains(got, "(page 3)") {
+		t.Errorf("extracted page 3 content = %q", got)
+	}
+	if got := logoData(t, plain.pages[0]); got != logoPixels {
+		t.Errorf("decrypted logo samples = %q, want %q", got, logoPixels)
+	}
+}
+
+func TestExtractRejectsUndecodableImages(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  []byte
+	}{
+		{name: "plain", pdf: buildPDF(jpegLogo(statementObjects(3))...)},
+		{name: "encrypted", pdf: buildEncryptedPDF("s3cret", jpegLogo(statementObjects(3))...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := OpenWithPassword(tt.pdf, "s3cret")
+			if err != nil {
+				t.Fatalf("OpenWithPassword: %v", err)
+			}
+			if _, err := doc.Extract(1, 3); !errors.Is(err, ErrUnsupportedImage) {
+				t.Errorf("Extract(1, 3) error = %v, want ErrUnsupportedImage", err)
+			}
+			// Pages without the image are copied
+			if _, err := doc.Extract(2, 3); err != nil {
+				t.Errorf("Extract(2, 3): %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsShortRC4Keys(t *testing.T) {
+	// 40-bit RC4 (revision 2) with an empty user password, which opens without one
+	id, owner := []byte("0123456789abcdef"), bytes.Repeat([]byte{0x4f}, 32)
+	h := md5.New()
+	h.Write(passwordPad)
+	h.Write(owner)
+	binary.Write(h, binary.LittleEndian, int32(-4))
+	h.Write(id)
+	c, _ := rc4.NewCipher(h.Sum(nil)[:5])
+	user := make([]byte, 32)
+	c.XORKeyStream(user, passwordPad)
+
+	data := buildPDFWithTrailer(fmt.Sprintf(" /ID [<%x> <%x>] /Encrypt << /Filter /Standard /V 1 /R 2 /P -4 /O <%x> /U <%x> >>",
+		id, id, owner, user), statementObjects(1)...)
+	_, err := Open(data)
+	if err == nil || !strings.Contains(err.Error(), "40-bit RC4") {
+		t.Errorf("Open() error = %v, want unsupported 40-bit RC4 encryption", err)
+	}
+}
+
+func TestUnpadAES(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"BT ET\x03\x03\x03", "BT ET"},
+		{"0123456789abcdef" + strings.Repeat("\x10", 16), "0123456789abcdef"},
+		{"BT ET\x03\x02", "BT ET\x03\x02"},
+		{"BT ET", "BT ET"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(unpadAES([]byte(tt.in))); got != tt.want {
+			t.Errorf("unpadAES(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtractRejectsInvalidRanges(t *testing.T) {
+	doc, err := Open(buildPDF(statementObjects(3)...))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, r := range [][2]int{{0, 1}, {2, 4}, {3, 2}} {
+		if _, err := doc.Extract(r[0], r[1]); err == nil {
+			t.Errorf("Extract(%d, %d) succeeded", r[0], r[1])
+		}
+	}
+}
+
+func TestExtractFailsOnUndecodableContent(t *testing.T) {
+	objects := statementObjects(1)
+	// Replace the content stream with one in a filter the reader cannot decode
+	for i, obj := range objects {
+		if strings.Contains(obj, "/FlateDecode") && !strings.Contains(obj, "/Image") {
+			objects[i] = streamObject("/Filter /LZWDecode", "\x80\x0b\x60\x50")
+		}
+	}
+	doc, err := Open(buildPDF(objects...))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := doc.Extract(1, 1); err == nil {
+		t.Error("Extract succeeded on an LZW content stream")
+	}
+}
@@ -0,0 +1,302 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Object numbers of the catalog and page tree root written by writer.
+const (
+	catalogObject = 1
+	pagesObject   = 2
+)
+
+// inheritedPageKeys are the page attributes a page can inherit from the page tree. They
+// are copied onto each written page, as the original tree is not written.
+var inheritedPageKeys = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// droppedPageKeys are page entries that are not written: the page tree parent, which is
+// replaced, and entries pointing at the rest of the document.
+var droppedPageKeys = map[string]bool{"Parent": true, "Annots": true, "B": true, "StructParents": true}
+
+// droppedStreamKeys are stream entries describing the stored data, which writer
+// re-encodes.
+var droppedStreamKeys = map[string]bool{"Length": true, "Filter": true, "DecodeParms": true, "DL": true}
+
+// writer writes a PDF made of copied pages. Streams are written as numbered objects as
+// they are reached, deduplicated by content; everything else is written inline.
+type writer struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+	next    int
+	streams map[string]int
+	pages   []int
+
+	// unpad strips the padding the reader leaves on AES-decrypted strings and
+	// unfiltered streams.
+	unpad bool
+}
+
+func newWriter(unpad bool) *writer {
+	w := &writer{
+		offsets: make(map[int]int),
+		next:    pagesObject + 1,
+		streams: make(map[string]int),
+		unpad:   unpad,
+	}
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// writeObject writes body as a new numbered object and returns its number.
+func (w *writer) writeObject(body []byte) int {
+	num := w.next
+	w.next++
+	w.writeNumbered(num, body)
+	return num
+}
+
+// writeNumbered writes body as object num.
+func (w *writer) writeNumbered(num int, body []byte) {
+	w.offsets[num] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", num)
+	w.buf.Write(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// writePage copies a page with everything it displays.
+func (w *writer) writePage(page pdf.Value) error {
+	var body bytes.Buffer
+	body.WriteString("<< /Type /Page")
+	fmt.Fprintf(&body, " /Parent %d 0 R", pagesObject)
+	for _, key := range page.Keys() {
+		if key == "Type" || droppedPageKeys[key] || isInherited(key) {
+			continue
+		}
+		if err := w.writeEntry(&body, key, page.Key(key), 1); err != nil {
+			return err
+		}
+	}
+	for _, key := range inheritedPageKeys {
+		v := inheritedValue(page, key)
+		if v.IsNull() {
+			if key == "MediaBox" {
+				// US Letter, the default of most producers
+				body.WriteString(" /MediaBox [0 0 612 792]")
+			}
+			continue
+		}
+		if err := w.writeEntry(&body, key, v, 1); err != nil {
+			return err
+		}
+	}
+	body.WriteString(" >>")
+	w.pages = append(w.pages, w.writeObject(body.Bytes()))
+	return nil
+}
+
+// isInherited reports whether key is an inheritable page attribute.
+func isInherited(key string) bool {
+	for _, k := range inheritedPageKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedValue returns the value of key on page or its nearest page tree ancestor.
+func inheritedValue(page pdf.Value, key string) pdf.Value {
+	v := page
+	for depth := 0; depth <= maxTreeDepth && !v.IsNull(); depth++ {
+		if r := v.Key(key); !r.IsNull() {
+			return r
+		}
+		v = v.Key("Parent")
+	}
+	return pdf.Value{}
+}
+
+// writeEntry writes " /key value" to buf.
+func (w *writer) writeEntry(buf *bytes.Buffer, key string, v pdf.Value, depth int) error {
+	buf.WriteString(" ")
+	writeName(buf, key)
+	buf.WriteString(" ")
+	return w.writeValue(buf, v, depth)
+}
+
+// writeValue writes v to buf, writing any streams it contains as separate objects.
+func (w *writer) writeValue(buf *bytes.Buffer, v pdf.Value, depth int) error {
+	if depth > maxTreeDepth {
+		return fmt.Errorf("objects nested deeper than %d levels", maxTreeDepth)
+	}
+	switch v.Kind() {
+	case pdf.Null:
+		buf.WriteString("null")
+	case pdf.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case pdf.Integer:
+		buf.WriteString(strconv.FormatInt(v.Int64(), 10))
+	case pdf.Real:
+		buf.WriteString(strconv.FormatFloat(v.Float64(), 'f', -1, 64))
+	case pdf.String:
+		data := []byte(v.RawString())
+		if w.unpad {
+			data = unpadAES(data)
+		}
+		buf.WriteString("<")
+		buf.WriteString(hex.EncodeToString(data))
+		buf.WriteString(">")
+	case pdf.Name:
+		writeName(buf, v.Name())
+	case pdf.Array:
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			if err := w.writeValue(buf, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case pdf.Dict:
+		buf.WriteString("<<")
+		for _, key := range v.Keys() {
+			if key == "Parent" {
+				continue
+			}
+			if err := w.writeEntry(buf, key, v.Key(key), depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(" >>")
+	case pdf.Stream:
+		num, err := w.writeStream(v, depth)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%d 0 R", num)
+	}
+	return nil
+}
+
+// writeStream writes a stream as a Flate-compressed object and returns its number.
+// Images are copied like any other stream; those the reader cannot decode fail with
+// ErrUnsupportedImage.
+func (w *writer) writeStream(v pdf.Value, depth int) (int, error) {
+	data, err := readStream(v)
+	if err != nil {
+		if v.Key("Subtype").Name() == "Image" {
+			return 0, fmt.Errorf("%w: %v image: %v", ErrUnsupportedImage, v.Key("Filter"), err)
+		}
+		return 0, err
+	}
+	if w.unpad && v.Key("Filter").IsNull() {
+		// Decoding filtered data stops at its end, before the padding
+		data = unpadAES(data)
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	var body bytes.Buffer
+	body.WriteString("<<")
+	for _, key := range v.Keys() {
+		if droppedStreamKeys[key] || key == "Parent" {
+			continue
+		}
+		if err := w.writeEntry(&body, key, v.Key(key), depth+1); err != nil {
+			return 0, err
+		}
+	}
+	fmt.Fprintf(&body, " /Filter /FlateDecode /Length %d >>\nstream\n", compressed.Len())
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream")
+
+	sum := sha256.Sum256(body.Bytes())
+	key := string(sum[:])
+	if num, ok := w.streams[key]; ok {
+		return num, nil
+	}
+	num := w.writeObject(body.Bytes())
+	w.streams[key] = num
+	return num, nil
+}
+
+// readStream returns the decoded data of a stream.
+func readStream(v pdf.Value) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("decoding stream: %v", r)
+		}
+	}()
+	rc := v.Reader()
+	defer rc.Close()
+	data, err = io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("decoding stream: %w", err)
+	}
+	return data, nil
+}
+
+// unpadAES removes the PKCS #5 padding of AES-decrypted data, if it has valid padding.
+func unpadAES(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > 16 || n > len(data) {
+		return data
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return data
+		}
+	}
+	return data[:len(data)-n]
+}
+
+// writeName writes a PDF name, escaping the characters names cannot contain.
+func writeName(buf *bytes.Buffer, name string) {
+	buf.WriteString("/")
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x21 || c > 0x7e || bytes.IndexByte([]byte("()<>[]{}/%#"), c) >= 0 {
+			fmt.Fprintf(buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+// finish writes the catalog, page tree, cross-reference table and trailer, and returns
+// the PDF.
+func (w *writer) finish() []byte {
+	w.writeNumbered(catalogObject, []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject)))
+
+	var kids bytes.Buffer
+	for i, num := range w.pages {
+		if i > 0 {
+			kids.WriteString(" ")
+		}
+		fmt.Fprintf(&kids, "%d 0 R", num)
+	}
+	w.writeNumbered(pagesObject, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(w.pages))))
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", w.next)
+	for num := 1; num < w.next; num++ {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", w.offsets[num])
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", w.next, catalogObject, xref)
+	return w.buf.Bytes()
+}
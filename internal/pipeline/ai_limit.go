@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// MaxConcurrentAICallsEnv names the environment variable limiting how many model calls
+// the whole process makes at once, across all workers and statement chunks. 0 disables
+// the limit.
+const MaxConcurrentAICallsEnv = "MAX_CONCURRENT_AI_CALLS"
+
+// DefaultMaxConcurrentAICalls is used when MaxConcurrentAICallsEnv is unset.
+const DefaultMaxConcurrentAICalls = 4
+
+// aiCallLimiter bounds the number of concurrent model calls. A nil limiter is unlimited.
+type aiCallLimiter struct {
+	slots chan struct{}
+}
+
+// newAICallLimiter returns a limiter allowing max concurrent calls, or nil for max <= 0.
+func newAICallLimiter(max int) *aiCallLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &aiCallLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire waits for a free slot and returns the function releasing it.
+func (l *aiCallLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a model call slot: %w", ctx.Err())
+	}
+}
+
+var (
+	processAILimiterOnce sync.Once
+	processAILimiter     *aiCallLimiter
+	processAILimiterErr  error
+)
+
+// maxConcurrentAICallsFromEnv returns the configured limit, 0 when it is disabled.
+func maxConcurrentAICallsFromEnv() (int, error) {
+	v := os.Getenv(MaxConcurrentAICallsEnv)
+	if v == "" {
+		return DefaultMaxConcurrentAICalls, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", MaxConcurrentAICallsEnv, v)
+	}
+	return n, nil
+}
+
+// acquireAICall waits for a slot of the process-wide model call limit, which is read from
+// MaxConcurrentAICallsEnv on first use, and returns the function releasing it.
+func acquireAICall(ctx context.Context) (func(), error) {
+	processAILimiterOnce.Do(func() {
+		var max int
+		max, processAILimiterErr = maxConcurrentAICallsFromEnv()
+		processAILimiter = newAICallLimiter(max)
+	})
+	if processAILimiterErr != nil {
+		return nil, processAILimiterErr
+	}
+	return processAILimiter.acquire(ctx)
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAICallLimiter_BoundsConcurrency(t *testing.T) {
+	limiter := newAICallLimiter(3)
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			defer release()
+
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak > 3 {
+		t.Errorf("%d calls ran at once, want at most 3", peak)
+	}
+	if peak == 0 {
+		t.Error("no calls ran")
+	}
+}
+
+func TestAICallLimiter_AcquireHonoursContext(t *testing.T) {
+	limiter := newAICallLimiter(1)
+	release, err := limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx); err == nil {
+		t.Error("expected an error while the only slot is taken")
+	}
+}
+
+func TestAICallLimiter_Unlimited(t *testing.T) {
+	limiter := newAICallLimiter(0)
+	for i := 0; i < 100; i++ {
+		if _, err := limiter.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+	}
+}
+
+func TestMaxConcurrentAICallsFromEnv(t *testing.T) {
+	t.Setenv(MaxConcurrentAICallsEnv, "")
+	if n, err := maxConcurrentAICallsFromEnv(); err != nil || n != DefaultMaxConcurrentAICalls {
+		t.Errorf("unset: got %d, %v; want %d", n, err, DefaultMaxConcurrentAICalls)
+	}
+
+	t.Setenv(MaxConcurrentAICallsEnv, "0")
+	if n, err := maxConcurrentAICallsFromEnv(); err != nil || n != 0 {
+		t.Errorf("0: got %d, %v; want 0", n, err)
+	}
+
+	t.Setenv(MaxConcurrentAICallsEnv, "-1")
+	if _, err := maxConcurrentAICallsFromEnv(); err == nil {
+		t.Error("expected an error for -1")
+	}
+}
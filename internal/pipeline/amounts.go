@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// AmountDecimalSeparatorEnv names the environment variable setting the decimal separator
+// statements write amounts with: "." (the default, e.g. "£1,234.56") or "," (e.g.
+// "1.234,56 €"). It tells the model how to read amounts and is used to parse amounts the
+// model returns as text.
+const AmountDecimalSeparatorEnv = "AMOUNT_DECIMAL_SEPARATOR"
+
+// DefaultAmountDecimalSeparator is used when AmountDecimalSeparatorEnv is unset.
+const DefaultAmountDecimalSeparator = '.'
+
+// amountDecimalSeparatorFromEnv returns the configured decimal separator.
+func amountDecimalSeparatorFromEnv() (rune, error) {
+	switch v := strings.TrimSpace(os.Getenv(AmountDecimalSeparatorEnv)); v {
+	case "":
+		return DefaultAmountDecimalSeparator, nil
+	case ".", ",":
+		return rune(v[0]), nil
+	default:
+		return 0, fmt.Errorf("invalid %s %q: must be \".\" or \",\"", AmountDecimalSeparatorEnv, v)
+	}
+}
+
+// amountFormatInstruction tells the model how to write amounts read from a statement that
+// uses decimalSep.
+func amountFormatInstruction(decimalSep rune) string {
+	s := "Write every amount as a plain JSON number: no currency symbols, no thousands separators, " +
+		"\".\" as the decimal point and a leading \"-\" for negative amounts (e.g. -1234.56).\n"
+	if decimalSep == ',' {
+		s += "The statement writes amounts with a decimal comma: \"1.234,56 €\" is 1234.56.\n"
+	}
+	return s
+}
+
+// parseAmountString parses an amount the model returned as text, such as "£1,234.56",
+// "1.234,56 €", "-$12.00" or "(12.00)". Currency symbols, ISO codes and spaces are
+// ignored. When both "." and "," occur, the last one is the decimal separator; otherwise
+// decimalSep decides whether a single separator is decimal or grouping.
+func parseAmountString(s string, decimalSep rune) (*big.Rat, error) {
+	text := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		negative = true
+		text = text[1 : len(text)-1]
+	}
+
+	var b, code strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r), r == '.', r == ',':
+			b.WriteRune(r)
+		case r == '-' || r == '−':
+			negative = !negative
+		case unicode.IsUpper(r):
+			code.WriteRune(r)
+		case r == '+', unicode.IsSpace(r), unicode.Is(unicode.Sc, r), r == '\'':
+			// Currency symbols, signs and grouping spaces or apostrophes
+		default:
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	// Letters may only be an ISO currency code; markers like "DR" change the meaning
+	if code.Len() != 0 && code.Len() != 3 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	digits := b.String()
+
+	sep := decimalSep
+	if i, j := strings.LastIndex(digits, "."), strings.LastIndex(digits, ","); i >= 0 && j >= 0 {
+		sep = '.'
+		if j > i {
+			sep = ','
+		}
+	}
+	group := ","
+	if sep == ',' {
+		group = "."
+	}
+	digits = strings.ReplaceAll(digits, group, "")
+	if sep == ',' {
+		digits = strings.Replace(digits, ",", ".", 1)
+	}
+
+	r, ok := new(big.Rat).SetString(digits)
+	if !ok || digits == "" {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		r.Neg(r)
+	}
+	return r, nil
+}
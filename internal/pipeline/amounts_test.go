@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseAmountString(t *testing.T) {
+	tests := []struct {
+		in   string
+		sep  rune
+		want string
+	}{
+		{"£1,234.56", '.', "1234.56"},
+		{"1.234,56 €", '.', "1234.56"},
+		{"1.234,56 €", ',', "1234.56"},
+		{"-$12.00", '.', "-12"},
+		{"(12.00)", '.', "-12"},
+		{"−7.5", '.', "-7.5"},
+		{"1 234,5", ',', "1234.5"},
+		{"12,50", ',', "12.5"},
+		{"1,000", '.', "1000"},
+		{"CHF 1'234.00", '.', "1234"},
+		{"42", '.', "42"},
+	}
+	for _, tt := range tests {
+		got, err := parseAmountString(tt.in, tt.sep)
+		if err != nil {
+			t.Errorf("parseAmountString(%q, %q): %v", tt.in, tt.sep, err)
+			continue
+		}
+		want, _ := new(big.Rat).SetString(tt.want)
+		if got.Cmp(want) != 0 {
+			t.Errorf("parseAmountString(%q, %q) = %s, want %s", tt.in, tt.sep, got.FloatString(2), tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "£", "fifty", "12.00 DR", "1.2.3"} {
+		if _, err := parseAmountString(in, '.'); err == nil {
+			t.Errorf("parseAmountString(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestAmountDecimalSeparatorFromEnv(t *testing.T) {
+	t.Setenv(AmountDecimalSeparatorEnv, "")
+	if sep, err := amountDecimalSeparatorFromEnv(); err != nil || sep != '.' {
+		t.Errorf("default = %q, %v; want '.'", sep, err)
+	}
+
+	t.Setenv(AmountDecimalSeparatorEnv, ",")
+	if sep, err := amountDecimalSeparatorFromEnv(); err != nil || sep != ',' {
+		t.Errorf("\",\" = %q, %v; want ','", sep, err)
+	}
+	if !strings.Contains(buildTransactionSchema(), "decimal comma") {
+		t.Error("transaction schema does not mention the decimal comma")
+	}
+
+	t.Setenv(AmountDecimalSeparatorEnv, ";")
+	if _, err := amountDecimalSeparatorFromEnv(); err == nil {
+		t.Error("expected error for invalid separator")
+	}
+}
+
+func TestTransformModelOutputToTransactions_StringAmounts(t *testing.T) {
+	t.Setenv(AmountDecimalSeparatorEnv, ",")
+	out := map[string]interface{}{
+		"transactions": []interface{}{
+			map[string]interface{}{"date": "2024-03-01", "description": "Rent", "amount": "-1.234,56 €", "category": "Housing"},
+		},
+	}
+	txs, err := transformModelOutputToTransactions(context.Background(), out, DefaultCurrency)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := big.NewRat(-123456, 100); txs[0].Amount.Cmp(want) != 0 {
+		t.Errorf("amount = %s, want -1234.56", txs[0].Amount.FloatString(2))
+	}
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// readStatementBalances returns the opening and closing balances of the extracted account
+// header. A missing or malformed balance is nil.
+func readStatementBalances(accountInfo map[string]interface{}) (opening, closing *big.Rat) {
+	opening, _ = getOptionalRatField(accountInfo, "opening_balance")
+	closing, _ = getOptionalRatField(accountInfo, "closing_balance")
+	return opening, closing
+}
+
+// checkBalanceContinuity returns a review reason if the opening balance plus the
+// transaction amounts does not equal the closing balance, which means transactions were
+// missed, duplicated or misread. It needs both balances.
+func checkBalanceContinuity(opening, closing *big.Rat, txs []*Transaction) []string {
+	if opening == nil || closing == nil {
+		return nil
+	}
+
+	sum := new(big.Rat)
+	for _, t := range txs {
+		if t.Amount != nil {
+			sum.Add(sum, t.Amount)
+		}
+	}
+
+	expected := new(big.Rat).Add(opening, sum)
+	if expected.Cmp(closing) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("opening balance %s plus transactions %s is %s, but the closing balance is %s (off by %s)",
+		opening.FloatString(2), sum.FloatString(2), expected.FloatString(2), closing.FloatString(2),
+		new(big.Rat).Sub(closing, expected).FloatString(2))}
+}
+
+// StoreStatementBalancesStep reads the opening and closing balances from the statement
+// header and stores them on the document. Failing to store them is logged but does not
+// fail the parse.
+type StoreStatementBalancesStep struct{}
+
+func (s *StoreStatementBalancesStep) Name() string {
+	return "StoreStatementBalances"
+}
+
+func (s *StoreStatementBalancesStep) Execute(ctx context.Context, state *PipelineState) error {
+	log := logger.FromContext(ctx)
+
+	state.OpeningBalance, state.ClosingBalance = readStatementBalances(state.ExtractedAccountInfo)
+	if state.OpeningBalance == nil && state.ClosingBalance == nil {
+		return nil
+	}
+
+	if err := state.DocumentRepo.UpdateDocumentBalances(ctx, state.DocumentID, state.OpeningBalance, state.ClosingBalance); err != nil {
+		log.Warn().Err(err).Str("document_id", state.DocumentID).Msg("Failed to store statement balances")
+	}
+	return nil
+}
+
+// CheckBalanceContinuityStep flags runs whose transactions do not reconcile the opening
+// balance with the closing balance. It never fails the pipeline; problems are recorded
+// for review.
+type CheckBalanceContinuityStep struct{}
+
+func (s *CheckBalanceContinuityStep) Name() string {
+	return "CheckBalanceContinuity"
+}
+
+func (s *CheckBalanceContinuityStep) Execute(ctx context.Context, state *PipelineState) error {
+	state.ReviewReasons = append(state.ReviewReasons,
+		checkBalanceContinuity(state.OpeningBalance, state.ClosingBalance, state.Transactions)...)
+	return nil
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestReadStatementBalances(t *testing.T) {
+	opening, closing := readStatementBalances(map[string]interface{}{
+		"opening_balance": 100.5,
+		"closing_balance": "£1,234.56",
+	})
+	if opening == nil || opening.Cmp(big.NewRat(201, 2)) != 0 {
+		t.Errorf("opening = %v, want 100.50", opening)
+	}
+	if closing == nil || closing.Cmp(big.NewRat(123456, 100)) != 0 {
+		t.Errorf("closing = %v, want 1234.56", closing)
+	}
+
+	opening, closing = readStatementBalances(map[string]interface{}{"opening_balance": "n/a"})
+	if opening != nil || closing != nil {
+		t.Errorf("got %v, %v; want nil balances", opening, closing)
+	}
+}
+
+func TestCheckBalanceContinuity(t *testing.T) {
+	txs := []*Transaction{
+		{Amount: big.NewRat(-25, 1)},
+		{Amount: big.NewRat(1005, 10)},
+		{},
+	}
+
+	tests := []struct {
+		name            string
+		opening         *big.Rat
+		closing         *big.Rat
+		wantReasonMatch string
+	}{
+		{name: "reconciles", opening: big.NewRat(100, 1), closing: big.NewRat(351, 2)},
+		{name: "missing opening", closing: big.NewRat(1, 1)},
+		{name: "missing closing", opening: big.NewRat(1, 1)},
+		{name: "mismatch", opening: big.NewRat(100, 1), closing: big.NewRat(200, 1), wantReasonMatch: "off by 24.50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := checkBalanceContinuity(tt.opening, tt.closing, txs)
+			if tt.wantReasonMatch == "" {
+				if len(reasons) != 0 {
+					t.Errorf("reasons = %v, want none", reasons)
+				}
+				return
+			}
+			if len(reasons) != 1 || !strings.Contains(reasons[0], tt.wantReasonMatch) {
+				t.Errorf("reasons = %v, want one containing %q", reasons, tt.wantReasonMatch)
+			}
+		})
+	}
+}
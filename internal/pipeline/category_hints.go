@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// CategoryHintsEnv names the environment variable pointing at a JSON file of merchant
+// category hints that replaces DefaultCategoryHints. The file is read on every parse.
+const CategoryHintsEnv = "CATEGORY_HINTS_FILE"
+
+// CategoryHint tells the model which category to use for transactions from certain merchants.
+type CategoryHint struct {
+	// Merchants are merchant names or kinds of merchant, e.g. "Uber" or "taxi".
+	Merchants []string `json:"merchants"`
+
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory,omitempty"`
+}
+
+// DefaultCategoryHints are the hints used when CategoryHintsEnv is unset.
+var DefaultCategoryHints = []CategoryHint{
+	{
+		Merchants:   []string{"Uber", "Lyft", "Bolt", "taxi"},
+		Category:    "Transportation",
+		Subcategory: "Public Transit",
+	},
+}
+
+// loadCategoryHints returns the hints from the file named by CategoryHintsEnv, or
+// DefaultCategoryHints when it is unset.
+func loadCategoryHints() ([]CategoryHint, error) {
+	path := os.Getenv(CategoryHintsEnv)
+	if path == "" {
+		return DefaultCategoryHints, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadCategoryHints: reading %s: %w", path, err)
+	}
+
+	var hints []CategoryHint
+	if err := json.Unmarshal(b, &hints); err != nil {
+		return nil, fmt.Errorf("loadCategoryHints: parsing %s: %w", path, err)
+	}
+	for i, h := range hints {
+		if len(h.Merchants) == 0 || strings.TrimSpace(h.Category) == "" {
+			return nil, fmt.Errorf("loadCategoryHints: %s: hint %d needs merchants and a category", path, i+1)
+		}
+	}
+	return hints, nil
+}
+
+// formatCategoryHints renders hints for the categories prompt. Hints naming a category or
+// subcategory that is not in the taxonomy are left out with a warning, so the prompt never
+// contradicts the allowed categories.
+func formatCategoryHints(ctx context.Context, hints []CategoryHint, rows []bigquery.CategoryRow) string {
+	log := logger.FromContext(ctx)
+
+	var b strings.Builder
+	for _, h := range hints {
+		if !taxonomyHas(rows, h.Category, h.Subcategory) {
+			log.Warn().
+				Strs("merchants", h.Merchants).
+				Str("category", h.Category).
+				Str("subcategory", h.Subcategory).
+				Msg("Category hint does not match an active category; ignoring it")
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: category %q, subcategory %q.\n", strings.Join(h.Merchants, ", "), h.Category, h.Subcategory)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "MERCHANT CATEGORY HINTS:\n" + b.String()
+}
+
+// taxonomyHas reports whether category/subcategory is an active taxonomy entry.
+// An empty subcategory matches a category row without subcategory.
+func taxonomyHas(rows []bigquery.CategoryRow, category, subcategory string) bool {
+	for _, row := range rows {
+		if row.CategoryName != category {
+			continue
+		}
+		sub := ""
+		if row.SubcategoryName.Valid {
+			sub = row.SubcategoryName.StringVal
+		}
+		if sub == subcategory {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+)
+
+func hintTestCategories() []bigquery.CategoryRow {
+	return []bigquery.CategoryRow{
+		{CategoryID: "t1", CategoryName: "Transportation", SubcategoryName: bigquerylib.NullString{StringVal: "Public Transit", Valid: true}},
+		{CategoryID: "t2", CategoryName: "Transportation", SubcategoryName: bigquerylib.NullString{StringVal: "Ride-Hailing", Valid: true}},
+		{CategoryID: "u1", CategoryName: "Uncategorized"},
+	}
+}
+
+func TestBuildCategoriesPrompt_DefaultHints(t *testing.T) {
+	t.Setenv(CategoryHintsEnv, "")
+
+	prompt, err := buildCategoriesPromptWithRepo(context.Background(), &mockCategoryRepository{categories: hintTestCategories()}, DefaultUserID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "- Uber, Lyft, Bolt, taxi: category \"Transportation\", subcategory \"Public Transit\".\n"
+	if !strings.Contains(prompt, "MERCHANT CATEGORY HINTS:\n"+want) {
+		t.Errorf("prompt is missing the default hint:\n%s", prompt)
+	}
+}
+
+func TestBuildCategoriesPrompt_ConfiguredHints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hints.json")
+	config := `[
+		{"merchants": ["Uber", "Bolt"], "category": "Transportation", "subcategory": "Ride-Hailing"},
+		{"merchants": ["Deliveroo"], "category": "Food & Dining", "subcategory": "Takeaway"}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(CategoryHintsEnv, path)
+
+	prompt, err := buildCategoriesPromptWithRepo(context.Background(), &mockCategoryRepository{categories: hintTestCategories()}, DefaultUserID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(prompt, "- Uber, Bolt: category \"Transportation\", subcategory \"Ride-Hailing\".\n") {
+		t.Errorf("prompt is missing the configured hint:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "Lyft") {
+		t.Errorf("prompt still contains the default hint:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "Deliveroo") {
+		t.Errorf("prompt contains a hint for a category outside the taxonomy:\n%s", prompt)
+	}
+}
+
+func TestLoadCategoryHints_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"malformed.json":   `{"merchants": `,
+		"no-category.json": `[{"merchants": ["Uber"]}]`,
+		"no-merchant.json": `[{"category": "Transportation"}]`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(CategoryHintsEnv, path)
+
+		if _, err := loadCategoryHints(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
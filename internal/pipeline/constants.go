@@ -9,6 +9,10 @@ const (
 	// DefaultSourceSystem is the default source system for documents.
 	DefaultSourceSystem = "BARCLAYS"
 
+	// DefaultCurrency is used for transactions when neither the line nor the account
+	// states one, unless DefaultCurrencyEnv overrides it.
+	DefaultCurrency = "GBP"
+
 	// DefaultDocumentType is the default document type for uploaded files.
 	DefaultDocumentType = "BANK_STATEMENT"
 
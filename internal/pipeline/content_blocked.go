@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// ErrContentBlocked is returned when the model refused to answer because the prompt or
+// its response was blocked, e.g. by the safety filters. The error message carries the
+// reason the model gave.
+var ErrContentBlocked = errors.New("model response blocked")
+
+// blockedFinishReasons are the finish reasons of a candidate whose text was withheld.
+var blockedFinishReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:                 true,
+	genai.FinishReasonRecitation:             true,
+	genai.FinishReasonBlocklist:              true,
+	genai.FinishReasonProhibitedContent:      true,
+	genai.FinishReasonSPII:                   true,
+	genai.FinishReasonImageSafety:            true,
+	genai.FinishReasonImageProhibitedContent: true,
+}
+
+// responseText returns the text of a model response. A response whose prompt was
+// blocked, or whose candidate stopped for a safety reason without text, returns
+// ErrContentBlocked instead of an empty text, which would be retried and reported as
+// an empty response.
+func responseText(resp *genai.GenerateContentResponse) (string, error) {
+	if resp == nil {
+		return "", nil
+	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" && fb.BlockReason != genai.BlockedReasonUnspecified {
+		return "", fmt.Errorf("%w: prompt blocked (%s)%s", ErrContentBlocked, fb.BlockReason,
+			blockDetails(fb.BlockReasonMessage, fb.SafetyRatings))
+	}
+
+	text := resp.Text()
+	if text != "" || len(resp.Candidates) == 0 {
+		return text, nil
+	}
+	c := resp.Candidates[0]
+	if blockedFinishReasons[c.FinishReason] {
+		return "", fmt.Errorf("%w: response stopped (%s)%s", ErrContentBlocked, c.FinishReason,
+			blockDetails(c.FinishMessage, c.SafetyRatings))
+	}
+	return text, nil
+}
+
+// blockDetails formats the message and the blocking safety categories of a blocked
+// response, e.g. ": HARM_CATEGORY_DANGEROUS_CONTENT".
+func blockDetails(message string, ratings []*genai.SafetyRating) string {
+	var details []string
+	if message != "" {
+		details = append(details, message)
+	}
+	for _, r := range ratings {
+		if r != nil && r.Blocked {
+			details = append(details, string(r.Category))
+		}
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(details, ", ")
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestResponseText(t *testing.T) {
+	tests := []struct {
+		name        string
+		resp        *genai.GenerateContentResponse
+		wantText    string
+		wantBlocked string // substring of the ErrContentBlocked message, "" if not blocked
+	}{
+		{
+			name: "text",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+				Content:      genai.NewContentFromText("[]", genai.RoleModel),
+				FinishReason: genai.FinishReasonStop,
+			}}},
+			wantText: "[]",
+		},
+		{
+			name: "empty without block",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonStop}}},
+		},
+		{
+			name: "prompt blocked",
+			resp: &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{
+				BlockReason: genai.BlockedReasonProhibitedContent,
+			}},
+			wantBlocked: "prompt blocked (PROHIBITED_CONTENT)",
+		},
+		{
+			name: "response stopped for safety",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+				FinishReason: genai.FinishReasonSafety,
+				SafetyRatings: []*genai.SafetyRating{
+					{Category: genai.HarmCategoryHarassment},
+					{Category: genai.HarmCategoryDangerousContent, Blocked: true},
+				},
+			}}},
+			wantBlocked: "response stopped (SAFETY): HARM_CATEGORY_DANGEROUS_CONTENT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := responseText(tt.resp)
+			if tt.wantBlocked == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if text != tt.wantText {
+					t.Errorf("text = %q, want %q", text, tt.wantText)
+				}
+				return
+			}
+			if !errors.Is(err, ErrContentBlocked) || !strings.Contains(err.Error(), tt.wantBlocked) {
+				t.Errorf("err = %v, want ErrContentBlocked with %q", err, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestGenerateNonEmptyDoesNotRetryBlockedResponse(t *testing.T) {
+	blocked := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonSafety}}}
+
+	calls := 0
+	_, err := generateNonEmpty(context.Background(), "test", 3, func(ctx context.Context) (string, error) {
+		calls++
+		return responseText(blocked)
+	})
+	if !errors.Is(err, ErrContentBlocked) {
+		t.Errorf("err = %v, want ErrContentBlocked", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
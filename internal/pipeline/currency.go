@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultCurrencyEnv names the environment variable overriding DefaultCurrency, the
+// currency of transactions whose line and account state none, e.g. "EUR".
+const DefaultCurrencyEnv = "DEFAULT_CURRENCY"
+
+// defaultCurrencyFromEnv returns the configured default currency as an upper-case
+// 3-letter ISO code.
+func defaultCurrencyFromEnv() (string, error) {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(DefaultCurrencyEnv)))
+	if v == "" {
+		return DefaultCurrency, nil
+	}
+	if len(v) != 3 || strings.Trim(v, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid %s %q: must be a 3-letter ISO currency code", DefaultCurrencyEnv, v)
+	}
+	return v, nil
+}
@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DocumentIDModeEnv names the environment variable choosing how new documents get
+// their ID: DocumentIDRandom (the default) or DocumentIDChecksum.
+const DocumentIDModeEnv = "DOCUMENT_ID_MODE"
+
+// Document ID modes.
+const (
+	// DocumentIDRandom gives every new document a random UUID.
+	DocumentIDRandom = "random"
+	// DocumentIDChecksum derives the ID from the owner and the file's SHA-256
+	// checksum with DocumentIDFromChecksum, so identical content from one user always
+	// maps to the same document, from upload onwards.
+	DocumentIDChecksum = "checksum"
+)
+
+// documentIDNamespace is the UUID namespace of checksum-derived document IDs.
+var documentIDNamespace = uuid.MustParse("6f1d7c2e-3b8a-4e51-9c0d-2a4b6e8f1c37")
+
+// DocumentIDFromChecksum returns the document ID for userID's content with the given
+// hex SHA-256 checksum: a name-based (version 5) UUID of the user and checksum, so
+// different users uploading the same file get different documents.
+func DocumentIDFromChecksum(userID, checksum string) string {
+	return uuid.NewSHA1(documentIDNamespace, []byte(userID+"/"+strings.ToLower(checksum))).String()
+}
+
+// DocumentIDModeFromEnv returns the configured document ID mode.
+func DocumentIDModeFromEnv() (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(DocumentIDModeEnv))); v {
+	case "":
+		return DocumentIDRandom, nil
+	case DocumentIDRandom, DocumentIDChecksum:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %s or %s",
+			DocumentIDModeEnv, v, DocumentIDRandom, DocumentIDChecksum)
+	}
+}
+
+// newDocumentID returns the ID for userID's new document with the given checksum,
+// which may be empty if it is not known.
+func newDocumentID(userID, checksum string) (string, error) {
+	mode, err := DocumentIDModeFromEnv()
+	if err != nil {
+		return "", err
+	}
+	if mode == DocumentIDChecksum && checksum != "" {
+		return DocumentIDFromChecksum(userID, checksum), nil
+	}
+	return uuid.NewString(), nil
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDocumentIDFromChecksum(t *testing.T) {
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	id := DocumentIDFromChecksum(DefaultUserID, checksum)
+	if parsed, err := uuid.Parse(id); err != nil || parsed.Version() != 5 {
+		t.Fatalf("DocumentIDFromChecksum = %q, want a version 5 UUID", id)
+	}
+	if again := DocumentIDFromChecksum(DefaultUserID, strings.ToUpper(checksum)); again != id {
+		t.Errorf("ID of the upper-case checksum = %q, want %q", again, id)
+	}
+	if other := DocumentIDFromChecksum(DefaultUserID, strings.Repeat("0", 64)); other == id {
+		t.Errorf("different checksums both map to %q", id)
+	}
+	if other := DocumentIDFromChecksum("other-user", checksum); other == id {
+		t.Errorf("different users both map to %q", id)
+	}
+}
+
+func TestNewDocumentID(t *testing.T) {
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	t.Setenv(DocumentIDModeEnv, "")
+	if a, _ := newDocumentID(DefaultUserID, checksum); a == DocumentIDFromChecksum(DefaultUserID, checksum) {
+		t.Errorf("random mode returned the checksum ID %q", a)
+	}
+
+	t.Setenv(DocumentIDModeEnv, "Checksum")
+	if got, err := newDocumentID(DefaultUserID, checksum); err != nil || got != DocumentIDFromChecksum(DefaultUserID, checksum) {
+		t.Errorf("newDocumentID = %q, %v; want %q", got, err, DocumentIDFromChecksum(DefaultUserID, checksum))
+	}
+	if got, err := newDocumentID(DefaultUserID, ""); err != nil || got == "" {
+		t.Errorf("newDocumentID without checksum = %q, %v; want a random ID", got, err)
+	}
+
+	t.Setenv(DocumentIDModeEnv, "sequential")
+	if _, err := newDocumentID(DefaultUserID, checksum); err == nil {
+		t.Error("newDocumentID with an invalid mode succeeded")
+	}
+}
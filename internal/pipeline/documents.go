@@ -3,9 +3,9 @@ package pipeline
 import (
 	"context"
 	"fmt"
-	"time"
 
 	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
 	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
@@ -20,7 +20,10 @@ func createDocument(ctx context.Context, gcsURI string) (string, error) {
 	}
 	defer repo.Close()
 
-	storage := gcsuploader.NewGCSStorageService()
+	storage, err := gcsuploader.NewStorageServiceFromEnv()
+	if err != nil {
+		return "", fmt.Errorf("createDocument: %w", err)
+	}
 	return createDocumentWithRepo(ctx, gcsURI, repo, storage)
 }
 
@@ -48,8 +51,8 @@ func createDocumentWithRepo(ctx context.Context, gcsURI string, repo bigquery.Do
 		SourceSystem:     DefaultSourceSystem,
 		InstitutionID:    "", // Can be filled later
 		AccountID:        "", // Can be filled later
-		ParsingStatus:    "PENDING",
-		UploadTS:         time.Now(),
+		ParsingStatus:    bigquery.DocumentStatusPending,
+		UploadTS:         apptime.Now(),
 		OriginalFilename: filename,
 		FileMimeType:     "",                                 // Fill later if you detect MIME
 		Metadata:         bigquerylib.NullJSON{Valid: false}, // NULL for now
@@ -62,10 +65,17 @@ func createDocumentWithRepo(ctx context.Context, gcsURI string, repo bigquery.Do
 	return documentID, nil
 }
 
-// createDocumentWithChecksumRepo inserts a row into the documents table with checksum.
-func createDocumentWithChecksumRepo(ctx context.Context, gcsURI string, checksum string, repo bigquery.DocumentRepository, storage StorageService) (string, error) {
-	// Generate a UUID for this document
-	documentID := uuid.NewString()
+// createDocumentWithChecksumRepo inserts a row for userID into the documents table with checksum.
+// An empty sourceSystem means DefaultSourceSystem.
+func createDocumentWithChecksumRepo(ctx context.Context, gcsURI, checksum, userID, sourceSystem, institutionID string, repo bigquery.DocumentRepository, storage StorageService) (string, error) {
+	if sourceSystem == "" {
+		sourceSystem = DefaultSourceSystem
+	}
+
+	documentID, err := newDocumentID(userID, checksum)
+	if err != nil {
+		return "", fmt.Errorf("createDocumentWithChecksum: %w", err)
+	}
 
 	// Extract filename from GCS URI
 	filename := storage.ExtractFilenameFromGCSURI(gcsURI)
@@ -73,14 +83,14 @@ func createDocumentWithChecksumRepo(ctx context.Context, gcsURI string, checksum
 	// Prepare row to insert with checksum
 	row := &bigquery.DocumentRow{
 		DocumentID:       documentID,
-		UserID:           DefaultUserID,
+		UserID:           userID,
 		GCSURI:           gcsURI,
 		DocumentType:     DefaultDocumentType,
-		SourceSystem:     DefaultSourceSystem,
-		InstitutionID:    "",
+		SourceSystem:     sourceSystem,
+		InstitutionID:    institutionID,
 		AccountID:        "",
-		ParsingStatus:    "PENDING",
-		UploadTS:         time.Now(),
+		ParsingStatus:    bigquery.DocumentStatusPending,
+		UploadTS:         apptime.Now(),
 		OriginalFilename: filename,
 		FileMimeType:     "",
 		ChecksumSHA256:   checksum, // Set the calculated checksum
@@ -94,6 +104,27 @@ func createDocumentWithChecksumRepo(ctx context.Context, gcsURI string, checksum
 	return documentID, nil
 }
 
+// loadDocument returns the document documentID, or a validation error if it does not exist.
+func loadDocument(ctx context.Context, repo bigquery.DocumentRepository, documentID string) (*bigquery.DocumentRow, error) {
+	doc, err := repo.GetDocument(ctx, documentID)
+	if err != nil {
+		return nil, classify(ErrStorage, fmt.Errorf("loading document: %w", err))
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("%w: document %s not found", ErrValidation, documentID)
+	}
+	return doc, nil
+}
+
+// documentUserID returns the owner of doc. Documents stored before user IDs were
+// recorded belong to DefaultUserID.
+func documentUserID(doc *bigquery.DocumentRow) string {
+	if doc.UserID == "" {
+		return DefaultUserID
+	}
+	return doc.UserID
+}
+
 // extractFilenameFromGCSURI extracts the filename from a GCS URI.
 // e.g., "gs://bucket/folder/file.pdf" → "file.pdf"
 // DEPRECATED: Use StorageService.ExtractFilenameFromGCSURI instead.
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// EmptyResponseRetriesEnv names the environment variable setting how many times a model
+// call that returned an empty response is repeated before failing.
+const EmptyResponseRetriesEnv = "MODEL_EMPTY_RESPONSE_RETRIES"
+
+// DefaultEmptyResponseRetries is used when EmptyResponseRetriesEnv is unset.
+const DefaultEmptyResponseRetries = 2
+
+// emptyResponseBackoff is the wait before the first retry; it doubles with every attempt.
+var emptyResponseBackoff = 2 * time.Second
+
+// emptyResponseRetriesFromEnv returns the configured number of empty-response retries.
+func emptyResponseRetriesFromEnv() (int, error) {
+	v := os.Getenv(EmptyResponseRetriesEnv)
+	if v == "" {
+		return DefaultEmptyResponseRetries, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", EmptyResponseRetriesEnv, v)
+	}
+	return n, nil
+}
+
+// generateNonEmpty calls generate until it returns a non-empty response, retrying up to
+// retries times with exponential backoff. Errors from generate are returned immediately;
+// only empty responses, which Gemini returns transiently, are retried.
+func generateNonEmpty(ctx context.Context, op string, retries int, generate func(ctx context.Context) (string, error)) (string, error) {
+	log := logger.FromContext(ctx)
+	backoff := emptyResponseBackoff
+
+	for attempt := 0; ; attempt++ {
+		text, err := generate(ctx)
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			return text, nil
+		}
+		if attempt >= retries {
+			return "", fmt.Errorf("empty response from model after %d attempts", attempt+1)
+		}
+
+		log.Warn().
+			Str("op", op).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("Empty response from model, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", fmt.Errorf("waiting to retry empty response: %w", ctx.Err())
+		}
+		backoff *= 2
+	}
+}
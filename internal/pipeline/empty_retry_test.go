@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenerateNonEmpty(t *testing.T) {
+	defer func(d time.Duration) { emptyResponseBackoff = d }(emptyResponseBackoff)
+	emptyResponseBackoff = time.Millisecond
+
+	t.Run("retries empty responses", func(t *testing.T) {
+		calls := 0
+		text, err := generateNonEmpty(context.Background(), "test", 2, func(ctx context.Context) (string, error) {
+			calls++
+			if calls < 3 {
+				return "", nil
+			}
+			return "[]", nil
+		})
+		if err != nil || text != "[]" {
+			t.Fatalf("got %q, %v; want [] after retries", text, err)
+		}
+		if calls != 3 {
+			t.Errorf("generate called %d times, want 3", calls)
+		}
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		calls := 0
+		_, err := generateNonEmpty(context.Background(), "test", 1, func(ctx context.Context) (string, error) {
+			calls++
+			return "", nil
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if calls != 2 {
+			t.Errorf("generate called %d times, want 2", calls)
+		}
+	})
+
+	t.Run("does not retry errors", func(t *testing.T) {
+		calls := 0
+		wantErr := errors.New("quota exceeded")
+		_, err := generateNonEmpty(context.Background(), "test", 3, func(ctx context.Context) (string, error) {
+			calls++
+			return "", wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("error = %v, want %v", err, wantErr)
+		}
+		if calls != 1 {
+			t.Errorf("generate called %d times, want 1", calls)
+		}
+	})
+}
+
+func TestEmptyResponseRetriesFromEnv(t *testing.T) {
+	t.Setenv(EmptyResponseRetriesEnv, "")
+	if n, err := emptyResponseRetriesFromEnv(); err != nil || n != DefaultEmptyResponseRetries {
+		t.Errorf("unset: got %d, %v", n, err)
+	}
+
+	t.Setenv(EmptyResponseRetriesEnv, "0")
+	if n, err := emptyResponseRetriesFromEnv(); err != nil || n != 0 {
+		t.Errorf("0: got %d, %v", n, err)
+	}
+
+	t.Setenv(EmptyResponseRetriesEnv, "-1")
+	if _, err := emptyResponseRetriesFromEnv(); err == nil {
+		t.Error("-1: expected an error")
+	}
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds returned by pipeline steps. Callers match them with errors.Is to tell
+// failure classes apart, e.g. to decide whether a failed job is worth retrying.
+var (
+	// ErrParse indicates the statement could not be parsed or the model output was malformed.
+	ErrParse = errors.New("parse error")
+
+	// ErrStorage indicates a failure reading from or writing to GCS or BigQuery.
+	ErrStorage = errors.New("storage error")
+
+	// ErrValidation indicates the parsed data was rejected, e.g. by category validation.
+	ErrValidation = errors.New("validation error")
+
+	// ErrDuplicate indicates the statement has already been ingested as another document.
+	ErrDuplicate = errors.New("duplicate document")
+)
+
+// StepError is returned by Pipeline.Execute and records which step failed.
+// Use errors.As to retrieve it and errors.Is to check the error kind.
+type StepError struct {
+	// Index is the 1-based position of the failed step in the pipeline.
+	Index int
+
+	// Step is the name of the failed step.
+	Step string
+
+	// Err is the error returned by the step.
+	Err error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("pipeline step %d (%s) failed: %v", e.Index, e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns a short label for the kind of a pipeline error:
+// "parse", "storage", "validation", "duplicate" or "unknown".
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		return "duplicate"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, ErrParse):
+		return "parse"
+	case errors.Is(err, ErrStorage):
+		return "storage"
+	default:
+		return "unknown"
+	}
+}
+
+// IsRetryable reports whether running the pipeline again could succeed.
+// Validation and duplicate failures are deterministic and will fail the same way.
+func IsRetryable(err error) bool {
+	return !errors.Is(err, ErrValidation) && !errors.Is(err, ErrDuplicate)
+}
+
+// classify tags err with kind unless it already carries one.
+func classify(kind, err error) error {
+	if err == nil || ErrorKind(err) != "unknown" {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
@@ -0,0 +1,127 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func TestPipelineErrorKinds(t *testing.T) {
+	okStorage := &MockStorageService{
+		FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+			return []byte("mock pdf data"), nil
+		},
+	}
+	okAccounts := &MockAccountRepository{
+		UpsertAccountFunc: func(ctx context.Context, row *bigquery.AccountRow) (string, error) {
+			return "test-account-id", nil
+		},
+	}
+	okParser := &MockAIParser{
+		ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+			return map[string]interface{}{"transactions": []interface{}{}}, nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		repo      *MockDocumentRepository
+		storage   *MockStorageService
+		parser    *MockAIParser
+		wantKind  error
+		wantStep  string
+		retryable bool
+	}{
+		{
+			name: "fetch failure is a storage error",
+			repo: &MockDocumentRepository{},
+			storage: &MockStorageService{
+				FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+					return nil, errors.New("object not found")
+				},
+			},
+			parser:    okParser,
+			wantKind:  pipeline.ErrStorage,
+			wantStep:  "FetchPDF",
+			retryable: true,
+		},
+		{
+			name:    "model failure is a parse error",
+			repo:    &MockDocumentRepository{},
+			storage: okStorage,
+			parser: &MockAIParser{
+				ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+					return nil, errors.New("model returned invalid JSON")
+				},
+			},
+			wantKind:  pipeline.ErrParse,
+			wantStep:  "ParseStatement",
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pipeline.IngestStatementFromGCSWithDeps(
+				context.Background(),
+				"gs://test-bucket/test.pdf",
+				"",
+				&mockDocumentRepo{MockDocumentRepository: tt.repo},
+				okAccounts,
+				tt.storage,
+				tt.parser,
+			)
+
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("error = %v, want kind %v", err, tt.wantKind)
+			}
+
+			var stepErr *pipeline.StepError
+			if !errors.As(err, &stepErr) {
+				t.Fatalf("error %v is not a *StepError", err)
+			}
+			if stepErr.Step != tt.wantStep {
+				t.Errorf("failed step = %q, want %q", stepErr.Step, tt.wantStep)
+			}
+
+			if got := pipeline.IsRetryable(err); got != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestPipelineReparseOfSameDocumentIsNotDuplicate(t *testing.T) {
+	repo := &MockDocumentRepository{
+		FindDocumentByChecksumFunc: func(ctx context.Context, checksum string) (*bigquery.DocumentRow, error) {
+			return &bigquery.DocumentRow{DocumentID: "abcdef12-3456-7890-abcd-ef1234567890"}, nil
+		},
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			return []bigquery.CategoryRow{}, nil
+		},
+	}
+	storage := &MockStorageService{
+		FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+			return []byte("mock pdf data"), nil
+		},
+	}
+	accounts := &MockAccountRepository{
+		UpsertAccountFunc: func(ctx context.Context, row *bigquery.AccountRow) (string, error) {
+			return "test-account-id", nil
+		},
+	}
+	parser := &MockAIParser{
+		ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+			return map[string]interface{}{"transactions": []interface{}{}}, nil
+		},
+	}
+
+	err := pipeline.IngestStatementFromGCSWithDeps(context.Background(), "gs://test-bucket/test.pdf", "abcdef12-3456-7890-abcd-ef1234567890",
+		&mockDocumentRepo{MockDocumentRepository: repo}, accounts, storage, parser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -2,21 +2,42 @@ package pipeline_test
 
 import (
 	"context"
+	"math/big"
 	"testing"
+	"time"
 
+	bigquerylib "cloud.google.com/go/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/pipeline"
 )
 
 // MockDocumentRepository is a mock implementation of DocumentRepository for testing.
 type MockDocumentRepository struct {
-	InsertDocumentFunc          func(ctx context.Context, row interface{}) error
-	InsertTransactionsFunc      func(ctx context.Context, rows interface{}) error
-	InsertModelOutputFunc       func(ctx context.Context, row interface{}) error
-	StartParsingRunFunc         func(ctx context.Context, documentID string) (string, error)
-	MarkParsingRunFailedFunc    func(ctx context.Context, parsingRunID string, parseErr error)
-	MarkParsingRunSucceededFunc func(ctx context.Context, parsingRunID string) error
-	ListActiveCategoriesFunc    func(ctx context.Context) (interface{}, error)
+	InsertDocumentFunc               func(ctx context.Context, row interface{}) error
+	InsertTransactionsFunc           func(ctx context.Context, rows interface{}) error
+	InsertModelOutputFunc            func(ctx context.Context, row interface{}) error
+	StartParsingRunFunc              func(ctx context.Context, documentID string) (string, error)
+	MarkParsingRunFailedFunc         func(ctx context.Context, parsingRunID string, parseErr error)
+	MarkParsingRunSucceededFunc      func(ctx context.Context, parsingRunID string) error
+	ListActiveCategoriesFunc         func(ctx context.Context, userID string) (interface{}, error)
+	ListModelOutputsByParsingRunFunc func(ctx context.Context, parsingRunID string) ([]*bigquery.ModelOutputRow, error)
+	FindParsingRunAccountIDFunc      func(ctx context.Context, parsingRunID string) (string, error)
+	FindParsingRunFunc               func(ctx context.Context, parsingRunID string) (*bigquery.ParsingRunRow, error)
+	QueryTransactionsFunc            func(ctx context.Context, q bigquery.TransactionQuery) ([]*bigquery.TransactionRow, error)
+	FindDocumentByChecksumFunc       func(ctx context.Context, checksum string) (*bigquery.DocumentRow, error)
+	GetDocumentFunc                  func(ctx context.Context, documentID string) (*bigquery.DocumentRow, error)
+	ListAllAccountsFunc              func(ctx context.Context) ([]*bigquery.AccountRow, error)
+	FlagParsingRunForReviewFunc      func(ctx context.Context, parsingRunID string, reasons []string) error
+	UpdateDocumentInstitutionFunc    func(ctx context.Context, documentID, institutionID string) error
+	TransitionDocumentStatusFunc     func(ctx context.Context, documentID string, to bigquery.DocumentStatus) error
+	UpdateDocumentMetadataFunc       func(ctx context.Context, documentID string, metadata bigquerylib.NullJSON) error
+	UpdateDocumentBalancesFunc       func(ctx context.Context, documentID string, opening, closing *big.Rat) error
+
+	ListDocumentCategoryAssignmentsFunc func(ctx context.Context, documentID string) ([]bigquery.CategoryAssignment, error)
+	UpdateDocumentCategoryIDsFunc       func(ctx context.Context, documentID string, assignments []bigquery.CategoryAssignment) (int64, error)
+
+	ListTagRulesFunc          func(ctx context.Context, userID string) ([]bigquery.TagRuleRow, error)
+	UpdateTransactionTagsFunc func(ctx context.Context, updates []bigquery.TransactionTags) (int64, error)
 }
 
 // MockStorageService is a mock implementation of StorageService for testing.
@@ -52,6 +73,9 @@ type MockAccountRepository struct {
 	UpsertAccountFunc                  func(ctx context.Context, row *bigquery.AccountRow) (string, error)
 	FindAccountByNumberAndCurrencyFunc func(ctx context.Context, accountNumber, currency string) (*bigquery.AccountRow, error)
 	ListAllAccountsFunc                func(ctx context.Context) ([]*bigquery.AccountRow, error)
+	GetAccountBalanceFunc              func(ctx context.Context, accountID string, asOf time.Time) (*bigquery.AccountBalance, error)
+	MergeAccountFunc                   func(ctx context.Context, fromAccountID, toAccountID string) error
+	GetAccountCoverageFunc             func(ctx context.Context, accountID string) (*bigquery.AccountCoverage, error)
 }
 
 func (m *MockAccountRepository) UpsertAccount(ctx context.Context, row *bigquery.AccountRow) (string, error) {
@@ -75,15 +99,36 @@ func (m *MockAccountRepository) ListAllAccounts(ctx context.Context) ([]*bigquer
 	return []*bigquery.AccountRow{}, nil
 }
 
+func (m *MockAccountRepository) GetAccountBalance(ctx context.Context, accountID string, asOf time.Time) (*bigquery.AccountBalance, error) {
+	if m.GetAccountBalanceFunc != nil {
+		return m.GetAccountBalanceFunc(ctx, accountID, asOf)
+	}
+	return nil, nil
+}
+
+func (m *MockAccountRepository) MergeAccount(ctx context.Context, fromAccountID, toAccountID string) error {
+	if m.MergeAccountFunc != nil {
+		return m.MergeAccountFunc(ctx, fromAccountID, toAccountID)
+	}
+	return nil
+}
+
+func (m *MockAccountRepository) GetAccountCoverage(ctx context.Context, accountID string) (*bigquery.AccountCoverage, error) {
+	if m.GetAccountCoverageFunc != nil {
+		return m.GetAccountCoverageFunc(ctx, accountID)
+	}
+	return nil, nil
+}
+
 // MockAIParser is a mock implementation of AIParser for testing.
 type MockAIParser struct {
-	ParseStatementFunc       func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error)
+	ParseStatementFunc       func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error)
 	ExtractAccountHeaderFunc func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error)
 }
 
-func (m *MockAIParser) ParseStatement(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+func (m *MockAIParser) ParseStatement(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
 	if m.ParseStatementFunc != nil {
-		return m.ParseStatementFunc(ctx, pdfBytes)
+		return m.ParseStatementFunc(ctx, pdfBytes, userID, institutionID)
 	}
 	return map[string]interface{}{
 		"transactions": []interface{}{},
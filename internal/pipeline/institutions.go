@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"strings"
+	"unicode"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+// Institution describes a bank whose statements the pipeline can recognise.
+type Institution struct {
+	// ID is the canonical identifier stored in institution_id, e.g. "BARCLAYS".
+	ID string
+
+	// Name is the display name used in parsing prompts.
+	Name string
+
+	// Aliases are lower-case, alphanumeric-only fragments of names the bank appears under.
+	Aliases []string
+
+	// IBANBankCodes are the 4-letter bank codes used in the bank's GB IBANs.
+	IBANBankCodes []string
+
+	// SignConvention is how the bank's statements show money in and out, one of the
+	// SignConvention constants. Empty means SignConventionSigned.
+	SignConvention string
+}
+
+// KnownInstitutions lists the institutions detectInstitution can recognise. Entries whose
+// aliases contain another entry's alias (First Direct is part of HSBC) come first.
+var KnownInstitutions = []Institution{
+	{ID: "FIRST_DIRECT", Name: "First Direct", Aliases: []string{"firstdirect"}},
+	{ID: "BARCLAYS", Name: "Barclays", Aliases: []string{"barclays", "barclaycard"}, IBANBankCodes: []string{"BARC", "BUKB"}},
+	{ID: "HSBC", Name: "HSBC", Aliases: []string{"hsbc", "midlandbank"}, IBANBankCodes: []string{"HBUK", "MIDL"}},
+	{ID: "LLOYDS", Name: "Lloyds", Aliases: []string{"lloyds"}, IBANBankCodes: []string{"LOYD"}},
+	{ID: "HALIFAX", Name: "Halifax", Aliases: []string{"halifax"}, IBANBankCodes: []string{"HLFX"}},
+	{ID: "NATWEST", Name: "NatWest", Aliases: []string{"natwest", "nationalwestminster"}, IBANBankCodes: []string{"NWBK"}},
+	{ID: "RBS", Name: "Royal Bank of Scotland", Aliases: []string{"royalbankofscotland", "rbs"}, IBANBankCodes: []string{"RBOS"}},
+	{ID: "SANTANDER", Name: "Santander", Aliases: []string{"santander"}, IBANBankCodes: []string{"ABBY"}},
+	{ID: "NATIONWIDE", Name: "Nationwide", Aliases: []string{"nationwide"}, IBANBankCodes: []string{"NAIA"}},
+	{ID: "TSB", Name: "TSB", Aliases: []string{"tsb"}, IBANBankCodes: []string{"TSBS"}},
+	{ID: "MONZO", Name: "Monzo", Aliases: []string{"monzo"}, IBANBankCodes: []string{"MONZ"}},
+	{ID: "STARLING", Name: "Starling", Aliases: []string{"starling"}, IBANBankCodes: []string{"SRLG"}},
+	{ID: "REVOLUT", Name: "Revolut", Aliases: []string{"revolut"}, IBANBankCodes: []string{"REVO"}},
+}
+
+// LookupInstitution returns the known institution with the given ID (case-insensitive).
+func LookupInstitution(id string) (Institution, bool) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	for _, inst := range KnownInstitutions {
+		if inst.ID == id {
+			return inst, true
+		}
+	}
+	return Institution{}, false
+}
+
+// institutionPromptName returns the display name used in prompts for institutionID,
+// falling back to the default institution when it is empty or unknown.
+func institutionPromptName(institutionID string) string {
+	if inst, ok := LookupInstitution(institutionID); ok {
+		return inst.Name
+	}
+	inst, _ := LookupInstitution(DefaultSourceSystem)
+	return inst.Name
+}
+
+// detectInstitution identifies the issuing bank from the extracted account header.
+// The bank name reported by the model is tried first because it tells apart banks
+// sharing an IBAN bank code; the IBAN is used when the name is missing or unknown.
+func detectInstitution(accountInfo map[string]interface{}) (Institution, bool) {
+	if name, ok := accountInfo["institution_id"].(string); ok {
+		if inst, ok := matchInstitutionName(name); ok {
+			return inst, true
+		}
+	}
+	if iban, ok := accountInfo["iban"].(string); ok {
+		if inst, ok := matchIBANBankCode(iban); ok {
+			return inst, true
+		}
+	}
+	return Institution{}, false
+}
+
+// matchInstitutionName matches a free-form bank name such as "Barclays Bank UK PLC"
+// against the aliases of the known institutions.
+func matchInstitutionName(name string) (Institution, bool) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	key := b.String()
+	if key == "" {
+		return Institution{}, false
+	}
+
+	for _, inst := range KnownInstitutions {
+		if strings.ToLower(strings.ReplaceAll(inst.ID, "_", "")) == key {
+			return inst, true
+		}
+		for _, alias := range inst.Aliases {
+			if strings.Contains(key, alias) {
+				return inst, true
+			}
+		}
+	}
+	return Institution{}, false
+}
+
+// matchIBANBankCode matches the bank code of a GB IBAN against the known institutions.
+func matchIBANBankCode(iban string) (Institution, bool) {
+	normalized, ok := infraBQ.NormalizeIBAN(iban)
+	if !ok || !strings.HasPrefix(normalized, "GB") || len(normalized) < 8 {
+		return Institution{}, false
+	}
+	code := normalized[4:8]
+
+	for _, inst := range KnownInstitutions {
+		for _, c := range inst.IBANBankCodes {
+			if c == code {
+				return inst, true
+			}
+		}
+	}
+	return Institution{}, false
+}
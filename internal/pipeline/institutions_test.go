@@ -0,0 +1,64 @@
+package pipeline
+
+import "testing"
+
+func TestDetectInstitution(t *testing.T) {
+	tests := []struct {
+		name string
+		info map[string]interface{}
+		want string
+	}{
+		{"bank name", map[string]interface{}{"institution_id": "Barclays Bank UK PLC"}, "BARCLAYS"},
+		{"canonical id", map[string]interface{}{"institution_id": "FIRST_DIRECT"}, "FIRST_DIRECT"},
+		{"name before shared iban code", map[string]interface{}{"institution_id": "first direct", "iban": "GB33 MIDL 4000 0012 3456 78"}, "FIRST_DIRECT"},
+		{"iban only", map[string]interface{}{"iban": "GB33 BUKB 2020 1555 5555 55"}, "BARCLAYS"},
+		{"iban bank code", map[string]interface{}{"institution_id": "Some Bank", "iban": "GB29 NWBK 6016 1331 9268 19"}, "NATWEST"},
+		{"invalid iban", map[string]interface{}{"iban": "GB00 NWBK 6016 1331 9268 19"}, ""},
+		{"unknown", map[string]interface{}{"institution_id": "Bank of Nowhere"}, ""},
+		{"nothing extracted", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst, ok := detectInstitution(tt.info)
+			if ok != (tt.want != "") || inst.ID != tt.want {
+				t.Errorf("detectInstitution() = %q, %v; want %q", inst.ID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngestOptions(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             IngestOptions
+		wantInstitution  string
+		wantSourceSystem string
+		wantErr          bool
+	}{
+		{"defaults", IngestOptions{}, "", DefaultSourceSystem, false},
+		{"institution", IngestOptions{InstitutionID: "hsbc"}, "HSBC", "HSBC", false},
+		{"source system", IngestOptions{InstitutionID: "monzo", SourceSystem: " open_banking "}, "MONZO", "OPEN_BANKING", false},
+		{"unknown institution", IngestOptions{InstitutionID: "Bank of Nowhere"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.opts.Normalize()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if opts.InstitutionID != tt.wantInstitution {
+				t.Errorf("InstitutionID = %q, want %q", opts.InstitutionID, tt.wantInstitution)
+			}
+			if got := opts.DocumentSourceSystem(); got != tt.wantSourceSystem {
+				t.Errorf("DocumentSourceSystem() = %q, want %q", got, tt.wantSourceSystem)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package pipeline_test
 import (
 	"context"
 	"errors"
+	"math/big"
 	"testing"
 	"time"
 
@@ -40,7 +41,7 @@ func TestPipelineWithCategoryValidation(t *testing.T) {
 		MarkParsingRunFailedFunc: func(ctx context.Context, parsingRunID string, parseErr error) {
 			// Track failures if needed
 		},
-		ListActiveCategoriesFunc: func(ctx context.Context) (interface{}, error) {
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
 			return mockCategories, nil
 		},
 	}
@@ -58,7 +59,7 @@ func TestPipelineWithCategoryValidation(t *testing.T) {
 	// Test case 1: Valid categories
 	t.Run("ValidCategories", func(t *testing.T) {
 		mockAIParser := &MockAIParser{
-			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
 				return map[string]interface{}{
 					"transactions": []interface{}{
 						map[string]interface{}{
@@ -106,7 +107,7 @@ func TestPipelineWithCategoryValidation(t *testing.T) {
 	// Test case 2: Invalid category
 	t.Run("InvalidCategory", func(t *testing.T) {
 		mockAIParser := &MockAIParser{
-			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
 				return map[string]interface{}{
 					"transactions": []interface{}{
 						map[string]interface{}{
@@ -149,12 +150,15 @@ func TestPipelineWithCategoryValidation(t *testing.T) {
 		if err == nil {
 			t.Error("Expected error with invalid category, got nil")
 		}
+		if !errors.Is(err, pipeline.ErrValidation) {
+			t.Errorf("Expected ErrValidation, got %v", err)
+		}
 	})
 
 	// Test case 3: Invalid subcategory
 	t.Run("InvalidSubcategory", func(t *testing.T) {
 		mockAIParser := &MockAIParser{
-			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
 				return map[string]interface{}{
 					"transactions": []interface{}{
 						map[string]interface{}{
@@ -197,9 +201,136 @@ func TestPipelineWithCategoryValidation(t *testing.T) {
 		if err == nil {
 			t.Error("Expected error with invalid subcategory, got nil")
 		}
+		if !errors.Is(err, pipeline.ErrValidation) {
+			t.Errorf("Expected ErrValidation, got %v", err)
+		}
 	})
 }
 
+// TestPipelineUsesDocumentOwner checks that an existing document is parsed with the
+// categories, tag rules and accounts of its owner.
+func TestPipelineUsesDocumentOwner(t *testing.T) {
+	const owner = "user-2"
+	var categoryUsers, tagRuleUsers, promptUsers []string
+	var accountUser string
+	var inserted []*bigquery.TransactionRow
+
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		GetDocumentFunc: func(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+			return &bigquery.DocumentRow{DocumentID: documentID, UserID: owner}, nil
+		},
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			categoryUsers = append(categoryUsers, userID)
+			return []bigquery.CategoryRow{{CategoryID: "cat_healthcare", CategoryName: "Healthcare"}}, nil
+		},
+		ListTagRulesFunc: func(ctx context.Context, userID string) ([]bigquery.TagRuleRow, error) {
+			tagRuleUsers = append(tagRuleUsers, userID)
+			return nil, nil
+		},
+		InsertTransactionsFunc: func(ctx context.Context, rows interface{}) error {
+			inserted = rows.([]*bigquery.TransactionRow)
+			return nil
+		},
+	}}
+	parser := &MockAIParser{
+		ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+			promptUsers = append(promptUsers, userID)
+			return map[string]interface{}{
+				"transactions": []interface{}{
+					map[string]interface{}{
+						"date":        "2024-01-01",
+						"description": "Pharmacy",
+						"amount":      -10.50,
+						"currency":    "GBP",
+						"category":    "Healthcare",
+					},
+				},
+			}, nil
+		},
+		ExtractAccountHeaderFunc: func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+			return map[string]interface{}{"account_number": "12345678", "currency": "GBP"}, nil
+		},
+	}
+	accounts := &MockAccountRepository{
+		UpsertAccountFunc: func(ctx context.Context, row *bigquery.AccountRow) (string, error) {
+			accountUser = row.UserID
+			return "test-account-id", nil
+		},
+	}
+	storage := &MockStorageService{
+		FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+			return []byte("mock pdf data"), nil
+		},
+	}
+
+	err := pipeline.IngestStatementFromGCSWithDeps(context.Background(), "gs://test-bucket/test.pdf", "doc-12345678", repo, accounts, storage, parser)
+	if err != nil {
+		t.Fatalf("IngestStatementFromGCSWithDeps: %v", err)
+	}
+
+	for name, users := range map[string][]string{"categories": categoryUsers, "tag rules": tagRuleUsers, "prompt": promptUsers} {
+		if len(users) == 0 {
+			t.Errorf("%s were not loaded", name)
+		}
+		for _, u := range users {
+			if u != owner {
+				t.Errorf("%s loaded for user %q, want %q", name, u, owner)
+			}
+		}
+	}
+	if accountUser != owner {
+		t.Errorf("account upserted for user %q, want %q", accountUser, owner)
+	}
+	if len(inserted) != 1 || inserted[0].UserID != owner {
+		t.Errorf("inserted transactions %+v, want one of user %q", inserted, owner)
+	}
+}
+
+// TestPipelineUsesStoredMimeType checks that the parser registered for the stored
+// file_mime_type of an existing document parses it.
+func TestPipelineUsesStoredMimeType(t *testing.T) {
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		GetDocumentFunc: func(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+			return &bigquery.DocumentRow{DocumentID: documentID, UserID: pipeline.DefaultUserID, FileMimeType: "text/csv"}, nil
+		},
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			return []bigquery.CategoryRow{{CategoryID: "cat_healthcare", CategoryName: "Healthcare"}}, nil
+		},
+	}}
+	header := func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+		return map[string]interface{}{"account_number": "12345678", "currency": "GBP"}, nil
+	}
+	var used string
+	parserNamed := func(name string) *MockAIParser {
+		return &MockAIParser{
+			ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+				used = name
+				return map[string]interface{}{"transactions": []interface{}{
+					map[string]interface{}{"date": "2024-01-01", "description": "Pharmacy", "amount": -10.50, "category": "Healthcare"},
+				}}, nil
+			},
+			ExtractAccountHeaderFunc: header,
+		}
+	}
+	aiParser := parserNamed("gemini")
+	parsers := pipeline.NewParserRegistry(aiParser)
+	parsers.Register(pipeline.ParserKey{MimeType: "text/csv"}, parserNamed("csv"))
+	storage := &MockStorageService{
+		FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+			return []byte("date,description,amount\n"), nil
+		},
+	}
+
+	err := pipeline.IngestStatementWithParsers(context.Background(), "gs://test-bucket/test.csv",
+		pipeline.IngestOptions{DocumentID: "doc-12345678"}, repo, &MockAccountRepository{}, storage, aiParser, parsers)
+	if err != nil {
+		t.Fatalf("IngestStatementWithParsers: %v", err)
+	}
+	if used != "csv" {
+		t.Errorf("statement parsed by %q, want the csv parser", used)
+	}
+}
+
 // mockDocumentRepo implements both DocumentRepository and CategoryRepository interfaces
 type mockDocumentRepo struct {
 	*MockDocumentRepository
@@ -212,6 +343,10 @@ func (m *mockDocumentRepo) InsertDocument(ctx context.Context, row *bigquery.Doc
 	return nil
 }
 
+func (m *mockDocumentRepo) InsertDocumentIfAbsent(ctx context.Context, row *bigquery.DocumentRow) (bool, error) {
+	return true, m.InsertDocument(ctx, row)
+}
+
 func (m *mockDocumentRepo) InsertTransactions(ctx context.Context, rows []*bigquery.TransactionRow) error {
 	if m.InsertTransactionsFunc != nil {
 		return m.InsertTransactionsFunc(ctx, rows)
@@ -246,9 +381,9 @@ func (m *mockDocumentRepo) MarkParsingRunSucceeded(ctx context.Context, parsingR
 	return nil
 }
 
-func (m *mockDocumentRepo) ListActiveCategories(ctx context.Context) ([]bigquery.CategoryRow, error) {
+func (m *mockDocumentRepo) ListActiveCategories(ctx context.Context, userID string) ([]bigquery.CategoryRow, error) {
 	if m.ListActiveCategoriesFunc != nil {
-		result, err := m.ListActiveCategoriesFunc(ctx)
+		result, err := m.ListActiveCategoriesFunc(ctx, userID)
 		if err != nil {
 			return nil, err
 		}
@@ -265,17 +400,46 @@ func (m *mockDocumentRepo) QueryTransactionsByDateRange(ctx context.Context, sta
 	return []*bigquery.TransactionRow{}, nil
 }
 
-func (m *mockDocumentRepo) ListAllAccounts(ctx context.Context) ([]*bigquery.AccountRow, error) {
+func (m *mockDocumentRepo) QueryTransactionsWithFilter(ctx context.Context, startDate, endDate time.Time, filter bigquery.TransactionFilter) ([]*bigquery.TransactionRow, error) {
 	// Not needed for pipeline tests, return empty slice
+	return []*bigquery.TransactionRow{}, nil
+}
+
+func (m *mockDocumentRepo) QueryTransactions(ctx context.Context, q bigquery.TransactionQuery) ([]*bigquery.TransactionRow, error) {
+	if m.QueryTransactionsFunc != nil {
+		return m.QueryTransactionsFunc(ctx, q)
+	}
+	return []*bigquery.TransactionRow{}, nil
+}
+
+func (m *mockDocumentRepo) ListAllAccounts(ctx context.Context) ([]*bigquery.AccountRow, error) {
+	if m.ListAllAccountsFunc != nil {
+		return m.ListAllAccountsFunc(ctx)
+	}
 	return []*bigquery.AccountRow{}, nil
 }
 
+func (m *mockDocumentRepo) ListAllDocumentsWithStats(ctx context.Context) ([]*bigquery.DocumentWithStats, error) {
+	return nil, nil
+}
+
 func (m *mockDocumentRepo) ListAllDocuments(ctx context.Context) ([]*bigquery.DocumentRow, error) {
 	// Not needed for pipeline tests, return empty slice
 	return []*bigquery.DocumentRow{}, nil
 }
 
+func (m *mockDocumentRepo) GetDocument(ctx context.Context, documentID string) (*bigquery.DocumentRow, error) {
+	if m.GetDocumentFunc != nil {
+		return m.GetDocumentFunc(ctx, documentID)
+	}
+	// For tests, every document exists and belongs to the default user
+	return &bigquery.DocumentRow{DocumentID: documentID, UserID: pipeline.DefaultUserID}, nil
+}
+
 func (m *mockDocumentRepo) FindDocumentByChecksum(ctx context.Context, checksum string) (*bigquery.DocumentRow, error) {
+	if m.FindDocumentByChecksumFunc != nil {
+		return m.FindDocumentByChecksumFunc(ctx, checksum)
+	}
 	// For tests, return nil to indicate no duplicate found
 	return nil, nil
 }
@@ -285,6 +449,128 @@ func (m *mockDocumentRepo) MarkParsingRunsAsSuperseded(ctx context.Context, docu
 	return nil
 }
 
+func (m *mockDocumentRepo) UpdateDocumentParsingStatus(ctx context.Context, documentID, status string) error {
+	// For tests, just return success
+	return nil
+}
+
+func (m *mockDocumentRepo) ListDocumentCategoryAssignments(ctx context.Context, documentID string) ([]bigquery.CategoryAssignment, error) {
+	if m.ListDocumentCategoryAssignmentsFunc != nil {
+		return m.ListDocumentCategoryAssignmentsFunc(ctx, documentID)
+	}
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) UpdateDocumentCategoryIDs(ctx context.Context, documentID string, assignments []bigquery.CategoryAssignment) (int64, error) {
+	if m.UpdateDocumentCategoryIDsFunc != nil {
+		return m.UpdateDocumentCategoryIDsFunc(ctx, documentID, assignments)
+	}
+	return 0, nil
+}
+
+func (m *mockDocumentRepo) ReassignDocumentAccount(ctx context.Context, documentID, accountID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockDocumentRepo) ListDistinctDescriptions(ctx context.Context, userID, prefix string, limit int) ([]bigquery.DescriptionCount, error) {
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) ListTagRules(ctx context.Context, userID string) ([]bigquery.TagRuleRow, error) {
+	if m.ListTagRulesFunc != nil {
+		return m.ListTagRulesFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) InsertTagRule(ctx context.Context, row *bigquery.TagRuleRow) error {
+	return nil
+}
+
+func (m *mockDocumentRepo) UpdateTagRule(ctx context.Context, row *bigquery.TagRuleRow) (bool, error) {
+	return true, nil
+}
+
+func (m *mockDocumentRepo) DeleteTagRule(ctx context.Context, userID, ruleID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockDocumentRepo) UpdateTransactionTags(ctx context.Context, updates []bigquery.TransactionTags) (int64, error) {
+	if m.UpdateTransactionTagsFunc != nil {
+		return m.UpdateTransactionTagsFunc(ctx, updates)
+	}
+	return int64(len(updates)), nil
+}
+
+func (m *mockDocumentRepo) TransitionDocumentStatus(ctx context.Context, documentID string, to bigquery.DocumentStatus) error {
+	if m.TransitionDocumentStatusFunc != nil {
+		return m.TransitionDocumentStatusFunc(ctx, documentID, to)
+	}
+	return nil
+}
+
+func (m *mockDocumentRepo) UpdateDocumentInstitution(ctx context.Context, documentID, institutionID string) error {
+	if m.UpdateDocumentInstitutionFunc != nil {
+		return m.UpdateDocumentInstitutionFunc(ctx, documentID, institutionID)
+	}
+	return nil
+}
+
+func (m *mockDocumentRepo) UpdateDocumentBalances(ctx context.Context, documentID string, opening, closing *big.Rat) error {
+	if m.UpdateDocumentBalancesFunc != nil {
+		return m.UpdateDocumentBalancesFunc(ctx, documentID, opening, closing)
+	}
+	return nil
+}
+
+func (m *mockDocumentRepo) UpdateDocumentMetadata(ctx context.Context, documentID string, metadata bigquerylib.NullJSON) error {
+	if m.UpdateDocumentMetadataFunc != nil {
+		return m.UpdateDocumentMetadataFunc(ctx, documentID, metadata)
+	}
+	return nil
+}
+
+func (m *mockDocumentRepo) GetLatestModelOutput(ctx context.Context, documentID string) (*bigquery.ModelOutputRow, error) {
+	// Not needed for pipeline tests
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) ListModelOutputsByParsingRun(ctx context.Context, parsingRunID string) ([]*bigquery.ModelOutputRow, error) {
+	if m.ListModelOutputsByParsingRunFunc != nil {
+		return m.ListModelOutputsByParsingRunFunc(ctx, parsingRunID)
+	}
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) FindParsingRun(ctx context.Context, parsingRunID string) (*bigquery.ParsingRunRow, error) {
+	if m.FindParsingRunFunc != nil {
+		return m.FindParsingRunFunc(ctx, parsingRunID)
+	}
+	return nil, nil
+}
+
+func (m *mockDocumentRepo) FindParsingRunAccountID(ctx context.Context, parsingRunID string) (string, error) {
+	if m.FindParsingRunAccountIDFunc != nil {
+		return m.FindParsingRunAccountIDFunc(ctx, parsingRunID)
+	}
+	return "", nil
+}
+
+func (m *mockDocumentRepo) FlagParsingRunForReview(ctx context.Context, parsingRunID string, reasons []string) error {
+	if m.FlagParsingRunForReviewFunc != nil {
+		return m.FlagParsingRunForReviewFunc(ctx, parsingRunID, reasons)
+	}
+	return nil
+}
+
+func (m *mockDocumentRepo) SetTransactionReviewed(ctx context.Context, transactionID string, reviewed bool) (bool, error) {
+	return true, nil
+}
+
+func (m *mockDocumentRepo) RecategorizeTransactions(ctx context.Context, filter bigquery.RecategorizeFilter, category bigquery.CategoryRow) (int64, error) {
+	return 0, nil
+}
+
 func (m *mockDocumentRepo) Close() error {
 	return nil
 }
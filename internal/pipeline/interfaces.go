@@ -17,7 +17,9 @@ type CategoryRepository = bigquery.CategoryRepository
 // This interface enables mocking and testing of AI parsing functionality.
 type AIParser interface {
 	// ParseStatement sends PDF bytes to an AI model and returns parsed JSON output.
-	ParseStatement(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error)
+	// The model is offered the categories of userID. institutionID selects the prompt
+	// for the issuing bank; "" uses the default.
+	ParseStatement(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error)
 
 	// ExtractAccountHeader sends PDF bytes to an AI model to extract account metadata from the header.
 	ExtractAccountHeader(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error)
@@ -36,8 +38,13 @@ func NewGeminiAIParser(repo CategoryRepository) *GeminiAIParser {
 }
 
 // ParseStatement delegates to the existing parseStatementWithModel function.
-func (p *GeminiAIParser) ParseStatement(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
-	return parseStatementWithModel(ctx, pdfBytes, p.repo)
+func (p *GeminiAIParser) ParseStatement(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+	return parseStatementWithModel(ctx, pdfBytes, p.repo, userID, institutionID, nil)
+}
+
+// ParseStatementPages parses only the transactions on the given pages of the PDF.
+func (p *GeminiAIParser) ParseStatementPages(ctx context.Context, pdfBytes []byte, userID, institutionID string, pages PageRange) (map[string]interface{}, error) {
+	return parseStatementWithModel(ctx, pdfBytes, p.repo, userID, institutionID, &pages)
 }
 
 // ExtractAccountHeader calls the AI model to extract account metadata from the statement header.
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+// IngestStatementFromFile processes a bank statement PDF read from the local disk,
+// without uploading it to GCS. The document is stored with a file:// URI of the file's
+// absolute path, so it can only be re-parsed on the same machine.
+func IngestStatementFromFile(ctx context.Context, path string, opts IngestOptions) error {
+	uri, err := fileURI(path)
+	if err != nil {
+		return classify(ErrValidation, fmt.Errorf("IngestStatementFromFile: %w", err))
+	}
+
+	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		return fmt.Errorf("IngestStatementFromFile: creating BigQuery repository: %w", err)
+	}
+	defer repo.Close()
+
+	accountRepo, err := infraBQ.NewBigQueryAccountRepository(ctx)
+	if err != nil {
+		return fmt.Errorf("IngestStatementFromFile: creating BigQuery account repository: %w", err)
+	}
+	defer accountRepo.Close()
+
+	storage := &localFileStorage{uri: uri}
+	return ingestStatementWithDeps(ctx, uri, opts, repo, accountRepo, storage, NewGeminiAIParser(repo), nil)
+}
+
+// localFileStorage is the StorageService of IngestStatementFromFile. It only reads the
+// file being ingested, so a document URI cannot make it read anything else.
+type localFileStorage struct {
+	uri string
+}
+
+// UploadFile is not supported: ingesting a local file never uploads it.
+func (s *localFileStorage) UploadFile(ctx context.Context, bucketName, objectName, filePath string) error {
+	return fmt.Errorf("localFileStorage: uploading is not supported")
+}
+
+// FetchFromGCS reads the ingested file. Any other URI is rejected.
+func (s *localFileStorage) FetchFromGCS(ctx context.Context, uri string) ([]byte, error) {
+	path, ok := gcsuploader.LocalPathFromURI(uri)
+	if uri != s.uri || !ok {
+		return nil, fmt.Errorf("localFileStorage: %s is not the file being ingested", uri)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("localFileStorage: %w", err)
+	}
+	return data, nil
+}
+
+// ExtractFilenameFromGCSURI extracts the filename from a file:// URI.
+func (s *localFileStorage) ExtractFilenameFromGCSURI(uri string) string {
+	if path, ok := gcsuploader.LocalPathFromURI(uri); ok {
+		return filepath.Base(path)
+	}
+	return gcsuploader.ExtractFilenameFromGCSURI(uri)
+}
+
+// fileURI returns the file:// URI of the regular file at path.
+func fileURI(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	return gcsuploader.FileURI(path)
+}
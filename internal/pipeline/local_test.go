@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileURI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statement.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := fileURI(path)
+	if err != nil {
+		t.Fatalf("fileURI: %v", err)
+	}
+	if !strings.HasPrefix(uri, "file:///") || !strings.HasSuffix(uri, "/statement.pdf") {
+		t.Errorf("fileURI() = %q, want a file:/// URI of the file", uri)
+	}
+}
+
+func TestFileURIRejectsDirectory(t *testing.T) {
+	if _, err := fileURI(t.TempDir()); err == nil {
+		t.Error("fileURI accepted a directory")
+	}
+}
+
+func TestLocalFileStorageReadsOnlyItsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "statement.pdf")
+	other := filepath.Join(dir, "other.pdf")
+	for _, p := range []string{path, other} {
+		if err := os.WriteFile(p, []byte(filepath.Base(p)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri, err := fileURI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherURI, err := fileURI(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &localFileStorage{uri: uri}
+	if data, err := s.FetchFromGCS(context.Background(), uri); err != nil || string(data) != "statement.pdf" {
+		t.Errorf("FetchFromGCS(own file) = %q, %v", data, err)
+	}
+	if _, err := s.FetchFromGCS(context.Background(), otherURI); err == nil {
+		t.Error("FetchFromGCS read another file")
+	}
+	if got := s.ExtractFilenameFromGCSURI(uri); got != "statement.pdf" {
+		t.Errorf("ExtractFilenameFromGCSURI() = %q, want statement.pdf", got)
+	}
+}
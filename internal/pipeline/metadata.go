@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+)
+
+// DocumentMetadata is stored as JSON in the metadata column of a document.
+type DocumentMetadata struct {
+	// PageCount is the number of pages in the PDF, 0 when it cannot be counted.
+	PageCount int `json:"page_count,omitempty"`
+
+	// Language is the ISO 639-1 code of the statement's language, as detected by the model.
+	Language string `json:"language,omitempty"`
+
+	// StatementReference is the statement number or reference printed on the statement.
+	StatementReference string `json:"statement_reference,omitempty"`
+
+	// ModelName is the AI model that parsed the statement.
+	ModelName string `json:"model_name,omitempty"`
+}
+
+// readPDFMetadata returns the metadata that can be read from the PDF itself.
+func readPDFMetadata(pdfBytes []byte) *DocumentMetadata {
+	return &DocumentMetadata{
+		PageCount: pdfdoc.PageCount(pdfBytes),
+		ModelName: DefaultModelName,
+	}
+}
+
+// addHeaderMetadata copies the language and statement reference of the extracted account
+// header into m. Missing or malformed fields are left empty.
+func (m *DocumentMetadata) addHeaderMetadata(accountInfo map[string]interface{}) {
+	if lang, _ := getOptionalStringField(accountInfo, "statement_language"); lang != nil {
+		m.Language = strings.ToLower(*lang)
+	}
+	if ref, _ := getOptionalStringField(accountInfo, "statement_reference"); ref != nil {
+		m.StatementReference = *ref
+	}
+}
+
+// nullJSON returns m as a value for a JSON column.
+func (m *DocumentMetadata) nullJSON() (bigquerylib.NullJSON, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return bigquerylib.NullJSON{}, fmt.Errorf("marshal document metadata: %w", err)
+	}
+	return bigquerylib.NullJSON{JSONVal: string(b), Valid: true}, nil
+}
+
+// ReadPDFMetadataStep reads metadata such as the page count from the fetched PDF.
+type ReadPDFMetadataStep struct{}
+
+func (s *ReadPDFMetadataStep) Name() string {
+	return "ReadPDFMetadata"
+}
+
+func (s *ReadPDFMetadataStep) Execute(ctx context.Context, state *PipelineState) error {
+	state.Metadata = readPDFMetadata(state.PDFBytes)
+	return nil
+}
+
+// StoreDocumentMetadataStep adds the metadata found in the statement header to the
+// metadata read from the PDF and stores it on the document. Failing to store it is
+// logged but does not fail the parse, as the metadata is informational.
+type StoreDocumentMetadataStep struct{}
+
+func (s *StoreDocumentMetadataStep) Name() string {
+	return "StoreDocumentMetadata"
+}
+
+func (s *StoreDocumentMetadataStep) Execute(ctx context.Context, state *PipelineState) error {
+	log := logger.FromContext(ctx)
+
+	if state.Metadata == nil {
+		state.Metadata = readPDFMetadata(state.PDFBytes)
+	}
+	state.Metadata.addHeaderMetadata(state.ExtractedAccountInfo)
+
+	metadata, err := state.Metadata.nullJSON()
+	if err == nil {
+		err = state.DocumentRepo.UpdateDocumentMetadata(ctx, state.DocumentID, metadata)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("document_id", state.DocumentID).Msg("Failed to store document metadata")
+	}
+	return nil
+}
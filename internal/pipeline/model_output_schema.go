@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// modelOutputSchemaJSON is the JSON schema of the raw statement model output, as stored
+// in model_outputs: {"transactions": [...]}. It accepts what
+// transformModelOutputToTransactions accepts; fields it does not read are allowed, and
+// so are malformed provenance fields, which it drops.
+const modelOutputSchemaJSON = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["transactions"],
+	"properties": {
+		"transactions": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["date", "description", "amount", "category"],
+				"properties": {
+					"date": {"type": "string", "pattern": "^[0-9]{4}-[0-9]{2}-[0-9]{2}$"},
+					"description": {"type": "string", "pattern": "\\S"},
+					"amount": {"type": ["number", "string"]},
+					"currency": {"type": ["string", "null"]},
+					"category": {"type": "string", "pattern": "\\S"},
+					"subcategory": {"type": ["string", "null"]},
+					"balance_after": {"type": ["number", "string", "null"]},
+					"original_amount": {"type": ["number", "string", "null"]},
+					"original_currency": {"type": ["string", "null"]},
+					"statement_page_no": {},
+					"statement_line_no": {},
+					"booking_datetime": {"type": ["string", "null"]}
+				}
+			}
+		}
+	}
+}`
+
+// modelOutputSchema is the compiled modelOutputSchemaJSON.
+var modelOutputSchema = mustCompileSchema("model_output.json", modelOutputSchemaJSON)
+
+// mustCompileSchema compiles a built-in JSON schema, panicking if it is invalid.
+func mustCompileSchema(name, schemaJSON string) *jsonschema.Schema {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schemaJSON))
+	if err != nil {
+		panic(fmt.Sprintf("parse schema %s: %v", name, err))
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(name, doc); err != nil {
+		panic(fmt.Sprintf("add schema %s: %v", name, err))
+	}
+	return c.MustCompile(name)
+}
+
+// ModelOutputError reports every way a model output does not match the expected schema.
+type ModelOutputError struct {
+	// Violations are "location: problem" messages, e.g.
+	// "/transactions/3/amount: got boolean, want number or string", sorted.
+	Violations []string
+}
+
+func (e *ModelOutputError) Error() string {
+	return fmt.Sprintf("model output does not match the schema (%d problems): %s",
+		len(e.Violations), strings.Join(e.Violations, "; "))
+}
+
+// schemaMessages prints schema violations.
+var schemaMessages = message.NewPrinter(language.English)
+
+// validateModelOutput checks rawOutput against the model output schema. It returns a
+// *ModelOutputError listing all violations rather than only the first.
+func validateModelOutput(rawOutput map[string]interface{}) error {
+	err := modelOutputSchema.Validate(rawOutput)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return fmt.Errorf("validate model output: %w", err)
+	}
+
+	var violations []string
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			violations = append(violations, fmt.Sprintf("/%s: %s",
+				strings.Join(e.InstanceLocation, "/"), e.ErrorKind.LocalizedString(schemaMessages)))
+			return
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(verr)
+	sort.Strings(violations)
+	return &ModelOutputError{Violations: violations}
+}
+
+// ValidateModelOutputStep checks the shape of the raw model output before it is
+// transformed, so a malformed output fails with every problem at once.
+type ValidateModelOutputStep struct{}
+
+func (s *ValidateModelOutputStep) Name() string {
+	return "ValidateModelOutput"
+}
+
+func (s *ValidateModelOutputStep) Execute(ctx context.Context, state *PipelineState) error {
+	if err := validateModelOutput(state.RawModelOutput); err != nil {
+		err = fmt.Errorf("ValidateModelOutput: %w", err)
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrParse, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validModelTx = `{"date": "2024-01-05", "description": "TESCO STORES", "amount": -12.50,
+	"currency": "GBP", "category": "Groceries", "subcategory": null,
+	"balance_after": "£1,234.56", "statement_line_no": 3}`
+
+func decodeModelOutput(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := unmarshalModelJSON([]byte(data), &out); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return out
+}
+
+func TestValidateModelOutput_Valid(t *testing.T) {
+	for _, data := range []string{
+		`{"transactions": []}`,
+		`{"transactions": [` + validModelTx + `]}`,
+		`{"transactions": [{"date": "2024-01-05", "description": "x", "amount": "1,000.00", "category": "Income", "extra": true}]}`,
+		`{"transactions": [{"date": "2024-01-05", "description": "x", "amount": 1, "category": "Bills", "statement_line_no": 2.5, "statement_page_no": "12a"}]}`,
+	} {
+		if err := validateModelOutput(decodeModelOutput(t, data)); err != nil {
+			t.Errorf("validateModelOutput(%s) = %v, want nil", data, err)
+		}
+	}
+}
+
+func TestValidateModelOutput_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string // locations of the expected violations
+	}{
+		{name: "missing transactions", data: `{}`, want: []string{"/"}},
+		{name: "transactions not an array", data: `{"transactions": "none"}`, want: []string{"/transactions"}},
+		{name: "transaction not an object", data: `{"transactions": ["x"]}`, want: []string{"/transactions/0"}},
+		{
+			name: "every problem reported",
+			data: `{"transactions": [` + validModelTx + `,
+				{"date": "2024-01-05", "description": "A", "amount": true, "category": "Bills"},
+				{"description": "B", "amount": 1},
+				{"date": "05/01/2024", "description": "C", "amount": 1, "category": "Bills"},
+				{"date": "2024-01-05", "description": "  ", "amount": 1, "category": "Bills"}
+			]}`,
+			want: []string{
+				"/transactions/1/amount",
+				"/transactions/2",
+				"/transactions/3/date",
+				"/transactions/4/description",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateModelOutput(decodeModelOutput(t, tt.data))
+			var moErr *ModelOutputError
+			if !errors.As(err, &moErr) {
+				t.Fatalf("err = %v, want *ModelOutputError", err)
+			}
+			var got []string
+			for _, v := range moErr.Violations {
+				loc, _, _ := strings.Cut(v, ":")
+				got = append(got, loc)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %q, want locations %q", moErr.Violations, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+)
+
+// PagesPerChunkEnv names the environment variable enabling paged parsing. When set to a
+// positive number, statements with more pages are parsed in chunks of that many pages,
+// one model call per chunk. Unset or 0 parses every statement in a single call.
+const PagesPerChunkEnv = "PARSE_PAGES_PER_CHUNK"
+
+// ChunkConcurrencyEnv names the environment variable limiting how many chunks of a paged
+// parse are sent to the model at once. Keep it within the model's rate limits.
+const ChunkConcurrencyEnv = "PARSE_CHUNK_CONCURRENCY"
+
+// DefaultChunkConcurrency is the number of concurrent chunk parses when
+// ChunkConcurrencyEnv is unset.
+const DefaultChunkConcurrency = 2
+
+// PageRange is an inclusive, 1-based range of PDF pages.
+type PageRange struct {
+	First int `json:"first_page"`
+	Last  int `json:"last_page"`
+}
+
+func (r PageRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// PagedAIParser is implemented by parsers that can parse a statement a few pages at a
+// time. ParseStatementStep uses it for long statements when paged parsing is enabled.
+type PagedAIParser interface {
+	// ParseStatementPages parses a PDF holding only the given pages of a statement.
+	// Page numbers in the output are those of the whole statement; line numbers
+	// restart at 1.
+	ParseStatementPages(ctx context.Context, pdfBytes []byte, userID, institutionID string, pages PageRange) (map[string]interface{}, error)
+}
+
+// pagesPerChunkFromEnv returns the configured chunk size, 0 when paged parsing is disabled.
+func pagesPerChunkFromEnv() (int, error) {
+	v := os.Getenv(PagesPerChunkEnv)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", PagesPerChunkEnv, v)
+	}
+	return n, nil
+}
+
+// chunkConcurrencyFromEnv returns the configured number of concurrent chunk parses.
+func chunkConcurrencyFromEnv() (int, error) {
+	v := os.Getenv(ChunkConcurrencyEnv)
+	if v == "" {
+		return DefaultChunkConcurrency, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", ChunkConcurrencyEnv, v)
+	}
+	return n, nil
+}
+
+// splitPDFPages returns a PDF holding each page range of pdfBytes, in order.
+func splitPDFPages(pdfBytes []byte, ranges []PageRange) ([][]byte, error) {
+	doc, err := pdfdoc.Open(pdfBytes)
+	if err != nil {
+		return nil, fmt.Errorf("splitPDFPages: %w", err)
+	}
+	chunks := make([][]byte, len(ranges))
+	for i, pages := range ranges {
+		chunk, err := doc.Extract(pages.First, pages.Last)
+		if err != nil {
+			return nil, fmt.Errorf("splitPDFPages: %w", err)
+		}
+		chunks[i] = chunk
+	}
+	return chunks, nil
+}
+
+// splitPageRanges splits pageCount pages into consecutive ranges of at most perChunk pages.
+func splitPageRanges(pageCount, perChunk int) []PageRange {
+	if pageCount <= 0 || perChunk <= 0 {
+		return nil
+	}
+	var ranges []PageRange
+	for first := 1; first <= pageCount; first += perChunk {
+		last := first + perChunk - 1
+		if last > pageCount {
+			last = pageCount
+		}
+		ranges = append(ranges, PageRange{First: first, Last: last})
+	}
+	return ranges
+}
+
+// pageRangeInstruction is appended to the statement prompt when parsing a chunk.
+func pageRangeInstruction(pages PageRange) string {
+	return fmt.Sprintf("\n\nPAGE RANGE:\n"+
+		"- This PDF holds only pages %d to %d of a longer statement; the other pages are extracted separately.\n"+
+		"- statement_page_no is the page number in the whole statement: the first page of this PDF is page %d.\n"+
+		"- Number statement_line_no from 1 at the first transaction of this PDF.\n",
+		pages.First, pages.Last, pages.First)
+}
+
+// parseStatementInChunks parses the page ranges, whose pages are in the matching chunks
+// from splitPDFPages, with up to concurrency model calls at a time and merges the results
+// in page order. The first failing chunk cancels the others.
+func parseStatementInChunks(ctx context.Context, parser PagedAIParser, chunks [][]byte, userID, institutionID string, ranges []PageRange, concurrency int) (map[string]interface{}, error) {
+	log := logger.FromContext(ctx)
+	if len(chunks) != len(ranges) {
+		return nil, fmt.Errorf("parseStatementInChunks: %d page ranges but %d chunks", len(ranges), len(chunks))
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]map[string]interface{}, len(ranges))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+launch:
+	for i, pages := range ranges {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break launch
+		}
+
+		wg.Add(1)
+		go func(i int, pages PageRange) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			out, err := parser.ParseStatementPages(ctx, chunks[i], userID, institutionID, pages)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("parseStatementInChunks: pages %s: %w", pages, err)
+					cancel()
+				})
+				return
+			}
+			log.Debug().Str("pages", pages.String()).Msg("Parsed statement chunk")
+			outputs[i] = out
+		}(i, pages)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("parseStatementInChunks: %w", err)
+	}
+
+	return mergeChunkOutputs(ranges, outputs)
+}
+
+// mergeChunkOutputs concatenates the transactions of per-chunk model outputs in page order.
+// Transactions the model reported on a page outside its chunk are dropped, as the chunk
+// owning that page reports them. The ranges do not overlap, so nothing else is dropped:
+// identical transactions in two chunks are distinct lines, e.g. two equal card payments
+// either side of a page break. statement_line_no is renumbered across the whole
+// statement. The page ranges are kept under "chunks".
+func mergeChunkOutputs(ranges []PageRange, outputs []map[string]interface{}) (map[string]interface{}, error) {
+	if len(ranges) != len(outputs) {
+		return nil, fmt.Errorf("mergeChunkOutputs: %d page ranges but %d outputs", len(ranges), len(outputs))
+	}
+
+	var merged []interface{}
+	var chunks []interface{}
+
+	for i, out := range outputs {
+		pages := ranges[i]
+		txs, ok := out["transactions"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("mergeChunkOutputs: pages %s: 'transactions' is %T, want []interface{}", pages, out["transactions"])
+		}
+
+		kept, dropped := 0, 0
+		for j, item := range txs {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("mergeChunkOutputs: pages %s: element %d is %T, want map[string]interface{}", pages, j, item)
+			}
+
+			page, hasPage := chunkTransactionPage(obj)
+			if hasPage && (page < pages.First || page > pages.Last) {
+				dropped++
+				continue
+			}
+			if v, ok := obj["statement_line_no"]; ok && v != nil {
+				obj["statement_line_no"] = float64(len(merged) + 1)
+			}
+			merged = append(merged, obj)
+			kept++
+		}
+
+		chunks = append(chunks, map[string]interface{}{
+			"first_page":   pages.First,
+			"last_page":    pages.Last,
+			"transactions": kept,
+			"dropped":      dropped,
+		})
+	}
+
+	if merged == nil {
+		merged = []interface{}{}
+	}
+	result := map[string]interface{}{
+		"transactions": merged,
+		"chunks":       chunks,
+	}
+	return result, nil
+}
+
+// chunkTransactionPage returns the statement_page_no of a raw transaction, if present.
+func chunkTransactionPage(obj map[string]interface{}) (int, bool) {
+	switch v := obj["statement_page_no"].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitPageRanges(t *testing.T) {
+	got := splitPageRanges(7, 3)
+	want := []PageRange{{1, 3}, {4, 6}, {7, 7}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitPageRanges(7, 3) = %v, want %v", got, want)
+	}
+	if got := splitPageRanges(0, 3); got != nil {
+		t.Errorf("splitPageRanges(0, 3) = %v, want nil", got)
+	}
+}
+
+func chunkTx(date, desc string, amount float64, page, line int) map[string]interface{} {
+	return map[string]interface{}{
+		"date":              date,
+		"description":       desc,
+		"amount":            amount,
+		"statement_page_no": float64(page),
+		"statement_line_no": float64(line),
+	}
+}
+
+func TestMergeChunkOutputs(t *testing.T) {
+	ranges := []PageRange{{1, 2}, {3, 4}}
+	outputs := []map[string]interface{}{
+		{"transactions": []interface{}{
+			chunkTx("2024-01-01", "Tesco", -10, 1, 1),
+			chunkTx("2024-01-02", "Salary", 2000, 2, 2),
+			chunkTx("2024-01-02", "Card payment", -4, 2, 3),
+			// Belongs to the next chunk
+			chunkTx("2024-01-03", "Rent", -900, 3, 4),
+		}},
+		{"transactions": []interface{}{
+			// Repeat of a transaction of the previous chunk
+			chunkTx("2024-01-02", "Salary", 2000, 2, 1),
+			// The same payment again, on the next page
+			chunkTx("2024-01-02", "Card payment", -4, 3, 2),
+			chunkTx("2024-01-03", "Rent", -900, 3, 3),
+			chunkTx("2024-01-04", "Cafe", -3, 4, 4),
+		}},
+	}
+
+	merged, err := mergeChunkOutputs(ranges, outputs)
+	if err != nil {
+		t.Fatalf("mergeChunkOutputs: %v", err)
+	}
+
+	txs := merged["transactions"].([]interface{})
+	var descs []string
+	for i, item := range txs {
+		obj := item.(map[string]interface{})
+		descs = append(descs, obj["description"].(string))
+		if line := obj["statement_line_no"]; line != float64(i+1) {
+			t.Errorf("transaction %d statement_line_no = %v, want %d", i, line, i+1)
+		}
+	}
+	if want := []string{"Tesco", "Salary", "Card payment", "Card payment", "Rent", "Cafe"}; !reflect.DeepEqual(descs, want) {
+		t.Errorf("merged descriptions = %v, want %v", descs, want)
+	}
+	if chunks := merged["chunks"].([]interface{}); len(chunks) != 2 {
+		t.Errorf("got %d chunks, want 2", len(chunks))
+	}
+}
+
+// fakePagedParser returns one transaction per chunk, optionally failing one chunk.
+type fakePagedParser struct {
+	mu      sync.Mutex
+	calls   []PageRange
+	running int
+	peak    int
+
+	failPage int
+	delay    time.Duration
+}
+
+func (f *fakePagedParser) ParseStatementPages(ctx context.Context, pdfBytes []byte, userID, institutionID string, pages PageRange) (map[string]interface{}, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, pages)
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	if pages.First == f.failPage {
+		return nil, errors.New("model unavailable")
+	}
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return map[string]interface{}{"transactions": []interface{}{
+		chunkTx("2024-01-01", "Page "+pages.String(), -1, pages.First, 1),
+	}}, nil
+}
+
+func TestParseStatementInChunks(t *testing.T) {
+	parser := &fakePagedParser{delay: 10 * time.Millisecond}
+	ranges := splitPageRanges(9, 2)
+
+	out, err := parseStatementInChunks(context.Background(), parser, make([][]byte, len(ranges)), DefaultUserID, "BARCLAYS", ranges, 2)
+	if err != nil {
+		t.Fatalf("parseStatementInChunks: %v", err)
+	}
+	if len(parser.calls) != len(ranges) {
+		t.Errorf("parsed %d chunks, want %d", len(parser.calls), len(ranges))
+	}
+	if parser.peak > 2 {
+		t.Errorf("%d chunks parsed at once, want at most 2", parser.peak)
+	}
+
+	// Results are merged in page order regardless of completion order
+	txs := out["transactions"].([]interface{})
+	if len(txs) != len(ranges) {
+		t.Fatalf("got %d transactions, want %d", len(txs), len(ranges))
+	}
+	for i, item := range txs {
+		if got, want := item.(map[string]interface{})["description"], "Page "+ranges[i].String(); got != want {
+			t.Errorf("transaction %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestParseStatementInChunks_ErrorCancelsOthers(t *testing.T) {
+	parser := &fakePagedParser{failPage: 3, delay: time.Minute}
+	ranges := splitPageRanges(8, 2)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := parseStatementInChunks(context.Background(), parser, make([][]byte, len(ranges)), DefaultUserID, "", ranges, 4)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "pages 3-4") || !strings.Contains(err.Error(), "model unavailable") {
+			t.Errorf("error = %v, want the failure of pages 3-4", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parseStatementInChunks did not cancel the remaining chunks")
+	}
+}
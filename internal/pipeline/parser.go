@@ -2,50 +2,39 @@ package pipeline
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/dvloznov/finance-tracker/internal/logger"
 	"google.golang.org/genai"
 )
 
 // parseStatementWithModel sends the PDF to Gemini and returns the parsed JSON output.
-// It expects the model to return a STRICT JSON array of transactions.
-func parseStatementWithModel(ctx context.Context, pdfBytes []byte, repo CategoryRepository) (map[string]interface{}, error) {
+// It expects the model to return a STRICT JSON array of transactions. A non-nil pages
+// restricts the parse to those pages. The prompt lists the categories of userID.
+func parseStatementWithModel(ctx context.Context, pdfBytes []byte, repo CategoryRepository, userID, institutionID string, pages *PageRange) (map[string]interface{}, error) {
+	log := logger.FromContext(ctx)
+
 	// 1) Build category prompt from BigQuery taxonomy.
-	catPrompt, err := buildCategoriesPromptWithRepo(ctx, repo)
+	catPrompt, err := buildCategoriesPromptWithRepo(ctx, repo, userID)
 	if err != nil {
 		return nil, fmt.Errorf("parseStatementWithModel: loading categories: %w", err)
 	}
 
-	// 2) Base instructions.
-	basePrompt :=
-		"You are a financial statement parser for Barclays UK PDF bank statements.\n\n" +
-			"Task:\n" +
-			"- Parse ALL transactions in the attached Barclays statement.\n" +
-			"- Output STRICT JSON only (no comments, no trailing commas, no extra text).\n" +
-			"- Output a JSON array of objects.\n\n"
-
-	// Transaction schema (account fields removed - handled separately).
-	txSchema := buildTransactionSchema()
-
-	rulesPrompt :=
-		"Rules:\n" +
-			"- Classify each transaction into the most appropriate category/subcategory.\n" +
-			"- IMPORTANT: If a category has subcategories, you MUST select one - never leave it empty.\n" +
-			"- For ride-sharing services (Uber, Lyft, etc.), always use \"Transportation\" / \"Public Transit\".\n" +
-			"- If the statement has separate \"paid out\" / \"paid in\" columns, convert to a single signed \"amount\".\n" +
-			"- If the running balance is missing, set \"balance_after\" to null.\n\n" +
-			"CRITICAL OUTPUT REQUIREMENTS:\n" +
-			"- Return ONLY valid, parseable JSON that follows RFC 8259 standard.\n" +
-			"- Separate array elements with COMMAS (,) - never use words or other separators.\n" +
-			"- Do NOT wrap the response in code fences.\n" +
-			"- Do NOT use ```json or any Markdown.\n" +
-			"- Do NOT include any comments or explanatory text.\n" +
-			"- Output must begin with \"[\" and end with \"]\".\n" +
-			"- Example format: [{...}, {...}, {...}]\n"
-
-	fullPrompt := basePrompt + txSchema + "\n" + catPrompt + "\n\n" + rulesPrompt
+	// 2) Render the full prompt (built-in or user-supplied template for the institution).
+	fullPrompt, err := buildStatementPrompt(catPrompt, institutionID)
+	if err != nil {
+		return nil, fmt.Errorf("parseStatementWithModel: %w", err)
+	}
+	if pages != nil {
+		fullPrompt += pageRangeInstruction(*pages)
+	}
+	log.Debug().Str("institution_id", institutionID).Str("prompt", fullPrompt).Msg("Statement prompt")
+
+	retries, err := emptyResponseRetriesFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("parseStatementWithModel: %w", err)
+	}
 
 	// 3) Create GenAI client (same style as your test program).
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
@@ -70,14 +59,21 @@ func parseStatementWithModel(ctx context.Context, pdfBytes []byte, repo Category
 		},
 	}
 
-	resp, err := client.Models.GenerateContent(ctx, DefaultModelName, contents, nil)
-	if err != nil {
-		return nil, fmt.Errorf("parseStatementWithModel: generate content: %w", err)
-	}
+	rawText, err := generateNonEmpty(ctx, "parseStatementWithModel", retries, func(ctx context.Context) (string, error) {
+		release, err := acquireAICall(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer release()
 
-	rawText := resp.Text()
-	if rawText == "" {
-		return nil, fmt.Errorf("parseStatementWithModel: empty response from model")
+		resp, err := client.Models.GenerateContent(ctx, DefaultModelName, contents, nil)
+		if err != nil {
+			return "", fmt.Errorf("generate content: %w", err)
+		}
+		return responseText(resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parseStatementWithModel: %w", err)
 	}
 
 	// Clean up Markdown fences / extra text if the model ignored instructions.
@@ -85,7 +81,7 @@ func parseStatementWithModel(ctx context.Context, pdfBytes []byte, repo Category
 
 	// 4) Parse JSON into a generic value.
 	var parsed interface{}
-	if err := json.Unmarshal([]byte(clean), &parsed); err != nil {
+	if err := unmarshalModelJSON([]byte(clean), &parsed); err != nil {
 		return nil, fmt.Errorf("parseStatementWithModel: unmarshal JSON: %w\nraw response: %s", err, rawText)
 	}
 
@@ -142,6 +138,11 @@ func extractAccountHeaderWithModel(ctx context.Context, pdfBytes []byte) (map[st
 	// Use the account header extraction prompt
 	prompt := buildAccountHeaderPrompt()
 
+	retries, err := emptyResponseRetriesFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("extractAccountHeaderWithModel: %w", err)
+	}
+
 	// Create GenAI client
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		HTTPOptions: genai.HTTPOptions{APIVersion: "v1"},
@@ -165,14 +166,21 @@ func extractAccountHeaderWithModel(ctx context.Context, pdfBytes []byte) (map[st
 		},
 	}
 
-	resp, err := client.Models.GenerateContent(ctx, DefaultModelName, contents, nil)
-	if err != nil {
-		return nil, fmt.Errorf("extractAccountHeaderWithModel: generate content: %w", err)
-	}
+	rawText, err := generateNonEmpty(ctx, "extractAccountHeaderWithModel", retries, func(ctx context.Context) (string, error) {
+		release, err := acquireAICall(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer release()
 
-	rawText := resp.Text()
-	if rawText == "" {
-		return nil, fmt.Errorf("extractAccountHeaderWithModel: empty response from model")
+		resp, err := client.Models.GenerateContent(ctx, DefaultModelName, contents, nil)
+		if err != nil {
+			return "", fmt.Errorf("generate content: %w", err)
+		}
+		return responseText(resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("extractAccountHeaderWithModel: %w", err)
 	}
 
 	// Clean up Markdown fences / extra text
@@ -180,7 +188,7 @@ func extractAccountHeaderWithModel(ctx context.Context, pdfBytes []byte) (map[st
 
 	// Parse JSON into a generic value
 	var parsed interface{}
-	if err := json.Unmarshal([]byte(clean), &parsed); err != nil {
+	if err := unmarshalModelJSON([]byte(clean), &parsed); err != nil {
 		return nil, fmt.Errorf("extractAccountHeaderWithModel: unmarshal JSON: %w\nraw response: %s", err, rawText)
 	}
 
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrNoParser is returned when no parser is registered for a document.
+var ErrNoParser = errors.New("no parser registered")
+
+// ParserKey selects a statement parser. An empty field matches any value.
+type ParserKey struct {
+	DocumentType  string // e.g. DefaultDocumentType
+	MimeType      string // e.g. "application/pdf"
+	InstitutionID string // e.g. "BARCLAYS"
+}
+
+func (k ParserKey) String() string {
+	return fmt.Sprintf("(%s, %s, %s)", orAny(k.DocumentType), orAny(k.MimeType), orAny(k.InstitutionID))
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+// normalize puts k in the form keys are stored in: upper-case document type and
+// institution, and a lower-case MIME type without parameters.
+func (k ParserKey) normalize() ParserKey {
+	k.DocumentType = strings.ToUpper(strings.TrimSpace(k.DocumentType))
+	k.InstitutionID = strings.ToUpper(strings.TrimSpace(k.InstitutionID))
+	if mediaType, _, err := mime.ParseMediaType(k.MimeType); err == nil {
+		k.MimeType = mediaType
+	} else {
+		k.MimeType = strings.ToLower(strings.TrimSpace(k.MimeType))
+	}
+	return k
+}
+
+// ParserRegistry picks the parser for a document by its type, MIME type and issuing
+// bank. ParseStatementStep consults it, so new formats plug in by registering a parser.
+type ParserRegistry struct {
+	parsers  map[ParserKey]AIParser
+	fallback AIParser
+}
+
+// NewParserRegistry creates a registry that uses fallback for documents no registered
+// parser matches. A nil fallback makes Lookup fail for them instead.
+func NewParserRegistry(fallback AIParser) *ParserRegistry {
+	return &ParserRegistry{
+		parsers:  make(map[ParserKey]AIParser),
+		fallback: fallback,
+	}
+}
+
+// Register adds parser for documents matching key, replacing any parser registered for
+// the same key.
+func (r *ParserRegistry) Register(key ParserKey, parser AIParser) {
+	r.parsers[key.normalize()] = parser
+}
+
+// Lookup returns the parser for key. The most specific registration wins: an exact
+// match, then one for any institution, then one for any MIME type, then one for any
+// document type, and finally the fallback.
+func (r *ParserRegistry) Lookup(key ParserKey) (AIParser, error) {
+	key = key.normalize()
+	for _, candidate := range []ParserKey{
+		key,
+		{DocumentType: key.DocumentType, MimeType: key.MimeType},
+		{DocumentType: key.DocumentType, InstitutionID: key.InstitutionID},
+		{DocumentType: key.DocumentType},
+		{MimeType: key.MimeType, InstitutionID: key.InstitutionID},
+		{MimeType: key.MimeType},
+		{InstitutionID: key.InstitutionID},
+		{},
+	} {
+		if parser, ok := r.parsers[candidate]; ok {
+			return parser, nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoParser, key)
+}
+
+// statementParser returns the parser for the document being ingested. Without a
+// registry it is AIParser.
+func (s *PipelineState) statementParser() (AIParser, error) {
+	if s.Parsers == nil {
+		return s.AIParser, nil
+	}
+	documentType := s.DocumentType
+	if documentType == "" {
+		documentType = DefaultDocumentType
+	}
+	mimeType := s.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(s.PDFBytes)
+	}
+	return s.Parsers.Lookup(ParserKey{
+		DocumentType:  documentType,
+		MimeType:      mimeType,
+		InstitutionID: s.InstitutionID,
+	})
+}
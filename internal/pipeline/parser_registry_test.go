@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// namedParser is an AIParser told apart by name.
+type namedParser struct{ name string }
+
+func (p *namedParser) ParseStatement(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+	return map[string]interface{}{"parser": p.name}, nil
+}
+
+func (p *namedParser) ExtractAccountHeader(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func TestParserRegistryLookup(t *testing.T) {
+	fallback := &namedParser{"fallback"}
+	registry := NewParserRegistry(fallback)
+	registry.Register(ParserKey{MimeType: "text/csv"}, &namedParser{"csv"})
+	registry.Register(ParserKey{MimeType: "text/csv", InstitutionID: "monzo"}, &namedParser{"monzo-csv"})
+	registry.Register(ParserKey{DocumentType: DefaultDocumentType, MimeType: "application/pdf", InstitutionID: "BARCLAYS"}, &namedParser{"barclays-pdf"})
+
+	tests := []struct {
+		name string
+		key  ParserKey
+		want string
+	}{
+		{name: "exact match", key: ParserKey{DocumentType: "bank_statement", MimeType: "application/pdf", InstitutionID: "barclays"}, want: "barclays-pdf"},
+		{name: "any document type", key: ParserKey{DocumentType: DefaultDocumentType, MimeType: "text/csv; charset=utf-8", InstitutionID: "MONZO"}, want: "monzo-csv"},
+		{name: "any institution", key: ParserKey{DocumentType: DefaultDocumentType, MimeType: "text/csv", InstitutionID: "HSBC"}, want: "csv"},
+		{name: "fallback", key: ParserKey{DocumentType: DefaultDocumentType, MimeType: "application/pdf", InstitutionID: "HSBC"}, want: "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := registry.Lookup(tt.key)
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			if got := parser.(*namedParser).name; got != tt.want {
+				t.Errorf("parser = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserRegistryLookupWithoutFallback(t *testing.T) {
+	_, err := NewParserRegistry(nil).Lookup(ParserKey{MimeType: "text/csv"})
+	if !errors.Is(err, ErrNoParser) {
+		t.Errorf("err = %v, want ErrNoParser", err)
+	}
+}
+
+func TestStatementParserDetectsMimeType(t *testing.T) {
+	registry := NewParserRegistry(&namedParser{"fallback"})
+	registry.Register(ParserKey{MimeType: "application/pdf"}, &namedParser{"pdf"})
+
+	state := &PipelineState{PDFBytes: []byte("%PDF-1.4"), Parsers: registry}
+	parser, err := state.statementParser()
+	if err != nil {
+		t.Fatalf("statementParser: %v", err)
+	}
+	if got := parser.(*namedParser).name; got != "pdf" {
+		t.Errorf("parser = %s, want pdf", got)
+	}
+
+	state = &PipelineState{AIParser: &namedParser{"default"}}
+	parser, _ = state.statementParser()
+	if got := parser.(*namedParser).name; got != "default" {
+		t.Errorf("parser without registry = %s, want default", got)
+	}
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+)
+
+// PDFPasswordEnv names the environment variable the ingest commands read the password
+// of a password-protected statement from. It is not a flag, as other users can see the
+// flags in the process list.
+const PDFPasswordEnv = "PDF_PASSWORD"
+
+// ErrPDFEncrypted is returned for password-protected statement PDFs that cannot be
+// decrypted, because no password or an incorrect one was given or because the
+// encryption is not supported.
+var ErrPDFEncrypted = errors.New("statement PDF is password-protected")
+
+// ErrPDFPasswordRequired is returned for a password-protected PDF given without a
+// password. It wraps ErrPDFEncrypted.
+var ErrPDFPasswordRequired = fmt.Errorf("%w: password required", ErrPDFEncrypted)
+
+// ErrPDFPasswordIncorrect is returned for a password-protected PDF given with a password
+// that does not open it. It wraps ErrPDFEncrypted.
+var ErrPDFPasswordIncorrect = fmt.Errorf("%w: password incorrect", ErrPDFEncrypted)
+
+// ErrPDFEncryptedImages is returned for a password-protected PDF with images that cannot
+// be copied decrypted, such as scanned pages, which the model would see blank. It wraps
+// ErrPDFEncrypted.
+var ErrPDFEncryptedImages = fmt.Errorf("%w: encrypted PDFs with scanned or JPEG images are not supported", ErrPDFEncrypted)
+
+// pdfEncryptEntry matches the /Encrypt entry of a PDF trailer or cross-reference stream,
+// which is only present in encrypted files. Both are stored uncompressed.
+var pdfEncryptEntry = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+
+// decryptPDF returns a decrypted copy of pdfBytes if it is encrypted, opening it with
+// password, and pdfBytes itself otherwise. Files encrypted with an empty user password
+// open without one but are still decrypted, as the model cannot read them as they are.
+func decryptPDF(pdfBytes []byte, password string) ([]byte, error) {
+	if !pdfEncryptEntry.Match(pdfBytes) {
+		return pdfBytes, nil
+	}
+
+	doc, err := pdfdoc.OpenWithPassword(pdfBytes, password)
+	switch {
+	case errors.Is(err, pdfdoc.ErrPasswordRequired):
+		return nil, ErrPDFPasswordRequired
+	case errors.Is(err, pdfdoc.ErrPasswordIncorrect):
+		return nil, ErrPDFPasswordIncorrect
+	case err != nil:
+		return nil, fmt.Errorf("%w: cannot decrypt it: %v", ErrPDFEncrypted, err)
+	}
+	if !doc.Encrypted() {
+		// The entry was matched in content, not in the trailer
+		return pdfBytes, nil
+	}
+
+	decrypted, err := doc.Extract(1, doc.NumPages())
+	if errors.Is(err, pdfdoc.ErrUnsupportedImage) {
+		return nil, fmt.Errorf("%w (%v)", ErrPDFEncryptedImages, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decrypt it: %v", ErrPDFEncrypted, err)
+	}
+	return decrypted, nil
+}
+
+// DecryptPDFStep replaces an encrypted PDF with a decrypted copy, using the password
+// given with the document, so the model can read it. It runs after the checksum is
+// calculated, which identifies the stored file.
+type DecryptPDFStep struct{}
+
+func (s *DecryptPDFStep) Name() string {
+	return "DecryptPDF"
+}
+
+func (s *DecryptPDFStep) Execute(ctx context.Context, state *PipelineState) error {
+	decrypted, err := decryptPDF(state.PDFBytes, state.PDFPassword)
+	if err != nil {
+		err = fmt.Errorf("DecryptPDF: %w", err)
+		if state.ParsingRunID != "" {
+			state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		}
+		return classify(ErrValidation, err)
+	}
+	state.PDFBytes = decrypted
+	return nil
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+)
+
+// MaxPDFBytesEnv names the environment variable limiting the size of statement PDFs
+// sent to the model. 0 disables the limit.
+const MaxPDFBytesEnv = "MAX_PDF_BYTES"
+
+// DefaultMaxPDFBytes is the limit used when MaxPDFBytesEnv is unset. It matches the
+// size of inline data Gemini accepts in a single request.
+const DefaultMaxPDFBytes = 20 << 20
+
+// ErrPDFTooLarge is returned when a statement PDF exceeds the configured size limit.
+var ErrPDFTooLarge = errors.New("statement PDF too large")
+
+// maxPDFBytesFromEnv returns the configured PDF size limit, 0 when there is none.
+func maxPDFBytesFromEnv() (int64, error) {
+	v := os.Getenv(MaxPDFBytesEnv)
+	if v == "" {
+		return DefaultMaxPDFBytes, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", MaxPDFBytesEnv, v)
+	}
+	return n, nil
+}
+
+// checkPDFSize returns an error wrapping ErrPDFTooLarge if a statement of size bytes,
+// sent to the model whole, exceeds limit.
+func checkPDFSize(size, limit int64) error {
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w: statement is %.1f MB, exceeding the %.1f MB limit (%s); split it into smaller PDFs or set %s to parse it in page chunks",
+		ErrPDFTooLarge, megabytes(size), megabytes(limit), MaxPDFBytesEnv, PagesPerChunkEnv)
+}
+
+// checkChunkSizes returns an error wrapping ErrPDFTooLarge if the chunk of a paged parse
+// holding any of the page ranges exceeds limit.
+func checkChunkSizes(chunks [][]byte, ranges []PageRange, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	for i, chunk := range chunks {
+		if size := int64(len(chunk)); size > limit {
+			return fmt.Errorf("%w: pages %s are %.1f MB, exceeding the %.1f MB limit (%s); lower %s",
+				ErrPDFTooLarge, ranges[i], megabytes(size), megabytes(limit), MaxPDFBytesEnv, PagesPerChunkEnv)
+		}
+	}
+	return nil
+}
+
+// headerPDF returns the PDF to extract the account header from: the whole statement, or
+// with paged parsing, its first chunk of pages if the whole statement is over the limit.
+func headerPDF(pdfBytes []byte) ([]byte, error) {
+	limit, err := maxPDFBytesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	perChunk, err := pagesPerChunkFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if perChunk == 0 || limit <= 0 || int64(len(pdfBytes)) <= limit {
+		return pdfBytes, checkPDFSize(int64(len(pdfBytes)), limit)
+	}
+
+	ranges := splitPageRanges(pdfdoc.PageCount(pdfBytes), perChunk)
+	if len(ranges) == 0 {
+		return nil, checkPDFSize(int64(len(pdfBytes)), limit)
+	}
+	chunks, err := splitPDFPages(pdfBytes, ranges[:1])
+	if err != nil {
+		// Unsplittable, so every call sends the whole file
+		return nil, fmt.Errorf("%w (cannot split it into page chunks: %v)", checkPDFSize(int64(len(pdfBytes)), limit), err)
+	}
+	return chunks[0], checkChunkSizes(chunks, ranges[:1], limit)
+}
+
+func megabytes(n int64) float64 {
+	return float64(n) / (1 << 20)
+}
@@ -0,0 +1,297 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+// buildStatementPDF returns a PDF with the given number of pages, written like most
+// current producers do: the page tree in a compressed object stream, indexed by a
+// cross-reference stream.
+func buildStatementPDF(pages int) []byte {
+	return buildStatementPDFWithImage(pages, "")
+}
+
+// buildStatementPDFWithImage returns a PDF like buildStatementPDF whose first page shows
+// an image with the given data in a JPEG stream, if not empty.
+func buildStatementPDFWithImage(pages int, jpeg string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for n := 1; n <= pages; n++ {
+		resources := ""
+		if n == 1 && jpeg != "" {
+			resources = fmt.Sprintf(" /Resources << /XObject << /Scan %d 0 R >> >>", len(objects)+3)
+		}
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents %d 0 R%s >>", len(objects)+2, resources))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (page %d) Tj ET", n)
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		if resources != "" {
+			objects = append(objects, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(jpeg), jpeg))
+		}
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 595 842] >>", strings.Join(kids, " "), pages)
+
+	// Objects that are not streams go into object stream objStm
+	objStm, xrefStm := len(objects)+1, len(objects)+2
+	var header, body bytes.Buffer
+	index := make(map[int]int)
+	for i, obj := range objects {
+		if !strings.Contains(obj, "stream\n") {
+			index[i+1] = len(index)
+			fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+			body.WriteString(obj + "\n")
+		}
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(append(header.Bytes(), body.Bytes()...))
+	zw.Close()
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := make(map[int]int)
+	for i, obj := range objects {
+		if _, ok := index[i+1]; !ok {
+			offsets[i+1] = buf.Len()
+			fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+		}
+	}
+	offsets[objStm] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		objStm, len(index), header.Len(), compressed.Len(), compressed.Bytes())
+
+	var entries bytes.Buffer
+	entries.Write([]byte{0, 0, 0, 0, 0, 0xff, 0xff})
+	for num := 1; num <= xrefStm; num++ {
+		if i, ok := index[num]; ok {
+			entries.WriteByte(2)
+			binary.Write(&entries, binary.BigEndian, uint32(objStm))
+			binary.Write(&entries, binary.BigEndian, uint16(i))
+		} else {
+			entries.WriteByte(1)
+			binary.Write(&entries, binary.BigEndian, uint32(offsets[num]))
+			binary.Write(&entries, binary.BigEndian, uint16(0))
+		}
+	}
+	offsets[xrefStm] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		xrefStm, xrefStm+1, entries.Len(), entries.Bytes())
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", offsets[xrefStm])
+	return buf.Bytes()
+}
+
+// chunkRecordingParser records the number of pages in each PDF it is sent.
+type chunkRecordingParser struct {
+	mu          sync.Mutex
+	wholePages  []int
+	headerPages []int
+	chunkPages  map[pipeline.PageRange]int
+}
+
+func (p *chunkRecordingParser) ParseStatement(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wholePages = append(p.wholePages, pdfdoc.PageCount(pdfBytes))
+	return map[string]interface{}{"transactions": []interface{}{}}, nil
+}
+
+func (p *chunkRecordingParser) ExtractAccountHeader(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.headerPages = append(p.headerPages, pdfdoc.PageCount(pdfBytes))
+	return map[string]interface{}{}, nil
+}
+
+func (p *chunkRecordingParser) ParseStatementPages(ctx context.Context, pdfBytes []byte, userID, institutionID string, pages pipeline.PageRange) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chunkPages[pages] = pdfdoc.PageCount(pdfBytes)
+	return map[string]interface{}{"transactions": []interface{}{}}, nil
+}
+
+func TestParseStatementStep_SendsEachChunkItsPages(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	parser := &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	state := &pipeline.PipelineState{
+		DocumentID:   "doc-1",
+		PDFBytes:     buildStatementPDF(5),
+		AIParser:     parser,
+		DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+	}
+	if err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("ParseStatement: %v", err)
+	}
+
+	want := map[pipeline.PageRange]int{{First: 1, Last: 2}: 2, {First: 3, Last: 4}: 2, {First: 5, Last: 5}: 1}
+	if fmt.Sprint(parser.chunkPages) != fmt.Sprint(want) {
+		t.Errorf("chunk page counts = %v, want %v", parser.chunkPages, want)
+	}
+	if len(parser.wholePages) != 0 {
+		t.Errorf("whole statement parsed %d times, want 0", len(parser.wholePages))
+	}
+}
+
+func TestParseStatementStep_ParsesScansInOneCall(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	parser := &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	state := &pipeline.PipelineState{
+		DocumentID: "doc-1",
+		// The chunks could not show the JPEG
+		PDFBytes:     buildStatementPDFWithImage(5, "\xff\xd8 scanned page"),
+		AIParser:     parser,
+		DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+	}
+	if pdfdoc.PageCount(state.PDFBytes) != 5 {
+		t.Fatalf("test PDF has %d pages, want 5", pdfdoc.PageCount(state.PDFBytes))
+	}
+	if err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("ParseStatement: %v", err)
+	}
+	if len(parser.wholePages) != 1 || parser.wholePages[0] != 5 || len(parser.chunkPages) != 0 {
+		t.Errorf("parsed whole statements of %v pages and %d chunks, want one of 5 pages and no chunks", parser.wholePages, len(parser.chunkPages))
+	}
+}
+
+func TestParseStatementStep_ParsesWholeStatementWhenItCannotSplit(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	parser := &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	state := &pipeline.PipelineState{
+		DocumentID: "doc-1",
+		PDFBytes:   []byte("%PDF-1.4 truncated"),
+		// Counted earlier, e.g. from a stored document
+		Metadata:     &pipeline.DocumentMetadata{PageCount: 5},
+		AIParser:     parser,
+		DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+	}
+	if err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("ParseStatement: %v", err)
+	}
+	if len(parser.wholePages) != 1 || len(parser.chunkPages) != 0 {
+		t.Errorf("parsed %d whole statements and %d chunks, want 1 and 0", len(parser.wholePages), len(parser.chunkPages))
+	}
+}
+
+// largestChunk returns the size of the largest chunk pdfBytes splits into with
+// perChunk pages per chunk.
+func largestChunk(t *testing.T, pdfBytes []byte, perChunk int) int {
+	t.Helper()
+	doc, err := pdfdoc.Open(pdfBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largest := 0
+	for first := 1; first <= doc.NumPages(); first += perChunk {
+		last := min(first+perChunk-1, doc.NumPages())
+		chunk, err := doc.Extract(first, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		largest = max(largest, len(chunk))
+	}
+	return largest
+}
+
+func TestParseStatementStep_LimitsEachChunkSize(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	pdfBytes := buildStatementPDF(5)
+	largest := largestChunk(t, pdfBytes, 2)
+	if largest >= len(pdfBytes) {
+		t.Fatalf("largest chunk is %d bytes, want less than the %d byte statement", largest, len(pdfBytes))
+	}
+
+	newState := func(parser *chunkRecordingParser) *pipeline.PipelineState {
+		return &pipeline.PipelineState{
+			DocumentID:   "doc-1",
+			PDFBytes:     pdfBytes,
+			AIParser:     parser,
+			DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+		}
+	}
+
+	// The whole statement is over the limit, but every chunk is within it
+	t.Setenv(pipeline.MaxPDFBytesEnv, strconv.Itoa(largest))
+	parser := &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	if err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), newState(parser)); err != nil {
+		t.Fatalf("ParseStatement: %v", err)
+	}
+	if len(parser.chunkPages) != 3 {
+		t.Errorf("parsed %d chunks, want 3", len(parser.chunkPages))
+	}
+
+	t.Setenv(pipeline.MaxPDFBytesEnv, strconv.Itoa(largest-1))
+	parser = &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), newState(parser))
+	if !errors.Is(err, pipeline.ErrPDFTooLarge) {
+		t.Fatalf("Execute() error = %v, want ErrPDFTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), pipeline.PagesPerChunkEnv) {
+		t.Errorf("error %q does not mention %s", err, pipeline.PagesPerChunkEnv)
+	}
+	if len(parser.chunkPages) != 0 {
+		t.Errorf("parsed %d chunks of an oversized statement, want 0", len(parser.chunkPages))
+	}
+}
+
+func TestParseStatementStep_LimitsUnsplitStatementSize(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	pdfBytes := buildStatementPDFWithImage(5, "\xff\xd8 scanned page")
+	t.Setenv(pipeline.MaxPDFBytesEnv, strconv.Itoa(len(pdfBytes)-1))
+
+	parser := &chunkRecordingParser{chunkPages: make(map[pipeline.PageRange]int)}
+	state := &pipeline.PipelineState{
+		DocumentID:   "doc-1",
+		PDFBytes:     pdfBytes,
+		AIParser:     parser,
+		DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+	}
+	err := (&pipeline.ParseStatementStep{}).Execute(context.Background(), state)
+	if !errors.Is(err, pipeline.ErrPDFTooLarge) {
+		t.Fatalf("Execute() error = %v, want ErrPDFTooLarge", err)
+	}
+	if len(parser.wholePages) != 0 {
+		t.Errorf("parsed %d whole statements, want 0", len(parser.wholePages))
+	}
+}
+
+func TestExtractAccountHeaderStep_SendsFirstChunkOfLargeStatement(t *testing.T) {
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	pdfBytes := buildStatementPDF(5)
+
+	for _, tt := range []struct {
+		name  string
+		limit int
+		pages int
+	}{
+		{"within limit", len(pdfBytes), 5},
+		{"over limit", largestChunk(t, pdfBytes, 2), 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(pipeline.MaxPDFBytesEnv, strconv.Itoa(tt.limit))
+			parser := &chunkRecordingParser{}
+			state := &pipeline.PipelineState{
+				DocumentID:   "doc-1",
+				PDFBytes:     pdfBytes,
+				AIParser:     parser,
+				DocumentRepo: &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}},
+			}
+			if err := (&pipeline.ExtractAccountHeaderStep{}).Execute(context.Background(), state); err != nil {
+				t.Fatalf("ExtractAccountHeader: %v", err)
+			}
+			if len(parser.headerPages) != 1 || parser.headerPages[0] != tt.pages {
+				t.Errorf("header extracted from PDFs of %v pages, want one of %d", parser.headerPages, tt.pages)
+			}
+		})
+	}
+}
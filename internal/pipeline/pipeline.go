@@ -6,20 +6,75 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
-	"time"
 
 	bigquerylib "cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
 	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
 	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
 	"github.com/google/uuid"
 )
 
+// IngestOptions are optional settings of an ingestion.
+type IngestOptions struct {
+	// DocumentID is an existing document record to use instead of creating a new one.
+	DocumentID string
+
+	// SourceSystem is stored in the source_system of a new document. Empty means
+	// InstitutionID, or DefaultSourceSystem when that is empty too.
+	SourceSystem string
+
+	// InstitutionID is the issuing bank as given by the caller. When set, it is used
+	// instead of detecting the bank from the statement header.
+	InstitutionID string
+
+	// PDFPassword opens a password-protected statement PDF. It is not stored.
+	PDFPassword string
+}
+
+// Normalize validates the options and puts InstitutionID and SourceSystem in their
+// canonical upper-case form.
+func (o IngestOptions) Normalize() (IngestOptions, error) {
+	if o.InstitutionID != "" {
+		inst, ok := LookupInstitution(o.InstitutionID)
+		if !ok {
+			return o, fmt.Errorf("unknown institution %q", o.InstitutionID)
+		}
+		o.InstitutionID = inst.ID
+	}
+	o.SourceSystem = strings.ToUpper(strings.TrimSpace(o.SourceSystem))
+	return o, nil
+}
+
+// DocumentSourceSystem returns the source_system of a new document.
+func (o IngestOptions) DocumentSourceSystem() string {
+	switch {
+	case o.SourceSystem != "":
+		return o.SourceSystem
+	case o.InstitutionID != "":
+		return o.InstitutionID
+	default:
+		return DefaultSourceSystem
+	}
+}
+
 // IngestStatementFromGCS processes a single bank statement PDF stored in GCS.
 // gcsURI should look like: "gs://bucket/path/to/statement.pdf".
 // documentID is optional - if provided, it will use the existing document record instead of creating a new one.
 func IngestStatementFromGCS(ctx context.Context, gcsURI string, documentID ...string) error {
+	// Use provided documentID if available
+	var opts IngestOptions
+	if len(documentID) > 0 && documentID[0] != "" {
+		opts.DocumentID = documentID[0]
+	}
+
+	return IngestStatementFromGCSWithOptions(ctx, gcsURI, opts)
+}
+
+// IngestStatementFromGCSWithOptions processes a single bank statement PDF stored in GCS
+// with the given options.
+func IngestStatementFromGCSWithOptions(ctx context.Context, gcsURI string, opts IngestOptions) error {
 	// Initialize concrete dependencies
 	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
 	if err != nil {
@@ -33,16 +88,13 @@ func IngestStatementFromGCS(ctx context.Context, gcsURI string, documentID ...st
 	}
 	defer accountRepo.Close()
 
-	storage := &gcsuploader.GCSStorageService{}
-	aiParser := NewGeminiAIParser(repo)
-
-	// Use provided documentID if available
-	var docID string
-	if len(documentID) > 0 && documentID[0] != "" {
-		docID = documentID[0]
+	storage, err := gcsuploader.NewStorageServiceFromEnv()
+	if err != nil {
+		return fmt.Errorf("IngestStatementFromGCS: %w", err)
 	}
+	aiParser := NewGeminiAIParser(repo)
 
-	return IngestStatementFromGCSWithDeps(ctx, gcsURI, docID, repo, accountRepo, storage, aiParser)
+	return ingestStatementWithDeps(ctx, gcsURI, opts, repo, accountRepo, storage, aiParser, nil)
 }
 
 // IngestStatementFromGCSWithDeps processes a single bank statement PDF stored in GCS
@@ -57,14 +109,55 @@ func IngestStatementFromGCSWithDeps(
 	storage StorageService,
 	aiParser AIParser,
 ) error {
+	return ingestStatementWithDeps(ctx, gcsURI, IngestOptions{DocumentID: documentID}, repo, accountRepo, storage, aiParser, nil)
+}
+
+// IngestStatementWithParsers processes a single bank statement with the given options
+// and dependencies, picking the statement parser from parsers by the document's type,
+// MIME type and institution. aiParser extracts the account header.
+func IngestStatementWithParsers(
+	ctx context.Context,
+	gcsURI string,
+	opts IngestOptions,
+	repo bigquery.DocumentRepository,
+	accountRepo bigquery.AccountRepository,
+	storage StorageService,
+	aiParser AIParser,
+	parsers *ParserRegistry,
+) error {
+	return ingestStatementWithDeps(ctx, gcsURI, opts, repo, accountRepo, storage, aiParser, parsers)
+}
+
+// ingestStatementWithDeps runs the ingestion pipeline with the given options and
+// dependencies. A nil parsers parses every document with aiParser.
+func ingestStatementWithDeps(
+	ctx context.Context,
+	gcsURI string,
+	opts IngestOptions,
+	repo bigquery.DocumentRepository,
+	accountRepo bigquery.AccountRepository,
+	storage StorageService,
+	aiParser AIParser,
+	parsers *ParserRegistry,
+) error {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return classify(ErrValidation, fmt.Errorf("IngestStatementFromGCS: %w", err))
+	}
+
 	// Initialize pipeline state
 	state := &PipelineState{
 		GCSURI:         gcsURI,
-		DocumentID:     documentID, // Set documentID if provided
+		DocumentID:     opts.DocumentID, // Set documentID if provided
+		UserID:         DefaultUserID,   // Replaced by the owner of an existing document
+		SourceSystem:   opts.DocumentSourceSystem(),
+		InstitutionID:  opts.InstitutionID,
+		PDFPassword:    opts.PDFPassword,
 		DocumentRepo:   repo,
 		AccountRepo:    accountRepo,
 		StorageService: storage,
 		AIParser:       aiParser,
+		Parsers:        parsers,
 	}
 
 	// Create and execute the standard ingestion pipeline
@@ -120,7 +213,7 @@ func storeModelOutputWithRepo(
 		},
 
 		CreatedTS: bigquerylib.NullTimestamp{
-			Timestamp: time.Now(),
+			Timestamp: apptime.Now(),
 			Valid:     true,
 		},
 
@@ -144,6 +237,36 @@ func storeModelOutputWithRepo(
 	return outputID, nil
 }
 
+// transactionIDNamespace is the UUID namespace of transaction IDs.
+var transactionIDNamespace = uuid.MustParse("6f1c2b1e-8a3d-4c55-9e0f-2d7b3a9c4e61")
+
+// numericScale is the number of decimal places of the BigQuery NUMERIC type.
+const numericScale = 9
+
+// transactionID derives a stable ID for the index-th transaction of a parsing run from
+// its statement line (or its position when the line is unknown) and its content, so
+// inserting the same run twice produces the same IDs.
+func transactionID(parsingRunID string, index int, t *Transaction) string {
+	line := fmt.Sprintf("pos%d", index+1)
+	if t.StatementLineNo != nil {
+		line = fmt.Sprintf("line%d", *t.StatementLineNo)
+	}
+
+	balance := ""
+	if t.BalanceAfter != nil {
+		balance = t.BalanceAfter.FloatString(numericScale)
+	}
+	fingerprint := strings.Join([]string{
+		t.Date.Format("2006-01-02"),
+		t.Amount.FloatString(numericScale),
+		t.Currency,
+		t.Description,
+		balance,
+	}, "|")
+
+	return uuid.NewSHA1(transactionIDNamespace, []byte(parsingRunID+"|"+line+"|"+fingerprint)).String()
+}
+
 // insertTransactions writes a batch of transactions to the transactions table.
 func insertTransactions(
 	ctx context.Context,
@@ -157,12 +280,14 @@ func insertTransactions(
 	}
 	defer repo.Close()
 
-	return insertTransactionsWithRepo(ctx, documentID, parsingRunID, "", txs, repo)
+	return insertTransactionsWithRepo(ctx, DefaultUserID, documentID, parsingRunID, "", txs, repo)
 }
 
-// insertTransactionsWithRepo writes a batch of transactions to the transactions table using the provided repository.
+// insertTransactionsWithRepo writes a batch of userID's transactions to the transactions table using the provided repository.
+// Transaction IDs are deterministic, so retrying after a partial insert does not duplicate rows.
 func insertTransactionsWithRepo(
 	ctx context.Context,
+	userID string,
 	documentID string,
 	parsingRunID string,
 	accountID string,
@@ -175,20 +300,22 @@ func insertTransactionsWithRepo(
 
 	rows := make([]*bigquery.TransactionRow, 0, len(txs))
 
-	for _, t := range txs {
+	for i, t := range txs {
 		// Determine direction based on sign of amount
 		var dir bigquerylib.NullString
-		if t.Amount > 0 {
+		if t.Amount.Sign() > 0 {
 			dir = bigquerylib.NullString{StringVal: "IN", Valid: true}
-		} else if t.Amount < 0 {
+		} else if t.Amount.Sign() < 0 {
 			dir = bigquerylib.NullString{StringVal: "OUT", Valid: true}
 		}
 
 		txDate := civil.DateOf(t.Date)
 
-		var balanceAfter *big.Rat
-		if t.BalanceAfter != nil {
-			balanceAfter = new(big.Rat).SetFloat64(*t.BalanceAfter)
+		var originalAmount *big.Rat
+		var originalCurrency bigquerylib.NullString
+		if t.OriginalAmount != nil && t.OriginalCurrency != "" {
+			originalAmount = t.OriginalAmount
+			originalCurrency = bigquerylib.NullString{StringVal: t.OriginalCurrency, Valid: true}
 		}
 
 		var normalizedDescription bigquerylib.NullString
@@ -223,21 +350,38 @@ func insertTransactionsWithRepo(
 			}
 		}
 
+		var statementLineNo, statementPageNo bigquerylib.NullInt64
+		if t.StatementLineNo != nil {
+			statementLineNo = bigquerylib.NullInt64{Int64: *t.StatementLineNo, Valid: true}
+		}
+		if t.StatementPageNo != nil {
+			statementPageNo = bigquerylib.NullInt64{Int64: *t.StatementPageNo, Valid: true}
+		}
+
+		var bookingDatetime bigquerylib.NullDateTime
+		if t.BookingDatetime != nil {
+			bookingDatetime = bigquerylib.NullDateTime{DateTime: civil.DateTimeOf(t.BookingDatetime.In(apptime.Location())), Valid: true}
+		}
+
 		row := &bigquery.TransactionRow{
-			TransactionID: uuid.NewString(),
+			TransactionID: transactionID(parsingRunID, i, t),
 
-			UserID:    DefaultUserID,
+			UserID:    userID,
 			AccountID: accountID, // Link transaction to account
 
 			DocumentID:   documentID,
 			ParsingRunID: parsingRunID,
 
 			TransactionDate: txDate,
+			BookingDatetime: bookingDatetime,
 
-			Amount:   new(big.Rat).SetFloat64(t.Amount),
+			Amount:   t.Amount,
 			Currency: t.Currency,
 
-			BalanceAfter: balanceAfter,
+			BalanceAfter: t.BalanceAfter,
+
+			OriginalAmount:   originalAmount,
+			OriginalCurrency: originalCurrency,
 
 			Direction: dir,
 
@@ -248,7 +392,12 @@ func insertTransactionsWithRepo(
 			CategoryName:    categoryName,
 			SubcategoryName: subcategoryName,
 
-			CreatedTS: time.Now(),
+			StatementLineNo: statementLineNo,
+			StatementPageNo: statementPageNo,
+
+			Tags: t.Tags,
+
+			CreatedTS: apptime.Now(),
 		}
 
 		rows = append(rows, row)
@@ -2,14 +2,146 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
+
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
 )
 
+// PromptTemplateEnv names the environment variable pointing at a statement prompt
+// template file that replaces the built-in prompt. The file is read on every parse,
+// so edits take effect without restarting.
+const PromptTemplateEnv = "STATEMENT_PROMPT_TEMPLATE"
+
+// PromptTemplateDirEnv names the environment variable pointing at a directory of
+// per-institution statement prompt templates named after the lower-case institution ID,
+// e.g. hsbc.tmpl. A template for the detected institution takes precedence over
+// PromptTemplateEnv.
+const PromptTemplateDirEnv = "STATEMENT_PROMPT_TEMPLATE_DIR"
+
+// StatementPromptData is the data available to statement prompt templates.
+type StatementPromptData struct {
+	// Schema describes the fields of each transaction object ({{.Schema}}).
+	Schema string
+
+	// Categories lists the allowed categories and the category assignment rules ({{.Categories}}).
+	Categories string
+
+	// Institution is the display name of the issuing bank, e.g. "Barclays" ({{.Institution}}).
+	Institution string
+}
+
+// DefaultStatementPromptTemplate is the built-in statement parsing prompt.
+const DefaultStatementPromptTemplate = "You are a financial statement parser for {{.Institution}} UK PDF bank statements.\n\n" +
+	"Task:\n" +
+	"- Parse ALL transactions in the attached {{.Institution}} statement.\n" +
+	"- Output STRICT JSON only (no comments, no trailing commas, no extra text).\n" +
+	"- Output a JSON array of objects.\n\n" +
+	"{{.Schema}}\n" +
+	"{{.Categories}}\n\n" +
+	"Rules:\n" +
+	"- Classify each transaction into the most appropriate category/subcategory.\n" +
+	"- IMPORTANT: If a category has subcategories, you MUST select one - never leave it empty.\n" +
+	"- If the statement has separate \"paid out\" / \"paid in\" columns, convert to a single signed \"amount\".\n" +
+	"- If the running balance is missing, set \"balance_after\" to null.\n\n" +
+	"CRITICAL OUTPUT REQUIREMENTS:\n" +
+	"- Return ONLY valid, parseable JSON that follows RFC 8259 standard.\n" +
+	"- Separate array elements with COMMAS (,) - never use words or other separators.\n" +
+	"- Do NOT wrap the response in code fences.\n" +
+	"- Do NOT use ```json or any Markdown.\n" +
+	"- Do NOT include any comments or explanatory text.\n" +
+	"- Output must begin with \"[\" and end with \"]\".\n" +
+	"- Example format: [{...}, {...}, {...}]\n"
+
+// buildStatementPrompt renders the statement parsing prompt for institutionID. The template
+// is the institution's file in PromptTemplateDirEnv if there is one, otherwise the file named
+// by PromptTemplateEnv, falling back to DefaultStatementPromptTemplate when neither is set.
+func buildStatementPrompt(catPrompt, institutionID string) (string, error) {
+	text := DefaultStatementPromptTemplate
+	path, err := statementPromptTemplatePath(institutionID)
+	if err != nil {
+		return "", fmt.Errorf("buildStatementPrompt: %w", err)
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("buildStatementPrompt: reading template %s: %w", path, err)
+		}
+		text = string(b)
+	}
+
+	convention, err := signConventionFor(institutionID)
+	if err != nil {
+		return "", fmt.Errorf("buildStatementPrompt: %w", err)
+	}
+
+	return renderStatementPrompt(text, StatementPromptData{
+		Schema:      buildTransactionSchema() + signConventionInstruction(convention),
+		Categories:  catPrompt,
+		Institution: institutionPromptName(institutionID),
+	})
+}
+
+// BuildStatementPrompt returns the full statement parsing prompt sent to the model for a
+// statement of userID and institutionID ("" for the default), built from the current
+// category taxonomy, without calling the model. It is meant for debugging prompts.
+func BuildStatementPrompt(ctx context.Context, userID, institutionID string) (string, error) {
+	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		return "", fmt.Errorf("BuildStatementPrompt: creating BigQuery repository: %w", err)
+	}
+	defer repo.Close()
+
+	return BuildStatementPromptWithRepo(ctx, repo, userID, institutionID)
+}
+
+// BuildStatementPromptWithRepo returns the full statement parsing prompt using the
+// provided category repository.
+func BuildStatementPromptWithRepo(ctx context.Context, repo CategoryRepository, userID, institutionID string) (string, error) {
+	catPrompt, err := buildCategoriesPromptWithRepo(ctx, repo, userID)
+	if err != nil {
+		return "", fmt.Errorf("BuildStatementPrompt: loading categories: %w", err)
+	}
+	return buildStatementPrompt(catPrompt, institutionID)
+}
+
+// statementPromptTemplatePath returns the template file to use for institutionID, or ""
+// for the built-in prompt.
+func statementPromptTemplatePath(institutionID string) (string, error) {
+	if dir := os.Getenv(PromptTemplateDirEnv); dir != "" && institutionID != "" {
+		path := filepath.Join(dir, strings.ToLower(institutionID)+".tmpl")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("checking template %s: %w", path, err)
+		}
+	}
+	return os.Getenv(PromptTemplateEnv), nil
+}
+
+// renderStatementPrompt executes a statement prompt template.
+func renderStatementPrompt(text string, data StatementPromptData) (string, error) {
+	tmpl, err := template.New("statement-prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("renderStatementPrompt: parsing template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("renderStatementPrompt: executing template: %w", err)
+	}
+	return b.String(), nil
+}
+
 // buildCategoriesPromptWithRepo constructs a prompt string containing all active categories
-// and subcategories from BigQuery, formatted for LLM consumption.
-func buildCategoriesPromptWithRepo(ctx context.Context, repo CategoryRepository) (string, error) {
-	rows, err := repo.ListActiveCategories(ctx)
+// and subcategories from BigQuery, including the custom categories of userID, and the merchant category hints, formatted for LLM consumption.
+func buildCategoriesPromptWithRepo(ctx context.Context, repo CategoryRepository, userID string) (string, error) {
+	rows, err := repo.ListActiveCategories(ctx, userID)
 	if err != nil {
 		return "", fmt.Errorf("buildCategoriesPrompt: list categories: %w", err)
 	}
@@ -18,6 +150,11 @@ func buildCategoriesPromptWithRepo(ctx context.Context, repo CategoryRepository)
 		return "", fmt.Errorf("buildCategoriesPrompt: no active categories found")
 	}
 
+	hints, err := loadCategoryHints()
+	if err != nil {
+		return "", fmt.Errorf("buildCategoriesPrompt: %w", err)
+	}
+
 	// Group by category name
 	categoryMap := make(map[string][]string)
 	for _, row := range rows {
@@ -52,9 +189,12 @@ func buildCategoriesPromptWithRepo(ctx context.Context, repo CategoryRepository)
 	b.WriteString("1. Category must be EXACTLY one of the category names shown above (case-sensitive).\n")
 	b.WriteString("2. If a category has subcategories listed, you MUST choose one of them - never use empty string.\n")
 	b.WriteString("3. If a category shows \"(no subcategories)\", use empty string \"\" for subcategory.\n")
-	b.WriteString("4. If you are unsure, use category \"Uncategorized\" with subcategory \"\".\n")
-	b.WriteString("5. For Uber/taxi rides, use: category \"Transportation\", subcategory \"Public Transit\".\n")
-	b.WriteString("6. Never leave subcategory empty when the category has available subcategories.\n")
+	b.WriteString(fmt.Sprintf("4. If you are unsure, use category %q with subcategory \"\".\n", uncategorizedCategoryFromEnv()))
+	b.WriteString("5. Never leave subcategory empty when the category has available subcategories.\n")
+
+	if hintsPrompt := formatCategoryHints(ctx, hints, rows); hintsPrompt != "" {
+		b.WriteString("\n" + hintsPrompt)
+	}
 
 	return b.String(), nil
 }
@@ -62,7 +202,7 @@ func buildCategoriesPromptWithRepo(ctx context.Context, repo CategoryRepository)
 // buildAccountHeaderPrompt constructs a prompt for extracting account metadata
 // from the bank statement header (not individual transactions).
 func buildAccountHeaderPrompt() string {
-	return "You are a financial statement parser for Barclays UK PDF bank statements.\n\n" +
+	return "You are a financial statement parser for UK PDF bank statements.\n\n" +
 		"Task:\n" +
 		"- Extract ONLY the account metadata from the statement header/top section.\n" +
 		"- DO NOT parse transactions - only account information.\n" +
@@ -74,8 +214,12 @@ func buildAccountHeaderPrompt() string {
 		"- \"account_name\": string or null (e.g., \"Current Account\", \"Savings Account\")\n" +
 		"- \"account_type\": string or null (e.g., \"CURRENT\", \"SAVINGS\", \"CREDIT_CARD\")\n" +
 		"- \"currency\": string or null (e.g., \"GBP\", \"USD\", \"EUR\")\n" +
-		"- \"institution_id\": string or null (bank name, e.g., \"BARCLAYS\")\n" +
-		"- \"opened_date\": string or null (ISO format \"YYYY-MM-DD\" if shown on statement)\n\n" +
+		"- \"institution_id\": string or null (name of the issuing bank as printed, e.g., \"Barclays Bank UK PLC\")\n" +
+		"- \"opened_date\": string or null (ISO format \"YYYY-MM-DD\" if shown on statement)\n" +
+		"- \"statement_language\": string or null (ISO 639-1 code of the language the statement is written in, e.g., \"en\")\n" +
+		"- \"statement_reference\": string or null (statement number or reference as printed, if any)\n" +
+		"- \"opening_balance\": number or null (balance at the start of the statement period, negative if overdrawn)\n" +
+		"- \"closing_balance\": number or null (balance at the end of the statement period, negative if overdrawn)\n\n" +
 		"Rules:\n" +
 		"- Set a field to null if the information is not present in the statement header.\n" +
 		"- Focus ONLY on the top section/header of the statement, not transaction details.\n" +
@@ -93,14 +237,25 @@ func buildAccountHeaderPrompt() string {
 
 // buildTransactionSchema returns the transaction schema portion of the prompt.
 // Account fields (account_name, account_number) are removed since accounts are
-// extracted separately via buildAccountHeaderPrompt.
+// extracted separately via buildAccountHeaderPrompt. The amount format follows
+// AmountDecimalSeparatorEnv; an invalid value is reported when amounts are parsed.
 func buildTransactionSchema() string {
+	decimalSep, err := amountDecimalSeparatorFromEnv()
+	if err != nil {
+		decimalSep = DefaultAmountDecimalSeparator
+	}
 	return "Each transaction object must have these fields:\n" +
 		"- \"date\": string, ISO format \"YYYY-MM-DD\"\n" +
 		"- \"description\": string\n" +
 		"- \"amount\": number (positive for money IN, negative for money OUT)\n" +
 		"- \"currency\": string (e.g. \"GBP\")\n" +
 		"- \"balance_after\": number or null\n" +
+		"- \"original_amount\": number or null (for foreign-currency transactions, the amount in the original currency before conversion)\n" +
+		"- \"original_currency\": string or null (3-letter ISO code of original_amount, e.g. \"EUR\")\n" +
 		"- \"category\": string (MUST be one of the predefined categories below)\n" +
-		"- \"subcategory\": string (MUST be one of the valid subcategories for that category, or empty string if category has no subcategories)\n\n"
+		"- \"subcategory\": string (MUST be one of the valid subcategories for that category, or empty string if category has no subcategories)\n" +
+		"- \"statement_page_no\": integer or null (1-based page number of the PDF where the transaction appears)\n" +
+		"- \"statement_line_no\": integer or null (1-based position of the transaction line within the whole statement, in reading order)\n" +
+		"- \"booking_datetime\": string or null (only if the statement prints a time: the date and time the transaction was booked, ISO format \"YYYY-MM-DDTHH:MM:SS\", with the UTC offset if the statement gives one)\n\n" +
+		amountFormatInstruction(decimalSep) + "\n"
 }
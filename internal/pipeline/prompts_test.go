@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+)
+
+func TestBuildStatementPrompt_Default(t *testing.T) {
+	t.Setenv(PromptTemplateEnv, "")
+
+	prompt, err := buildStatementPrompt("CATEGORY BLOCK", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(prompt, buildTransactionSchema()) {
+		t.Error("default prompt is missing the transaction schema")
+	}
+	if !strings.Contains(prompt, "\nCATEGORY BLOCK\n\nRules:") {
+		t.Error("default prompt is missing the category block")
+	}
+	if strings.Contains(prompt, "{{") {
+		t.Error("default prompt contains unexpanded placeholders")
+	}
+}
+
+func TestBuildStatementPrompt_TemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(path, []byte("Custom bank.\n{{.Schema}}--\n{{.Categories}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PromptTemplateEnv, path)
+
+	prompt, err := buildStatementPrompt("CATEGORY BLOCK", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "Custom bank.\n" + buildTransactionSchema() + "--\nCATEGORY BLOCK"
+	if prompt != want {
+		t.Errorf("prompt = %q, want %q", prompt, want)
+	}
+}
+
+func TestBuildStatementPrompt_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("unknown placeholder", func(t *testing.T) {
+		path := filepath.Join(dir, "unknown.tmpl")
+		if err := os.WriteFile(path, []byte("{{.Accounts}}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(PromptTemplateEnv, path)
+
+		if _, err := buildStatementPrompt("x", ""); err == nil {
+			t.Error("expected error for unknown placeholder")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv(PromptTemplateEnv, filepath.Join(dir, "missing.tmpl"))
+
+		if _, err := buildStatementPrompt("x", ""); err == nil {
+			t.Error("expected error for missing template file")
+		}
+	})
+}
+
+func TestBuildStatementPrompt_Institution(t *testing.T) {
+	t.Setenv(PromptTemplateEnv, "")
+	t.Setenv(PromptTemplateDirEnv, "")
+
+	prompt, err := buildStatementPrompt("x", "HSBC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(prompt, "parser for HSBC UK PDF bank statements") {
+		t.Error("prompt does not name the detected institution")
+	}
+
+	prompt, err = buildStatementPrompt("x", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(prompt, "parser for Barclays UK PDF bank statements") {
+		t.Error("prompt for an undetected institution does not name the default institution")
+	}
+}
+
+func TestBuildStatementPrompt_InstitutionTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "monzo.tmpl"), []byte("Monzo prompt for {{.Institution}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fallback := filepath.Join(dir, "fallback.tmpl")
+	if err := os.WriteFile(fallback, []byte("Fallback prompt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PromptTemplateDirEnv, dir)
+	t.Setenv(PromptTemplateEnv, fallback)
+
+	tests := []struct {
+		institutionID string
+		want          string
+	}{
+		{"MONZO", "Monzo prompt for Monzo"},
+		{"HSBC", "Fallback prompt"},
+		{"", "Fallback prompt"},
+	}
+	for _, tt := range tests {
+		prompt, err := buildStatementPrompt("x", tt.institutionID)
+		if err != nil {
+			t.Fatalf("buildStatementPrompt(%q): unexpected error: %v", tt.institutionID, err)
+		}
+		if prompt != tt.want {
+			t.Errorf("buildStatementPrompt(%q) = %q, want %q", tt.institutionID, prompt, tt.want)
+		}
+	}
+}
+
+func TestBuildStatementPromptWithRepo(t *testing.T) {
+	t.Setenv(PromptTemplateEnv, "")
+	t.Setenv(PromptTemplateDirEnv, "")
+	t.Setenv(CategoryHintsEnv, "")
+
+	repo := &mockCategoryRepository{categories: []bigquery.CategoryRow{
+		{CategoryID: "c1", CategoryName: "Housing", SubcategoryName: bigquerylib.NullString{StringVal: "Rent", Valid: true}},
+	}}
+
+	prompt, err := BuildStatementPromptWithRepo(context.Background(), repo, DefaultUserID, "MONZO")
+	if err != nil {
+		t.Fatalf("BuildStatementPromptWithRepo: %v", err)
+	}
+	for _, want := range []string{"parser for Monzo UK PDF bank statements", "Housing:\n  - Rent", "CATEGORY ASSIGNMENT RULES"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+// ReprocessFromModelOutput re-runs transform, validation and insert for a parsing run using
+// its stored raw model output, without calling the AI model again. The result is written to
+// a fresh parsing run which supersedes the document's earlier runs.
+// Returns the new parsing run ID.
+func ReprocessFromModelOutput(ctx context.Context, parsingRunID string) (string, error) {
+	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ReprocessFromModelOutput: creating BigQuery repository: %w", err)
+	}
+	defer repo.Close()
+
+	return ReprocessFromModelOutputWithDeps(ctx, parsingRunID, repo)
+}
+
+// ReprocessFromModelOutputWithDeps re-runs post-processing for a parsing run using the
+// provided repository. This enables dependency injection for testing.
+func ReprocessFromModelOutputWithDeps(ctx context.Context, parsingRunID string, repo bigquery.DocumentRepository) (string, error) {
+	state := &PipelineState{
+		SourceParsingRunID: parsingRunID,
+		DocumentRepo:       repo,
+	}
+
+	if err := NewReprocessPipeline().Execute(ctx, state); err != nil {
+		return "", err
+	}
+	return state.ParsingRunID, nil
+}
+
+// NewReprocessPipeline creates the pipeline for re-processing a stored model output.
+func NewReprocessPipeline() *Pipeline {
+	return NewPipeline(
+		&LoadModelOutputStep{},
+		&SupersedeOldParsingRunsStep{},
+		&StartParsingRunStep{},
+		&StoreModelOutputStep{},
+		&ValidateModelOutputStep{},
+		&TransformTransactionsStep{},
+		&CheckTransactionCountStep{},
+		&CheckStatementOrderStep{},
+		&CorrectAmountSignsStep{},
+		&HandleZeroAmountsStep{},
+		&ApplyTagRulesStep{},
+		&CreateCategoryValidatorStep{},
+		&ValidateCategoriesStep{},
+		&InsertTransactionsStep{},
+		&FlagForReviewStep{},
+		&MarkSuccessStep{},
+	)
+}
+
+// LoadModelOutputStep loads the stored raw model output, document owner and account of
+// SourceParsingRunID. The account's currency is used for transactions without one.
+type LoadModelOutputStep struct{}
+
+func (s *LoadModelOutputStep) Name() string {
+	return "LoadModelOutput"
+}
+
+func (s *LoadModelOutputStep) Execute(ctx context.Context, state *PipelineState) error {
+	outputs, err := state.DocumentRepo.ListModelOutputsByParsingRun(ctx, state.SourceParsingRunID)
+	if err != nil {
+		return classify(ErrStorage, fmt.Errorf("LoadModelOutput: %w", err))
+	}
+	if len(outputs) == 0 {
+		return fmt.Errorf("LoadModelOutput: %w: no model output stored for parsing run %s", ErrValidation, state.SourceParsingRunID)
+	}
+
+	// Outputs are returned newest first
+	output := outputs[0]
+	if output.PrunedTS.Valid {
+		return fmt.Errorf("LoadModelOutput: %w: model output %s was pruned (archive: %s)", ErrValidation, output.OutputID, output.ArchiveURI.StringVal)
+	}
+	if !output.RawJSON.Valid || output.RawJSON.JSONVal == "" {
+		return fmt.Errorf("LoadModelOutput: %w: model output %s has no raw JSON", ErrParse, output.OutputID)
+	}
+
+	var raw map[string]interface{}
+	if err := unmarshalModelJSON([]byte(output.RawJSON.JSONVal), &raw); err != nil {
+		return fmt.Errorf("LoadModelOutput: %w: unmarshal raw JSON of output %s: %w", ErrParse, output.OutputID, err)
+	}
+
+	accountID, err := state.DocumentRepo.FindParsingRunAccountID(ctx, state.SourceParsingRunID)
+	if err != nil {
+		return classify(ErrStorage, fmt.Errorf("LoadModelOutput: %w", err))
+	}
+
+	doc, err := loadDocument(ctx, state.DocumentRepo, output.DocumentID)
+	if err != nil {
+		return fmt.Errorf("LoadModelOutput: %w", err)
+	}
+
+	currency, err := accountCurrency(ctx, state.DocumentRepo, accountID)
+	if err != nil {
+		return classify(ErrStorage, fmt.Errorf("LoadModelOutput: %w", err))
+	}
+
+	state.DocumentID = output.DocumentID
+	state.UserID = documentUserID(doc)
+	state.StatementCurrency = currency
+	state.RawModelOutput = raw
+	state.AccountID = accountID
+	state.IsReparse = true
+	return nil
+}
+
+// accountCurrency returns the currency of the account accountID, or "" if the account
+// is unknown or has none.
+func accountCurrency(ctx context.Context, repo bigquery.DocumentRepository, accountID string) (string, error) {
+	if accountID == "" {
+		return "", nil
+	}
+	accounts, err := repo.ListAllAccounts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("loading account currency: %w", err)
+	}
+	for _, a := range accounts {
+		if a.AccountID == accountID {
+			return a.Currency, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,147 @@
+package pipeline_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func TestReprocessFromModelOutput(t *testing.T) {
+	mockCategories := []bigquery.CategoryRow{
+		{CategoryID: "cat1-sub1", CategoryName: "Food & Dining", SubcategoryName: bigquerylib.NullString{StringVal: "Groceries", Valid: true}},
+	}
+
+	var inserted []*bigquery.TransactionRow
+	var storedOutput *bigquery.ModelOutputRow
+	var lookedUpRun string
+
+	mockRepo := &MockDocumentRepository{
+		StartParsingRunFunc: func(ctx context.Context, documentID string) (string, error) {
+			if documentID != "doc-1" {
+				t.Errorf("StartParsingRun documentID = %q, want doc-1", documentID)
+			}
+			return "new-run", nil
+		},
+		InsertModelOutputFunc: func(ctx context.Context, row interface{}) error {
+			storedOutput = row.(*bigquery.ModelOutputRow)
+			return nil
+		},
+		InsertTransactionsFunc: func(ctx context.Context, rows interface{}) error {
+			inserted = rows.([]*bigquery.TransactionRow)
+			return nil
+		},
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			return mockCategories, nil
+		},
+		ListModelOutputsByParsingRunFunc: func(ctx context.Context, parsingRunID string) ([]*bigquery.ModelOutputRow, error) {
+			lookedUpRun = parsingRunID
+			return []*bigquery.ModelOutputRow{{
+				OutputID:     "out-1",
+				ParsingRunID: parsingRunID,
+				DocumentID:   "doc-1",
+				RawJSON: bigquerylib.NullJSON{Valid: true, JSONVal: `{"transactions":[
+					{"date":"2024-01-01","description":"Tesco","amount":-10.5,"currency":"GBP",
+					 "category":"Food & Dining","subcategory":"Groceries","balance_after":100}
+				]}`},
+			}}, nil
+		},
+		FindParsingRunAccountIDFunc: func(ctx context.Context, parsingRunID string) (string, error) {
+			return "acc-1", nil
+		},
+	}
+
+	newRunID, err := pipeline.ReprocessFromModelOutputWithDeps(context.Background(), "old-run", &mockDocumentRepo{MockDocumentRepository: mockRepo})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if newRunID != "new-run" {
+		t.Errorf("new parsing run = %q, want new-run", newRunID)
+	}
+	if lookedUpRun != "old-run" {
+		t.Errorf("loaded model output for %q, want old-run", lookedUpRun)
+	}
+	if storedOutput == nil || storedOutput.ParsingRunID != "new-run" {
+		t.Errorf("expected model output to be stored against the new run, got %+v", storedOutput)
+	}
+	if len(inserted) != 1 {
+		t.Fatalf("inserted %d transactions, want 1", len(inserted))
+	}
+	if inserted[0].ParsingRunID != "new-run" || inserted[0].AccountID != "acc-1" || inserted[0].DocumentID != "doc-1" {
+		t.Errorf("unexpected inserted transaction: run=%s account=%s document=%s",
+			inserted[0].ParsingRunID, inserted[0].AccountID, inserted[0].DocumentID)
+	}
+}
+
+func TestReprocessFromModelOutput_AccountCurrency(t *testing.T) {
+	var inserted []*bigquery.TransactionRow
+	mockRepo := &MockDocumentRepository{
+		InsertTransactionsFunc: func(ctx context.Context, rows interface{}) error {
+			inserted = rows.([]*bigquery.TransactionRow)
+			return nil
+		},
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			return []bigquery.CategoryRow{{CategoryID: "cat_healthcare", CategoryName: "Healthcare"}}, nil
+		},
+		ListModelOutputsByParsingRunFunc: func(ctx context.Context, parsingRunID string) ([]*bigquery.ModelOutputRow, error) {
+			return []*bigquery.ModelOutputRow{{
+				OutputID:     "out-1",
+				ParsingRunID: parsingRunID,
+				DocumentID:   "doc-1",
+				RawJSON: bigquerylib.NullJSON{Valid: true, JSONVal: `{"transactions":[
+					{"date":"2024-01-01","description":"Apotheke","amount":-10.5,"category":"Healthcare"}
+				]}`},
+			}}, nil
+		},
+		FindParsingRunAccountIDFunc: func(ctx context.Context, parsingRunID string) (string, error) {
+			return "acc-eur", nil
+		},
+		ListAllAccountsFunc: func(ctx context.Context) ([]*bigquery.AccountRow, error) {
+			return []*bigquery.AccountRow{
+				{AccountID: "acc-gbp", Currency: "GBP"},
+				{AccountID: "acc-eur", Currency: "EUR"},
+			}, nil
+		},
+	}
+
+	if _, err := pipeline.ReprocessFromModelOutputWithDeps(context.Background(), "old-run", &mockDocumentRepo{MockDocumentRepository: mockRepo}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inserted) != 1 || inserted[0].Currency != "EUR" {
+		t.Errorf("inserted %+v, want one transaction in the account's EUR", inserted)
+	}
+}
+
+func TestReprocessFromModelOutput_NoStoredOutput(t *testing.T) {
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}}
+
+	if _, err := pipeline.ReprocessFromModelOutputWithDeps(context.Background(), "missing-run", repo); err == nil {
+		t.Error("expected error when no model output is stored")
+	}
+}
+
+func TestReprocessFromModelOutput_PrunedOutput(t *testing.T) {
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		ListModelOutputsByParsingRunFunc: func(ctx context.Context, parsingRunID string) ([]*bigquery.ModelOutputRow, error) {
+			return []*bigquery.ModelOutputRow{{
+				OutputID:     "out-1",
+				ParsingRunID: parsingRunID,
+				DocumentID:   "doc-1",
+				ArchiveURI:   bigquerylib.NullString{Valid: true, StringVal: "gs://archive/model_outputs/doc-1/old-run/out-1.json.gz"},
+				PrunedTS:     bigquerylib.NullTimestamp{Valid: true},
+			}}, nil
+		},
+	}}
+
+	_, err := pipeline.ReprocessFromModelOutputWithDeps(context.Background(), "old-run", repo)
+	if err == nil {
+		t.Fatal("expected error when the model output was pruned")
+	}
+	if !strings.Contains(err.Error(), "gs://archive/") {
+		t.Errorf("error %q does not mention the archive URI", err)
+	}
+}
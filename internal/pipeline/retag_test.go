@@ -0,0 +1,52 @@
+package pipeline_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func TestApplyTagRules(t *testing.T) {
+	var updated []bigquery.TransactionTags
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		ListTagRulesFunc: func(ctx context.Context, userID string) ([]bigquery.TagRuleRow, error) {
+			return []bigquery.TagRuleRow{
+				{RuleID: "commute", Merchant: bigquerylib.NullString{StringVal: "tfl", Valid: true}, Tags: []string{"commute"}, Priority: 10},
+			}, nil
+		},
+		QueryTransactionsFunc: func(ctx context.Context, q bigquery.TransactionQuery) ([]*bigquery.TransactionRow, error) {
+			if q.DocumentID != "doc-1" {
+				t.Errorf("DocumentID = %q, want doc-1", q.DocumentID)
+			}
+			return []*bigquery.TransactionRow{
+				// Gets the rule's tag next to its own
+				{TransactionID: "tx-1", RawDescription: "CARD 1234", NormalizedDescription: bigquerylib.NullString{StringVal: "TfL Travel", Valid: true}, Tags: []string{"work"}},
+				// Already tagged
+				{TransactionID: "tx-2", RawDescription: "TFL TRAVEL CH", Tags: []string{"commute"}},
+				// No rule matches
+				{TransactionID: "tx-3", RawDescription: "TESCO STORES"},
+			}, nil
+		},
+		UpdateTransactionTagsFunc: func(ctx context.Context, updates []bigquery.TransactionTags) (int64, error) {
+			updated = updates
+			return int64(len(updates)), nil
+		},
+	}}
+
+	result, err := pipeline.ApplyTagRulesWithDeps(context.Background(), "doc-1", repo)
+	if err != nil {
+		t.Fatalf("ApplyTagRulesWithDeps: %v", err)
+	}
+
+	want := []bigquery.TransactionTags{{TransactionID: "tx-1", Tags: []string{"work", "commute"}}}
+	if !reflect.DeepEqual(updated, want) {
+		t.Errorf("updated = %+v, want %+v", updated, want)
+	}
+	if result.Matched != 2 || result.Updated != 1 {
+		t.Errorf("result = %+v, want Matched 2, Updated 1", result)
+	}
+}
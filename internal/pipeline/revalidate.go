@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+// RevalidationResult summarizes a RevalidateCategories run.
+type RevalidationResult struct {
+	// Updated is the number of transactions whose category_id changed.
+	Updated int64
+
+	// Invalid lists the category assignments that no longer validate against the
+	// taxonomy. Their transactions are left unchanged.
+	Invalid []bigquery.CategoryAssignment
+}
+
+// RevalidateCategories re-runs category validation for the stored transactions of a
+// document against the current taxonomy and updates their category_id, without calling
+// the AI model again.
+func RevalidateCategories(ctx context.Context, documentID string) (*RevalidationResult, error) {
+	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("RevalidateCategories: creating BigQuery repository: %w", err)
+	}
+	defer repo.Close()
+
+	return RevalidateCategoriesWithDeps(ctx, documentID, repo)
+}
+
+// RevalidateCategoriesWithDeps revalidates the categories of a document's transactions
+// using the provided repository. This enables dependency injection for testing.
+func RevalidateCategoriesWithDeps(ctx context.Context, documentID string, repo bigquery.DocumentRepository) (*RevalidationResult, error) {
+	doc, err := loadDocument(ctx, repo, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("RevalidateCategories: %w", err)
+	}
+
+	validator, err := NewCategoryValidator(ctx, repo, documentUserID(doc))
+	if err != nil {
+		return nil, classify(ErrStorage, fmt.Errorf("RevalidateCategories: %w", err))
+	}
+
+	assignments, err := repo.ListDocumentCategoryAssignments(ctx, documentID)
+	if err != nil {
+		return nil, classify(ErrStorage, fmt.Errorf("RevalidateCategories: %w", err))
+	}
+
+	result := &RevalidationResult{}
+	var changed []bigquery.CategoryAssignment
+	for _, a := range assignments {
+		categoryID, err := validator.ValidateCategory(a.CategoryName, a.SubcategoryName)
+		if err != nil {
+			result.Invalid = append(result.Invalid, a)
+			continue
+		}
+		if categoryID != a.CategoryID {
+			a.CategoryID = categoryID
+			changed = append(changed, a)
+		}
+	}
+
+	if len(changed) > 0 {
+		result.Updated, err = repo.UpdateDocumentCategoryIDs(ctx, documentID, changed)
+		if err != nil {
+			return nil, classify(ErrStorage, fmt.Errorf("RevalidateCategories: %w", err))
+		}
+	}
+
+	return result, nil
+}
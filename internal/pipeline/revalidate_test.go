@@ -0,0 +1,53 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func TestRevalidateCategories(t *testing.T) {
+	categories := []bigquery.CategoryRow{
+		{CategoryID: "groceries-v2", CategoryName: "Food & Dining", SubcategoryName: bigquerylib.NullString{StringVal: "Groceries", Valid: true}},
+		{CategoryID: "rent", CategoryName: "Housing", SubcategoryName: bigquerylib.NullString{StringVal: "Rent", Valid: true}},
+	}
+
+	var updated []bigquery.CategoryAssignment
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		ListActiveCategoriesFunc: func(ctx context.Context, userID string) (interface{}, error) {
+			return categories, nil
+		},
+		ListDocumentCategoryAssignmentsFunc: func(ctx context.Context, documentID string) ([]bigquery.CategoryAssignment, error) {
+			return []bigquery.CategoryAssignment{
+				// Taxonomy entry was replaced
+				{CategoryName: "Food & Dining", SubcategoryName: "Groceries", CategoryID: "groceries-v1", TransactionCount: 4},
+				// Already up to date
+				{CategoryName: "Housing", SubcategoryName: "Rent", CategoryID: "rent", TransactionCount: 1},
+				// Category was removed from the taxonomy
+				{CategoryName: "Pets", CategoryID: "pets", TransactionCount: 2},
+			}, nil
+		},
+		UpdateDocumentCategoryIDsFunc: func(ctx context.Context, documentID string, assignments []bigquery.CategoryAssignment) (int64, error) {
+			updated = assignments
+			return 4, nil
+		},
+	}}
+
+	result, err := pipeline.RevalidateCategoriesWithDeps(context.Background(), "doc-1", repo)
+	if err != nil {
+		t.Fatalf("RevalidateCategoriesWithDeps: %v", err)
+	}
+
+	if len(updated) != 1 || updated[0].CategoryID != "groceries-v2" {
+		t.Errorf("updated assignments = %+v, want Groceries -> groceries-v2 only", updated)
+	}
+	if result.Updated != 4 {
+		t.Errorf("Updated = %d, want 4", result.Updated)
+	}
+	if len(result.Invalid) != 1 || result.Invalid[0].CategoryName != "Pets" {
+		t.Errorf("Invalid = %+v, want Pets", result.Invalid)
+	}
+}
@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// maxReviewIssues caps how many individual problems of one kind are listed in review reasons.
+const maxReviewIssues = 5
+
+// checkStatementOrder returns review reasons if transactions are not in statement order:
+// page and line numbers must increase, line numbers must have no gaps, and dates must move
+// in one direction. Any of these usually means the model skipped or reordered rows.
+func checkStatementOrder(txs []*Transaction) []string {
+	if len(txs) < 2 {
+		return nil
+	}
+
+	var reasons []string
+	addIssue := func(issues *int, format string, args ...interface{}) {
+		*issues++
+		if *issues <= maxReviewIssues {
+			reasons = append(reasons, fmt.Sprintf(format, args...))
+		}
+	}
+
+	// Statements list either oldest or newest first; take the direction from the ends
+	descending := txs[len(txs)-1].Date.Before(txs[0].Date)
+
+	var orderIssues, gapIssues, dateIssues int
+	for i := 1; i < len(txs); i++ {
+		prev, cur := txs[i-1], txs[i]
+
+		if prev.StatementPageNo != nil && cur.StatementPageNo != nil && *cur.StatementPageNo < *prev.StatementPageNo {
+			addIssue(&orderIssues, "transaction %d is on page %d after a transaction on page %d",
+				i, *cur.StatementPageNo, *prev.StatementPageNo)
+		}
+
+		if prev.StatementLineNo != nil && cur.StatementLineNo != nil {
+			switch {
+			case *cur.StatementLineNo <= *prev.StatementLineNo:
+				addIssue(&orderIssues, "transaction %d has line %d after line %d",
+					i, *cur.StatementLineNo, *prev.StatementLineNo)
+			case *cur.StatementLineNo > *prev.StatementLineNo+1:
+				addIssue(&gapIssues, "lines %d to %d are missing before transaction %d",
+					*prev.StatementLineNo+1, *cur.StatementLineNo-1, i)
+			}
+		}
+
+		if (!descending && cur.Date.Before(prev.Date)) || (descending && cur.Date.After(prev.Date)) {
+			addIssue(&dateIssues, "transaction %d dated %s is out of order after %s",
+				i, cur.Date.Format("2006-01-02"), prev.Date.Format("2006-01-02"))
+		}
+	}
+
+	for _, c := range []struct {
+		issues int
+		what   string
+	}{
+		{orderIssues, "out-of-order lines"},
+		{gapIssues, "line number gaps"},
+		{dateIssues, "out-of-order dates"},
+	} {
+		if c.issues > maxReviewIssues {
+			reasons = append(reasons, fmt.Sprintf("%d more %s", c.issues-maxReviewIssues, c.what))
+		}
+	}
+
+	return reasons
+}
+
+// Environment variables overriding DefaultTransactionLimits.
+const (
+	MaxTransactionsEnv        = "MAX_TRANSACTIONS_PER_STATEMENT"
+	MaxTransactionsPerPageEnv = "MAX_TRANSACTIONS_PER_PAGE"
+)
+
+// TransactionLimits bounds how many transactions a statement may plausibly contain.
+// A zero field disables that check.
+type TransactionLimits struct {
+	// MaxTotal fails the run when exceeded; output this large is almost certainly hallucinated.
+	MaxTotal int
+
+	// MaxPerPage flags the run for review when the average number of transactions per
+	// page exceeds it. Pages are taken from statement_page_no.
+	MaxPerPage float64
+}
+
+// DefaultTransactionLimits are generous for monthly personal bank statements.
+var DefaultTransactionLimits = TransactionLimits{
+	MaxTotal:   2000,
+	MaxPerPage: 60,
+}
+
+// transactionLimitsFromEnv returns DefaultTransactionLimits with any environment overrides applied.
+func transactionLimitsFromEnv() (TransactionLimits, error) {
+	limits := DefaultTransactionLimits
+
+	if v := os.Getenv(MaxTransactionsEnv); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid %s %q: must be a non-negative integer", MaxTransactionsEnv, v)
+		}
+		limits.MaxTotal = n
+	}
+	if v := os.Getenv(MaxTransactionsPerPageEnv); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return limits, fmt.Errorf("invalid %s %q: must be a non-negative number", MaxTransactionsPerPageEnv, v)
+		}
+		limits.MaxPerPage = f
+	}
+
+	return limits, nil
+}
+
+// checkTransactionCount applies limits to txs. It returns an error if the run must fail
+// and review reasons if it only looks suspicious.
+func checkTransactionCount(txs []*Transaction, limits TransactionLimits) ([]string, error) {
+	if limits.MaxTotal > 0 && len(txs) > limits.MaxTotal {
+		return nil, fmt.Errorf("%w: %d transactions exceed the limit of %d per statement", ErrValidation, len(txs), limits.MaxTotal)
+	}
+
+	var pages int64
+	for _, tx := range txs {
+		if tx.StatementPageNo != nil && *tx.StatementPageNo > pages {
+			pages = *tx.StatementPageNo
+		}
+	}
+	if limits.MaxPerPage <= 0 || pages == 0 {
+		return nil, nil
+	}
+
+	perPage := float64(len(txs)) / float64(pages)
+	if perPage > limits.MaxPerPage {
+		return []string{fmt.Sprintf("%d transactions on %d pages (%.1f per page) exceed the limit of %g per page",
+			len(txs), pages, perPage, limits.MaxPerPage)}, nil
+	}
+	return nil, nil
+}
+
+// CheckTransactionCountStep guards against runaway model output: it fails the run when the
+// statement has implausibly many transactions and flags it for review when there are
+// unusually many per page.
+type CheckTransactionCountStep struct {
+	// Limits overrides the limits read from the environment.
+	Limits *TransactionLimits
+}
+
+func (s *CheckTransactionCountStep) Name() string {
+	return "CheckTransactionCount"
+}
+
+func (s *CheckTransactionCountStep) Execute(ctx context.Context, state *PipelineState) error {
+	var limits TransactionLimits
+	if s.Limits != nil {
+		limits = *s.Limits
+	} else {
+		var err error
+		if limits, err = transactionLimitsFromEnv(); err != nil {
+			return fmt.Errorf("CheckTransactionCount: %w", err)
+		}
+	}
+
+	reasons, err := checkTransactionCount(state.Transactions, limits)
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return fmt.Errorf("CheckTransactionCount: %w", err)
+	}
+	state.ReviewReasons = append(state.ReviewReasons, reasons...)
+	return nil
+}
+
+// CheckStatementOrderStep flags runs whose transactions are not in statement order.
+// It never fails the pipeline; problems are recorded for review.
+type CheckStatementOrderStep struct{}
+
+func (s *CheckStatementOrderStep) Name() string {
+	return "CheckStatementOrder"
+}
+
+func (s *CheckStatementOrderStep) Execute(ctx context.Context, state *PipelineState) error {
+	state.ReviewReasons = append(state.ReviewReasons, checkStatementOrder(state.Transactions)...)
+	return nil
+}
+
+// FlagForReviewStep records any review reasons collected by earlier steps on the parsing run.
+// Failing to record them is logged but does not fail ingestion.
+type FlagForReviewStep struct{}
+
+func (s *FlagForReviewStep) Name() string {
+	return "FlagForReview"
+}
+
+func (s *FlagForReviewStep) Execute(ctx context.Context, state *PipelineState) error {
+	if len(state.ReviewReasons) == 0 {
+		return nil
+	}
+
+	log := logger.FromContext(ctx)
+	log.Warn().
+		Str("parsing_run_id", state.ParsingRunID).
+		Strs("reasons", state.ReviewReasons).
+		Msg("Parsing run flagged for review")
+
+	if err := state.DocumentRepo.FlagParsingRunForReview(ctx, state.ParsingRunID, state.ReviewReasons); err != nil {
+		log.Error().Err(err).Str("parsing_run_id", state.ParsingRunID).Msg("Failed to flag parsing run for review")
+	}
+	return nil
+}
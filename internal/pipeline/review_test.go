@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tx builds a transaction on the given day of January 2024 at a page and line.
+func tx(day int, page, line int64) *Transaction {
+	return &Transaction{
+		Date:            time.Date(2024, time.January, day, 0, 0, 0, 0, time.UTC),
+		StatementPageNo: &page,
+		StatementLineNo: &line,
+	}
+}
+
+func TestCheckStatementOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		txs  []*Transaction
+		want []string // substrings expected in the reasons, in order; nil means no reasons
+	}{
+		{
+			name: "in order",
+			txs:  []*Transaction{tx(1, 1, 1), tx(1, 1, 2), tx(3, 2, 3)},
+		},
+		{
+			name: "newest first is accepted",
+			txs:  []*Transaction{tx(5, 1, 1), tx(3, 1, 2), tx(1, 2, 3)},
+		},
+		{
+			name: "without provenance only dates are checked",
+			txs:  []*Transaction{{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, {Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name: "reordered lines",
+			txs:  []*Transaction{tx(1, 1, 1), tx(1, 1, 3), tx(1, 1, 2)},
+			want: []string{"lines 2 to 2 are missing", "transaction 2 has line 2 after line 3"},
+		},
+		{
+			name: "skipped rows",
+			txs:  []*Transaction{tx(1, 1, 1), tx(2, 1, 2), tx(3, 1, 6)},
+			want: []string{"lines 3 to 5 are missing before transaction 2"},
+		},
+		{
+			name: "page goes backwards",
+			txs:  []*Transaction{tx(1, 2, 1), tx(2, 1, 2)},
+			want: []string{"transaction 1 is on page 1 after a transaction on page 2"},
+		},
+		{
+			name: "date out of order",
+			txs:  []*Transaction{tx(1, 1, 1), tx(5, 1, 2), tx(2, 1, 3), tx(6, 1, 4)},
+			want: []string{"transaction 2 dated 2024-01-02 is out of order after 2024-01-05"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkStatementOrder(tt.txs)
+			if len(got) != len(tt.want) {
+				t.Fatalf("checkStatementOrder() = %q, want %d reasons", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("reason %d = %q, want it to contain %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckStatementOrder_CapsReasons(t *testing.T) {
+	var txs []*Transaction
+	for i := int64(0); i < 10; i++ {
+		txs = append(txs, tx(1, 1, 100-i))
+	}
+
+	got := checkStatementOrder(txs)
+	if len(got) != maxReviewIssues+1 {
+		t.Fatalf("got %d reasons, want %d", len(got), maxReviewIssues+1)
+	}
+	if last := got[len(got)-1]; last != "4 more out-of-order lines" {
+		t.Errorf("last reason = %q, want summary of the remaining issues", last)
+	}
+}
+
+// onPages builds n transactions spread evenly over the given number of pages.
+func onPages(n int, pages int64) []*Transaction {
+	txs := make([]*Transaction, n)
+	for i := range txs {
+		txs[i] = tx(1, int64(i)*pages/int64(n)+1, int64(i+1))
+	}
+	return txs
+}
+
+func TestCheckTransactionCount(t *testing.T) {
+	limits := TransactionLimits{MaxTotal: 100, MaxPerPage: 10}
+
+	if _, err := checkTransactionCount(onPages(101, 20), limits); !errors.Is(err, ErrValidation) {
+		t.Errorf("over MaxTotal: err = %v, want ErrValidation", err)
+	}
+
+	reasons, err := checkTransactionCount(onPages(30, 2), limits)
+	if err != nil || len(reasons) != 1 || !strings.Contains(reasons[0], "15.0 per page") {
+		t.Errorf("over MaxPerPage: reasons = %v, err = %v; want one per-page reason", reasons, err)
+	}
+
+	if reasons, err := checkTransactionCount(onPages(30, 3), limits); err != nil || reasons != nil {
+		t.Errorf("within limits: reasons = %v, err = %v; want none", reasons, err)
+	}
+
+	// Without page numbers only the total is checked.
+	noPages := []*Transaction{{}, {}, {}}
+	if reasons, err := checkTransactionCount(noPages, TransactionLimits{MaxPerPage: 1}); err != nil || reasons != nil {
+		t.Errorf("no pages: reasons = %v, err = %v; want none", reasons, err)
+	}
+
+	if _, err := checkTransactionCount(onPages(500, 1), TransactionLimits{}); err != nil {
+		t.Errorf("zero limits: err = %v, want checks disabled", err)
+	}
+}
+
+func TestTransactionLimitsFromEnv(t *testing.T) {
+	t.Setenv(MaxTransactionsEnv, "50")
+	t.Setenv(MaxTransactionsPerPageEnv, "7.5")
+
+	got, err := transactionLimitsFromEnv()
+	if err != nil {
+		t.Fatalf("transactionLimitsFromEnv: %v", err)
+	}
+	if got.MaxTotal != 50 || got.MaxPerPage != 7.5 {
+		t.Errorf("limits = %+v, want {50 7.5}", got)
+	}
+
+	t.Setenv(MaxTransactionsEnv, "lots")
+	if _, err := transactionLimitsFromEnv(); err == nil {
+		t.Error("expected an error for a non-numeric limit")
+	}
+}
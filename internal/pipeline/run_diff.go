@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+)
+
+// ErrParsingRunNotFound is returned when a parsing run does not exist or belongs to
+// another document.
+var ErrParsingRunNotFound = errors.New("parsing run not found")
+
+// RunDiff lists how the transactions of one parsing run of a document differ from
+// another, e.g. a reparse from the run it would supersede.
+type RunDiff struct {
+	DocumentID string `json:"document_id"`
+	FromRunID  string `json:"from_parsing_run_id"`
+	ToRunID    string `json:"to_parsing_run_id"`
+
+	// Added are only in the "to" run, Removed only in the "from" run.
+	Added   []*bigquery.TransactionRow `json:"added"`
+	Removed []*bigquery.TransactionRow `json:"removed"`
+
+	// Changed are in both runs with different details.
+	Changed []TransactionChange `json:"changed"`
+
+	// Unchanged is the number of transactions identical in both runs.
+	Unchanged int `json:"unchanged"`
+}
+
+// TransactionChange is a transaction found in both runs whose details differ.
+type TransactionChange struct {
+	// Fields names the differing columns.
+	Fields []string                 `json:"fields"`
+	From   *bigquery.TransactionRow `json:"from"`
+	To     *bigquery.TransactionRow `json:"to"`
+}
+
+// DiffParsingRuns compares the transactions of two parsing runs of a document. Runs
+// are compared whatever their status, so a superseded run can be compared with the
+// current one. Transactions are matched by fingerprint: date, amount, currency and
+// description, with repeats of the same fingerprint paired in order.
+func DiffParsingRuns(ctx context.Context, repo bigquery.DocumentRepository, documentID, fromRunID, toRunID string) (*RunDiff, error) {
+	var runs [2][]*bigquery.TransactionRow
+	for i, runID := range []string{fromRunID, toRunID} {
+		run, err := repo.FindParsingRun(ctx, runID)
+		if err != nil {
+			return nil, fmt.Errorf("DiffParsingRuns: find parsing run %s: %w", runID, err)
+		}
+		if run == nil || run.DocumentID != documentID {
+			return nil, fmt.Errorf("DiffParsingRuns: %w: %s for document %s", ErrParsingRunNotFound, runID, documentID)
+		}
+
+		runs[i], err = repo.QueryTransactions(ctx, bigquery.TransactionQuery{
+			DocumentID:   documentID,
+			ParsingRunID: runID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DiffParsingRuns: list transactions of %s: %w", runID, err)
+		}
+	}
+
+	diff := diffTransactions(runs[0], runs[1])
+	diff.DocumentID = documentID
+	diff.FromRunID = fromRunID
+	diff.ToRunID = toRunID
+	return diff, nil
+}
+
+// diffTransactions matches the transactions of two runs by fingerprint and compares
+// the pairs. Results keep the order of the input.
+func diffTransactions(from, to []*bigquery.TransactionRow) *RunDiff {
+	diff := &RunDiff{
+		Added:   []*bigquery.TransactionRow{},
+		Removed: []*bigquery.TransactionRow{},
+		Changed: []TransactionChange{},
+	}
+
+	// The n-th repeat of a fingerprint in one run pairs with the n-th in the other
+	unmatched := make(map[string][]*bigquery.TransactionRow)
+	for _, row := range from {
+		key := transactionFingerprint(row)
+		unmatched[key] = append(unmatched[key], row)
+	}
+
+	for _, row := range to {
+		key := transactionFingerprint(row)
+		candidates := unmatched[key]
+		if len(candidates) == 0 {
+			diff.Added = append(diff.Added, row)
+			continue
+		}
+		old := candidates[0]
+		unmatched[key] = candidates[1:]
+
+		if fields := changedTransactionFields(old, row); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, TransactionChange{Fields: fields, From: old, To: row})
+		} else {
+			diff.Unchanged++
+		}
+	}
+
+	for _, row := range from {
+		key := transactionFingerprint(row)
+		if candidates := unmatched[key]; len(candidates) > 0 && candidates[0] == row {
+			diff.Removed = append(diff.Removed, row)
+			unmatched[key] = candidates[1:]
+		}
+	}
+
+	return diff
+}
+
+// transactionFingerprint identifies a transaction across parsing runs. Unlike the
+// transaction ID it leaves out the run, the statement line and the balance, which a
+// reparse may change.
+func transactionFingerprint(row *bigquery.TransactionRow) string {
+	amount := ""
+	if row.Amount != nil {
+		amount = row.Amount.FloatString(numericScale)
+	}
+	return strings.Join([]string{
+		row.TransactionDate.String(),
+		amount,
+		row.Currency,
+		row.RawDescription,
+	}, "|")
+}
+
+// changedTransactionFields returns the columns, other than those in the fingerprint and
+// the per-run IDs and timestamps, whose values differ between a and b.
+func changedTransactionFields(a, b *bigquery.TransactionRow) []string {
+	var fields []string
+	for _, f := range []struct {
+		name  string
+		equal bool
+	}{
+		{"account_id", a.AccountID == b.AccountID},
+		{"posting_date", a.PostingDate == b.PostingDate},
+		{"booking_datetime", a.BookingDatetime == b.BookingDatetime},
+		{"balance_after", ratsEqual(a.BalanceAfter, b.BalanceAfter)},
+		{"original_amount", ratsEqual(a.OriginalAmount, b.OriginalAmount)},
+		{"original_currency", a.OriginalCurrency == b.OriginalCurrency},
+		{"direction", a.Direction == b.Direction},
+		{"normalized_description", a.NormalizedDescription == b.NormalizedDescription},
+		{"category_name", a.CategoryName == b.CategoryName},
+		{"subcategory_name", a.SubcategoryName == b.SubcategoryName},
+		{"statement_line_no", a.StatementLineNo == b.StatementLineNo},
+		{"statement_page_no", a.StatementPageNo == b.StatementPageNo},
+		{"is_pending", a.IsPending == b.IsPending},
+		{"is_refund", a.IsRefund == b.IsRefund},
+		{"is_internal_transfer", a.IsInternalTransfer == b.IsInternalTransfer},
+	} {
+		if !f.equal {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// ratsEqual reports whether a and b are both nil or hold the same value.
+func ratsEqual(a, b *big.Rat) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Cmp(b) == 0
+}
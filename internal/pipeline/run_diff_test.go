@@ -0,0 +1,90 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func diffTx(runID string, day int, amount int64, description, category string) *bigquery.TransactionRow {
+	return &bigquery.TransactionRow{
+		TransactionID:   runID + "-" + description,
+		ParsingRunID:    runID,
+		TransactionDate: civil.Date{Year: 2024, Month: 3, Day: day},
+		Amount:          big.NewRat(amount, 1),
+		Currency:        "GBP",
+		RawDescription:  description,
+		CategoryName:    bigquerylib.NullString{StringVal: category, Valid: true},
+	}
+}
+
+func TestDiffParsingRuns(t *testing.T) {
+	runs := map[string][]*bigquery.TransactionRow{
+		"run-old": {
+			diffTx("run-old", 1, -3, "COFFEE", "Food"),
+			diffTx("run-old", 1, -3, "COFFEE", "Food"),
+			diffTx("run-old", 2, -50, "TESCO", "Other"),
+			diffTx("run-old", 3, -10, "PHANTOM", "Other"),
+		},
+		"run-new": {
+			diffTx("run-new", 1, -3, "COFFEE", "Food"),
+			diffTx("run-new", 2, -50, "TESCO", "Groceries"),
+			diffTx("run-new", 4, 1000, "SALARY", "Income"),
+		},
+	}
+
+	var queries []bigquery.TransactionQuery
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		FindParsingRunFunc: func(ctx context.Context, parsingRunID string) (*bigquery.ParsingRunRow, error) {
+			if _, ok := runs[parsingRunID]; !ok {
+				return nil, nil
+			}
+			return &bigquery.ParsingRunRow{ParsingRunID: parsingRunID, DocumentID: "doc-1"}, nil
+		},
+		QueryTransactionsFunc: func(ctx context.Context, q bigquery.TransactionQuery) ([]*bigquery.TransactionRow, error) {
+			queries = append(queries, q)
+			return runs[q.ParsingRunID], nil
+		},
+	}}
+
+	diff, err := pipeline.DiffParsingRuns(context.Background(), repo, "doc-1", "run-old", "run-new")
+	if err != nil {
+		t.Fatalf("DiffParsingRuns: %v", err)
+	}
+
+	if len(queries) != 2 || queries[0].DocumentID != "doc-1" || queries[1].ParsingRunID != "run-new" {
+		t.Errorf("queries = %+v, want one per run of doc-1", queries)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", diff.Unchanged)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].RawDescription != "SALARY" {
+		t.Errorf("added = %+v, want SALARY", diff.Added)
+	}
+	// One of the two identical coffees is gone
+	if len(diff.Removed) != 2 || diff.Removed[0].RawDescription != "COFFEE" || diff.Removed[1].RawDescription != "PHANTOM" {
+		t.Errorf("removed = %+v, want COFFEE and PHANTOM", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || !reflect.DeepEqual(diff.Changed[0].Fields, []string{"category_name"}) {
+		t.Fatalf("changed = %+v, want TESCO with a new category", diff.Changed)
+	}
+	if diff.Changed[0].From.ParsingRunID != "run-old" || diff.Changed[0].To.ParsingRunID != "run-new" {
+		t.Errorf("changed pair = %s -> %s, want run-old -> run-new", diff.Changed[0].From.ParsingRunID, diff.Changed[0].To.ParsingRunID)
+	}
+
+	for _, runID := range []string{"missing", ""} {
+		if _, err := pipeline.DiffParsingRuns(context.Background(), repo, "doc-1", "run-old", runID); !errors.Is(err, pipeline.ErrParsingRunNotFound) {
+			t.Errorf("run %q: err = %v, want ErrParsingRunNotFound", runID, err)
+		}
+	}
+	if _, err := pipeline.DiffParsingRuns(context.Background(), repo, "doc-2", "run-old", "run-new"); !errors.Is(err, pipeline.ErrParsingRunNotFound) {
+		t.Errorf("other document: err = %v, want ErrParsingRunNotFound", err)
+	}
+}
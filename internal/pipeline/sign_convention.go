@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// Sign conventions describe how a bank's statements show whether money went in or out.
+const (
+	// SignConventionSigned statements print outgoing amounts with a minus sign, as
+	// Barclays does. It is the default.
+	SignConventionSigned = "signed"
+
+	// SignConventionColumns statements print unsigned amounts in separate "paid in" and
+	// "paid out" columns.
+	SignConventionColumns = "columns"
+
+	// SignConventionCreditCard statements print charges as positive amounts and mark
+	// payments and refunds with "CR" or a minus sign.
+	SignConventionCreditCard = "credit_card"
+)
+
+// SignConventionsEnv names the environment variable overriding the sign conventions of
+// institutions, as comma-separated INSTITUTION=convention pairs, e.g.
+// "HSBC=columns,AMEX=credit_card".
+const SignConventionsEnv = "AMOUNT_SIGN_CONVENTIONS"
+
+// SignCorrectionEnv names the environment variable choosing when amount signs are
+// corrected from the running balance: SignCorrectionAuto (the default), SignCorrectionAlways
+// or SignCorrectionNever.
+const SignCorrectionEnv = "AMOUNT_SIGN_CORRECTION"
+
+// Sign correction modes.
+const (
+	// SignCorrectionAuto corrects statements of institutions whose convention is not
+	// SignConventionSigned, where the model most often gets signs wrong.
+	SignCorrectionAuto = "auto"
+	// SignCorrectionAlways corrects every statement.
+	SignCorrectionAlways = "always"
+	// SignCorrectionNever leaves the signs the model returned.
+	SignCorrectionNever = "never"
+)
+
+// validSignConvention reports whether c is a known sign convention.
+func validSignConvention(c string) bool {
+	switch c {
+	case SignConventionSigned, SignConventionColumns, SignConventionCreditCard:
+		return true
+	}
+	return false
+}
+
+// signConventionFor returns the sign convention of institutionID: its override in
+// SignConventionsEnv, else the convention of the known institution, else
+// SignConventionSigned.
+func signConventionFor(institutionID string) (string, error) {
+	id := strings.ToUpper(strings.TrimSpace(institutionID))
+
+	if v := os.Getenv(SignConventionsEnv); v != "" {
+		for _, pair := range strings.Split(v, ",") {
+			name, convention, ok := strings.Cut(pair, "=")
+			convention = strings.ToLower(strings.TrimSpace(convention))
+			if !ok || !validSignConvention(convention) {
+				return "", fmt.Errorf("invalid %s entry %q: want INSTITUTION=%s|%s|%s",
+					SignConventionsEnv, strings.TrimSpace(pair), SignConventionSigned, SignConventionColumns, SignConventionCreditCard)
+			}
+			if id != "" && strings.ToUpper(strings.TrimSpace(name)) == id {
+				return convention, nil
+			}
+		}
+	}
+
+	if inst, ok := LookupInstitution(id); ok && inst.SignConvention != "" {
+		return inst.SignConvention, nil
+	}
+	return SignConventionSigned, nil
+}
+
+// signCorrectionModeFromEnv returns the configured sign correction mode.
+func signCorrectionModeFromEnv() (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(SignCorrectionEnv))); v {
+	case "":
+		return SignCorrectionAuto, nil
+	case SignCorrectionAuto, SignCorrectionAlways, SignCorrectionNever:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %s, %s or %s",
+			SignCorrectionEnv, v, SignCorrectionAuto, SignCorrectionAlways, SignCorrectionNever)
+	}
+}
+
+// signConventionInstruction tells the model how the statement shows the direction of
+// amounts, so it returns them signed as the schema asks.
+func signConventionInstruction(convention string) string {
+	switch convention {
+	case SignConventionColumns:
+		return "The statement prints amounts without a sign in separate \"paid in\" and \"paid out\" columns: " +
+			"amounts in the paid-out (debit) column are negative, amounts in the paid-in (credit) column are positive.\n"
+	case SignConventionCreditCard:
+		return "This is a credit card statement: charges printed as positive amounts are money OUT (negative), " +
+			"payments and refunds marked \"CR\" or with a minus sign are money IN (positive).\n"
+	default:
+		return ""
+	}
+}
+
+// correctAmountSigns flips the sign of transactions whose amount has the wrong sign
+// according to the running balance: if the balance went up by the amount, money came
+// in. Only transactions whose balance and previous balance are both known are checked;
+// opening is the balance before the first transaction, nil if unknown. It returns the
+// number of corrected transactions.
+func correctAmountSigns(txs []*Transaction, opening *big.Rat) int {
+	if len(txs) == 0 {
+		return 0
+	}
+
+	// Statements list either oldest or newest first; walk them oldest first
+	ordered := txs
+	if txs[len(txs)-1].Date.Before(txs[0].Date) {
+		ordered = make([]*Transaction, len(txs))
+		for i, t := range txs {
+			ordered[len(txs)-1-i] = t
+		}
+	}
+
+	corrected := 0
+	prev := opening
+	for _, t := range ordered {
+		if prev != nil && t.BalanceAfter != nil && t.Amount != nil && t.Amount.Sign() != 0 {
+			delta := new(big.Rat).Sub(t.BalanceAfter, prev)
+			if delta.Cmp(new(big.Rat).Neg(t.Amount)) == 0 {
+				t.Amount.Neg(t.Amount)
+				if t.OriginalAmount != nil {
+					t.OriginalAmount.Neg(t.OriginalAmount)
+				}
+				corrected++
+			}
+		}
+		prev = t.BalanceAfter
+	}
+	return corrected
+}
+
+// CorrectAmountSignsStep fixes amounts whose sign disagrees with the running balance,
+// overriding the model where balances are printed. SignCorrectionEnv decides for which
+// statements it runs.
+type CorrectAmountSignsStep struct{}
+
+func (s *CorrectAmountSignsStep) Name() string {
+	return "CorrectAmountSigns"
+}
+
+func (s *CorrectAmountSignsStep) Execute(ctx context.Context, state *PipelineState) error {
+	mode, err := signCorrectionModeFromEnv()
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, fmt.Errorf("CorrectAmountSigns: %w", err))
+	}
+	convention, err := signConventionFor(state.InstitutionID)
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, fmt.Errorf("CorrectAmountSigns: %w", err))
+	}
+	if mode == SignCorrectionNever || (mode == SignCorrectionAuto && convention == SignConventionSigned) {
+		return nil
+	}
+
+	if n := correctAmountSigns(state.Transactions, state.OpeningBalance); n > 0 {
+		log := logger.FromContext(ctx)
+		log.Info().
+			Str("document_id", state.DocumentID).
+			Str("sign_convention", convention).
+			Int("corrected", n).
+			Msg("Corrected amount signs from the running balance")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignConventionFor(t *testing.T) {
+	t.Setenv(SignConventionsEnv, "hsbc=columns, AMEX=credit_card")
+
+	tests := []struct {
+		institutionID string
+		want          string
+	}{
+		{"HSBC", SignConventionColumns},
+		{"amex", SignConventionCreditCard},
+		{"BARCLAYS", SignConventionSigned},
+		{"", SignConventionSigned},
+	}
+	for _, tt := range tests {
+		got, err := signConventionFor(tt.institutionID)
+		if err != nil {
+			t.Fatalf("signConventionFor(%q): %v", tt.institutionID, err)
+		}
+		if got != tt.want {
+			t.Errorf("signConventionFor(%q) = %s, want %s", tt.institutionID, got, tt.want)
+		}
+	}
+
+	t.Setenv(SignConventionsEnv, "HSBC=backwards")
+	if _, err := signConventionFor("HSBC"); err == nil {
+		t.Error("expected error for unknown convention")
+	}
+}
+
+func TestBuildStatementPromptSignConvention(t *testing.T) {
+	t.Setenv(SignConventionsEnv, "HSBC=columns")
+
+	prompt, err := buildStatementPrompt("CATEGORIES", "HSBC")
+	if err != nil {
+		t.Fatalf("buildStatementPrompt: %v", err)
+	}
+	if !strings.Contains(prompt, "paid-out (debit) column are negative") {
+		t.Error("prompt for a columns institution lacks the sign instruction")
+	}
+
+	prompt, err = buildStatementPrompt("CATEGORIES", "BARCLAYS")
+	if err != nil {
+		t.Fatalf("buildStatementPrompt: %v", err)
+	}
+	if strings.Contains(prompt, "paid-out (debit)") {
+		t.Error("prompt for a signed institution has the columns instruction")
+	}
+}
+
+func TestCorrectAmountSigns(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	rat := func(s string) *big.Rat {
+		r, _ := new(big.Rat).SetString(s)
+		return r
+	}
+
+	t.Run("oldest first", func(t *testing.T) {
+		txs := []*Transaction{
+			{Date: day(1), Amount: rat("-50"), BalanceAfter: rat("150")},                           // salary read as OUT
+			{Date: day(2), Amount: rat("20"), BalanceAfter: rat("130"), OriginalAmount: rat("23")}, // card payment read as IN
+			{Date: day(3), Amount: rat("-10"), BalanceAfter: rat("120")},                           // correct
+			{Date: day(4), Amount: rat("5")},                                                       // no balance
+			{Date: day(5), Amount: rat("-7"), BalanceAfter: rat("132")},                            // previous balance unknown
+		}
+		if n := correctAmountSigns(txs, rat("100")); n != 2 {
+			t.Errorf("corrected %d, want 2", n)
+		}
+		for i, want := range []string{"50", "-20", "-10", "5", "-7"} {
+			if txs[i].Amount.Cmp(rat(want)) != 0 {
+				t.Errorf("transaction %d amount = %s, want %s", i, txs[i].Amount.FloatString(2), want)
+			}
+		}
+		if txs[1].OriginalAmount.Cmp(rat("-23")) != 0 {
+			t.Errorf("original amount = %s, want -23", txs[1].OriginalAmount.FloatString(2))
+		}
+	})
+
+	t.Run("newest first", func(t *testing.T) {
+		txs := []*Transaction{
+			{Date: day(3), Amount: rat("30"), BalanceAfter: rat("40")},
+			{Date: day(2), Amount: rat("-30"), BalanceAfter: rat("70")},
+		}
+		if n := correctAmountSigns(txs, nil); n != 1 {
+			t.Errorf("corrected %d, want 1", n)
+		}
+		if txs[0].Amount.Cmp(rat("-30")) != 0 || txs[1].Amount.Cmp(rat("-30")) != 0 {
+			t.Errorf("amounts = %s, %s; want -30, -30", txs[0].Amount.FloatString(2), txs[1].Amount.FloatString(2))
+		}
+	})
+}
+
+func TestCorrectAmountSignsStepModes(t *testing.T) {
+	newState := func(institutionID string) *PipelineState {
+		return &PipelineState{
+			InstitutionID:  institutionID,
+			OpeningBalance: big.NewRat(100, 1),
+			Transactions:   []*Transaction{{Amount: big.NewRat(-50, 1), BalanceAfter: big.NewRat(150, 1)}},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		mode          string
+		institutionID string
+		wantFlipped   bool
+	}{
+		{name: "auto skips signed", institutionID: "BARCLAYS"},
+		{name: "auto corrects columns", institutionID: "HSBC", wantFlipped: true},
+		{name: "always", mode: SignCorrectionAlways, institutionID: "BARCLAYS", wantFlipped: true},
+		{name: "never", mode: SignCorrectionNever, institutionID: "HSBC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SignConventionsEnv, "HSBC=columns")
+			t.Setenv(SignCorrectionEnv, tt.mode)
+
+			state := newState(tt.institutionID)
+			if err := (&CorrectAmountSignsStep{}).Execute(context.Background(), state); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if flipped := state.Transactions[0].Amount.Sign() > 0; flipped != tt.wantFlipped {
+				t.Errorf("flipped = %v, want %v", flipped, tt.wantFlipped)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package pipeline
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
-	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
 )
 
 // PipelineStep represents a single step in the ingestion pipeline.
@@ -26,19 +29,50 @@ type PipelineState struct {
 	Transactions   []*Transaction
 	IsReparse      bool // True if we're re-parsing an existing document
 
+	// UserID owns the document; its categories, tag rules and accounts are used.
+	UserID string
+
+	// PDFPassword opens the PDF if it is password-protected.
+	PDFPassword string
+
+	// SourceParsingRunID is the parsing run whose stored model output is being reprocessed.
+	SourceParsingRunID string
+
 	// Account extraction results
 	ExtractedAccountInfo map[string]interface{} // Raw LLM output for account header
 	AccountID            string                 // Resolved/created account ID
+	UsedDefaultAccount   bool                   // True if AccountID is a document-scoped default account
+	StatementCurrency    string                 // Account currency, used for transactions that omit one
+	InstitutionID        string                 // Issuing bank given by the caller or detected, "" if not recognised
+	SourceSystem         string                 // source_system of a new document, DefaultSourceSystem if empty
+
+	// DocumentType and MimeType select the statement parser from Parsers; they are loaded
+	// from an existing document. Empty means DefaultDocumentType and the type detected
+	// from the file's content.
+	DocumentType string
+	MimeType     string
+
+	// Metadata is stored on the document; ReadPDFMetadataStep sets it.
+	Metadata *DocumentMetadata
+
+	// Balances printed on the statement, nil if not shown; StoreStatementBalancesStep sets them.
+	OpeningBalance *big.Rat
+	ClosingBalance *big.Rat
+
+	// ReviewReasons lists suspicious findings; the run is flagged for review if non-empty.
+	ReviewReasons []string
 
 	// Injected dependencies
 	DocumentRepo      bigquery.DocumentRepository
 	AccountRepo       bigquery.AccountRepository
 	StorageService    StorageService
 	AIParser          AIParser
+	Parsers           *ParserRegistry // Picks the statement parser; nil means AIParser
 	CategoryValidator *CategoryValidator
 }
 
-// Step 1: CreateDocumentStep creates a document record for the file.
+// Step 1: CreateDocumentStep creates a document record for the file, or loads the
+// existing document given by DocumentID.
 type CreateDocumentStep struct{}
 
 func (s *CreateDocumentStep) Name() string {
@@ -46,8 +80,15 @@ func (s *CreateDocumentStep) Name() string {
 }
 
 func (s *CreateDocumentStep) Execute(ctx context.Context, state *PipelineState) error {
-	// Skip if documentID is already provided (from upload)
+	// Load the document if documentID is already provided (from upload)
 	if state.DocumentID != "" {
+		doc, err := loadDocument(ctx, state.DocumentRepo, state.DocumentID)
+		if err != nil {
+			return fmt.Errorf("CreateDocument: %w", err)
+		}
+		state.UserID = documentUserID(doc)
+		state.DocumentType = doc.DocumentType
+		state.MimeType = doc.FileMimeType
 		return nil
 	}
 
@@ -55,21 +96,27 @@ func (s *CreateDocumentStep) Execute(ctx context.Context, state *PipelineState)
 	if state.Checksum != "" {
 		existingDoc, err := state.DocumentRepo.FindDocumentByChecksum(ctx, state.Checksum)
 		if err != nil {
-			return fmt.Errorf("CreateDocument: checking for duplicate: %w", err)
+			return classify(ErrStorage, fmt.Errorf("CreateDocument: checking for duplicate: %w", err))
 		}
 
 		if existingDoc != nil {
 			// Document already exists - reuse it
 			state.DocumentID = existingDoc.DocumentID
+			state.UserID = documentUserID(existingDoc)
+			state.DocumentType = existingDoc.DocumentType
+			state.MimeType = existingDoc.FileMimeType
 			state.IsReparse = true
 			return nil
 		}
 	}
 
 	// No duplicate found - create new document with checksum
-	documentID, err := createDocumentWithChecksumRepo(ctx, state.GCSURI, state.Checksum, state.DocumentRepo, state.StorageService)
+	if state.UserID == "" {
+		state.UserID = DefaultUserID
+	}
+	documentID, err := createDocumentWithChecksumRepo(ctx, state.GCSURI, state.Checksum, state.UserID, state.SourceSystem, state.InstitutionID, state.DocumentRepo, state.StorageService)
 	if err != nil {
-		return err
+		return classify(ErrStorage, err)
 	}
 	state.DocumentID = documentID
 	state.IsReparse = false
@@ -90,7 +137,7 @@ func (s *SupersedeOldParsingRunsStep) Execute(ctx context.Context, state *Pipeli
 	}
 
 	if err := state.DocumentRepo.MarkParsingRunsAsSuperseded(ctx, state.DocumentID); err != nil {
-		return fmt.Errorf("SupersedeOldParsingRuns: %w", err)
+		return classify(ErrStorage, fmt.Errorf("SupersedeOldParsingRuns: %w", err))
 	}
 	return nil
 }
@@ -105,13 +152,19 @@ func (s *StartParsingRunStep) Name() string {
 func (s *StartParsingRunStep) Execute(ctx context.Context, state *PipelineState) error {
 	parsingRunID, err := state.DocumentRepo.StartParsingRun(ctx, state.DocumentID)
 	if err != nil {
-		return err
+		return classify(ErrStorage, err)
 	}
 	state.ParsingRunID = parsingRunID
+
+	if err := transitionDocumentStatus(ctx, state, bigquery.DocumentStatusProcessing); err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return err
+	}
 	return nil
 }
 
-// Step 3: FetchPDFStep fetches the PDF bytes from GCS.
+// Step 3: FetchPDFStep fetches the PDF bytes from GCS and, unless paged parsing is
+// enabled, checks they are within the size limit.
 type FetchPDFStep struct{}
 
 func (s *FetchPDFStep) Name() string {
@@ -125,8 +178,27 @@ func (s *FetchPDFStep) Execute(ctx context.Context, state *PipelineState) error
 		if state.ParsingRunID != "" {
 			state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
 		}
-		return err
+		return classify(ErrStorage, err)
+	}
+
+	// Fail before the model call, which would reject an oversized PDF with an opaque
+	// error. With paged parsing the limit applies to each chunk, once it is split.
+	limit, err := maxPDFBytesFromEnv()
+	var perChunk int
+	if err == nil {
+		perChunk, err = pagesPerChunkFromEnv()
+	}
+	if err == nil && perChunk == 0 {
+		err = checkPDFSize(int64(len(pdfBytes)), limit)
+	}
+	if err != nil {
+		err = fmt.Errorf("FetchPDF: %w", err)
+		if state.ParsingRunID != "" {
+			state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		}
+		return classify(ErrValidation, err)
 	}
+
 	state.PDFBytes = pdfBytes
 	return nil
 }
@@ -140,7 +212,7 @@ func (s *CalculateChecksumStep) Name() string {
 
 func (s *CalculateChecksumStep) Execute(ctx context.Context, state *PipelineState) error {
 	if len(state.PDFBytes) == 0 {
-		return fmt.Errorf("CalculateChecksum: PDF bytes not available")
+		return fmt.Errorf("CalculateChecksum: %w: PDF bytes not available", ErrStorage)
 	}
 	// Calculate SHA-256 hash
 	hash := sha256.Sum256(state.PDFBytes)
@@ -156,15 +228,62 @@ func (s *ExtractAccountHeaderStep) Name() string {
 }
 
 func (s *ExtractAccountHeaderStep) Execute(ctx context.Context, state *PipelineState) error {
-	accountInfo, err := state.AIParser.ExtractAccountHeader(ctx, state.PDFBytes)
+	pdfBytes, err := headerPDF(state.PDFBytes)
 	if err != nil {
+		err = fmt.Errorf("ExtractAccountHeader: %w", err)
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrValidation, err)
+	}
+
+	accountInfo, err := state.AIParser.ExtractAccountHeader(ctx, pdfBytes)
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrParse, err)
 	}
 	state.ExtractedAccountInfo = accountInfo
 	return nil
 }
 
+// DetectInstitutionStep identifies the issuing bank from the extracted header,
+// records it on the document and selects the matching parsing prompt. An institution
+// given by the caller is recorded as is, without detection.
+type DetectInstitutionStep struct{}
+
+func (s *DetectInstitutionStep) Name() string {
+	return "DetectInstitution"
+}
+
+func (s *DetectInstitutionStep) Execute(ctx context.Context, state *PipelineState) error {
+	log := logger.FromContext(ctx)
+
+	source := "given"
+	if state.InstitutionID == "" {
+		inst, ok := detectInstitution(state.ExtractedAccountInfo)
+		if !ok {
+			log.Info().Str("document_id", state.DocumentID).Msg("Institution not recognised; using the default prompt")
+			return nil
+		}
+		state.InstitutionID = inst.ID
+		source = "detected"
+	}
+
+	if err := state.DocumentRepo.UpdateDocumentInstitution(ctx, state.DocumentID, state.InstitutionID); err != nil {
+		// The institution is still used for this parse
+		log.Warn().Err(err).
+			Str("document_id", state.DocumentID).
+			Str("institution_id", state.InstitutionID).
+			Msg("Failed to store institution")
+		return nil
+	}
+
+	log.Info().
+		Str("document_id", state.DocumentID).
+		Str("institution_id", state.InstitutionID).
+		Str("source", source).
+		Msg("Institution selected")
+	return nil
+}
+
 // Step 3c: UpsertAccountStep transforms account info and creates/finds account in BigQuery.
 type UpsertAccountStep struct{}
 
@@ -174,29 +293,40 @@ func (s *UpsertAccountStep) Name() string {
 
 func (s *UpsertAccountStep) Execute(ctx context.Context, state *PipelineState) error {
 	// Transform raw account info to AccountRow
-	accountRow, err := transformAccountInfo(state.ExtractedAccountInfo, state.DocumentID)
+	accountRow, err := transformAccountInfo(ctx, state.ExtractedAccountInfo, state.UserID, state.DocumentID)
 	if err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrParse, err)
 	}
 
 	// If extraction returned nothing useful, generate default account
 	if accountRow == nil {
-		accountRow = generateDefaultAccount(state.DocumentID)
+		currency, err := defaultCurrencyFromEnv()
+		if err != nil {
+			state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+			return classify(ErrValidation, fmt.Errorf("UpsertAccount: %w", err))
+		}
+		accountRow = generateDefaultAccount(state.UserID, state.DocumentID, currency)
+		state.UsedDefaultAccount = true
+	}
+	if state.InstitutionID != "" {
+		accountRow.InstitutionID = state.InstitutionID
 	}
 
 	// Upsert account (find existing or create new)
 	accountID, err := state.AccountRepo.UpsertAccount(ctx, accountRow)
 	if err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrStorage, err)
 	}
 
 	state.AccountID = accountID
+	state.StatementCurrency = accountRow.Currency
 	return nil
 }
 
-// Step 4: ParseStatementStep calls the statement parser (Gemini) with the PDF.
+// Step 4: ParseStatementStep calls the statement parser picked from the parser registry
+// (Gemini for PDFs by default) with the file.
 type ParseStatementStep struct{}
 
 func (s *ParseStatementStep) Name() string {
@@ -204,10 +334,78 @@ func (s *ParseStatementStep) Name() string {
 }
 
 func (s *ParseStatementStep) Execute(ctx context.Context, state *PipelineState) error {
-	rawModelOutput, err := state.AIParser.ParseStatement(ctx, state.PDFBytes)
+	perChunk, err := pagesPerChunkFromEnv()
 	if err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrValidation, fmt.Errorf("ParseStatement: %w", err))
+	}
+
+	concurrency, err := chunkConcurrencyFromEnv()
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, fmt.Errorf("ParseStatement: %w", err))
+	}
+
+	limit, err := maxPDFBytesFromEnv()
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, fmt.Errorf("ParseStatement: %w", err))
+	}
+
+	parser, err := state.statementParser()
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, fmt.Errorf("ParseStatement: %w", err))
+	}
+
+	var rawModelOutput map[string]interface{}
+	paged, canPage := parser.(PagedAIParser)
+	pageCount := 0
+	if state.Metadata != nil {
+		pageCount = state.Metadata.PageCount
+	} else {
+		pageCount = pdfdoc.PageCount(state.PDFBytes)
+	}
+	log := logger.FromContext(ctx)
+	var ranges []PageRange
+	var chunks [][]byte
+	if canPage && perChunk > 0 && pageCount > perChunk {
+		ranges = splitPageRanges(pageCount, perChunk)
+		chunks, err = splitPDFPages(state.PDFBytes, ranges)
+		if err != nil {
+			// Still parseable, just in one call that may be truncated
+			log.Warn().Err(err).
+				Str("document_id", state.DocumentID).
+				Msg("Cannot split statement into page chunks, parsing it in one call")
+			chunks = nil
+		}
+	}
+	// Each model call is checked against the size limit, a chunk at a time when paged
+	if chunks != nil {
+		err = checkChunkSizes(chunks, ranges, limit)
+	} else {
+		err = checkPDFSize(int64(len(state.PDFBytes)), limit)
+	}
+	if err != nil {
+		err = fmt.Errorf("ParseStatement: %w", err)
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrValidation, err)
+	}
+
+	if chunks != nil {
+		log.Info().
+			Str("document_id", state.DocumentID).
+			Int("pages", pageCount).
+			Int("chunks", len(ranges)).
+			Int("concurrency", concurrency).
+			Msg("Parsing statement in page chunks")
+		rawModelOutput, err = parseStatementInChunks(ctx, paged, chunks, state.UserID, state.InstitutionID, ranges, concurrency)
+	} else {
+		rawModelOutput, err = parser.ParseStatement(ctx, state.PDFBytes, state.UserID, state.InstitutionID)
+	}
+	if err != nil {
+		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+		return classify(ErrParse, err)
 	}
 	state.RawModelOutput = rawModelOutput
 	return nil
@@ -224,7 +422,7 @@ func (s *StoreModelOutputStep) Execute(ctx context.Context, state *PipelineState
 	_, err := storeModelOutputWithRepo(ctx, state.ParsingRunID, state.DocumentID, state.RawModelOutput, state.DocumentRepo)
 	if err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrStorage, err)
 	}
 	return nil
 }
@@ -237,16 +435,27 @@ func (s *TransformTransactionsStep) Name() string {
 }
 
 func (s *TransformTransactionsStep) Execute(ctx context.Context, state *PipelineState) error {
-	txs, err := transformModelOutputToTransactions(state.RawModelOutput)
+	fallbackCurrency := state.StatementCurrency
+	if fallbackCurrency == "" {
+		currency, err := defaultCurrencyFromEnv()
+		if err != nil {
+			state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
+			return classify(ErrValidation, fmt.Errorf("TransformTransactions: %w", err))
+		}
+		fallbackCurrency = currency
+	}
+
+	txs, err := transformModelOutputToTransactions(ctx, state.RawModelOutput, fallbackCurrency)
 	if err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrParse, err)
 	}
 	state.Transactions = txs
 	return nil
 }
 
-// Step 6a: CreateCategoryValidatorStep creates a category validator from the taxonomy.
+// Step 6a: CreateCategoryValidatorStep creates a category validator from the taxonomy
+// of the document's user.
 type CreateCategoryValidatorStep struct{}
 
 func (s *CreateCategoryValidatorStep) Name() string {
@@ -254,9 +463,9 @@ func (s *CreateCategoryValidatorStep) Name() string {
 }
 
 func (s *CreateCategoryValidatorStep) Execute(ctx context.Context, state *PipelineState) error {
-	validator, err := NewCategoryValidator(ctx, state.DocumentRepo)
+	validator, err := NewCategoryValidator(ctx, state.DocumentRepo, state.UserID)
 	if err != nil {
-		return fmt.Errorf("CreateCategoryValidator: %w", err)
+		return classify(ErrStorage, fmt.Errorf("CreateCategoryValidator: %w", err))
 	}
 	state.CategoryValidator = validator
 	return nil
@@ -291,7 +500,7 @@ func (s *ValidateCategoriesStep) Execute(ctx context.Context, state *PipelineSta
 		err := fmt.Errorf("category validation failed:\n  - %s",
 			fmt.Sprintf("%v", validationErrors))
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrValidation, err)
 	}
 
 	return nil
@@ -305,9 +514,9 @@ func (s *InsertTransactionsStep) Name() string {
 }
 
 func (s *InsertTransactionsStep) Execute(ctx context.Context, state *PipelineState) error {
-	if err := insertTransactionsWithRepo(ctx, state.DocumentID, state.ParsingRunID, state.AccountID, state.Transactions, state.DocumentRepo); err != nil {
+	if err := insertTransactionsWithRepo(ctx, state.UserID, state.DocumentID, state.ParsingRunID, state.AccountID, state.Transactions, state.DocumentRepo); err != nil {
 		state.DocumentRepo.MarkParsingRunFailed(ctx, state.ParsingRunID, err)
-		return err
+		return classify(ErrStorage, err)
 	}
 	return nil
 }
@@ -321,17 +530,75 @@ func (s *MarkSuccessStep) Name() string {
 
 func (s *MarkSuccessStep) Execute(ctx context.Context, state *PipelineState) error {
 	if err := state.DocumentRepo.MarkParsingRunSucceeded(ctx, state.ParsingRunID); err != nil {
-		return err
+		return classify(ErrStorage, err)
+	}
+
+	return transitionDocumentStatus(ctx, state, bigquery.DocumentStatusCompleted)
+}
+
+// Step 9: MergeDefaultAccountStep folds a document's earlier default account into the
+// real account resolved by this parse, so transactions are not fragmented across both.
+// It runs after the parse succeeded, so a failed parse leaves the accounts unchanged.
+type MergeDefaultAccountStep struct{}
+
+func (s *MergeDefaultAccountStep) Name() string {
+	return "MergeDefaultAccount"
+}
+
+func (s *MergeDefaultAccountStep) Execute(ctx context.Context, state *PipelineState) error {
+	if state.UsedDefaultAccount || state.AccountID == "" {
+		return nil
+	}
+
+	log := logger.FromContext(ctx)
+	currency, err := defaultCurrencyFromEnv()
+	if err != nil {
+		log.Warn().Err(err).Str("document_id", state.DocumentID).Msg("Failed to look up default account for merge")
+		return nil
+	}
+	defaultAccount := generateDefaultAccount(state.UserID, state.DocumentID, currency)
+
+	existing, err := state.AccountRepo.FindAccountByNumberAndCurrency(ctx, defaultAccount.AccountNumber, defaultAccount.Currency)
+	if err != nil {
+		// Cleanup only - never fail ingestion because of it
+		log.Warn().Err(err).Str("document_id", state.DocumentID).Msg("Failed to look up default account for merge")
+		return nil
+	}
+	if existing == nil || existing.AccountID == state.AccountID || existing.ClosedDate.Valid {
+		return nil
 	}
 
-	// Update document status to COMPLETED
-	if err := infraBQ.UpdateDocumentParsingStatus(ctx, state.DocumentID, "COMPLETED"); err != nil {
-		return fmt.Errorf("updating document status: %w", err)
+	if err := state.AccountRepo.MergeAccount(ctx, existing.AccountID, state.AccountID); err != nil {
+		log.Warn().Err(err).
+			Str("document_id", state.DocumentID).
+			Str("from_account_id", existing.AccountID).
+			Str("to_account_id", state.AccountID).
+			Msg("Failed to merge default account")
+		return nil
 	}
 
+	log.Info().
+		Str("document_id", state.DocumentID).
+		Str("from_account_id", existing.AccountID).
+		Str("to_account_id", state.AccountID).
+		Msg("Merged default account into extracted account")
 	return nil
 }
 
+// transitionDocumentStatus moves the document to the given status. A transition its
+// current status does not allow is a validation error, as retrying cannot fix it.
+func transitionDocumentStatus(ctx context.Context, state *PipelineState, to bigquery.DocumentStatus) error {
+	err := state.DocumentRepo.TransitionDocumentStatus(ctx, state.DocumentID, to)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("updating document status to %s: %w", to, err)
+	if errors.Is(err, bigquery.ErrInvalidStatusTransition) {
+		return classify(ErrValidation, err)
+	}
+	return classify(ErrStorage, err)
+}
+
 // Pipeline executes a sequence of steps in order.
 type Pipeline struct {
 	steps []PipelineStep
@@ -346,7 +613,7 @@ func NewPipeline(steps ...PipelineStep) *Pipeline {
 func (p *Pipeline) Execute(ctx context.Context, state *PipelineState) error {
 	for i, step := range p.steps {
 		if err := step.Execute(ctx, state); err != nil {
-			return fmt.Errorf("pipeline step %d (%s) failed: %w", i+1, step.Name(), err)
+			return &StepError{Index: i + 1, Step: step.Name(), Err: err}
 		}
 	}
 	return nil
@@ -357,17 +624,31 @@ func NewStatementIngestionPipeline() *Pipeline {
 	return NewPipeline(
 		&FetchPDFStep{},
 		&CalculateChecksumStep{},
+		&DecryptPDFStep{},
+		&ReadPDFMetadataStep{},
 		&CreateDocumentStep{},
 		&SupersedeOldParsingRunsStep{},
 		&StartParsingRunStep{},
 		&ExtractAccountHeaderStep{},
+		&DetectInstitutionStep{},
+		&StoreDocumentMetadataStep{},
+		&StoreStatementBalancesStep{},
 		&UpsertAccountStep{},
 		&ParseStatementStep{},
 		&StoreModelOutputStep{},
+		&ValidateModelOutputStep{},
 		&TransformTransactionsStep{},
+		&CheckTransactionCountStep{},
+		&CheckStatementOrderStep{},
+		&CorrectAmountSignsStep{},
+		&CheckBalanceContinuityStep{},
+		&HandleZeroAmountsStep{},
+		&ApplyTagRulesStep{},
 		&CreateCategoryValidatorStep{},
 		&ValidateCategoriesStep{},
 		&InsertTransactionsStep{},
+		&FlagForReviewStep{},
 		&MarkSuccessStep{},
+		&MergeDefaultAccountStep{},
 	)
 }
@@ -0,0 +1,457 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/pdfdoc"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+)
+
+func TestMergeDefaultAccountStep(t *testing.T) {
+	const documentID = "abcdef12-3456-7890-abcd-ef1234567890"
+
+	tests := []struct {
+		name           string
+		usedDefault    bool
+		existing       *bigquery.AccountRow
+		wantMergedFrom string
+	}{
+		{
+			name:           "merges open default account into extracted account",
+			existing:       &bigquery.AccountRow{AccountID: "default-acc"},
+			wantMergedFrom: "default-acc",
+		},
+		{
+			name:        "skips when this parse used the default account",
+			usedDefault: true,
+			existing:    &bigquery.AccountRow{AccountID: "default-acc"},
+		},
+		{
+			name: "skips when no default account exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookedUp, mergedFrom, mergedTo string
+			accountRepo := &MockAccountRepository{
+				FindAccountByNumberAndCurrencyFunc: func(ctx context.Context, accountNumber, currency string) (*bigquery.AccountRow, error) {
+					lookedUp = accountNumber
+					return tt.existing, nil
+				},
+				MergeAccountFunc: func(ctx context.Context, fromAccountID, toAccountID string) error {
+					mergedFrom, mergedTo = fromAccountID, toAccountID
+					return nil
+				},
+			}
+
+			state := &pipeline.PipelineState{
+				DocumentID:         documentID,
+				AccountID:          "real-acc",
+				UsedDefaultAccount: tt.usedDefault,
+				AccountRepo:        accountRepo,
+			}
+
+			if err := (&pipeline.MergeDefaultAccountStep{}).Execute(context.Background(), state); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tt.usedDefault && lookedUp != "DOC-abcdef12" {
+				t.Errorf("looked up account %q, want DOC-abcdef12", lookedUp)
+			}
+			if mergedFrom != tt.wantMergedFrom {
+				t.Errorf("merged from %q, want %q", mergedFrom, tt.wantMergedFrom)
+			}
+			if tt.wantMergedFrom != "" && mergedTo != "real-acc" {
+				t.Errorf("merged into %q, want real-acc", mergedTo)
+			}
+		})
+	}
+}
+
+func TestMergeDefaultAccountStep_NotRunWhenParseFails(t *testing.T) {
+	merged := false
+	accounts := &MockAccountRepository{
+		UpsertAccountFunc: func(ctx context.Context, row *bigquery.AccountRow) (string, error) {
+			return "real-acc", nil
+		},
+		FindAccountByNumberAndCurrencyFunc: func(ctx context.Context, accountNumber, currency string) (*bigquery.AccountRow, error) {
+			return &bigquery.AccountRow{AccountID: "default-acc"}, nil
+		},
+		MergeAccountFunc: func(ctx context.Context, fromAccountID, toAccountID string) error {
+			merged = true
+			return nil
+		},
+	}
+	storage := &MockStorageService{
+		FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+			return []byte("mock pdf data"), nil
+		},
+	}
+	parser := &MockAIParser{
+		ExtractAccountHeaderFunc: func(ctx context.Context, pdfBytes []byte) (map[string]interface{}, error) {
+			return map[string]interface{}{"account_number": "12345678", "sort_code": "20-00-00", "currency": "GBP"}, nil
+		},
+		ParseStatementFunc: func(ctx context.Context, pdfBytes []byte, userID, institutionID string) (map[string]interface{}, error) {
+			return nil, errors.New("model returned invalid JSON")
+		},
+	}
+
+	err := pipeline.IngestStatementFromGCSWithDeps(context.Background(), "gs://test-bucket/test.pdf", "abcdef12-3456-7890-abcd-ef1234567890",
+		&mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}}, accounts, storage, parser)
+	if err == nil {
+		t.Fatal("expected the parse to fail")
+	}
+	if merged {
+		t.Error("default account was merged although the parse failed")
+	}
+}
+
+func TestDetectInstitutionStep(t *testing.T) {
+	var storedDoc, storedInstitution string
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		UpdateDocumentInstitutionFunc: func(ctx context.Context, documentID, institutionID string) error {
+			storedDoc, storedInstitution = documentID, institutionID
+			return nil
+		},
+	}}
+
+	state := &pipeline.PipelineState{
+		DocumentID:           "doc-12345678",
+		ExtractedAccountInfo: map[string]interface{}{"institution_id": "HSBC UK Bank plc"},
+		DocumentRepo:         repo,
+	}
+	if err := (&pipeline.DetectInstitutionStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if state.InstitutionID != "HSBC" {
+		t.Errorf("InstitutionID = %q, want HSBC", state.InstitutionID)
+	}
+	if storedDoc != "doc-12345678" || storedInstitution != "HSBC" {
+		t.Errorf("stored institution %q for %q, want HSBC for doc-12345678", storedInstitution, storedDoc)
+	}
+}
+
+func TestDetectInstitutionStep_GivenInstitution(t *testing.T) {
+	var storedInstitution string
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		UpdateDocumentInstitutionFunc: func(ctx context.Context, documentID, institutionID string) error {
+			storedInstitution = institutionID
+			return nil
+		},
+	}}
+
+	// The caller's institution wins over the one in the header
+	state := &pipeline.PipelineState{
+		DocumentID:           "doc-12345678",
+		InstitutionID:        "MONZO",
+		ExtractedAccountInfo: map[string]interface{}{"institution_id": "HSBC UK Bank plc"},
+		DocumentRepo:         repo,
+	}
+	if err := (&pipeline.DetectInstitutionStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.InstitutionID != "MONZO" || storedInstitution != "MONZO" {
+		t.Errorf("InstitutionID = %q, stored %q; want MONZO", state.InstitutionID, storedInstitution)
+	}
+}
+
+func TestDocumentMetadataSteps(t *testing.T) {
+	var storedDoc, storedMetadata string
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		UpdateDocumentMetadataFunc: func(ctx context.Context, documentID string, metadata bigquerylib.NullJSON) error {
+			storedDoc, storedMetadata = documentID, metadata.JSONVal
+			return nil
+		},
+	}}
+
+	state := &pipeline.PipelineState{
+		DocumentID: "doc-12345678",
+		PDFBytes:   buildStatementPDF(2),
+		ExtractedAccountInfo: map[string]interface{}{
+			"statement_language":  "EN",
+			"statement_reference": "Statement 42",
+		},
+		DocumentRepo: repo,
+	}
+	ctx := context.Background()
+	if err := (&pipeline.ReadPDFMetadataStep{}).Execute(ctx, state); err != nil {
+		t.Fatalf("ReadPDFMetadata: %v", err)
+	}
+	if err := (&pipeline.StoreDocumentMetadataStep{}).Execute(ctx, state); err != nil {
+		t.Fatalf("StoreDocumentMetadata: %v", err)
+	}
+
+	want := fmt.Sprintf(`{"page_count":2,"language":"en","statement_reference":"Statement 42","model_name":%q}`, pipeline.DefaultModelName)
+	if storedDoc != "doc-12345678" || storedMetadata != want {
+		t.Errorf("stored %s for %q, want %s for doc-12345678", storedMetadata, storedDoc, want)
+	}
+}
+
+func TestInsertTransactionsStep_RetryAfterPartialInsert(t *testing.T) {
+	line := func(n int64) *int64 { return &n }
+	txs := []*pipeline.Transaction{
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Description: "Tesco", Amount: big.NewRat(-25, 2), Currency: "GBP", StatementLineNo: line(1)},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Description: "Salary", Amount: big.NewRat(2000, 1), Currency: "GBP", StatementLineNo: line(2)},
+		// Same content as the first one, on another line
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Description: "Tesco", Amount: big.NewRat(-25, 2), Currency: "GBP", StatementLineNo: line(3)},
+		{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Description: "Rent", Amount: big.NewRat(-900, 1), Currency: "GBP"},
+	}
+
+	// The table skips rows whose transaction_id exists, like the MERGE in the real repository
+	table := map[string]*bigquery.TransactionRow{}
+	failAfter := 2
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{}}
+	repo.InsertTransactionsFunc = func(ctx context.Context, rows interface{}) error {
+		for i, row := range rows.([]*bigquery.TransactionRow) {
+			if failAfter > 0 && i == failAfter {
+				failAfter = 0
+				return errors.New("connection reset")
+			}
+			if _, ok := table[row.TransactionID]; !ok {
+				table[row.TransactionID] = row
+			}
+		}
+		return nil
+	}
+
+	state := &pipeline.PipelineState{
+		DocumentID:   "doc-1",
+		ParsingRunID: "run-1",
+		AccountID:    "acc-1",
+		Transactions: txs,
+		DocumentRepo: repo,
+	}
+	step := &pipeline.InsertTransactionsStep{}
+	if err := step.Execute(context.Background(), state); err == nil {
+		t.Fatal("expected the first insert to fail")
+	}
+	if len(table) != 2 {
+		t.Fatalf("%d rows after the partial insert, want 2", len(table))
+	}
+
+	if err := step.Execute(context.Background(), state); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if len(table) != len(txs) {
+		t.Errorf("%d rows after the retry, want %d", len(table), len(txs))
+	}
+}
+
+func TestStartParsingRunStep_InvalidStatusTransition(t *testing.T) {
+	var failedRun string
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		StartParsingRunFunc: func(ctx context.Context, documentID string) (string, error) {
+			return "run-1", nil
+		},
+		MarkParsingRunFailedFunc: func(ctx context.Context, parsingRunID string, parseErr error) {
+			failedRun = parsingRunID
+		},
+		TransitionDocumentStatusFunc: func(ctx context.Context, documentID string, to bigquery.DocumentStatus) error {
+			return fmt.Errorf("document %s: %w", documentID, bigquery.ErrInvalidStatusTransition)
+		},
+	}}
+
+	state := &pipeline.PipelineState{DocumentID: "doc-1", DocumentRepo: repo}
+	err := (&pipeline.StartParsingRunStep{}).Execute(context.Background(), state)
+	if !errors.Is(err, bigquery.ErrInvalidStatusTransition) {
+		t.Fatalf("error = %v, want ErrInvalidStatusTransition", err)
+	}
+	if pipeline.IsRetryable(err) {
+		t.Error("invalid status transition should not be retryable")
+	}
+	if failedRun != "run-1" {
+		t.Errorf("marked run %q as failed, want run-1", failedRun)
+	}
+}
+
+func TestMarkSuccessStep_CompletesDocument(t *testing.T) {
+	var statuses []bigquery.DocumentStatus
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		TransitionDocumentStatusFunc: func(ctx context.Context, documentID string, to bigquery.DocumentStatus) error {
+			statuses = append(statuses, to)
+			return nil
+		},
+	}}
+
+	state := &pipeline.PipelineState{DocumentID: "doc-1", ParsingRunID: "run-1", DocumentRepo: repo}
+	if err := (&pipeline.MarkSuccessStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0] != bigquery.DocumentStatusCompleted {
+		t.Errorf("status transitions = %v, want [COMPLETED]", statuses)
+	}
+}
+
+func TestFetchPDFStep_TooLarge(t *testing.T) {
+	t.Setenv(pipeline.MaxPDFBytesEnv, "10")
+
+	var failedRun string
+	state := &pipeline.PipelineState{
+		ParsingRunID: "run-1",
+		StorageService: &MockStorageService{
+			FetchFromGCSFunc: func(ctx context.Context, gcsURI string) ([]byte, error) {
+				return []byte("0123456789a"), nil
+			},
+		},
+		DocumentRepo: &mockDocumentRepo{&MockDocumentRepository{
+			MarkParsingRunFailedFunc: func(ctx context.Context, parsingRunID string, parseErr error) {
+				failedRun = parsingRunID
+			},
+		}},
+	}
+
+	err := (&pipeline.FetchPDFStep{}).Execute(context.Background(), state)
+	if !errors.Is(err, pipeline.ErrPDFTooLarge) {
+		t.Fatalf("Execute() error = %v, want ErrPDFTooLarge", err)
+	}
+	if kind := pipeline.ErrorKind(err); kind != "validation" {
+		t.Errorf("ErrorKind() = %q, want validation", kind)
+	}
+	if failedRun != "run-1" {
+		t.Errorf("parsing run marked failed = %q, want run-1", failedRun)
+	}
+	if state.PDFBytes != nil {
+		t.Error("PDFBytes set for an oversized PDF")
+	}
+
+	t.Setenv(pipeline.MaxPDFBytesEnv, "0")
+	if err := (&pipeline.FetchPDFStep{}).Execute(context.Background(), state); err != nil {
+		t.Errorf("Execute() with the limit disabled: %v", err)
+	}
+
+	// Paged parses check each chunk instead, once the statement is split
+	t.Setenv(pipeline.MaxPDFBytesEnv, "10")
+	t.Setenv(pipeline.PagesPerChunkEnv, "2")
+	if err := (&pipeline.FetchPDFStep{}).Execute(context.Background(), state); err != nil {
+		t.Errorf("Execute() with paged parsing: %v", err)
+	}
+}
+
+func TestDecryptPDFStep(t *testing.T) {
+	// Three pages encrypted with 128-bit RC4 for the user password "statement-2024", with
+	// a logo on page 1: Flate-compressed, and a JPEG in the scan
+	encrypted, err := os.ReadFile("testdata/encrypted_statement.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan, err := os.ReadFile("testdata/encrypted_scan.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := buildStatementPDF(2)
+
+	tests := []struct {
+		name     string
+		pdf      []byte
+		password string
+		wantErr  error
+	}{
+		{name: "plain", pdf: plain},
+		{name: "no password", pdf: encrypted, wantErr: pipeline.ErrPDFPasswordRequired},
+		{name: "incorrect password", pdf: encrypted, password: "statement-2023", wantErr: pipeline.ErrPDFPasswordIncorrect},
+		{name: "correct password", pdf: encrypted, password: "statement-2024"},
+		{name: "JPEG image", pdf: scan, password: "statement-2024", wantErr: pipeline.ErrPDFEncryptedImages},
+		{name: "unreadable", pdf: []byte("%PDF-1.6\ntrailer\n<< /Size 9 /Root 1 0 R /Encrypt 8 0 R >>"), password: "statement-2024", wantErr: pipeline.ErrPDFEncrypted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &pipeline.PipelineState{
+				PDFBytes:     tt.pdf,
+				PDFPassword:  tt.password,
+				DocumentRepo: &mockDocumentRepo{&MockDocumentRepository{}},
+			}
+
+			err := (&pipeline.DecryptPDFStep{}).Execute(context.Background(), state)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, pipeline.ErrPDFEncrypted) {
+					t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+				}
+				if pipeline.ErrorKind(err) != "validation" {
+					t.Errorf("ErrorKind() = %q, want validation", pipeline.ErrorKind(err))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute(): %v", err)
+			}
+
+			doc, err := pdfdoc.Open(state.PDFBytes)
+			if err != nil {
+				t.Fatalf("opening the PDF without a password: %v", err)
+			}
+			if doc.Encrypted() {
+				t.Error("PDF is still encrypted")
+			}
+			if tt.name == "plain" && !bytes.Equal(state.PDFBytes, plain) {
+				t.Error("plain PDF was rewritten")
+			}
+			if tt.name == "correct password" && !bytes.Contains(state.PDFBytes, []byte("/Subtype /Image")) {
+				t.Error("decrypted PDF lost its image")
+			}
+		})
+	}
+}
+
+func TestValidateModelOutputStep_FailsAsParseError(t *testing.T) {
+	var failed error
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		MarkParsingRunFailedFunc: func(ctx context.Context, parsingRunID string, parseErr error) {
+			failed = parseErr
+		},
+	}}
+	state := &pipeline.PipelineState{
+		DocumentRepo:   repo,
+		RawModelOutput: map[string]interface{}{"transactions": []interface{}{map[string]interface{}{"amount": false}}},
+	}
+
+	err := (&pipeline.ValidateModelOutputStep{}).Execute(context.Background(), state)
+	if kind := pipeline.ErrorKind(err); kind != "parse" {
+		t.Fatalf("ErrorKind(%v) = %q, want parse", err, kind)
+	}
+	var moErr *pipeline.ModelOutputError
+	if !errors.As(err, &moErr) || len(moErr.Violations) != 2 {
+		t.Errorf("err = %v, want a *ModelOutputError with 2 violations", err)
+	}
+	if failed == nil {
+		t.Error("parsing run was not marked failed")
+	}
+}
+
+func TestCreateDocumentStep_ChecksumDocumentID(t *testing.T) {
+	t.Setenv(pipeline.DocumentIDModeEnv, pipeline.DocumentIDChecksum)
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	var inserted *bigquery.DocumentRow
+	repo := &mockDocumentRepo{MockDocumentRepository: &MockDocumentRepository{
+		InsertDocumentFunc: func(ctx context.Context, row interface{}) error {
+			inserted = row.(*bigquery.DocumentRow)
+			return nil
+		},
+	}}
+	state := &pipeline.PipelineState{
+		GCSURI:         "gs://bucket/statement.pdf",
+		Checksum:       checksum,
+		DocumentRepo:   repo,
+		StorageService: &MockStorageService{},
+	}
+
+	if err := (&pipeline.CreateDocumentStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := pipeline.DocumentIDFromChecksum(pipeline.DefaultUserID, checksum)
+	if state.DocumentID != want || inserted == nil || inserted.DocumentID != want {
+		t.Errorf("document ID = %q (inserted %+v), want %q", state.DocumentID, inserted, want)
+	}
+}
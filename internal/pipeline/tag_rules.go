@@ -0,0 +1,250 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// tagRule is a TagRuleRow ready for matching.
+type tagRule struct {
+	id       string
+	pattern  *regexp.Regexp // nil if the rule has no description pattern
+	merchant string         // lower-case; empty if the rule has no merchant
+	tags     []string
+	stop     bool
+}
+
+// ValidateTagRule checks a tag rule before it is stored: it needs a description pattern
+// that compiles or a merchant, and at least one tag. Tags are trimmed and deduplicated
+// in place.
+func ValidateTagRule(row *bigquery.TagRuleRow) error {
+	_, err := compileTagRule(row)
+	if err != nil {
+		return err
+	}
+	row.Tags = addTags(nil, row.Tags)
+	return nil
+}
+
+// compileTagRule turns row into a tagRule.
+func compileTagRule(row *bigquery.TagRuleRow) (*tagRule, error) {
+	rule := &tagRule{
+		id:       row.RuleID,
+		merchant: strings.ToLower(strings.TrimSpace(row.Merchant.StringVal)),
+		tags:     addTags(nil, row.Tags),
+		stop:     row.StopProcessing,
+	}
+	if pattern := strings.TrimSpace(row.DescriptionPattern.StringVal); pattern != "" {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid description pattern %q: %w", pattern, err)
+		}
+		rule.pattern = re
+	}
+	if rule.pattern == nil && rule.merchant == "" {
+		return nil, errors.New("a description pattern or merchant is required")
+	}
+	if len(rule.tags) == 0 {
+		return nil, errors.New("at least one tag is required")
+	}
+	return rule, nil
+}
+
+// compileTagRules compiles rows in the order they apply: by priority, then rule ID. Invalid
+// rules are left out and reported in the returned error.
+func compileTagRules(rows []bigquery.TagRuleRow) ([]*tagRule, error) {
+	sorted := append([]bigquery.TagRuleRow(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].RuleID < sorted[j].RuleID
+	})
+
+	var rules []*tagRule
+	var errs []error
+	for i := range sorted {
+		rule, err := compileTagRule(&sorted[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tag rule %s: %w", sorted[i].RuleID, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, errors.Join(errs...)
+}
+
+// matches reports whether a transaction with the given raw description and merchant (its
+// normalized description) meets every condition of the rule.
+func (r *tagRule) matches(description, merchant string) bool {
+	if r.pattern != nil && !r.pattern.MatchString(description) {
+		return false
+	}
+	if r.merchant != "" {
+		if merchant == "" {
+			merchant = description
+		}
+		if !strings.Contains(strings.ToLower(merchant), r.merchant) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsFor returns the tags rules give a transaction: those of every matching rule in
+// order, up to and including the first matching rule that stops processing.
+func tagsFor(rules []*tagRule, description, merchant string) []string {
+	var tags []string
+	for _, rule := range rules {
+		if !rule.matches(description, merchant) {
+			continue
+		}
+		tags = addTags(tags, rule.tags)
+		if rule.stop {
+			break
+		}
+	}
+	return tags
+}
+
+// addTags returns tags with the trimmed, non-empty extra tags it does not have yet
+// appended.
+func addTags(tags, extra []string) []string {
+	for _, tag := range extra {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		present := false
+		for _, t := range tags {
+			if t == tag {
+				present = true
+				break
+			}
+		}
+		if !present {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// loadTagRules returns the compiled tag rules of userID. Invalid rules are logged
+// and skipped.
+func loadTagRules(ctx context.Context, repo bigquery.DocumentRepository, userID string) ([]*tagRule, error) {
+	rows, err := repo.ListTagRules(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("loadTagRules: %w", err)
+	}
+	rules, err := compileTagRules(rows)
+	if err != nil {
+		log := logger.FromContext(ctx)
+		log.Warn().Err(err).Msg("Skipping invalid tag rules")
+	}
+	return rules, nil
+}
+
+// ApplyTagRulesStep adds the tags of the user's matching tag rules to the transactions.
+// Failing to load the rules is logged but does not fail the parse.
+type ApplyTagRulesStep struct{}
+
+func (s *ApplyTagRulesStep) Name() string {
+	return "ApplyTagRules"
+}
+
+func (s *ApplyTagRulesStep) Execute(ctx context.Context, state *PipelineState) error {
+	log := logger.FromContext(ctx)
+
+	rules, err := loadTagRules(ctx, state.DocumentRepo, state.UserID)
+	if err != nil {
+		log.Warn().Err(err).Str("document_id", state.DocumentID).Msg("Failed to load tag rules, transactions are not tagged")
+		return nil
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	tagged := 0
+	for _, t := range state.Transactions {
+		// Descriptions are stored as their own normalized form
+		if tags := tagsFor(rules, t.Description, t.Description); len(tags) > 0 {
+			t.Tags = addTags(t.Tags, tags)
+			tagged++
+		}
+	}
+	if tagged > 0 {
+		log.Info().Str("document_id", state.DocumentID).Int("tagged", tagged).Msg("Applied tag rules")
+	}
+	return nil
+}
+
+// RetagResult summarizes an ApplyTagRules run.
+type RetagResult struct {
+	// Matched is the number of transactions at least one rule matched.
+	Matched int
+
+	// Updated is the number of transactions that got new tags.
+	Updated int64
+}
+
+// ApplyTagRules applies the current tag rules to the stored transactions of a document.
+// Tags are only added: tags of rules that no longer match, and tags from other sources,
+// are kept.
+func ApplyTagRules(ctx context.Context, documentID string) (*RetagResult, error) {
+	repo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ApplyTagRules: creating BigQuery repository: %w", err)
+	}
+	defer repo.Close()
+
+	return ApplyTagRulesWithDeps(ctx, documentID, repo)
+}
+
+// ApplyTagRulesWithDeps applies the tag rules to a document's transactions using the
+// provided repository. This enables dependency injection for testing.
+func ApplyTagRulesWithDeps(ctx context.Context, documentID string, repo bigquery.DocumentRepository) (*RetagResult, error) {
+	doc, err := loadDocument(ctx, repo, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("ApplyTagRules: %w", err)
+	}
+
+	rules, err := loadTagRules(ctx, repo, documentUserID(doc))
+	if err != nil {
+		return nil, classify(ErrStorage, fmt.Errorf("ApplyTagRules: %w", err))
+	}
+
+	txs, err := repo.QueryTransactions(ctx, bigquery.TransactionQuery{DocumentID: documentID})
+	if err != nil {
+		return nil, classify(ErrStorage, fmt.Errorf("ApplyTagRules: %w", err))
+	}
+
+	result := &RetagResult{}
+	var updates []bigquery.TransactionTags
+	for _, t := range txs {
+		ruleTags := tagsFor(rules, t.RawDescription, t.NormalizedDescription.StringVal)
+		if len(ruleTags) == 0 {
+			continue
+		}
+		result.Matched++
+		tags := addTags(append([]string(nil), t.Tags...), ruleTags)
+		if len(tags) > len(t.Tags) {
+			updates = append(updates, bigquery.TransactionTags{TransactionID: t.TransactionID, Tags: tags})
+		}
+	}
+
+	if len(updates) > 0 {
+		result.Updated, err = repo.UpdateTransactionTags(ctx, updates)
+		if err != nil {
+			return nil, classify(ErrStorage, fmt.Errorf("ApplyTagRules: %w", err))
+		}
+	}
+	return result, nil
+}
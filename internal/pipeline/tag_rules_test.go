@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	bigquerylib "cloud.google.com/go/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+)
+
+func nullString(s string) bigquerylib.NullString {
+	return bigquerylib.NullString{StringVal: s, Valid: s != ""}
+}
+
+func TestTagsFor_Precedence(t *testing.T) {
+	rows := []bigquery.TagRuleRow{
+		// Listed out of order: rules apply by priority, then rule ID
+		{RuleID: "r-travel", Merchant: nullString("tfl"), Tags: []string{"travel"}, Priority: 20},
+		{RuleID: "r-commute", DescriptionPattern: nullString(`^TFL\b`), Tags: []string{"commute"}, Priority: 10},
+		{RuleID: "r-refund", DescriptionPattern: nullString(`refund`), Tags: []string{"refund"}, Priority: 5, StopProcessing: true},
+		{RuleID: "r-b", Merchant: nullString("coffee"), Tags: []string{"coffee", "treats"}, Priority: 30},
+		{RuleID: "r-a", Merchant: nullString("coffee"), Tags: []string{"treats"}, Priority: 30},
+		{RuleID: "r-both", DescriptionPattern: nullString(`^PRET`), Merchant: nullString("pret a manger"), Tags: []string{"lunch"}, Priority: 40},
+	}
+	rules, err := compileTagRules(rows)
+	if err != nil {
+		t.Fatalf("compileTagRules: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		merchant    string
+		want        []string
+	}{
+		{name: "tags accumulate by priority", description: "TFL TRAVEL CH", want: []string{"commute", "travel"}},
+		{name: "stop processing ends the list", description: "TFL TRAVEL CH REFUND", want: []string{"refund"}},
+		{name: "same priority ordered by rule ID without duplicates", description: "Coffee#1 Bristol", want: []string{"treats", "coffee"}},
+		{name: "pattern is case-insensitive", description: "tfl travel", want: []string{"commute", "travel"}},
+		{name: "merchant matches the normalized description", description: "CARD 1234 *99812", merchant: "TfL Travel", want: []string{"travel"}},
+		{name: "every condition must match", description: "PRET A MANGER LONDON", want: []string{"lunch"}},
+		{name: "no match", description: "TESCO STORES", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagsFor(rules, tt.description, tt.merchant); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagsFor(%q, %q) = %v, want %v", tt.description, tt.merchant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileTagRules_SkipsInvalid(t *testing.T) {
+	rules, err := compileTagRules([]bigquery.TagRuleRow{
+		{RuleID: "bad-pattern", DescriptionPattern: nullString(`(`), Tags: []string{"x"}},
+		{RuleID: "no-condition", Tags: []string{"x"}},
+		{RuleID: "no-tags", Merchant: nullString("tfl"), Tags: []string{" "}},
+		{RuleID: "ok", Merchant: nullString("tfl"), Tags: []string{"commute"}},
+	})
+	if err == nil {
+		t.Error("compileTagRules did not report the invalid rules")
+	}
+	if len(rules) != 1 || rules[0].id != "ok" {
+		t.Errorf("rules = %+v, want only ok", rules)
+	}
+}
+
+func TestValidateTagRule(t *testing.T) {
+	row := &bigquery.TagRuleRow{Merchant: nullString("TfL"), Tags: []string{" commute ", "commute", "", "travel"}}
+	if err := ValidateTagRule(row); err != nil {
+		t.Fatalf("ValidateTagRule: %v", err)
+	}
+	if want := []string{"commute", "travel"}; !reflect.DeepEqual(row.Tags, want) {
+		t.Errorf("Tags = %q, want %q", row.Tags, want)
+	}
+
+	if err := ValidateTagRule(&bigquery.TagRuleRow{DescriptionPattern: nullString(`[`), Tags: []string{"x"}}); err == nil {
+		t.Error("ValidateTagRule accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// TaxonomyReport describes whether the category taxonomy is usable for parsing.
+type TaxonomyReport struct {
+	// Usable is true when Problems is empty.
+	Usable bool `json:"usable"`
+
+	// ActiveCategories is the number of active taxonomy rows.
+	ActiveCategories int `json:"active_categories"`
+
+	// FallbackCategory is the configured uncategorized category.
+	FallbackCategory string `json:"fallback_category"`
+
+	// Problems lists what has to be fixed before ingestion, one actionable message each.
+	Problems []string `json:"problems"`
+}
+
+// CheckTaxonomy reports whether the active categories of userID are usable for parsing:
+// there is at least one, and the uncategorized fallback is among them, so transactions
+// the model cannot categorize get a category_id.
+func CheckTaxonomy(ctx context.Context, repo CategoryRepository, userID string) (*TaxonomyReport, error) {
+	rows, err := repo.ListActiveCategories(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("CheckTaxonomy: list categories: %w", err)
+	}
+
+	report := &TaxonomyReport{
+		ActiveCategories: len(rows),
+		FallbackCategory: uncategorizedCategoryFromEnv(),
+		Problems:         []string{},
+	}
+
+	if len(rows) == 0 {
+		report.Problems = append(report.Problems,
+			"there are no active categories: seed the categories table (migration 0008_seed_categories.sql) or reactivate categories")
+	}
+
+	fallback := normalizeCategory(report.FallbackCategory)
+	found := false
+	for _, row := range rows {
+		if normalizeCategory(row.CategoryName) == fallback && (!row.SubcategoryName.Valid || row.SubcategoryName.StringVal == "") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		report.Problems = append(report.Problems, fmt.Sprintf(
+			"fallback category %q has no active row without a subcategory: add it to the categories table or set %s to an existing category",
+			report.FallbackCategory, UncategorizedCategoryEnv))
+	}
+
+	report.Usable = len(report.Problems) == 0
+	return report, nil
+}
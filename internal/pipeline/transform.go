@@ -1,18 +1,47 @@
 package pipeline
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 	"time"
 
 	bigquerylib "cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
 )
 
+// unmarshalModelJSON decodes JSON returned by the model like json.Unmarshal, except that
+// numbers are kept as json.Number so amounts keep their exact decimal value.
+func unmarshalModelJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
 // transformModelOutputToTransactions converts raw model output into normalized transaction structs.
+// Banks often state the currency once per statement, so a transaction without one takes
+// fallbackCurrency, the currency of the statement's account.
 func transformModelOutputToTransactions(
+	ctx context.Context,
 	rawOutput map[string]interface{},
+	fallbackCurrency string,
 ) ([]*Transaction, error) {
 	// Expect top-level: { "transactions": [...] }
 	txAny, ok := rawOutput["transactions"]
@@ -25,6 +54,8 @@ func transformModelOutputToTransactions(
 		return nil, fmt.Errorf("transformModelOutputToTransactions: 'transactions' is %T, want []interface{}", txAny)
 	}
 
+	fallbackCurrency = strings.ToUpper(strings.TrimSpace(fallbackCurrency))
+
 	result := make([]*Transaction, 0, len(txSlice))
 
 	for i, item := range txSlice {
@@ -42,10 +73,17 @@ func transformModelOutputToTransactions(
 		if err != nil {
 			return nil, fmt.Errorf("transaction %d: %w", i, err)
 		}
-		currency, err := getStringField(obj, "currency", true)
+		currencyPtr, err := getOptionalStringField(obj, "currency")
 		if err != nil {
 			return nil, fmt.Errorf("transaction %d: %w", i, err)
 		}
+		currency := fallbackCurrency
+		if currencyPtr != nil && strings.TrimSpace(*currencyPtr) != "" {
+			currency = *currencyPtr
+		}
+		if currency == "" {
+			return nil, fmt.Errorf("transaction %d: missing currency and no statement currency to fall back to", i)
+		}
 		category, err := getStringField(obj, "category", true)
 		if err != nil {
 			return nil, fmt.Errorf("transaction %d: %w", i, err)
@@ -59,7 +97,7 @@ func transformModelOutputToTransactions(
 			subcategory = *subcategoryPtr
 		}
 
-		amount, err := getFloat64Field(obj, "amount", true)
+		amount, err := getRatField(obj, "amount", true)
 		if err != nil {
 			return nil, fmt.Errorf("transaction %d: %w", i, err)
 		}
@@ -71,19 +109,45 @@ func transformModelOutputToTransactions(
 		}
 
 		// Optional fields
-		balanceAfter, err := getOptionalFloat64Field(obj, "balance_after")
+		balanceAfter, err := getOptionalRatField(obj, "balance_after")
 		if err != nil {
 			return nil, fmt.Errorf("transaction %d: %w", i, err)
 		}
 
+		originalAmount, originalCurrency, err := getOriginalAmount(obj, amount, currency)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+
+		// Provenance and booking time are extra detail; a malformed value is dropped rather
+		// than failing the statement
+		log := logger.FromContext(ctx)
+		lineNo, err := getOptionalInt64Field(obj, "statement_line_no")
+		if err != nil {
+			log.Warn().Err(err).Int("transaction", i).Msg("Ignoring invalid statement_line_no")
+		}
+		pageNo, err := getOptionalInt64Field(obj, "statement_page_no")
+		if err != nil {
+			log.Warn().Err(err).Int("transaction", i).Msg("Ignoring invalid statement_page_no")
+		}
+		bookingDatetime, err := getOptionalDatetimeField(obj, "booking_datetime")
+		if err != nil {
+			log.Warn().Err(err).Int("transaction", i).Msg("Ignoring invalid booking_datetime")
+		}
+
 		t := &Transaction{
-			Date:         date,
-			Description:  desc,
-			Amount:       amount,
-			Currency:     currency,
-			BalanceAfter: balanceAfter,
-			Category:     category,
-			Subcategory:  subcategory,
+			Date:             date,
+			Description:      desc,
+			Amount:           amount,
+			Currency:         currency,
+			BalanceAfter:     balanceAfter,
+			OriginalAmount:   originalAmount,
+			OriginalCurrency: originalCurrency,
+			Category:         category,
+			Subcategory:      subcategory,
+			StatementLineNo:  lineNo,
+			StatementPageNo:  pageNo,
+			BookingDatetime:  bookingDatetime,
 		}
 
 		result = append(result, t)
@@ -92,6 +156,30 @@ func transformModelOutputToTransactions(
 	return result, nil
 }
 
+// getOriginalAmount reads the foreign-currency amount of a converted transaction. It is
+// ignored unless both original_amount and original_currency are given and the currency
+// differs from the transaction's. The amount takes the sign of the converted amount,
+// since statements often print foreign amounts unsigned.
+func getOriginalAmount(obj map[string]interface{}, amount *big.Rat, currency string) (*big.Rat, string, error) {
+	originalAmount, err := getOptionalRatField(obj, "original_amount")
+	if err != nil {
+		return nil, "", err
+	}
+	originalCurrency, err := getOptionalStringField(obj, "original_currency")
+	if err != nil {
+		return nil, "", err
+	}
+	if originalAmount == nil || originalCurrency == nil || strings.EqualFold(*originalCurrency, currency) {
+		return nil, "", nil
+	}
+
+	signed := new(big.Rat).Abs(originalAmount)
+	if amount.Sign() < 0 {
+		signed.Neg(signed)
+	}
+	return signed, strings.ToUpper(*originalCurrency), nil
+}
+
 func getStringField(m map[string]interface{}, key string, required bool) (string, error) {
 	v, ok := m[key]
 	if !ok {
@@ -128,44 +216,130 @@ func getOptionalStringField(m map[string]interface{}, key string) (*string, erro
 	}
 }
 
-func getFloat64Field(m map[string]interface{}, key string, required bool) (float64, error) {
+// getRatField reads a monetary amount. Model output is decoded with UseNumber, so amounts
+// arrive as json.Number and are converted from their decimal text without float rounding.
+// float64 is accepted for raw outputs decoded without UseNumber.
+func getRatField(m map[string]interface{}, key string, required bool) (*big.Rat, error) {
 	v, ok := m[key]
 	if !ok {
 		if required {
-			return 0, fmt.Errorf("missing required field %q", key)
+			return nil, fmt.Errorf("missing required field %q", key)
 		}
-		return 0, nil
+		return new(big.Rat), nil
+	}
+	r, err := ratFromJSON(key, v)
+	if err != nil {
+		return nil, fmt.Errorf("%w, want number", err)
 	}
+	return r, nil
+}
+
+func getOptionalRatField(m map[string]interface{}, key string) (*big.Rat, error) {
+	v, ok := m[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	r, err := ratFromJSON(key, v)
+	if err != nil {
+		return nil, fmt.Errorf("%w, want number or null", err)
+	}
+	return r, nil
+}
+
+// ratFromJSON converts a decoded JSON number to a big.Rat. Amounts returned as strings
+// are parsed with parseAmountString.
+func ratFromJSON(key string, v interface{}) (*big.Rat, error) {
+	var text string
 	switch val := v.(type) {
+	case json.Number:
+		text = val.String()
 	case float64:
-		return val, nil
+		// The shortest decimal that round-trips, e.g. 0.1 rather than its binary expansion
+		text = strconv.FormatFloat(val, 'f', -1, 64)
 	case int: // unlikely from encoding/json, but harmless to support
-		return float64(val), nil
+		return new(big.Rat).SetInt64(int64(val)), nil
+	case string:
+		// The model sometimes copies the amount as printed, e.g. "£1,234.56"
+		sep, err := amountDecimalSeparatorFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		r, err := parseAmountString(val, sep)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", key, err)
+		}
+		return r, nil
 	default:
-		return 0, fmt.Errorf("field %q has type %T, want number", key, v)
+		return nil, fmt.Errorf("field %q has type %T", key, v)
+	}
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("field %q has invalid number %q", key, text)
+	}
+	return r, nil
+}
+
+// datetimeLayouts are the accepted forms of a timestamp without a UTC offset.
+var datetimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// getOptionalDatetimeField reads a timestamp in the application timezone. One with a UTC
+// offset is converted to it; one without is taken as printed, i.e. already local.
+func getOptionalDatetimeField(m map[string]interface{}, key string) (*time.Time, error) {
+	s, err := getOptionalStringField(m, key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if t, err := time.Parse(time.RFC3339, *s); err == nil {
+		t = t.In(apptime.Location())
+		return &t, nil
 	}
+	for _, layout := range datetimeLayouts {
+		if t, err := time.ParseInLocation(layout, *s, apptime.Location()); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("field %q has invalid datetime %q, want YYYY-MM-DDTHH:MM:SS", key, *s)
 }
 
-func getOptionalFloat64Field(m map[string]interface{}, key string) (*float64, error) {
+func getOptionalInt64Field(m map[string]interface{}, key string) (*int64, error) {
 	v, ok := m[key]
 	if !ok || v == nil {
 		return nil, nil
 	}
 	switch val := v.(type) {
+	case json.Number:
+		n, err := val.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("field %q has non-integer value %v", key, val)
+		}
+		return &n, nil
 	case float64:
-		f := val
-		return &f, nil
+		// Outputs decoded without UseNumber hold float64; reject fractional values
+		if val != math.Trunc(val) {
+			return nil, fmt.Errorf("field %q has non-integer value %v", key, val)
+		}
+		n := int64(val)
+		return &n, nil
 	case int:
-		f := float64(val)
-		return &f, nil
+		n := int64(val)
+		return &n, nil
+	case int64:
+		n := val
+		return &n, nil
 	default:
-		return nil, fmt.Errorf("field %q has type %T, want number or null", key, v)
+		return nil, fmt.Errorf("field %q has type %T, want integer or null", key, v)
 	}
 }
 
 // transformAccountInfo converts raw LLM account extraction output into an AccountRow.
-// Returns nil if the extraction failed or data is invalid.
-func transformAccountInfo(rawOutput map[string]interface{}, documentID string) (*bigquery.AccountRow, error) {
+// Returns nil if the extraction failed or data is invalid. Sort codes and IBANs are
+// normalized; ones that fail validation are logged and stored as extracted.
+func transformAccountInfo(ctx context.Context, rawOutput map[string]interface{}, userID, documentID string) (*bigquery.AccountRow, error) {
 	// Extract optional fields
 	accountNumber, err := getOptionalStringField(rawOutput, "account_number")
 	if err != nil {
@@ -214,17 +388,32 @@ func transformAccountInfo(rawOutput map[string]interface{}, documentID string) (
 
 	// Build account row
 	row := &bigquery.AccountRow{
-		UserID: DefaultUserID,
+		UserID: userID,
 	}
 
 	if accountNumber != nil {
 		row.AccountNumber = *accountNumber
 	}
-	if iban != nil {
-		row.IBAN = *iban
+	log := logger.FromContext(ctx)
+	if iban != nil && strings.TrimSpace(*iban) != "" {
+		normalized, ok := infraBQ.NormalizeIBAN(*iban)
+		if !ok {
+			log.Warn().
+				Str("document_id", documentID).
+				Str("iban", maskIBAN(normalized)).
+				Msg("Extracted IBAN failed validation; storing it anyway")
+		}
+		row.IBAN = normalized
 	}
-	if sortCode != nil {
-		row.SortCode = *sortCode
+	if sortCode != nil && strings.TrimSpace(*sortCode) != "" {
+		normalized, ok := infraBQ.NormalizeSortCode(*sortCode)
+		if !ok {
+			log.Warn().
+				Str("document_id", documentID).
+				Str("sort_code", *sortCode).
+				Msg("Extracted sort code is not in XX-XX-XX form; storing it as extracted")
+		}
+		row.SortCode = normalized
 	}
 	if accountName != nil {
 		row.AccountName = *accountName
@@ -253,18 +442,26 @@ func transformAccountInfo(rawOutput map[string]interface{}, documentID string) (
 	return row, nil
 }
 
-// generateDefaultAccount creates a document-scoped fallback account when
-// extraction fails or returns no account identifiers.
-func generateDefaultAccount(documentID string) *bigquery.AccountRow {
+// maskIBAN hides all but the last four characters of an IBAN, for logging.
+func maskIBAN(iban string) string {
+	if len(iban) <= 4 {
+		return strings.Repeat("*", len(iban))
+	}
+	return strings.Repeat("*", len(iban)-4) + iban[len(iban)-4:]
+}
+
+// generateDefaultAccount creates a document-scoped fallback account of userID in
+// currency when extraction fails or returns no account identifiers.
+func generateDefaultAccount(userID, documentID, currency string) *bigquery.AccountRow {
 	// Generate synthetic account number from document ID
-	accountNumber := fmt.Sprintf("DOC-%s", documentID[:8])
+	accountNumber := infraBQ.DefaultAccountPrefix + documentID[:8]
 
 	return &bigquery.AccountRow{
-		UserID:        DefaultUserID,
+		UserID:        userID,
 		InstitutionID: DefaultSourceSystem,
 		AccountNumber: accountNumber,
 		AccountName:   fmt.Sprintf("Barclays Current Account (%s)", documentID[:8]),
 		AccountType:   "CURRENT",
-		Currency:      "GBP", // Default to GBP for UK statements
+		Currency:      currency,
 	}
 }
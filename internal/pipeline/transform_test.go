@@ -0,0 +1,378 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+func TestTransformModelOutputToTransactions_Provenance(t *testing.T) {
+	rawOutput := map[string]interface{}{
+		"transactions": []interface{}{
+			map[string]interface{}{
+				"date":              "2024-01-01",
+				"description":       "With provenance",
+				"amount":            -10.50,
+				"currency":          "GBP",
+				"category":          "Food & Dining",
+				"subcategory":       "Groceries",
+				"statement_line_no": float64(7),
+				"statement_page_no": float64(2),
+			},
+			map[string]interface{}{
+				"date":              "2024-01-02",
+				"description":       "Null provenance",
+				"amount":            20.0,
+				"currency":          "GBP",
+				"category":          "Income",
+				"statement_line_no": nil,
+				"statement_page_no": nil,
+			},
+			map[string]interface{}{
+				"date":        "2024-01-03",
+				"description": "Missing provenance",
+				"amount":      -1.0,
+				"currency":    "GBP",
+				"category":    "Healthcare",
+			},
+		},
+	}
+
+	txs, err := transformModelOutputToTransactions(context.Background(), rawOutput, DefaultCurrency)
+	if err != nil {
+		t.Fatalf("transformModelOutputToTransactions() error = %v", err)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(txs))
+	}
+
+	if txs[0].StatementLineNo == nil || *txs[0].StatementLineNo != 7 {
+		t.Errorf("StatementLineNo = %v, want 7", txs[0].StatementLineNo)
+	}
+	if txs[0].StatementPageNo == nil || *txs[0].StatementPageNo != 2 {
+		t.Errorf("StatementPageNo = %v, want 2", txs[0].StatementPageNo)
+	}
+
+	for _, tx := range txs[1:] {
+		if tx.StatementLineNo != nil || tx.StatementPageNo != nil {
+			t.Errorf("%s: expected nil provenance, got line=%v page=%v", tx.Description, tx.StatementLineNo, tx.StatementPageNo)
+		}
+	}
+}
+
+func TestTransformModelOutputToTransactions_InvalidProvenance(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+	}{
+		{name: "fractional line number", key: "statement_line_no", value: 3.5},
+		{name: "fractional JSON line number", key: "statement_line_no", value: json.Number("3.5")},
+		{name: "string page number", key: "statement_page_no", value: "12a"},
+		{name: "boolean page number", key: "statement_page_no", value: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := map[string]interface{}{
+				"date":              "2024-01-01",
+				"description":       "Bad provenance",
+				"amount":            -10.0,
+				"currency":          "GBP",
+				"category":          "Healthcare",
+				"statement_line_no": 4.0,
+				"statement_page_no": 2.0,
+			}
+			tx[tt.key] = tt.value
+
+			var logs bytes.Buffer
+			ctx := logger.WithContext(context.Background(), logger.NewWithWriter(&logs))
+			txs, err := transformModelOutputToTransactions(ctx, map[string]interface{}{
+				"transactions": []interface{}{tx},
+			}, DefaultCurrency)
+			if err != nil {
+				t.Fatalf("invalid %s failed the transform: %v", tt.key, err)
+			}
+			if len(txs) != 1 {
+				t.Fatalf("got %d transactions, want 1", len(txs))
+			}
+
+			// The malformed field is stored as NULL; the other is kept
+			line, page := txs[0].StatementLineNo, txs[0].StatementPageNo
+			if tt.key == "statement_line_no" && (line != nil || page == nil || *page != 2) {
+				t.Errorf("provenance = line %v page %v, want line nil page 2", line, page)
+			}
+			if tt.key == "statement_page_no" && (page != nil || line == nil || *line != 4) {
+				t.Errorf("provenance = line %v page %v, want line 4 page nil", line, page)
+			}
+			if !strings.Contains(logs.String(), "Ignoring invalid "+tt.key) {
+				t.Errorf("expected a warning about %s, got: %s", tt.key, logs.String())
+			}
+		})
+	}
+}
+
+func TestTransformModelOutputToTransactions_BookingDatetime(t *testing.T) {
+	if err := apptime.Configure("Europe/London"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { apptime.Configure(apptime.DefaultTimezone) })
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string // civil datetime in the app timezone; empty for none
+	}{
+		{name: "local time as printed", value: "2024-07-01T14:05:09", want: "2024-07-01T14:05:09"},
+		{name: "without seconds", value: "2024-07-01 14:05", want: "2024-07-01T14:05:00"},
+		{name: "UTC offset converted to app timezone", value: "2024-07-01T23:30:00Z", want: "2024-07-02T00:30:00"},
+		{name: "null", value: nil},
+		{name: "empty", value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs, err := transformModelOutputToTransactions(context.Background(), map[string]interface{}{
+				"transactions": []interface{}{map[string]interface{}{
+					"date":             "2024-07-01",
+					"description":      "CARD PAYMENT",
+					"amount":           -10.0,
+					"currency":         "GBP",
+					"category":         "Shopping",
+					"booking_datetime": tt.value,
+				}},
+			}, DefaultCurrency)
+			if err != nil {
+				t.Fatalf("transformModelOutputToTransactions() error = %v", err)
+			}
+
+			got := ""
+			if bt := txs[0].BookingDatetime; bt != nil {
+				got = civil.DateTimeOf(*bt).String()
+			}
+			if got != tt.want {
+				t.Errorf("BookingDatetime = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	txs, err := transformModelOutputToTransactions(context.Background(), map[string]interface{}{
+		"transactions": []interface{}{map[string]interface{}{
+			"date":             "2024-07-01",
+			"description":      "CARD PAYMENT",
+			"amount":           -10.0,
+			"currency":         "GBP",
+			"category":         "Shopping",
+			"booking_datetime": "01/07/2024 14:05",
+		}},
+	}, DefaultCurrency)
+	if err != nil {
+		t.Fatalf("unparseable booking_datetime failed the transform: %v", err)
+	}
+	if txs[0].BookingDatetime != nil {
+		t.Errorf("BookingDatetime = %v, want nil for an unparseable value", txs[0].BookingDatetime)
+	}
+}
+
+func TestTransformModelOutputToTransactions_CurrencyFallback(t *testing.T) {
+	lines := func() []interface{} {
+		return []interface{}{
+			map[string]interface{}{"date": "2024-01-01", "description": "Stated", "amount": -1.0, "currency": "USD", "category": "Shopping"},
+			map[string]interface{}{"date": "2024-01-02", "description": "Missing", "amount": -2.0, "category": "Shopping"},
+			map[string]interface{}{"date": "2024-01-03", "description": "Null", "amount": -3.0, "currency": nil, "category": "Shopping"},
+			map[string]interface{}{"date": "2024-01-04", "description": "Blank", "amount": -4.0, "currency": " ", "category": "Shopping"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		output   map[string]interface{}
+		fallback string
+		want     string
+	}{
+		{
+			name:     "fallback currency from account",
+			output:   map[string]interface{}{"transactions": lines()},
+			fallback: "CHF",
+			want:     "CHF",
+		},
+		{
+			name:     "fallback currency is normalized",
+			output:   map[string]interface{}{"transactions": lines()},
+			fallback: " eur ",
+			want:     "EUR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs, err := transformModelOutputToTransactions(context.Background(), tt.output, tt.fallback)
+			if err != nil {
+				t.Fatalf("transformModelOutputToTransactions() error = %v", err)
+			}
+			if txs[0].Currency != "USD" {
+				t.Errorf("%s: Currency = %q, want the stated USD", txs[0].Description, txs[0].Currency)
+			}
+			for _, tx := range txs[1:] {
+				if tx.Currency != tt.want {
+					t.Errorf("%s: Currency = %q, want %q", tx.Description, tx.Currency, tt.want)
+				}
+			}
+		})
+	}
+
+	_, err := transformModelOutputToTransactions(context.Background(), map[string]interface{}{"transactions": lines()}, "")
+	if err == nil {
+		t.Error("expected an error when no currency is available at all")
+	}
+}
+
+func TestTransformModelOutputToTransactions_OriginalAmount(t *testing.T) {
+	tx := func(desc string, extra map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{
+			"date":        "2024-05-01",
+			"description": desc,
+			"amount":      -43.10,
+			"currency":    "GBP",
+			"category":    "Travel",
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+	rawOutput := map[string]interface{}{
+		"transactions": []interface{}{
+			tx("Converted", map[string]interface{}{"original_amount": 50.0, "original_currency": "eur"}),
+			tx("Missing currency", map[string]interface{}{"original_amount": 50.0}),
+			tx("Same currency", map[string]interface{}{"original_amount": -43.10, "original_currency": "GBP"}),
+			tx("Domestic", nil),
+		},
+	}
+
+	txs, err := transformModelOutputToTransactions(context.Background(), rawOutput, DefaultCurrency)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if txs[0].OriginalAmount == nil || txs[0].OriginalAmount.Cmp(big.NewRat(-50, 1)) != 0 || txs[0].OriginalCurrency != "EUR" {
+		t.Errorf("converted: original = %v %q, want -50 EUR", txs[0].OriginalAmount, txs[0].OriginalCurrency)
+	}
+	for _, got := range txs[1:] {
+		if got.OriginalAmount != nil || got.OriginalCurrency != "" {
+			t.Errorf("%s: original = %v %q, want none", got.Description, got.OriginalAmount, got.OriginalCurrency)
+		}
+	}
+
+	bad := map[string]interface{}{
+		"transactions": []interface{}{tx("Bad", map[string]interface{}{"original_amount": "fifty", "original_currency": "EUR"})},
+	}
+	if _, err := transformModelOutputToTransactions(context.Background(), bad, DefaultCurrency); err == nil {
+		t.Error("expected error for non-numeric original_amount")
+	}
+}
+
+func TestTransformModelOutputToTransactions_ExactAmounts(t *testing.T) {
+	// Bahraini dinar amounts have three decimal places
+	raw := []byte(`{"transactions": [
+		{"date": "2024-03-01", "description": "Rent", "amount": -1234.565, "balance_after": 98765432109876.125, "category": "Housing"},
+		{"date": "2024-03-02", "description": "Refund", "amount": 0.1, "original_amount": 0.265, "original_currency": "USD", "category": "Other"}
+	]}`)
+	var rawOutput map[string]interface{}
+	if err := unmarshalModelJSON(raw, &rawOutput); err != nil {
+		t.Fatalf("unmarshalModelJSON: %v", err)
+	}
+
+	txs, err := transformModelOutputToTransactions(context.Background(), rawOutput, "BHD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  *big.Rat
+		want string
+	}{
+		{"amount", txs[0].Amount, "-1234.565"},
+		{"balance_after", txs[0].BalanceAfter, "98765432109876.125"},
+		{"small amount", txs[1].Amount, "0.1"},
+		{"original_amount", txs[1].OriginalAmount, "0.265"},
+	}
+	for _, c := range checks {
+		want, _ := new(big.Rat).SetString(c.want)
+		if c.got == nil || c.got.Cmp(want) != 0 {
+			t.Errorf("%s = %v, want exactly %s", c.name, c.got, c.want)
+		}
+	}
+	if txs[0].Currency != "BHD" {
+		t.Errorf("currency = %q, want BHD", txs[0].Currency)
+	}
+}
+
+func TestUnmarshalModelJSON_TrailingData(t *testing.T) {
+	var v interface{}
+	if err := unmarshalModelJSON([]byte(`{"a": 1} {"b": 2}`), &v); err == nil {
+		t.Error("expected an error for data after the top-level value")
+	}
+}
+
+func TestTransformAccountInfo_NormalizesIdentifiers(t *testing.T) {
+	row, err := transformAccountInfo(context.Background(), map[string]interface{}{
+		"account_number": "12345678",
+		"sort_code":      "20 00 00",
+		"iban":           "gb82 west 1234 5698 7654 32",
+	}, DefaultUserID, "doc-12345678")
+	if err != nil {
+		t.Fatalf("transformAccountInfo: %v", err)
+	}
+	if row.SortCode != "20-00-00" || row.IBAN != "GB82WEST12345698765432" {
+		t.Errorf("got sort code %q, IBAN %q", row.SortCode, row.IBAN)
+	}
+
+	row, err = transformAccountInfo(context.Background(), map[string]interface{}{
+		"sort_code": "20/00/00",
+		"iban":      "GB00 WEST 1234",
+	}, DefaultUserID, "doc-12345678")
+	if err != nil {
+		t.Fatalf("transformAccountInfo: %v", err)
+	}
+	if row.SortCode != "20/00/00" || row.IBAN != "GB00WEST1234" {
+		t.Errorf("invalid identifiers should still be stored, got sort code %q, IBAN %q", row.SortCode, row.IBAN)
+	}
+}
+
+func TestMaskIBAN(t *testing.T) {
+	if got := maskIBAN("GB82WEST12345698765432"); got != "******************5432" {
+		t.Errorf("maskIBAN() = %q", got)
+	}
+	if got := maskIBAN("GB8"); got != "***" {
+		t.Errorf("maskIBAN(short) = %q", got)
+	}
+}
+
+func TestDefaultCurrencyFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: DefaultCurrency},
+		{value: " eur ", want: "EUR"},
+		{value: "EURO", wantErr: true},
+		{value: "E1R", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv(DefaultCurrencyEnv, tt.value)
+		got, err := defaultCurrencyFromEnv()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("defaultCurrencyFromEnv() with %q = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
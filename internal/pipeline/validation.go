@@ -3,11 +3,27 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/dvloznov/finance-tracker/internal/bigquery"
 )
 
+// UncategorizedCategoryEnv names the environment variable setting the category the model
+// is told to use when it cannot categorize a transaction.
+const UncategorizedCategoryEnv = "UNCATEGORIZED_CATEGORY"
+
+// DefaultUncategorizedCategory is the fallback category when UncategorizedCategoryEnv is unset.
+const DefaultUncategorizedCategory = "Uncategorized"
+
+// uncategorizedCategoryFromEnv returns the configured fallback category name.
+func uncategorizedCategoryFromEnv() string {
+	if v := strings.TrimSpace(os.Getenv(UncategorizedCategoryEnv)); v != "" {
+		return v
+	}
+	return DefaultUncategorizedCategory
+}
+
 // CategoryValidator validates transaction categories against the taxonomy.
 type CategoryValidator struct {
 	// Map of "CATEGORY|SUBCATEGORY" or "CATEGORY|" -> category_id
@@ -15,9 +31,10 @@ type CategoryValidator struct {
 	categoryRows []bigquery.CategoryRow // Keep for other lookups if needed
 }
 
-// NewCategoryValidator creates a validator from the categories taxonomy.
-func NewCategoryValidator(ctx context.Context, repo CategoryRepository) (*CategoryValidator, error) {
-	rows, err := repo.ListActiveCategories(ctx)
+// NewCategoryValidator creates a validator from the categories taxonomy, including the
+// custom categories of userID.
+func NewCategoryValidator(ctx context.Context, repo CategoryRepository, userID string) (*CategoryValidator, error) {
+	rows, err := repo.ListActiveCategories(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("NewCategoryValidator: list categories: %w", err)
 	}
@@ -40,11 +57,19 @@ func NewCategoryValidator(ctx context.Context, repo CategoryRepository) (*Catego
 		validator.validPairs[key] = row.CategoryID
 	}
 
+	// The prompt tells the model to fall back to the uncategorized category, so it is
+	// always valid. Without a taxonomy row it has no category_id.
+	fallback := normalizeCategory(uncategorizedCategoryFromEnv()) + "|"
+	if _, ok := validator.validPairs[fallback]; !ok {
+		validator.validPairs[fallback] = ""
+	}
+
 	return validator, nil
 }
 
 // ValidateCategory checks if a category and subcategory are valid.
-// Returns the category_id if valid, error if invalid.
+// Returns the category_id if valid, error if invalid. The uncategorized fallback is
+// valid even when the taxonomy lacks it; its category_id is then empty.
 func (v *CategoryValidator) ValidateCategory(category, subcategory string) (string, error) {
 	normCat := normalizeCategory(category)
 	normSubcat := normalizeCategory(subcategory)
@@ -66,6 +91,22 @@ func (v *CategoryValidator) ValidateCategory(category, subcategory string) (stri
 	return "", fmt.Errorf("invalid category/subcategory combination: %q / %q", category, subcategory)
 }
 
+// ExactCategory returns the taxonomy row for category and subcategory, compared
+// case-insensitively. Unlike ValidateCategory it does not fall back to the parent
+// category when the subcategory is unknown.
+func (v *CategoryValidator) ExactCategory(category, subcategory string) (bigquery.CategoryRow, bool) {
+	categoryID, ok := v.validPairs[normalizeCategory(category)+"|"+normalizeCategory(subcategory)]
+	if !ok {
+		return bigquery.CategoryRow{}, false
+	}
+	for _, row := range v.categoryRows {
+		if row.CategoryID == categoryID {
+			return row, true
+		}
+	}
+	return bigquery.CategoryRow{}, false
+}
+
 // normalizeCategory normalizes a category name for comparison.
 // Converts to uppercase and trims whitespace for case-insensitive comparison.
 func normalizeCategory(name string) string {
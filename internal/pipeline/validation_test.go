@@ -13,7 +13,7 @@ type mockCategoryRepository struct {
 	categories []bigquery.CategoryRow
 }
 
-func (m *mockCategoryRepository) ListActiveCategories(ctx context.Context) ([]bigquery.CategoryRow, error) {
+func (m *mockCategoryRepository) ListActiveCategories(ctx context.Context, userID string) ([]bigquery.CategoryRow, error) {
 	return m.categories, nil
 }
 
@@ -28,7 +28,7 @@ func TestCategoryValidator_ValidateCategory(t *testing.T) {
 	}
 
 	repo := &mockCategoryRepository{categories: categories}
-	validator, err := NewCategoryValidator(context.Background(), repo)
+	validator, err := NewCategoryValidator(context.Background(), repo, DefaultUserID)
 	if err != nil {
 		t.Fatalf("NewCategoryValidator failed: %v", err)
 	}
@@ -87,6 +87,27 @@ func TestCategoryValidator_ValidateCategory(t *testing.T) {
 	}
 }
 
+func TestCategoryValidator_ExactCategory(t *testing.T) {
+	repo := &mockCategoryRepository{categories: []bigquery.CategoryRow{
+		{CategoryID: "cat1", CategoryName: "Housing", SubcategoryName: bigquerylib.NullString{Valid: false}},
+		{CategoryID: "cat1-sub1", CategoryName: "Housing", SubcategoryName: bigquerylib.NullString{StringVal: "Rent", Valid: true}},
+	}}
+	validator, err := NewCategoryValidator(context.Background(), repo, DefaultUserID)
+	if err != nil {
+		t.Fatalf("NewCategoryValidator failed: %v", err)
+	}
+
+	row, ok := validator.ExactCategory(" housing ", "RENT")
+	if !ok || row.CategoryID != "cat1-sub1" {
+		t.Errorf("ExactCategory(housing, RENT) = %q, %v; want cat1-sub1, true", row.CategoryID, ok)
+	}
+
+	// ValidateCategory would fall back to the parent; ExactCategory must not
+	if row, ok := validator.ExactCategory("Housing", "Mortgage"); ok {
+		t.Errorf("ExactCategory(Housing, Mortgage) = %q, want no match", row.CategoryID)
+	}
+}
+
 func TestNormalizeCategory(t *testing.T) {
 	tests := []struct {
 		input string
@@ -108,3 +129,79 @@ func TestNormalizeCategory(t *testing.T) {
 		})
 	}
 }
+
+func TestCategoryValidator_UncategorizedFallback(t *testing.T) {
+	repo := &mockCategoryRepository{categories: []bigquery.CategoryRow{
+		{CategoryID: "cat_healthcare", CategoryName: "Healthcare"},
+	}}
+
+	validator, err := NewCategoryValidator(context.Background(), repo, DefaultUserID)
+	if err != nil {
+		t.Fatalf("NewCategoryValidator failed: %v", err)
+	}
+	categoryID, err := validator.ValidateCategory("Uncategorized", "")
+	if err != nil {
+		t.Fatalf("Uncategorized should validate without a taxonomy row: %v", err)
+	}
+	if categoryID != "" {
+		t.Errorf("category_id = %q, want empty without a taxonomy row", categoryID)
+	}
+	if _, ok := validator.ExactCategory("Uncategorized", ""); ok {
+		t.Error("ExactCategory should only return taxonomy rows")
+	}
+
+	// A taxonomy row for the fallback keeps its ID
+	repo.categories = append(repo.categories, bigquery.CategoryRow{CategoryID: "cat_other", CategoryName: "Other"})
+	t.Setenv(UncategorizedCategoryEnv, "Other")
+	validator, err = NewCategoryValidator(context.Background(), repo, DefaultUserID)
+	if err != nil {
+		t.Fatalf("NewCategoryValidator failed: %v", err)
+	}
+	if categoryID, err := validator.ValidateCategory("other", ""); err != nil || categoryID != "cat_other" {
+		t.Errorf("ValidateCategory(other) = %q, %v; want cat_other", categoryID, err)
+	}
+	if _, err := validator.ValidateCategory("Uncategorized", ""); err == nil {
+		t.Error("Uncategorized should be rejected once another fallback is configured")
+	}
+}
+
+func TestCheckTaxonomy(t *testing.T) {
+	t.Run("empty taxonomy", func(t *testing.T) {
+		report, err := CheckTaxonomy(context.Background(), &mockCategoryRepository{}, DefaultUserID)
+		if err != nil {
+			t.Fatalf("CheckTaxonomy: %v", err)
+		}
+		if report.Usable || len(report.Problems) != 2 {
+			t.Errorf("report = %+v, want unusable with two problems", report)
+		}
+	})
+
+	t.Run("missing fallback", func(t *testing.T) {
+		repo := &mockCategoryRepository{categories: []bigquery.CategoryRow{
+			{CategoryID: "cat_healthcare", CategoryName: "Healthcare"},
+			{CategoryID: "cat_unc_sub", CategoryName: "Uncategorized", SubcategoryName: bigquerylib.NullString{StringVal: "Other", Valid: true}},
+		}}
+		report, err := CheckTaxonomy(context.Background(), repo, DefaultUserID)
+		if err != nil {
+			t.Fatalf("CheckTaxonomy: %v", err)
+		}
+		if report.Usable || len(report.Problems) != 1 || report.ActiveCategories != 2 {
+			t.Errorf("report = %+v, want unusable with one problem", report)
+		}
+	})
+
+	t.Run("usable", func(t *testing.T) {
+		t.Setenv(UncategorizedCategoryEnv, "Other")
+		repo := &mockCategoryRepository{categories: []bigquery.CategoryRow{
+			{CategoryID: "cat_healthcare", CategoryName: "Healthcare"},
+			{CategoryID: "cat_other", CategoryName: "other"},
+		}}
+		report, err := CheckTaxonomy(context.Background(), repo, DefaultUserID)
+		if err != nil {
+			t.Fatalf("CheckTaxonomy: %v", err)
+		}
+		if !report.Usable || len(report.Problems) != 0 || report.FallbackCategory != "Other" {
+			t.Errorf("report = %+v, want usable", report)
+		}
+	})
+}
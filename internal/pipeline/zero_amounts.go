@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// ZeroAmountTransactionsEnv names the environment variable choosing what happens to
+// transactions with a zero amount, typically informational lines such as "balance
+// brought forward": ZeroAmountKeep (the default), ZeroAmountTag or ZeroAmountSkip.
+const ZeroAmountTransactionsEnv = "ZERO_AMOUNT_TRANSACTIONS"
+
+// Zero-amount transaction handling modes.
+const (
+	// ZeroAmountKeep stores zero-amount transactions like any other.
+	ZeroAmountKeep = "keep"
+	// ZeroAmountTag stores them with ZeroAmountTag, so reports can exclude them.
+	ZeroAmountTag = "tag"
+	// ZeroAmountSkip drops them before they are stored.
+	ZeroAmountSkip = "skip"
+)
+
+// ZeroAmountTagName is the tag given to zero-amount transactions in ZeroAmountTag mode.
+const ZeroAmountTagName = "zero_amount"
+
+// zeroAmountModeFromEnv returns the configured zero-amount handling mode.
+func zeroAmountModeFromEnv() (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(ZeroAmountTransactionsEnv))); v {
+	case "":
+		return ZeroAmountKeep, nil
+	case ZeroAmountKeep, ZeroAmountTag, ZeroAmountSkip:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %s, %s or %s",
+			ZeroAmountTransactionsEnv, v, ZeroAmountKeep, ZeroAmountTag, ZeroAmountSkip)
+	}
+}
+
+// applyZeroAmountMode tags or drops the zero-amount transactions of txs according to
+// mode and returns the transactions to store and the number of zero-amount ones.
+func applyZeroAmountMode(txs []*Transaction, mode string) ([]*Transaction, int) {
+	kept := txs[:0:0]
+	zero := 0
+	for _, t := range txs {
+		if t.Amount == nil || t.Amount.Sign() != 0 {
+			kept = append(kept, t)
+			continue
+		}
+		zero++
+		switch mode {
+		case ZeroAmountSkip:
+			continue
+		case ZeroAmountTag:
+			t.Tags = append(t.Tags, ZeroAmountTagName)
+		}
+		kept = append(kept, t)
+	}
+	return kept, zero
+}
+
+// HandleZeroAmountsStep applies ZeroAmountTransactionsEnv to the transformed
+// transactions. It runs after the statement order check, so skipped lines are not
+// reported as gaps.
+type HandleZeroAmountsStep struct{}
+
+func (s *HandleZeroAmountsStep) Name() string {
+	return "HandleZeroAmounts"
+}
+
+func (s *HandleZeroAmountsStep) Execute(ctx context.Context, state *PipelineState) error {
+	mode, err := zeroAmountModeFromEnv()
+	if err != nil {
+		return classify(ErrValidation, fmt.Errorf("HandleZeroAmounts: %w", err))
+	}
+
+	log := logger.FromContext(ctx)
+
+	var zero int
+	state.Transactions, zero = applyZeroAmountMode(state.Transactions, mode)
+	if zero > 0 {
+		log.Info().
+			Str("document_id", state.DocumentID).
+			Int("transactions", zero).
+			Str("mode", mode).
+			Msg("Zero-amount transactions found")
+	}
+	return nil
+}
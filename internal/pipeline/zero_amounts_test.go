@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestApplyZeroAmountMode(t *testing.T) {
+	newTxs := func() []*Transaction {
+		return []*Transaction{
+			{Description: "BALANCE BROUGHT FORWARD", Amount: new(big.Rat)},
+			{Description: "TESCO", Amount: big.NewRat(-1250, 100)},
+			{Description: "INTEREST", Amount: big.NewRat(0, 1)},
+		}
+	}
+
+	kept, zero := applyZeroAmountMode(newTxs(), ZeroAmountKeep)
+	if len(kept) != 3 || zero != 2 || kept[0].Tags != nil {
+		t.Errorf("keep: %d kept, %d zero, tags %v; want 3 kept, 2 zero, untagged", len(kept), zero, kept[0].Tags)
+	}
+
+	kept, zero = applyZeroAmountMode(newTxs(), ZeroAmountTag)
+	if len(kept) != 3 || zero != 2 {
+		t.Fatalf("tag: %d kept, %d zero; want 3 and 2", len(kept), zero)
+	}
+	if !reflect.DeepEqual(kept[0].Tags, []string{ZeroAmountTagName}) || kept[1].Tags != nil {
+		t.Errorf("tag: tags = %v, %v; want only the zero-amount line tagged", kept[0].Tags, kept[1].Tags)
+	}
+
+	txs := newTxs()
+	kept, zero = applyZeroAmountMode(txs, ZeroAmountSkip)
+	if len(kept) != 1 || kept[0].Description != "TESCO" || zero != 2 {
+		t.Errorf("skip: kept %d transactions, %d zero; want only TESCO", len(kept), zero)
+	}
+	if len(txs) != 3 || txs[0].Description != "BALANCE BROUGHT FORWARD" {
+		t.Error("skip modified the input slice")
+	}
+}
+
+func TestHandleZeroAmountsStep(t *testing.T) {
+	t.Setenv(ZeroAmountTransactionsEnv, "SKIP")
+	state := &PipelineState{Transactions: []*Transaction{
+		{Description: "BALANCE BROUGHT FORWARD", Amount: new(big.Rat)},
+		{Description: "TESCO", Amount: big.NewRat(-5, 1)},
+	}}
+	if err := (&HandleZeroAmountsStep{}).Execute(context.Background(), state); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(state.Transactions) != 1 {
+		t.Errorf("transactions = %d, want the zero-amount line skipped", len(state.Transactions))
+	}
+
+	t.Setenv(ZeroAmountTransactionsEnv, "drop")
+	if err := (&HandleZeroAmountsStep{}).Execute(context.Background(), state); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
@@ -0,0 +1,38 @@
+package serve
+
+import "fmt"
+
+// Serve modes select which components Run starts.
+const (
+	// ModeAPI serves the HTTP API only; parse jobs are left to a separate worker.
+	ModeAPI = "api"
+	// ModeWorker processes parse jobs and reaps stale parsing runs, without the HTTP API.
+	ModeWorker = "worker"
+	// ModeAll runs the HTTP API and the worker in one process.
+	ModeAll = "all"
+)
+
+// sharedQueue reports whether the job queue is shared between processes. The in-memory
+// queue is not, so ModeAPI and ModeWorker are refused until it is replaced.
+const sharedQueue = false
+
+// parseMode returns whether the HTTP API and the worker run in mode. The api and worker
+// modes need a job queue shared between processes; without one, jobs enqueued by the
+// API would never reach a worker, so they are refused.
+func parseMode(mode string, sharedQueue bool) (serveAPI, runWorker bool, err error) {
+	switch mode {
+	case ModeAPI:
+		serveAPI = true
+	case ModeWorker:
+		runWorker = true
+	case ModeAll:
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("invalid mode %q: must be %s, %s or %s", mode, ModeAPI, ModeWorker, ModeAll)
+	}
+	if !sharedQueue {
+		return false, false, fmt.Errorf("mode %q runs the API and the worker in separate processes, which needs a job queue shared between them; "+
+			"the job queue is in memory, so run both in one process with -mode=%s", mode, ModeAll)
+	}
+	return serveAPI, runWorker, nil
+}
@@ -0,0 +1,42 @@
+package serve
+
+import "testing"
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode      string
+		serveAPI  bool
+		runWorker bool
+	}{
+		{"api", true, false},
+		{"worker", false, true},
+		{"all", true, true},
+	}
+	for _, tt := range tests {
+		serveAPI, runWorker, err := parseMode(tt.mode, true)
+		if err != nil {
+			t.Errorf("parseMode(%q): %v", tt.mode, err)
+			continue
+		}
+		if serveAPI != tt.serveAPI || runWorker != tt.runWorker {
+			t.Errorf("parseMode(%q) = %v, %v; want %v, %v", tt.mode, serveAPI, runWorker, tt.serveAPI, tt.runWorker)
+		}
+	}
+
+	for _, mode := range []string{"", "ALL", "both"} {
+		if _, _, err := parseMode(mode, true); err == nil {
+			t.Errorf("parseMode(%q) succeeded, want error", mode)
+		}
+	}
+}
+
+func TestParseModeWithoutSharedQueue(t *testing.T) {
+	if serveAPI, runWorker, err := parseMode(ModeAll, false); err != nil || !serveAPI || !runWorker {
+		t.Errorf("parseMode(all) = %v, %v, %v; want true, true, nil", serveAPI, runWorker, err)
+	}
+	for _, mode := range []string{"api", "worker"} {
+		if _, _, err := parseMode(mode, false); err == nil {
+			t.Errorf("parseMode(%q) without a shared queue succeeded, want error", mode)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package serve
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/api/handlers"
+	"github.com/dvloznov/finance-tracker/internal/api/middleware"
+	"github.com/dvloznov/finance-tracker/internal/apptime"
+	"github.com/dvloznov/finance-tracker/internal/gcsuploader"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/jobs/inmemory"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+	"github.com/dvloznov/finance-tracker/internal/worker"
+)
+
+// Run parses the command-line flags and starts the components selected by -mode,
+// defaulting to defaultMode, until the process is interrupted. It is the whole of
+// cmd/api and cmd/worker, which differ only in their default mode.
+func Run(defaultMode string) {
+	// Parse command-line flags
+	var (
+		mode = flag.String("mode", envOrDefault("SERVE_MODE", defaultMode),
+			"Components to start: all (HTTP API and worker); api (HTTP API only) and worker (parse jobs only) need a job queue shared between processes, which the in-memory queue is not (or set SERVE_MODE env)")
+
+		port   = flag.String("port", "8080", "HTTP server port")
+		bucket = flag.String("bucket", os.Getenv("GCS_BUCKET"), "GCS bucket name for document uploads (or set GCS_BUCKET env)")
+
+		objectTemplate = flag.String("object-name-template", envOrDefault("GCS_OBJECT_NAME_TEMPLATE", handlers.DefaultObjectNameTemplate),
+			"Upload object name template; placeholders: {date} {uuid} {filename} {user_id} {institution} (or set GCS_OBJECT_NAME_TEMPLATE env)")
+		signedURLExpiry = flag.String("signed-url-expiry", envOrDefault("SIGNED_URL_EXPIRY", handlers.DefaultSignedURLExpiry.String()),
+			"Lifetime of upload URLs, e.g. 15m or 2h; max 168h (or set SIGNED_URL_EXPIRY env)")
+		uploadURLKey = flag.String("upload-url-key", os.Getenv("UPLOAD_URL_KEY"),
+			"Secret signing upload URLs; share it between API instances. Empty generates one per start (or set UPLOAD_URL_KEY env)")
+		jobTimeout = flag.Duration("job-timeout", envDuration("JOB_TIMEOUT", inmemory.DefaultJobTimeout),
+			"Maximum duration of a single parse job (or set JOB_TIMEOUT env)")
+		reapInterval = flag.Duration("reap-interval", envDuration("STALE_RUN_REAP_INTERVAL", worker.DefaultReapInterval),
+			"How often the worker fails parsing runs abandoned by a crashed worker, 0 to disable (or set STALE_RUN_REAP_INTERVAL env)")
+		staleRunAge = flag.Duration("stale-run-age", envDuration("STALE_RUN_AGE", worker.DefaultStaleRunAge),
+			"How long a parsing run may stay RUNNING before it is reaped (or set STALE_RUN_AGE env)")
+
+		readTimeout = flag.Duration("read-timeout", envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+			"Maximum duration for reading a request (or set HTTP_READ_TIMEOUT env)")
+		writeTimeout = flag.Duration("write-timeout", envDuration("HTTP_WRITE_TIMEOUT", 15*time.Second),
+			"Maximum duration before timing out writes of a response (or set HTTP_WRITE_TIMEOUT env)")
+		idleTimeout = flag.Duration("idle-timeout", envDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			"Maximum time to wait for the next request on a keep-alive connection (or set HTTP_IDLE_TIMEOUT env)")
+		timezone = flag.String("timezone", envOrDefault(apptime.Env, apptime.DefaultTimezone),
+			"IANA timezone used for calendar dates such as default date ranges (or set APP_TIMEZONE env)")
+
+		uploadTimeout = flag.Duration("upload-timeout", envDuration("HTTP_UPLOAD_TIMEOUT", 10*time.Minute),
+			"Read/write timeout for direct uploads and proxied downloads, replacing -read-timeout and -write-timeout; 0 disables it (or set HTTP_UPLOAD_TIMEOUT env)")
+
+		downloadMode = flag.String("download-mode", envOrDefault("DOCUMENT_DOWNLOAD_MODE", handlers.DownloadModeProxy),
+			"How document downloads are served: proxy or signed_url (or set DOCUMENT_DOWNLOAD_MODE env)")
+
+		allowedContentTypes = flag.String("allowed-content-types", envOrDefault("UPLOAD_ALLOWED_CONTENT_TYPES", strings.Join(handlers.DefaultAllowedContentTypes, ",")),
+			"Comma-separated MIME types accepted for uploads (or set UPLOAD_ALLOWED_CONTENT_TYPES env)")
+
+		queueSize = flag.Int("queue-size", envInt("JOB_QUEUE_SIZE", inmemory.DefaultBufferSize),
+			"Parse jobs that may wait in the queue; when full, enqueueing answers 429 (or set JOB_QUEUE_SIZE env)")
+
+		maxConcurrentUploads = flag.Int("max-concurrent-uploads", envInt("MAX_CONCURRENT_UPLOADS", 4),
+			"Direct uploads streamed to GCS at once; more get 503 with Retry-After, 0 disables the limit (or set MAX_CONCURRENT_UPLOADS env)")
+
+		transactionsDefaultDays = flag.Int("transactions-default-days", envInt("TRANSACTIONS_DEFAULT_DAYS", handlers.DefaultTransactionWindowDays),
+			"Days of history /api/transactions returns when no start_date is given (or set TRANSACTIONS_DEFAULT_DAYS env)")
+	)
+	logFormat := logger.FormatFlag(flag.CommandLine)
+	flag.Parse()
+
+	// Initialize structured logger
+	log, err := logger.NewWithFormat(*logFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+
+	serveAPI, runWorker, err := parseMode(*mode, sharedQueue)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid mode")
+	}
+
+	if err := apptime.Configure(*timezone); err != nil {
+		log.Fatal().Err(err).Msg("Invalid timezone")
+	}
+
+	if err := handlers.ValidateObjectNameTemplate(*objectTemplate); err != nil {
+		log.Fatal().Err(err).Msg("Invalid object name template")
+	}
+
+	uploadURLExpiry, err := time.ParseDuration(*signedURLExpiry)
+	if err != nil {
+		log.Fatal().Err(err).Str("value", *signedURLExpiry).Msg("Invalid signed URL expiry")
+	}
+	if err := handlers.ValidateSignedURLExpiry(uploadURLExpiry); err != nil {
+		log.Fatal().Err(err).Msg("Invalid signed URL expiry")
+	}
+
+	if err := handlers.ValidateDownloadMode(*downloadMode); err != nil {
+		log.Fatal().Err(err).Msg("Invalid download mode")
+	}
+
+	uploadContentTypes, err := handlers.ParseContentTypes(*allowedContentTypes)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid allowed content types")
+	}
+
+	documentIDMode, err := pipeline.DocumentIDModeFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid document ID mode")
+	}
+
+	if *queueSize <= 0 {
+		log.Fatal().Int("queue_size", *queueSize).Msg("Invalid job queue size: must be positive")
+	}
+
+	if *transactionsDefaultDays <= 0 {
+		log.Fatal().Int("days", *transactionsDefaultDays).Msg("Invalid transactions default window: must be positive")
+	}
+
+	// Uploads and downloads use this store; parse jobs create their storage service per
+	// run from the same configuration, so fail fast on a bad one
+	store, err := gcsuploader.NewObjectStoreFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid storage configuration")
+	}
+	if _, local := store.(*gcsuploader.LocalStorageService); local && *downloadMode == handlers.DownloadModeSignedURL {
+		log.Fatal().Msg("Invalid download mode: signed URLs need the GCS storage backend")
+	}
+
+	if runWorker {
+		if *reapInterval < 0 {
+			log.Fatal().Dur("reap_interval", *reapInterval).Msg("Error: -reap-interval must not be negative")
+		}
+		if *reapInterval > 0 && *staleRunAge <= *jobTimeout {
+			// Runs younger than the job timeout may still be in progress
+			log.Fatal().
+				Dur("stale_run_age", *staleRunAge).
+				Dur("job_timeout", *jobTimeout).
+				Msg("Error: -stale-run-age must be longer than -job-timeout")
+		}
+	}
+
+	if serveAPI && *bucket == "" {
+		log.Warn().Msg("No GCS bucket configured - document uploads will be disabled")
+	}
+
+	// Initialize repositories
+	ctx := context.Background()
+
+	docRepo, err := infraBQ.NewBigQueryDocumentRepository(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create document repository")
+	}
+	defer docRepo.Close()
+
+	accountRepo, err := infraBQ.NewBigQueryAccountRepository(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create account repository")
+	}
+	defer accountRepo.Close()
+
+	// Initialize job infrastructure
+	jobStore := inmemory.NewStore()
+	jobQueue := inmemory.NewQueueWithConfig(inmemory.QueueConfig{
+		BufferSize: *queueSize,
+		JobTimeout: *jobTimeout,
+	}, jobStore)
+
+	// Start worker in background to process jobs
+	workerCtx, cancelWorker := context.WithCancel(ctx)
+	defer cancelWorker()
+
+	if runWorker {
+		// Start job consumer in background
+		go func() {
+			log.Info().Msg("Starting job worker")
+			if err := jobQueue.Start(workerCtx, worker.ParseJobHandler(log)); err != nil {
+				log.Error().Err(err).Msg("Job worker stopped with error")
+			}
+		}()
+
+		if *reapInterval > 0 {
+			go worker.NewStaleRunReaper(*reapInterval, *staleRunAge).Run(logger.WithContext(workerCtx, log))
+			log.Info().
+				Dur("interval", *reapInterval).
+				Dur("stale_run_age", *staleRunAge).
+				Msg("Stale parsing run reaper started")
+		}
+	}
+
+	// Initialize handlers
+	documentsHandler := handlers.NewDocumentsHandler(docRepo, jobQueue, store, handlers.DocumentsConfig{
+		Bucket:              *bucket,
+		ObjectNameTemplate:  *objectTemplate,
+		UserID:              pipeline.DefaultUserID,
+		SignedURLExpiry:     uploadURLExpiry,
+		UploadURLKey:        []byte(*uploadURLKey),
+		DownloadMode:        *downloadMode,
+		AllowedContentTypes: uploadContentTypes,
+		DocumentIDMode:      documentIDMode,
+	}, log)
+	transactionsHandler := handlers.NewTransactionsHandler(docRepo, handlers.TransactionsConfig{
+		DefaultWindowDays: *transactionsDefaultDays,
+		UserID:            pipeline.DefaultUserID,
+	}, log)
+	accountsHandler := handlers.NewAccountsHandler(accountRepo, log)
+	categoriesHandler := handlers.NewCategoriesHandler(docRepo, handlers.CategoriesConfig{
+		UserID: pipeline.DefaultUserID,
+	}, log)
+	tagRulesHandler := handlers.NewTagRulesHandler(docRepo, handlers.TagRulesConfig{
+		UserID: pipeline.DefaultUserID,
+	}, log)
+	jobsHandler := handlers.NewJobsHandler(jobStore, jobQueue, log)
+
+	// Create router
+	mux := http.NewServeMux()
+
+	// Direct uploads and proxied downloads stream the whole PDF through the server,
+	// so they get their own timeout
+	transferDeadlines := middleware.Deadlines(log, *uploadTimeout, *uploadTimeout)
+
+	// Every direct upload holds a GCS stream and buffers; bound how many run at once
+	uploadLimit := middleware.ConcurrencyLimit(*maxConcurrentUploads, 5*time.Second)
+
+	// Documents endpoints
+	mux.HandleFunc("/api/documents", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			documentsHandler.ListDocuments(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		// Handle GET /api/documents/:id/diff
+		if rest, ok := strings.CutSuffix(r.URL.Path, "/diff"); ok {
+			documentID := strings.TrimPrefix(rest, "/api/documents/")
+			if documentID == "" || strings.Contains(documentID, "/") {
+				middleware.WriteError(w, http.StatusBadRequest, "Invalid document ID")
+				return
+			}
+			if middleware.AllowMethods(w, r, http.MethodGet) {
+				documentsHandler.DiffParsingRuns(w, r, documentID)
+			}
+			return
+		}
+
+		// Handle POST /api/documents/:id/refresh-upload-url
+		if rest, ok := strings.CutSuffix(r.URL.Path, "/refresh-upload-url"); ok {
+			documentID := strings.TrimPrefix(rest, "/api/documents/")
+			if documentID == "" || strings.Contains(documentID, "/") {
+				middleware.WriteError(w, http.StatusBadRequest, "Invalid document ID")
+				return
+			}
+			if middleware.AllowMethods(w, r, http.MethodPost) {
+				documentsHandler.RefreshUploadURL(w, r, documentID)
+			}
+			return
+		}
+
+		// Handle GET /api/documents/:id/download
+		if rest, ok := strings.CutSuffix(r.URL.Path, "/download"); ok {
+			documentID := strings.TrimPrefix(rest, "/api/documents/")
+			if documentID == "" || strings.Contains(documentID, "/") {
+				middleware.WriteError(w, http.StatusBadRequest, "Invalid document ID")
+				return
+			}
+			if !middleware.AllowMethods(w, r, http.MethodGet) {
+				return
+			}
+			transferDeadlines(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				documentsHandler.DownloadDocument(w, r, documentID)
+			})).ServeHTTP(w, r)
+			return
+		}
+
+		// Handle GET, PATCH and DELETE /api/documents/:id
+		if !middleware.AllowMethods(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete) {
+			return
+		}
+		documentID := strings.TrimPrefix(r.URL.Path, "/api/documents/")
+		documentID = strings.TrimSuffix(documentID, "/")
+		if documentID == "" || strings.Contains(documentID, "/") {
+			middleware.WriteError(w, http.StatusBadRequest, "Invalid document ID")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			documentsHandler.GetDocument(w, r, documentID)
+			return
+		case http.MethodPatch:
+			documentsHandler.UpdateDocument(w, r, documentID)
+			return
+		}
+		documentsHandler.DeleteDocument(w, r, documentID)
+	})
+
+	mux.HandleFunc("/api/documents/upload-url", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodPost) {
+			documentsHandler.CreateUploadURL(w, r)
+		}
+	})
+
+	mux.Handle("/api/documents/upload/", transferDeadlines(uploadLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.AllowMethods(w, r, http.MethodPost, http.MethodPut) {
+			return
+		}
+		// Extract document ID from path
+		documentID := strings.TrimPrefix(r.URL.Path, "/api/documents/upload/")
+		if documentID == "" {
+			middleware.WriteError(w, http.StatusBadRequest, "Document ID is required")
+			return
+		}
+		documentsHandler.UploadDocument(w, r, documentID)
+	}))))
+
+	mux.HandleFunc("/api/documents/parse", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodPost) {
+			documentsHandler.EnqueueParsing(w, r)
+		}
+	})
+
+	// Transactions endpoints
+	mux.HandleFunc("/api/transactions", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			transactionsHandler.ListTransactions(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/transactions/recategorize", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodPost) {
+			transactionsHandler.RecategorizeTransactions(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		// Handle POST /api/transactions/:id/review
+		transactionID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/transactions/"), "/review")
+		if !ok || transactionID == "" || strings.Contains(transactionID, "/") {
+			middleware.WriteError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if middleware.AllowMethods(w, r, http.MethodPost) {
+			transactionsHandler.ReviewTransaction(w, r, transactionID)
+		}
+	})
+
+	mux.HandleFunc("/api/merchants", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			transactionsHandler.ListMerchants(w, r)
+		}
+	})
+
+	// Accounts endpoints
+	mux.HandleFunc("/api/accounts", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			accountsHandler.ListAccounts(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/accounts/", func(w http.ResponseWriter, r *http.Request) {
+		// Handle GET /api/accounts/:id/balance and GET /api/accounts/:id/coverage
+		accountID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/accounts/"), "/")
+		if !ok || accountID == "" || (action != "balance" && action != "coverage") {
+			middleware.WriteError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if !middleware.AllowMethods(w, r, http.MethodGet) {
+			return
+		}
+		if action == "balance" {
+			accountsHandler.GetBalance(w, r, accountID)
+		} else {
+			accountsHandler.GetCoverage(w, r, accountID)
+		}
+	})
+
+	// Categories endpoints
+	mux.HandleFunc("/api/categories", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			categoriesHandler.ListCategories(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/categories/validate", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			categoriesHandler.ValidateCategories(w, r)
+		}
+	})
+
+	// Tag rules endpoints
+	mux.HandleFunc("/api/tag-rules", func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.AllowMethods(w, r, http.MethodGet, http.MethodPost) {
+			return
+		}
+		if r.Method == http.MethodPost {
+			tagRulesHandler.CreateTagRule(w, r)
+		} else {
+			tagRulesHandler.ListTagRules(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/tag-rules/", func(w http.ResponseWriter, r *http.Request) {
+		ruleID := strings.TrimPrefix(r.URL.Path, "/api/tag-rules/")
+		if ruleID == "" || strings.Contains(ruleID, "/") {
+			middleware.WriteError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if !middleware.AllowMethods(w, r, http.MethodPut, http.MethodDelete) {
+			return
+		}
+		if r.Method == http.MethodPut {
+			tagRulesHandler.UpdateTagRule(w, r, ruleID)
+		} else {
+			tagRulesHandler.DeleteTagRule(w, r, ruleID)
+		}
+	})
+
+	// Jobs endpoints
+	mux.HandleFunc("/api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			jobsHandler.ListJobs(w, r)
+		}
+	})
+
+	mux.HandleFunc("/api/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.AllowMethods(w, r, http.MethodGet) {
+			return
+		}
+		// Extract job ID from path
+		jobID := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+		if jobID == "" {
+			middleware.WriteError(w, http.StatusBadRequest, "Job ID is required")
+			return
+		}
+		jobsHandler.GetJob(w, r, jobID)
+	})
+
+	mux.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.AllowMethods(w, r, http.MethodGet) {
+			jobsHandler.GetStats(w, r)
+		}
+	})
+
+	// Health check endpoint
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.AllowMethods(w, r, http.MethodGet) {
+			return
+		}
+		middleware.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "healthy",
+			"time":     apptime.Now().Format(time.RFC3339),
+			"timezone": apptime.Location().String(),
+
+			// Cumulative bytes processed by BigQuery queries since startup
+			"bigquery_bytes_processed": infraBQ.TotalBytesProcessed(),
+		})
+	})
+
+	// Apply middleware
+	handler := middleware.Recovery(log)(
+		middleware.Logger(log)(
+			middleware.RequestID(
+				middleware.Timezone(apptime.Location().String())(
+					middleware.CORS(
+						middleware.Auth(
+							middleware.PrettyJSON(mux),
+						),
+					),
+				),
+			),
+		),
+	)
+
+	// Create HTTP server
+	server := &http.Server{
+		Addr:         ":" + *port,
+		Handler:      handler,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+
+	// Start server in a goroutine; without it, shutdown has no server to stop
+	var apiServer httpServer
+	if serveAPI {
+		apiServer = server
+		go func() {
+			log.Info().Str("port", *port).Str("mode", *mode).Msg("Starting API server")
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Failed to start server")
+			}
+		}()
+	} else {
+		log.Info().Str("mode", *mode).Msg("Worker started, waiting for jobs...")
+	}
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info().Msg("Shutting down server...")
+
+	// Graceful shutdown: stop accepting requests, drain jobs, then cancel workers
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	shutdown(shutdownCtx, apiServer, jobQueue, cancelWorker, worker.FailDocument, log)
+
+	log.Info().Msg("Server exited")
+}
+
+// envOrDefault returns the value of the environment variable key, or def if it is unset.
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envDuration returns the duration in the environment variable key, or def if it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// envInt returns the integer in the environment variable key, or def if it is unset or invalid.
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
@@ -0,0 +1,89 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/rs/zerolog"
+)
+
+// abandonTimeout bounds marking the documents of abandoned jobs as FAILED. It is
+// separate from the shutdown deadline, which has usually run out by then.
+const abandonTimeout = 10 * time.Second
+
+// httpServer is the subset of *http.Server used during shutdown.
+type httpServer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// drainableQueue is the subset of the job queue used during shutdown.
+type drainableQueue interface {
+	Depth() int
+	InFlight() int
+	Stop(ctx context.Context) error
+	Abandon(ctx context.Context) []*jobs.ParseDocumentJob
+	Close() error
+}
+
+// documentFailer marks a document as FAILED.
+type documentFailer func(ctx context.Context, documentID string) error
+
+// shutdown stops the API components in dependency order:
+//  1. the HTTP server, if any, stops accepting requests and finishes in-flight ones,
+//     so no new jobs can be enqueued after the queue starts draining;
+//  2. the job queue stops and waits (bounded by ctx) for in-flight and buffered jobs;
+//  3. the worker context is cancelled, aborting anything that did not finish in time;
+//  4. jobs still buffered are abandoned and their documents marked FAILED, so they are
+//     not left PENDING with no job to process them;
+//  5. the queue is closed.
+func shutdown(ctx context.Context, server httpServer, queue drainableQueue, cancelWorker context.CancelFunc, failDocument documentFailer, log zerolog.Logger) {
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("HTTP server forced to shutdown")
+		}
+	}
+
+	pendingBefore := queue.Depth()
+	inFlightBefore := queue.InFlight()
+
+	if err := queue.Stop(ctx); err != nil {
+		log.Error().Err(err).Msg("Error stopping job queue")
+	}
+
+	// Anything still buffered or running at this point was not drained.
+	abandoned := queue.Depth() + queue.InFlight()
+	drained := pendingBefore + inFlightBefore - abandoned
+	if drained < 0 {
+		drained = 0
+	}
+
+	cancelWorker()
+
+	failCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abandonTimeout)
+	defer cancel()
+	for _, job := range queue.Abandon(failCtx) {
+		err := failDocument(failCtx, job.DocumentID)
+		if err != nil && !errors.Is(err, bigquery.ErrInvalidStatusTransition) {
+			log.Error().Err(err).
+				Str("job_id", job.JobID).
+				Str("document_id", job.DocumentID).
+				Msg("Failed to mark abandoned document as FAILED")
+		}
+	}
+
+	if err := queue.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close job queue")
+	}
+
+	event := log.Info()
+	if abandoned > 0 {
+		event = log.Warn()
+	}
+	event.
+		Int("drained_jobs", drained).
+		Int("abandoned_jobs", abandoned).
+		Msg("Job queue drained")
+}
@@ -0,0 +1,253 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/dvloznov/finance-tracker/internal/jobs/inmemory"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// noFail is a documentFailer for tests that abandon no jobs.
+func noFail(ctx context.Context, documentID string) error { return nil }
+
+// documentRecorder is a documentFailer that records the documents it fails.
+type documentRecorder struct {
+	mu     sync.Mutex
+	failed []string
+}
+
+func (r *documentRecorder) fail(ctx context.Context, documentID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, documentID)
+	return nil
+}
+
+// recorder collects the order in which shutdown steps are invoked.
+type recorder struct {
+	calls []string
+}
+
+type fakeServer struct {
+	rec *recorder
+}
+
+func (s *fakeServer) Shutdown(ctx context.Context) error {
+	s.rec.calls = append(s.rec.calls, "server.Shutdown")
+	return nil
+}
+
+type fakeQueue struct {
+	rec *recorder
+
+	depth    int
+	inFlight int
+
+	// state after Stop returns
+	depthAfterStop    int
+	inFlightAfterStop int
+
+	workerCtx context.Context
+
+	// jobs returned by Abandon
+	abandoned []*jobs.ParseDocumentJob
+}
+
+func (q *fakeQueue) Depth() int    { return q.depth }
+func (q *fakeQueue) InFlight() int { return q.inFlight }
+
+func (q *fakeQueue) Stop(ctx context.Context) error {
+	q.rec.calls = append(q.rec.calls, "queue.Stop")
+	// In-flight jobs must still be able to run while the queue drains.
+	if q.workerCtx.Err() != nil {
+		q.rec.calls = append(q.rec.calls, "worker context cancelled before drain")
+	}
+	q.depth = q.depthAfterStop
+	q.inFlight = q.inFlightAfterStop
+	return nil
+}
+
+func (q *fakeQueue) Abandon(ctx context.Context) []*jobs.ParseDocumentJob {
+	q.rec.calls = append(q.rec.calls, "queue.Abandon")
+	return q.abandoned
+}
+
+func (q *fakeQueue) Close() error {
+	q.rec.calls = append(q.rec.calls, "queue.Close")
+	return nil
+}
+
+func TestShutdownOrdering(t *testing.T) {
+	rec := &recorder{}
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	server := &fakeServer{rec: rec}
+	queue := &fakeQueue{rec: rec, depth: 1, inFlight: 2, workerCtx: workerCtx}
+
+	cancel := func() {
+		rec.calls = append(rec.calls, "cancelWorker")
+		cancelWorker()
+	}
+
+	buf := &bytes.Buffer{}
+	shutdown(context.Background(), server, queue, cancel, noFail, logger.NewWithWriter(buf))
+
+	want := []string{"server.Shutdown", "queue.Stop", "cancelWorker", "queue.Abandon", "queue.Close"}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("shutdown order = %v, want %v", rec.calls, want)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"drained_jobs":3`) || !strings.Contains(output, `"abandoned_jobs":0`) {
+		t.Errorf("expected drained/abandoned counts in log, got: %s", output)
+	}
+}
+
+func TestShutdownReportsAbandonedJobs(t *testing.T) {
+	rec := &recorder{}
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	queue := &fakeQueue{
+		rec:               rec,
+		depth:             2,
+		inFlight:          1,
+		depthAfterStop:    1,
+		inFlightAfterStop: 1,
+		workerCtx:         workerCtx,
+		abandoned:         []*jobs.ParseDocumentJob{{JobID: "job-1", DocumentID: "doc-1"}},
+	}
+	docs := &documentRecorder{}
+
+	buf := &bytes.Buffer{}
+	shutdown(context.Background(), &fakeServer{rec: rec}, queue, cancelWorker, docs.fail, logger.NewWithWriter(buf))
+
+	output := buf.String()
+	if !strings.Contains(output, `"drained_jobs":1`) || !strings.Contains(output, `"abandoned_jobs":2`) {
+		t.Errorf("expected drained/abandoned counts in log, got: %s", output)
+	}
+	if want := []string{"doc-1"}; !reflect.DeepEqual(docs.failed, want) {
+		t.Errorf("failed documents = %v, want %v", docs.failed, want)
+	}
+}
+
+// publishJobs enqueues n parse jobs for documents doc-0 to doc-(n-1).
+func publishJobs(t *testing.T, queue *inmemory.Queue, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		job := &jobs.ParseDocumentJob{DocumentID: fmt.Sprintf("doc-%d", i)}
+		if err := queue.PublishParseDocument(context.Background(), job); err != nil {
+			t.Fatalf("PublishParseDocument: %v", err)
+		}
+	}
+}
+
+func TestShutdownDrainsBufferedJobs(t *testing.T) {
+	// More jobs than the queue has workers, so most are still buffered at shutdown
+	const jobCount = 12
+
+	queue := inmemory.NewQueue(jobCount, nil)
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	started := make(chan struct{})
+	var handled atomic.Int64
+	handler := func(ctx context.Context, job jobs.Job) error {
+		<-started
+		time.Sleep(10 * time.Millisecond)
+		handled.Add(1)
+		return nil
+	}
+	if err := queue.Start(workerCtx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	publishJobs(t, queue, jobCount)
+	close(started)
+
+	docs := &documentRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	buf := &bytes.Buffer{}
+	shutdown(ctx, nil, queue, cancelWorker, docs.fail, logger.NewWithWriter(buf))
+
+	if got := handled.Load(); got != jobCount {
+		t.Errorf("handled %d jobs, want %d", got, jobCount)
+	}
+	if queue.Depth() != 0 {
+		t.Errorf("Depth() after shutdown = %d, want 0", queue.Depth())
+	}
+	if len(docs.failed) != 0 {
+		t.Errorf("failed documents = %v, want none", docs.failed)
+	}
+	if output := buf.String(); !strings.Contains(output, `"abandoned_jobs":0`) {
+		t.Errorf("expected no abandoned jobs in log, got: %s", output)
+	}
+}
+
+func TestShutdownFailsDocumentsOfAbandonedJobs(t *testing.T) {
+	const jobCount = 8
+
+	queue := inmemory.NewQueue(jobCount, nil)
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	// Every worker blocks until it is cancelled, so buffered jobs cannot drain in time
+	handler := func(ctx context.Context, job jobs.Job) error {
+		<-ctx.Done()
+		return jobs.ErrPermanent
+	}
+	if err := queue.Start(workerCtx, handler); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	publishJobs(t, queue, jobCount)
+
+	// Wait for the workers to pick up their jobs
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.InFlight() < queue.Workers() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	buffered := queue.Depth()
+	if buffered == 0 {
+		t.Fatal("expected jobs left in the buffer")
+	}
+
+	docs := &documentRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	shutdown(ctx, nil, queue, cancelWorker, docs.fail, logger.NewWithWriter(&bytes.Buffer{}))
+
+	if len(docs.failed) != buffered {
+		t.Errorf("failed %d documents, want the %d buffered jobs: %v", len(docs.failed), buffered, docs.failed)
+	}
+	if queue.Depth() != 0 {
+		t.Errorf("Depth() after shutdown = %d, want 0", queue.Depth())
+	}
+}
+
+func TestShutdownWithoutServer(t *testing.T) {
+	rec := &recorder{}
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	queue := &fakeQueue{rec: rec, workerCtx: workerCtx}
+
+	// -mode=worker starts no HTTP server
+	shutdown(context.Background(), nil, queue, cancelWorker, noFail, logger.NewWithWriter(&bytes.Buffer{}))
+
+	want := []string{"queue.Stop", "queue.Abandon", "queue.Close"}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("shutdown calls = %v, want %v", rec.calls, want)
+	}
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/apptime"
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/logger"
+)
+
+// staleRunReason is recorded as the error_message of reaped parsing runs.
+const staleRunReason = "abandoned: parsing run did not finish, worker presumed crashed"
+
+// StaleRunReaper periodically fails parsing runs left RUNNING by a crashed worker,
+// together with their documents.
+type StaleRunReaper struct {
+	// interval between reaps
+	interval time.Duration
+	// maxAge is how long a run may stay RUNNING before it is considered abandoned
+	maxAge time.Duration
+
+	failRuns     func(ctx context.Context, startedBefore time.Time, reason string) ([]*infraBQ.ParsingRunRow, error)
+	failDocument func(ctx context.Context, documentID string) error
+}
+
+// NewStaleRunReaper returns a reaper backed by BigQuery.
+func NewStaleRunReaper(interval, maxAge time.Duration) *StaleRunReaper {
+	return &StaleRunReaper{
+		interval: interval,
+		maxAge:   maxAge,
+		failRuns: infraBQ.FailStaleParsingRuns,
+		failDocument: func(ctx context.Context, documentID string) error {
+			return infraBQ.TransitionDocumentStatus(ctx, documentID, bigquery.DocumentStatusFailed)
+		},
+	}
+}
+
+// Run reaps once immediately and then every interval until ctx is cancelled.
+func (r *StaleRunReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.reapOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapOnce fails the parsing runs that have been RUNNING for longer than maxAge and
+// marks their documents as FAILED. It returns the number of runs reaped.
+func (r *StaleRunReaper) reapOnce(ctx context.Context) int {
+	log := logger.FromContext(ctx)
+
+	runs, err := r.failRuns(ctx, apptime.Now().Add(-r.maxAge), staleRunReason)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reap stale parsing runs")
+		return 0
+	}
+
+	for _, run := range runs {
+		err := r.failDocument(ctx, run.DocumentID)
+		switch {
+		case errors.Is(err, bigquery.ErrInvalidStatusTransition):
+			// The document has moved on, e.g. a later run completed it
+			log.Debug().Err(err).Str("document_id", run.DocumentID).Msg("Document of stale run left unchanged")
+		case err != nil:
+			log.Error().Err(err).Str("document_id", run.DocumentID).Msg("Failed to mark document of stale run as failed")
+		}
+
+		log.Warn().
+			Str("parsing_run_id", run.ParsingRunID).
+			Str("document_id", run.DocumentID).
+			Time("started_ts", run.StartedTS).
+			Msg("Reaped stale parsing run")
+	}
+
+	if len(runs) > 0 {
+		log.Info().Int("runs", len(runs)).Msg("Stale parsing runs reaped")
+	}
+	return len(runs)
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+)
+
+func TestStaleRunReaperReapOnce(t *testing.T) {
+	var cutoff time.Time
+	var failedDocs []string
+	r := &StaleRunReaper{
+		maxAge: time.Hour,
+		failRuns: func(ctx context.Context, startedBefore time.Time, reason string) ([]*infraBQ.ParsingRunRow, error) {
+			cutoff = startedBefore
+			return []*infraBQ.ParsingRunRow{
+				{ParsingRunID: "run-1", DocumentID: "doc-1"},
+				{ParsingRunID: "run-2", DocumentID: "doc-2"},
+			}, nil
+		},
+		failDocument: func(ctx context.Context, documentID string) error {
+			failedDocs = append(failedDocs, documentID)
+			if documentID == "doc-2" {
+				return fmt.Errorf("%w: COMPLETED to FAILED", bigquery.ErrInvalidStatusTransition)
+			}
+			return nil
+		},
+	}
+
+	if n := r.reapOnce(context.Background()); n != 2 {
+		t.Errorf("reaped %d runs, want 2", n)
+	}
+	if age := time.Since(cutoff); age < time.Hour || age > time.Hour+time.Minute {
+		t.Errorf("cutoff is %v ago, want about 1h", age)
+	}
+	if len(failedDocs) != 2 {
+		t.Errorf("failed documents %v, want doc-1 and doc-2", failedDocs)
+	}
+}
+
+func TestStaleRunReaperRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reaps := make(chan struct{}, 10)
+	r := &StaleRunReaper{
+		interval: 5 * time.Millisecond,
+		maxAge:   time.Hour,
+		failRuns: func(ctx context.Context, startedBefore time.Time, reason string) ([]*infraBQ.ParsingRunRow, error) {
+			select {
+			case reaps <- struct{}{}:
+			default:
+			}
+			return nil, nil
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	// Reaps immediately and then on every tick
+	for i := 0; i < 2; i++ {
+		select {
+		case <-reaps:
+		case <-time.After(time.Second):
+			t.Fatalf("reap %d did not happen", i+1)
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper did not stop after cancel")
+	}
+}
@@ -0,0 +1,82 @@
+// Package worker processes parse jobs. It is run by internal/serve, next to the HTTP
+// server or on its own.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dvloznov/finance-tracker/internal/bigquery"
+	infraBQ "github.com/dvloznov/finance-tracker/internal/infra/bigquery"
+	"github.com/dvloznov/finance-tracker/internal/jobs"
+	"github.com/dvloznov/finance-tracker/internal/pipeline"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultReapInterval is how often abandoned parsing runs are looked for.
+	DefaultReapInterval = 10 * time.Minute
+	// DefaultStaleRunAge is how long a parsing run may stay RUNNING before it is reaped.
+	DefaultStaleRunAge = time.Hour
+
+	// failDocumentTimeout bounds marking a document FAILED after its job failed.
+	failDocumentTimeout = 10 * time.Second
+)
+
+// FailDocument marks a document as FAILED.
+func FailDocument(ctx context.Context, documentID string) error {
+	return infraBQ.TransitionDocumentStatus(ctx, documentID, bigquery.DocumentStatusFailed)
+}
+
+// ParseJobHandler returns the handler that runs the ingestion pipeline for parse jobs.
+// A failed job marks its document as FAILED; failures that cannot succeed on retry are
+// returned as jobs.ErrPermanent.
+func ParseJobHandler(log zerolog.Logger) jobs.JobHandler {
+	return func(ctx context.Context, job jobs.Job) error {
+		parseJob, ok := job.(*jobs.ParseDocumentJob)
+		if !ok {
+			return fmt.Errorf("unexpected job type: %T", job)
+		}
+
+		log.Info().
+			Str("job_id", parseJob.JobID).
+			Str("document_id", parseJob.DocumentID).
+			Str("gcs_uri", parseJob.GCSURI).
+			Msg("Processing parse job")
+
+		// Execute the pipeline
+		err := pipeline.IngestStatementFromGCSWithOptions(ctx, parseJob.GCSURI, pipeline.IngestOptions{
+			DocumentID:    parseJob.DocumentID,
+			InstitutionID: parseJob.InstitutionID,
+			PDFPassword:   parseJob.PDFPassword,
+		})
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("job_id", parseJob.JobID).
+				Str("document_id", parseJob.DocumentID).
+				Str("error_kind", pipeline.ErrorKind(err)).
+				Msg("Pipeline execution failed")
+
+			// Update document status to FAILED, even if the job was cancelled by shutdown
+			failCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), failDocumentTimeout)
+			if updateErr := FailDocument(failCtx, parseJob.DocumentID); updateErr != nil {
+				log.Error().Err(updateErr).Msg("Failed to update document status")
+			}
+			cancel()
+
+			if !pipeline.IsRetryable(err) {
+				return fmt.Errorf("%w: %w", jobs.ErrPermanent, err)
+			}
+			return err
+		}
+
+		log.Info().
+			Str("job_id", parseJob.JobID).
+			Str("document_id", parseJob.DocumentID).
+			Msg("Pipeline execution completed successfully")
+
+		return nil
+	}
+}
@@ -0,0 +1,4 @@
+-- Track which transactions a user has reviewed
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.transactions`
+  ADD COLUMN IF NOT EXISTS is_reviewed BOOL,
+  ADD COLUMN IF NOT EXISTS reviewed_ts TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Keep the foreign-currency amount of converted card transactions
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.transactions`
+  ADD COLUMN IF NOT EXISTS original_amount NUMERIC,
+  ADD COLUMN IF NOT EXISTS original_currency STRING;
@@ -0,0 +1,7 @@
+-- Allow the raw output of old parsing runs to be pruned while keeping the row as a reference
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.model_outputs`
+  ALTER COLUMN raw_json DROP NOT NULL;
+
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.model_outputs`
+  ADD COLUMN IF NOT EXISTS archive_uri STRING,
+  ADD COLUMN IF NOT EXISTS pruned_ts TIMESTAMP;
@@ -0,0 +1,11 @@
+-- Keep the last four digits of account numbers so statements that only show those can be matched
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.accounts`
+  ADD COLUMN IF NOT EXISTS account_number_last4 STRING;
+
+UPDATE `{{PROJECT_ID}}.{{DATASET_ID}}.accounts`
+SET account_number_last4 = COALESCE(
+  REGEXP_EXTRACT(REGEXP_REPLACE(UPPER(account_number), r'[\s-]', ''), r'(\d{4})$'),
+  IF(STARTS_WITH(REGEXP_REPLACE(UPPER(iban), r'\s', ''), 'GB'), SUBSTR(REGEXP_REPLACE(UPPER(iban), r'\s', ''), -4), NULL),
+  ''
+)
+WHERE account_number_last4 IS NULL;
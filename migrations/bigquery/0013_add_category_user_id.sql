@@ -0,0 +1,5 @@
+-- Scope custom categories to a user. Rows with a NULL user_id are the global
+-- defaults; a user's row replaces the global row with the same category and
+-- subcategory name for that user.
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.categories`
+  ADD COLUMN IF NOT EXISTS user_id STRING;
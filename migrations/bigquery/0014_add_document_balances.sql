@@ -0,0 +1,5 @@
+-- Opening and closing balances printed on a statement, for statement-level
+-- reconciliation
+ALTER TABLE `{{PROJECT_ID}}.{{DATASET_ID}}.documents`
+  ADD COLUMN IF NOT EXISTS opening_balance NUMERIC,
+  ADD COLUMN IF NOT EXISTS closing_balance NUMERIC;
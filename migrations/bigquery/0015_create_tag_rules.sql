@@ -0,0 +1,14 @@
+-- Rules that tag transactions automatically. A rule sets a description regex, a
+-- merchant, or both; matching transactions get its tags. Rules apply by
+-- ascending priority, and a matching rule with stop_processing ends the list.
+CREATE TABLE IF NOT EXISTS `{{PROJECT_ID}}.{{DATASET_ID}}.tag_rules` (
+  rule_id             STRING NOT NULL,
+  user_id             STRING NOT NULL,
+  description_pattern STRING,
+  merchant            STRING,
+  tags                ARRAY<STRING>,
+  priority            INT64 NOT NULL,
+  stop_processing     BOOL NOT NULL,
+  created_ts          TIMESTAMP NOT NULL,
+  updated_ts          TIMESTAMP
+);
@@ -56,10 +56,18 @@ export default function TransactionsPage() {
         cell: ({ getValue, row }) => {
           const amount = parseFloat(getValue<string>());
           const currency = row.original.currency;
+          const { original_amount, original_currency } = row.original;
           return (
-            <span className={amount < 0 ? 'text-red-600 font-semibold' : 'text-green-600 font-semibold'}>
-              {amount.toFixed(2)} {currency}
-            </span>
+            <div className="flex flex-col">
+              <span className={amount < 0 ? 'text-red-600 font-semibold' : 'text-green-600 font-semibold'}>
+                {amount.toFixed(2)} {currency}
+              </span>
+              {original_amount && original_currency && (
+                <span className="text-xs text-slate-500">
+                  {parseFloat(original_amount).toFixed(2)} {original_currency}
+                </span>
+              )}
+            </div>
           );
         },
       },
@@ -29,6 +29,8 @@ export interface Transaction {
   raw_description: string;
   category_name?: string;
   balance_after?: string;
+  original_amount?: string;
+  original_currency?: string;
 }
 
 export interface Category {
@@ -79,7 +81,7 @@ class ApiClient {
     return response.documents || [];
   }
 
-  async createUploadUrl(filename: string): Promise<{ upload_url: string; document_id: string; gcs_uri: string; object_name: string }> {
+  async createUploadUrl(filename: string): Promise<{ upload_url: string; document_id: string; gcs_uri: string; object_name: string; expires_at: string; expires_in_seconds: number }> {
     return this.fetch('/api/documents/upload-url', {
       method: 'POST',
       body: JSON.stringify({ filename }),
@@ -93,6 +95,10 @@ class ApiClient {
     });
   }
 
+  documentDownloadUrl(documentId: string): string {
+    return `${this.baseUrl}/api/documents/${encodeURIComponent(documentId)}/download`;
+  }
+
   async deleteDocument(documentId: string): Promise<{ document_id: string; status: string }> {
     return this.fetch(`/api/documents/${documentId}`, {
       method: 'DELETE',
@@ -106,6 +112,18 @@ class ApiClient {
     return this.fetch<Transaction[]>(endpoint);
   }
 
+  async recategorizeTransactions(req: {
+    transaction_ids?: string[];
+    description_contains?: string;
+    category: string;
+    subcategory?: string;
+  }): Promise<{ updated: number; category_id: string }> {
+    return this.fetch('/api/transactions/recategorize', {
+      method: 'POST',
+      body: JSON.stringify(req),
+    });
+  }
+
   // Categories
   async listCategories(): Promise<Category[]> {
     return this.fetch<Category[]>('/api/categories');